@@ -0,0 +1,192 @@
+// Package allocation decides which batches a sale is taken from when the caller does not
+// name them. A strategy orders the available lots of a product; Allocate then takes the
+// requested quantity lot by lot and records why each lot was picked.
+package allocation
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Strategies
+const (
+	FIFO        = "fifo"         // oldest intake first
+	LIFO        = "lifo"         // newest intake first
+	FEFO        = "fefo"         // earliest expiry first, undated stock last
+	Cheapest    = "cheapest"     // lowest landed unit cost first
+	HighestRent = "highest_rent" // most unbilled rent per unit first, to cut storage cost
+	CloseOut    = "close_out"    // lots that empty their batch first, then oldest first
+)
+
+// Default is used when neither the request nor the product names a strategy
+const Default = FIFO
+
+// ErrUnknownStrategy is returned for a strategy name that is not registered
+var ErrUnknownStrategy = errors.New("unknown allocation strategy")
+
+// ErrInsufficientStock is returned when the lots cannot cover the requested quantity
+var ErrInsufficientStock = errors.New("not enough stock")
+
+// Lot is stock of one product in one batch that can be picked
+type Lot struct {
+	EntryID     uint
+	BatchID     uint
+	Available   int
+	StoredAt    time.Time
+	ExpiresAt   *time.Time
+	UnitCost    decimal.Decimal // buying price plus onboarding cost per unit, in the reporting currency
+	RentPerUnit decimal.Decimal // rent accrued and not yet invoiced, per unit
+	BatchStock  int             // units of all products still in the batch
+}
+
+// Pick is a quantity taken from a lot and the reason the strategy chose it
+type Pick struct {
+	Lot      Lot
+	Quantity int
+	Reason   string
+}
+
+type strategy struct {
+	less   func(a, b Lot) bool
+	reason func(l Lot) string
+}
+
+var strategies = map[string]strategy{
+	FIFO: {
+		less:   func(a, b Lot) bool { return a.StoredAt.Before(b.StoredAt) },
+		reason: func(l Lot) string { return "oldest stock, stored " + day(l.StoredAt) },
+	},
+	LIFO: {
+		less:   func(a, b Lot) bool { return a.StoredAt.After(b.StoredAt) },
+		reason: func(l Lot) string { return "newest stock, stored " + day(l.StoredAt) },
+	},
+	FEFO: {
+		less: func(a, b Lot) bool {
+			if a.ExpiresAt == nil || b.ExpiresAt == nil {
+				return a.ExpiresAt != nil && b.ExpiresAt == nil
+			}
+			return a.ExpiresAt.Before(*b.ExpiresAt)
+		},
+		reason: func(l Lot) string {
+			if l.ExpiresAt == nil {
+				return "no expiry date, used after dated stock"
+			}
+			return "earliest expiry, expires " + day(*l.ExpiresAt)
+		},
+	},
+	Cheapest: {
+		less:   func(a, b Lot) bool { return a.UnitCost.LessThan(b.UnitCost) },
+		reason: func(l Lot) string { return fmt.Sprintf("lowest unit cost %s", l.UnitCost.StringFixed(2)) },
+	},
+	HighestRent: {
+		less: func(a, b Lot) bool { return a.RentPerUnit.GreaterThan(b.RentPerUnit) },
+		reason: func(l Lot) string {
+			return fmt.Sprintf("highest unbilled rent %s per unit", l.RentPerUnit.StringFixed(2))
+		},
+	},
+	// CloseOut is ordered by Allocate itself since it depends on the requested quantity
+	CloseOut: {
+		less:   func(a, b Lot) bool { return a.StoredAt.Before(b.StoredAt) },
+		reason: func(l Lot) string { return "oldest stock, stored " + day(l.StoredAt) },
+	},
+}
+
+// Valid reports whether name is a registered strategy; "" is valid and means Default
+func Valid(name string) bool {
+	if name == "" {
+		return true
+	}
+	_, ok := strategies[name]
+	return ok
+}
+
+// Names lists the registered strategies
+func Names() []string {
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Allocate takes qty units from lots in the order of the named strategy ("" = Default).
+// Ties are broken oldest intake first, so every strategy is deterministic.
+func Allocate(name string, lots []Lot, qty int) ([]Pick, error) {
+	if name == "" {
+		name = Default
+	}
+	s, ok := strategies[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownStrategy, name)
+	}
+
+	ordered := make([]Lot, 0, len(lots))
+	for _, l := range lots {
+		if l.Available > 0 {
+			ordered = append(ordered, l)
+		}
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := ordered[i], ordered[j]
+		if s.less(a, b) {
+			return true
+		}
+		if s.less(b, a) {
+			return false
+		}
+		if !a.StoredAt.Equal(b.StoredAt) {
+			return a.StoredAt.Before(b.StoredAt)
+		}
+		if a.BatchID != b.BatchID {
+			return a.BatchID < b.BatchID
+		}
+		return a.EntryID < b.EntryID
+	})
+
+	// Close-out: lots that are all that is left of their batch and fit in the request
+	// go first, so the sale empties as many old batches as it can
+	closes := map[uint]bool{}
+	if name == CloseOut {
+		remaining := qty
+		for _, l := range ordered {
+			if l.Available == l.BatchStock && l.Available <= remaining {
+				closes[l.EntryID] = true
+				remaining -= l.Available
+			}
+		}
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return closes[ordered[i].EntryID] && !closes[ordered[j].EntryID]
+		})
+	}
+
+	picks := make([]Pick, 0)
+	remaining := qty
+	for _, l := range ordered {
+		if remaining <= 0 {
+			break
+		}
+		take := l.Available
+		if take > remaining {
+			take = remaining
+		}
+		reason := s.reason(l)
+		if closes[l.EntryID] {
+			reason = fmt.Sprintf("closes out batch %d (last %d units)", l.BatchID, l.Available)
+		}
+		picks = append(picks, Pick{Lot: l, Quantity: take, Reason: reason})
+		remaining -= take
+	}
+	if remaining > 0 {
+		return nil, fmt.Errorf("%w: needed %d, available %d", ErrInsufficientStock, qty, qty-remaining)
+	}
+	return picks, nil
+}
+
+func day(t time.Time) string {
+	return t.Format("2006-01-02")
+}
@@ -0,0 +1,118 @@
+// Package capacity measures storage space. A warehouse counts its capacity, and charges
+// rent, in one unit: floor area (square feet or square metres), volume (cubic metres) or
+// pallet positions. A product declares how much of each dimension one unit of it takes,
+// and this package turns that footprint into the warehouse's unit.
+package capacity
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Capacity units
+const (
+	Sqft       = "sqft"
+	Sqm        = "sqm"
+	CubicMetre = "m3"
+	Pallet     = "pallet"
+)
+
+// DefaultUnit is the unit of warehouses and product areas that name none
+const DefaultUnit = Sqft
+
+// SqftPerSqm converts square metres into square feet
+const SqftPerSqm = 10.7639104
+
+// ErrUnknownUnit is returned for a unit that is not a capacity unit
+var ErrUnknownUnit = errors.New("unknown capacity unit")
+
+// ErrNoFootprint is returned when a product does not declare the dimension a warehouse
+// measures its capacity in
+var ErrNoFootprint = errors.New("product has no footprint in the warehouse's capacity unit")
+
+// aliases maps the spellings accepted on input to a unit
+var aliases = map[string]string{
+	"sqft": Sqft, "sq ft": Sqft, "ft2": Sqft, "ft²": Sqft,
+	"sqm": Sqm, "sq m": Sqm, "m2": Sqm, "m²": Sqm,
+	"m3": CubicMetre, "m³": CubicMetre, "cbm": CubicMetre,
+	"pallet": Pallet, "pallets": Pallet, "pallet position": Pallet, "pallet positions": Pallet,
+}
+
+// Normalize maps a unit to its canonical name; empty is DefaultUnit
+func Normalize(unit string) (string, error) {
+	u := strings.ToLower(strings.TrimSpace(unit))
+	if u == "" {
+		return DefaultUnit, nil
+	}
+	if canonical, ok := aliases[u]; ok {
+		return canonical, nil
+	}
+	return "", fmt.Errorf("%w %q", ErrUnknownUnit, unit)
+}
+
+// IsArea reports whether unit measures floor area
+func IsArea(unit string) bool {
+	return unit == Sqft || unit == Sqm
+}
+
+// ConvertArea converts a floor area between square feet and square metres
+func ConvertArea(value float64, from, to string) (float64, error) {
+	if !IsArea(from) || !IsArea(to) {
+		return 0, fmt.Errorf("%w: cannot convert %s to %s", ErrUnknownUnit, from, to)
+	}
+	switch {
+	case from == to:
+		return value, nil
+	case from == Sqm:
+		return value * SqftPerSqm, nil
+	default:
+		return value / SqftPerSqm, nil
+	}
+}
+
+// Footprint is the space one unit of a product takes in each dimension. Zero means the
+// product does not declare that dimension.
+type Footprint struct {
+	Area           float64 // floor area, in AreaUnit
+	AreaUnit       string  // sqft or sqm (default sqft)
+	Volume         float64 // cubic metres
+	UnitsPerPallet int     // units that fill one pallet position
+}
+
+// PerUnit is the capacity one unit takes, in unit. Pallet positions are fractional, so
+// space taken and given back always adds up whatever the quantities.
+func (f Footprint) PerUnit(unit string) (float64, error) {
+	switch unit {
+	case Sqft, Sqm:
+		from, err := Normalize(f.AreaUnit)
+		if err != nil {
+			return 0, err
+		}
+		if !IsArea(from) {
+			return 0, fmt.Errorf("%w: area unit %q", ErrUnknownUnit, f.AreaUnit)
+		}
+		return ConvertArea(f.Area, from, unit)
+	case CubicMetre:
+		if f.Volume <= 0 {
+			return 0, fmt.Errorf("%w (%s)", ErrNoFootprint, unit)
+		}
+		return f.Volume, nil
+	case Pallet:
+		if f.UnitsPerPallet <= 0 {
+			return 0, fmt.Errorf("%w (%s)", ErrNoFootprint, unit)
+		}
+		return 1 / float64(f.UnitsPerPallet), nil
+	default:
+		return 0, fmt.Errorf("%w %q", ErrUnknownUnit, unit)
+	}
+}
+
+// For is the capacity qty units take, in unit
+func (f Footprint) For(qty int, unit string) (float64, error) {
+	perUnit, err := f.PerUnit(unit)
+	if err != nil {
+		return 0, err
+	}
+	return perUnit * float64(qty), nil
+}
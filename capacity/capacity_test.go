@@ -0,0 +1,75 @@
+package capacity
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", Sqft},
+		{" SqFt ", Sqft},
+		{"m²", Sqm},
+		{"CBM", CubicMetre},
+		{"pallet positions", Pallet},
+	}
+	for _, tt := range tests {
+		got, err := Normalize(tt.in)
+		if err != nil {
+			t.Fatalf("Normalize(%q): %v", tt.in, err)
+		}
+		if got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+	if _, err := Normalize("acre"); !errors.Is(err, ErrUnknownUnit) {
+		t.Errorf("Normalize(acre): err = %v, want ErrUnknownUnit", err)
+	}
+}
+
+func TestFootprintFor(t *testing.T) {
+	crate := Footprint{Area: 1, AreaUnit: Sqm, Volume: 0.25, UnitsPerPallet: 8}
+
+	tests := []struct {
+		name string
+		unit string
+		qty  int
+		want float64
+	}{
+		{"square metres as declared", Sqm, 4, 4},
+		{"square metres into square feet", Sqft, 2, 2 * SqftPerSqm},
+		{"volume", CubicMetre, 6, 1.5},
+		{"pallet positions are fractional", Pallet, 3, 0.375},
+		{"freed space is negative", Pallet, -8, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := crate.For(tt.qty, tt.unit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !near(got, tt.want) {
+				t.Errorf("For(%d, %s) = %v, want %v", tt.qty, tt.unit, got, tt.want)
+			}
+		})
+	}
+
+	// Square feet is assumed when a product names no area unit
+	if got, _ := (Footprint{Area: SqftPerSqm}).PerUnit(Sqm); !near(got, 1) {
+		t.Errorf("default area unit: per unit = %v sqm, want 1", got)
+	}
+
+	flat := Footprint{Area: 3}
+	for _, unit := range []string{CubicMetre, Pallet} {
+		if _, err := flat.PerUnit(unit); !errors.Is(err, ErrNoFootprint) {
+			t.Errorf("PerUnit(%s) without that dimension: err = %v, want ErrNoFootprint", unit, err)
+		}
+	}
+}
@@ -20,6 +20,9 @@ type Config struct {
 	DbSSLmode          string `mapstructure:"DB_SSLMODE"`
 	DbTimeZone         string `mapstructure:"DB_TIMEZONE"`
 	BaseUrl            string `mapstructure:"BASE_URL"`
+	IdempotencyKeyTTL  string `mapstructure:"IDEMPOTENCY_KEY_TTL"` // e.g. "24h"; how long Idempotency-Key responses are replayed
+	MoneyRounding      string `mapstructure:"MONEY_ROUNDING"`      // "line" or "document"; where bill amounts are rounded
+	BaseCurrency       string `mapstructure:"BASE_CURRENCY"`       // e.g. "INR"; default reporting currency of warehouses
 }
 
 var (
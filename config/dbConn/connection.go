@@ -3,7 +3,9 @@ package dbconn
 import (
 	"fmt"
 	"log"
+	"warehouse/capacity"
 	"warehouse/config"
+	"warehouse/fx"
 	"warehouse/models"
 
 	"gorm.io/driver/postgres"
@@ -81,8 +83,10 @@ func ConnectDB() *gorm.DB {
 	err = db.AutoMigrate(
 		&models.Warehouse{},
 		&models.RentRate{},
+		&models.RentRateVersion{},
 		&models.User{},
 		&models.Supplier{},
+		&models.Customer{},
 		&models.Product{},
 		&models.Profit{},
 		&models.Billing{},
@@ -91,6 +95,30 @@ func ConnectDB() *gorm.DB {
 		&models.BatchProductEntry{},
 		&models.OnBoardExpense{},
 		&models.OffBoardExpense{},
+		&models.CreditNote{},
+		&models.CreditNoteItem{},
+		&models.StockMovement{},
+		&models.StockAdjustment{},
+		&models.StockAdjustmentLine{},
+		&models.StockWriteOff{},
+		&models.TransferOrder{},
+		&models.TransferOrderItem{},
+		&models.RentInvoice{},
+		&models.RentInvoiceLine{},
+		&models.Payment{},
+		&models.PaymentAllocation{},
+		&models.InvoiceTemplate{},
+		&models.InvoiceSeries{},
+		&models.InvoiceCounter{},
+		&models.TaxRate{},
+		&models.PurchaseOrder{},
+		&models.PurchaseOrderItem{},
+		&models.GoodsReceipt{},
+		&models.GoodsReceiptItem{},
+		&models.IdempotencyKey{},
+		&models.ExchangeRate{},
+		&models.Location{},
+		&models.LocationStock{},
 	)
 	if err != nil {
 		log.Fatalf("❌ Auto migration failed: %v", err)
@@ -98,6 +126,265 @@ func ConnectDB() *gorm.DB {
 
 	log.Println("✅ Auto migration completed successfully with prefix 'mys_'")
 
+	// Step 6️⃣: Backfill data for new columns
+	backfillBillingWarehouse(db)
+	backfillBillingCustomer(db)
+	backfillBillingDueDates(db)
+	backfillBillingInvoiceTotals(db)
+	backfillBillingInvoiceNumbers(db)
+	backfillOpeningStockMovements(db)
+	backfillRentRateVersions(db)
+	backfillCurrencies(db)
+	backfillCapacityUnits(db)
+
+	// Step 7️⃣: Database-level guards
+	protectStockLedger(db)
+
 	DB = db
 	return DB
 }
+
+// backfillBillingWarehouse assigns a warehouse to bills created before Billing.WarehouseID
+// existed, taking it from the batches of the bill's items.
+func backfillBillingWarehouse(db *gorm.DB) {
+	ns := db.NamingStrategy
+
+	res := db.Exec(fmt.Sprintf(`
+		UPDATE %s AS bl
+		SET warehouse_id = src.warehouse_id
+		FROM (
+			SELECT bi.billing_id, MIN(b.warehouse_id) AS warehouse_id
+			FROM %s AS bi
+			JOIN %s AS b ON b.id = bi.batch_id
+			GROUP BY bi.billing_id
+		) AS src
+		WHERE src.billing_id = bl.id
+		AND (bl.warehouse_id IS NULL OR bl.warehouse_id = 0)
+	`, ns.TableName("Billing"), ns.TableName("BillingItem"), ns.TableName("Batch")))
+	if res.Error != nil {
+		log.Fatalf("❌ Failed to backfill billing warehouses: %v", res.Error)
+	}
+	if res.RowsAffected > 0 {
+		log.Printf("🔧 Backfilled warehouse for %d existing bills", res.RowsAffected)
+	}
+
+	var mixed int64
+	db.Raw(fmt.Sprintf(`
+		SELECT COUNT(*) FROM (
+			SELECT bi.billing_id
+			FROM %s AS bi
+			JOIN %s AS b ON b.id = bi.batch_id
+			GROUP BY bi.billing_id
+			HAVING COUNT(DISTINCT b.warehouse_id) > 1
+		) AS m
+	`, ns.TableName("BillingItem"), ns.TableName("Batch"))).Scan(&mixed)
+	if mixed > 0 {
+		log.Printf("⚠️ %d legacy bills span multiple warehouses; they were assigned to the lowest warehouse ID", mixed)
+	}
+}
+
+// legacyCustomerName is the placeholder customer bills created before customers existed are assigned to
+const legacyCustomerName = "Unassigned (legacy bills)"
+
+// backfillBillingCustomer attaches bills created before Billing.CustomerID existed to a
+// placeholder customer, so every bill has one and can be reassigned later.
+func backfillBillingCustomer(db *gorm.DB) {
+	ns := db.NamingStrategy
+
+	var missing int64
+	db.Table(ns.TableName("Billing")).
+		Where("customer_id IS NULL OR customer_id = 0").
+		Count(&missing)
+	if missing == 0 {
+		return
+	}
+
+	var customer models.Customer
+	if err := db.Table(ns.TableName("Customer")).
+		Where(models.Customer{Name: legacyCustomerName}).
+		FirstOrCreate(&customer).Error; err != nil {
+		log.Fatalf("❌ Failed to create placeholder customer: %v", err)
+	}
+
+	res := db.Table(ns.TableName("Billing")).
+		Where("customer_id IS NULL OR customer_id = 0").
+		Update("customer_id", customer.ID)
+	if res.Error != nil {
+		log.Fatalf("❌ Failed to backfill billing customers: %v", res.Error)
+	}
+	log.Printf("🔧 Assigned %d existing bills to customer %q (ID=%d)", res.RowsAffected, legacyCustomerName, customer.ID)
+}
+
+// backfillBillingDueDates gives bills created before due dates existed one from their
+// customer's credit days, so they show up correctly in receivables ageing.
+func backfillBillingDueDates(db *gorm.DB) {
+	ns := db.NamingStrategy
+
+	res := db.Exec(fmt.Sprintf(`
+		UPDATE %s AS bl
+		SET due_date = bl.created_at + make_interval(days => COALESCE(NULLIF(cu.credit_days, 0), ?))
+		FROM %s AS cu
+		WHERE cu.id = bl.customer_id AND bl.due_date IS NULL
+	`, ns.TableName("Billing"), ns.TableName("Customer")), models.DefaultCreditDays)
+	if res.Error != nil {
+		log.Fatalf("❌ Failed to backfill billing due dates: %v", res.Error)
+	}
+	if res.RowsAffected > 0 {
+		log.Printf("🔧 Set due dates for %d existing bills", res.RowsAffected)
+	}
+}
+
+// backfillBillingInvoiceTotals sets the invoice value of bills raised before GST was
+// computed: they carried no tax, so it equals their selling total.
+func backfillBillingInvoiceTotals(db *gorm.DB) {
+	ns := db.NamingStrategy
+
+	res := db.Exec(fmt.Sprintf(`
+		UPDATE %s
+		SET invoice_total = total_selling + total_tax
+		WHERE invoice_total = 0 AND total_selling <> 0
+	`, ns.TableName("Billing")))
+	if res.Error != nil {
+		log.Fatalf("❌ Failed to backfill billing invoice totals: %v", res.Error)
+	}
+	if res.RowsAffected > 0 {
+		log.Printf("🔧 Set invoice totals for %d existing bills", res.RowsAffected)
+	}
+}
+
+// backfillBillingInvoiceNumbers gives bills raised before invoice series existed the
+// number their invoices were printed with: the bill ID padded to six digits.
+func backfillBillingInvoiceNumbers(db *gorm.DB) {
+	ns := db.NamingStrategy
+
+	res := db.Exec(fmt.Sprintf(`
+		UPDATE %s
+		SET invoice_number = CASE WHEN length(id::text) >= 6 THEN id::text ELSE lpad(id::text, 6, '0') END
+		WHERE invoice_number IS NULL OR invoice_number = ''
+	`, ns.TableName("Billing")))
+	if res.Error != nil {
+		log.Fatalf("❌ Failed to backfill billing invoice numbers: %v", res.Error)
+	}
+	if res.RowsAffected > 0 {
+		log.Printf("🔧 Numbered %d existing bills", res.RowsAffected)
+	}
+}
+
+// backfillOpeningStockMovements gives every batch entry that predates the stock ledger an
+// opening-balance movement equal to its current stock, so the ledger replays to StockQuantity.
+func backfillOpeningStockMovements(db *gorm.DB) {
+	ns := db.NamingStrategy
+
+	res := db.Exec(fmt.Sprintf(`
+		INSERT INTO %s (warehouse_id, product_id, batch_id, entry_id, quantity, balance_after, reason, source_type, source_id, user_id, notes, created_at)
+		SELECT b.warehouse_id, be.product_id, be.batch_id, be.id, be.stock_quantity, be.stock_quantity, ?, ?, be.batch_id, 0, 'backfilled from existing stock', NOW()
+		FROM %s AS be
+		JOIN %s AS b ON b.id = be.batch_id
+		WHERE NOT EXISTS (SELECT 1 FROM %s AS sm WHERE sm.entry_id = be.id)
+	`, ns.TableName("StockMovement"), ns.TableName("BatchProductEntry"), ns.TableName("Batch"), ns.TableName("StockMovement")),
+		models.MovementOpeningBalance, models.SourceBatch)
+	if res.Error != nil {
+		log.Fatalf("❌ Failed to backfill opening stock movements: %v", res.Error)
+	}
+	if res.RowsAffected > 0 {
+		log.Printf("🔧 Recorded opening stock movements for %d existing batch entries", res.RowsAffected)
+	}
+}
+
+// protectStockLedger makes the stock movement table append-only: corrections must be
+// posted as new movements, never by editing or deleting history.
+func protectStockLedger(db *gorm.DB) {
+	table := db.NamingStrategy.TableName("StockMovement")
+
+	stmts := []string{
+		fmt.Sprintf(`
+		CREATE OR REPLACE FUNCTION %[1]s_append_only() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION '%[1]s is append-only (%% not allowed)', TG_OP;
+		END;
+		$$ LANGUAGE plpgsql`, table),
+		fmt.Sprintf(`DROP TRIGGER IF EXISTS %[1]s_no_change ON %[1]s`, table),
+		fmt.Sprintf(`
+		CREATE TRIGGER %[1]s_no_change
+		BEFORE UPDATE OR DELETE ON %[1]s
+		FOR EACH ROW EXECUTE FUNCTION %[1]s_append_only()`, table),
+	}
+	for _, stmt := range stmts {
+		if err := db.Exec(stmt).Error; err != nil {
+			log.Fatalf("❌ Failed to protect stock ledger: %v", err)
+		}
+	}
+}
+
+// backfillRentRateVersions seeds the rent history of warehouses created before rates were
+// versioned, using their current RentConfig from the day the warehouse was created.
+func backfillRentRateVersions(db *gorm.DB) {
+	ns := db.NamingStrategy
+
+	res := db.Exec(fmt.Sprintf(`
+		INSERT INTO %s (warehouse_id, rate_per_sqft, currency, billing_cycle, minimum_charge, effective_from, created_by, created_at)
+		SELECT w.id, rr.rate_per_sqft, rr.currency, COALESCE(NULLIF(LOWER(rr.billing_cycle), ''), 'monthly'), rr.minimum_charge, w.created_at, 0, NOW()
+		FROM %s AS w
+		JOIN %s AS rr ON rr.id = w.rent_config_id
+		WHERE NOT EXISTS (SELECT 1 FROM %s AS v WHERE v.warehouse_id = w.id)
+	`, ns.TableName("RentRateVersion"), ns.TableName("Warehouse"), ns.TableName("RentRate"), ns.TableName("RentRateVersion")))
+	if res.Error != nil {
+		log.Fatalf("❌ Failed to backfill rent rate versions: %v", res.Error)
+	}
+	if res.RowsAffected > 0 {
+		log.Printf("🔧 Seeded rent history for %d existing warehouses", res.RowsAffected)
+	}
+}
+
+// backfillCurrencies puts warehouses that predate currencies on BASE_CURRENCY and the
+// documents they issued on their warehouse's currency, at a rate of 1.
+func backfillCurrencies(db *gorm.DB) {
+	ns := db.NamingStrategy
+
+	base := fx.Code(config.Cfg.BaseCurrency)
+	if base == "" {
+		base = fx.DefaultCurrency
+	}
+	res := db.Exec(fmt.Sprintf(`
+		UPDATE %s SET reporting_currency = ? WHERE reporting_currency IS NULL OR reporting_currency = ''
+	`, ns.TableName("Warehouse")), base)
+	if res.Error != nil {
+		log.Fatalf("❌ Failed to backfill warehouse currencies: %v", res.Error)
+	}
+	if res.RowsAffected > 0 {
+		log.Printf("🔧 Set reporting currency %s for %d existing warehouses", base, res.RowsAffected)
+	}
+
+	for _, table := range []string{"Batch", "Billing", "Payment", "PurchaseOrder"} {
+		res := db.Exec(fmt.Sprintf(`
+			UPDATE %s AS d
+			SET currency = w.reporting_currency
+			FROM %s AS w
+			WHERE w.id = d.warehouse_id AND (d.currency IS NULL OR d.currency = '')
+		`, ns.TableName(table), ns.TableName("Warehouse")))
+		if res.Error != nil {
+			log.Fatalf("❌ Failed to backfill %s currencies: %v", table, res.Error)
+		}
+		if res.RowsAffected > 0 {
+			log.Printf("🔧 Set currency for %d existing %s rows", res.RowsAffected, table)
+		}
+	}
+}
+
+// backfillCapacityUnits keeps warehouses and products that predate capacity units on
+// square feet, the unit their areas and rent rates were recorded in
+func backfillCapacityUnits(db *gorm.DB) {
+	ns := db.NamingStrategy
+
+	for table, column := range map[string]string{"Warehouse": "capacity_unit", "Product": "area_unit"} {
+		res := db.Exec(fmt.Sprintf(`
+			UPDATE %s SET %s = ? WHERE %s IS NULL OR %s = ''
+		`, ns.TableName(table), column, column, column), capacity.DefaultUnit)
+		if res.Error != nil {
+			log.Fatalf("❌ Failed to backfill %s capacity units: %v", table, res.Error)
+		}
+		if res.RowsAffected > 0 {
+			log.Printf("🔧 Set %s %s for %d existing %s rows", column, capacity.DefaultUnit, res.RowsAffected, table)
+		}
+	}
+}
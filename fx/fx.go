@@ -0,0 +1,149 @@
+// Package fx converts amounts between currencies with effective-dated exchange rates. A
+// rate applies from its effective date until the next rate of the same pair; a pair can
+// be read in either direction and, failing that, crossed through a pivot currency.
+package fx
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// DefaultCurrency is the reporting currency when none is configured
+const DefaultCurrency = "INR"
+
+// RatePlaces is the precision exchange rates are stored and derived with
+const RatePlaces = 8
+
+// ErrNoRate is returned when no rate is effective for a pair at the requested time
+var ErrNoRate = errors.New("no exchange rate")
+
+// ErrInvalidCurrency is returned for a code that is not three letters
+var ErrInvalidCurrency = errors.New("invalid currency code")
+
+// Rate is one effective-dated exchange rate: 1 From buys Rate To
+type Rate struct {
+	From          string
+	To            string
+	Rate          decimal.Decimal
+	EffectiveFrom time.Time
+}
+
+// Code normalises a currency code to upper case
+func Code(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// ValidCode reports whether code is a three-letter currency code
+func ValidCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Table looks up rates. Rates of a pair are kept oldest first.
+type Table struct {
+	pivot string
+	pairs map[[2]string][]Rate
+}
+
+// NewTable indexes rates by pair. Pairs with no direct or inverse rate are crossed
+// through pivot, usually the reporting currency; an empty pivot disables crossing.
+func NewTable(rates []Rate, pivot string) *Table {
+	t := &Table{pivot: Code(pivot), pairs: map[[2]string][]Rate{}}
+	for _, r := range rates {
+		r.From, r.To = Code(r.From), Code(r.To)
+		key := [2]string{r.From, r.To}
+		t.pairs[key] = append(t.pairs[key], r)
+	}
+	for _, rates := range t.pairs {
+		sort.SliceStable(rates, func(i, j int) bool {
+			return rates[i].EffectiveFrom.Before(rates[j].EffectiveFrom)
+		})
+	}
+	return t
+}
+
+// effective is the latest rate of a pair in effect at at
+func (t *Table) effective(from, to string, at time.Time) (Rate, bool) {
+	rates := t.pairs[[2]string{from, to}]
+	for i := len(rates) - 1; i >= 0; i-- {
+		if !rates[i].EffectiveFrom.After(at) {
+			return rates[i], true
+		}
+	}
+	return Rate{}, false
+}
+
+// direct is the rate of a pair read either way. When both directions have a rate, the
+// more recent one wins and a direct rate wins a tie.
+func (t *Table) direct(from, to string, at time.Time) (decimal.Decimal, bool) {
+	if from == to {
+		return decimal.NewFromInt(1), true
+	}
+	forward, fok := t.effective(from, to, at)
+	inverse, iok := t.effective(to, from, at)
+	switch {
+	case fok && (!iok || !inverse.EffectiveFrom.After(forward.EffectiveFrom)):
+		return forward.Rate, true
+	case iok && inverse.Rate.IsPositive():
+		return decimal.NewFromInt(1).DivRound(inverse.Rate, RatePlaces), true
+	}
+	return decimal.Zero, false
+}
+
+// Rate is how many units of to one unit of from buys at at
+func (t *Table) Rate(from, to string, at time.Time) (decimal.Decimal, error) {
+	from, to = Code(from), Code(to)
+	if rate, ok := t.direct(from, to, at); ok {
+		return rate, nil
+	}
+	if t.pivot != "" && from != t.pivot && to != t.pivot {
+		in, iok := t.direct(from, t.pivot, at)
+		out, ook := t.direct(t.pivot, to, at)
+		if iok && ook {
+			return in.Mul(out).Round(RatePlaces), nil
+		}
+	}
+	return decimal.Zero, fmt.Errorf("%w from %s to %s on %s", ErrNoRate, from, to, at.Format("2006-01-02"))
+}
+
+// Convert turns amount in from into to at the rate effective at at. The result is not
+// rounded; the document it lands on decides that.
+func (t *Table) Convert(amount decimal.Decimal, from, to string, at time.Time) (decimal.Decimal, error) {
+	if amount.IsZero() || Code(from) == Code(to) {
+		return amount, nil
+	}
+	rate, err := t.Rate(from, to, at)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return amount.Mul(rate), nil
+}
+
+// Rebook moves an amount booked in one document currency into another through their
+// booked rates to the reporting currency, so historical costs keep the rate they were
+// booked at
+func Rebook(amount, bookedRate, targetRate decimal.Decimal) decimal.Decimal {
+	if bookedRate.Equal(targetRate) || !targetRate.IsPositive() {
+		return amount
+	}
+	return amount.Mul(bookedRate).Div(targetRate)
+}
+
+// GainLoss is the realised exchange difference, in the reporting currency, of settling
+// amount of a document booked at bookedRate with money received at settledRate. A
+// positive result is a gain.
+func GainLoss(amount, bookedRate, settledRate decimal.Decimal) decimal.Decimal {
+	return amount.Mul(settledRate.Sub(bookedRate))
+}
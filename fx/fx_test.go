@@ -0,0 +1,65 @@
+package fx
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func day(d int) time.Time {
+	return time.Date(2026, 1, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestRate(t *testing.T) {
+	table := NewTable([]Rate{
+		{From: "USD", To: "INR", Rate: decimal.RequireFromString("83"), EffectiveFrom: day(1)},
+		{From: "USD", To: "INR", Rate: decimal.RequireFromString("84"), EffectiveFrom: day(10)},
+		{From: "inr", To: "eur", Rate: decimal.RequireFromString("0.011"), EffectiveFrom: day(1)},
+		{From: "INR", To: "USD", Rate: decimal.RequireFromString("0.0125"), EffectiveFrom: day(20)},
+	}, "INR")
+
+	tests := []struct {
+		name     string
+		from, to string
+		at       time.Time
+		want     string
+	}{
+		{"same currency", "USD", "usd", day(1), "1"},
+		{"direct", "USD", "INR", day(5), "83"},
+		{"later rate takes over", "USD", "INR", day(10), "84"},
+		{"inverse", "INR", "USD", day(5), "0.01204819"},
+		{"newer inverse wins", "USD", "INR", day(25), "80"},
+		{"crossed through the pivot", "USD", "EUR", day(12), "0.924"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := table.Rate(tt.from, tt.to, tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(decimal.RequireFromString(tt.want)) {
+				t.Errorf("rate = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := table.Rate("USD", "INR", day(1).Add(-time.Second)); !errors.Is(err, ErrNoRate) {
+		t.Errorf("rate before the first effective date: err = %v, want ErrNoRate", err)
+	}
+	if _, err := table.Rate("USD", "GBP", day(5)); !errors.Is(err, ErrNoRate) {
+		t.Errorf("unknown pair: err = %v, want ErrNoRate", err)
+	}
+}
+
+func TestRebookAndGainLoss(t *testing.T) {
+	// 100 USD billed at 83 and settled at 84 gains 100 INR
+	if got := GainLoss(decimal.NewFromInt(100), decimal.NewFromInt(83), decimal.NewFromInt(84)); !got.Equal(decimal.NewFromInt(100)) {
+		t.Errorf("gain = %s, want 100", got)
+	}
+	// A 166 INR cost on a USD bill at 83 is 2 USD
+	if got := Rebook(decimal.NewFromInt(166), decimal.NewFromInt(1), decimal.NewFromInt(83)); !got.Equal(decimal.NewFromInt(2)) {
+		t.Errorf("rebooked = %s, want 2", got)
+	}
+}
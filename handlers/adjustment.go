@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"warehouse/models"
+	"warehouse/repo"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var stockAdjustmentRepo = repo.NewStockAdjustmentRepo()
+
+// adjustmentErrorStatus maps stock adjustment repo errors to HTTP status codes
+func adjustmentErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, repo.ErrWarehouseMismatch):
+		return http.StatusForbidden
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repo.ErrInvalidAdjustmentState):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// CreateStockAdjustmentHandler opens a count sheet for the warehouse or one batch
+func CreateStockAdjustmentHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	userId, ok := userIDFromToken(c)
+	if !ok {
+		return
+	}
+
+	var input models.StockAdjustmentInput
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	adjustment, err := stockAdjustmentRepo.CreateCountSheet(context.Background(), warehouseId, userId, input)
+	if err != nil {
+		c.JSON(adjustmentErrorStatus(err), models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{Success: true, Data: adjustment})
+}
+
+// GetAllStockAdjustmentsHandler lists adjustments, optionally filtered by ?status=
+func GetAllStockAdjustmentsHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+
+	adjustments, err := stockAdjustmentRepo.GetAll(context.Background(), warehouseId, c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: adjustments})
+}
+
+func GetStockAdjustmentHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "invalid adjustment ID"})
+		return
+	}
+
+	adjustment, err := stockAdjustmentRepo.GetByID(context.Background(), warehouseId, uint(id))
+	if err != nil {
+		c.JSON(adjustmentErrorStatus(err), models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: adjustment})
+}
+
+// RecordStockCountsHandler saves physical counts and recalculates variances
+func RecordStockCountsHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "invalid adjustment ID"})
+		return
+	}
+
+	var input models.StockCountSheetInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	adjustment, err := stockAdjustmentRepo.RecordCounts(context.Background(), warehouseId, uint(id), input)
+	if err != nil {
+		c.JSON(adjustmentErrorStatus(err), models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: adjustment})
+}
+
+// SubmitStockAdjustmentHandler sends a counted sheet for admin approval
+func SubmitStockAdjustmentHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "invalid adjustment ID"})
+		return
+	}
+
+	adjustment, err := stockAdjustmentRepo.Submit(context.Background(), warehouseId, uint(id))
+	if err != nil {
+		c.JSON(adjustmentErrorStatus(err), models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: adjustment})
+}
+
+// ApproveStockAdjustmentHandler approves a submitted sheet (admin only)
+func ApproveStockAdjustmentHandler(c *gin.Context) {
+	decideStockAdjustment(c, true)
+}
+
+// RejectStockAdjustmentHandler rejects a submitted sheet (admin only)
+func RejectStockAdjustmentHandler(c *gin.Context) {
+	decideStockAdjustment(c, false)
+}
+
+func decideStockAdjustment(c *gin.Context, approve bool) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	userId, ok := userIDFromToken(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "invalid adjustment ID"})
+		return
+	}
+
+	var input models.StockAdjustmentDecisionInput
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	adjustment, err := stockAdjustmentRepo.Decide(context.Background(), warehouseId, uint(id), userId, approve, input.Notes)
+	if err != nil {
+		c.JSON(adjustmentErrorStatus(err), models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: adjustment})
+}
+
+// PostStockAdjustmentHandler applies an approved sheet to stock
+func PostStockAdjustmentHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	userId, ok := userIDFromToken(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "invalid adjustment ID"})
+		return
+	}
+
+	adjustment, err := stockAdjustmentRepo.Post(context.Background(), warehouseId, uint(id), userId)
+	if err != nil {
+		c.JSON(adjustmentErrorStatus(err), models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: adjustment})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"strconv"
+	"time"
 	"warehouse/repo"
 
 	"github.com/gin-gonic/gin"
@@ -11,6 +12,7 @@ import (
 
 var analyticsRepo = repo.NewAnalyticsRepo()
 
+// GetAnalyticsHandler reports warehouse analytics; ?customer_id= narrows the sales figures
 func GetAnalyticsHandler(c *gin.Context) {
 	duration := c.Param("duration")
 	warehouseIdAny, exists := c.Get("warehouse_id")
@@ -23,7 +25,12 @@ func GetAnalyticsHandler(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "invalid warehouse_id type"})
 		return
 	}
-	data, err := analyticsRepo.GetAnalytics(context.Background(), warehouseId, duration)
+	customerId, err := optionalUintQuery(c, "customer_id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	data, err := analyticsRepo.GetAnalytics(context.Background(), warehouseId, customerId, duration)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
 		return
@@ -76,7 +83,13 @@ func GetProductAnalyticsByIdHandler(c *gin.Context) {
 		return
 	}
 
-	data, err := analyticsRepo.GetProductAnalyticsById(c.Request.Context(), warehouseId, uint(productID))
+	customerId, err := optionalUintQuery(c, "customer_id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	data, err := analyticsRepo.GetProductAnalyticsById(c.Request.Context(), warehouseId, uint(productID), customerId)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
 		return
@@ -87,3 +100,88 @@ func GetProductAnalyticsByIdHandler(c *gin.Context) {
 		"data":    data,
 	})
 }
+
+// GetARAgeingHandler reports outstanding receivables by age, optionally for ?customer_id=
+func GetARAgeingHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	customerId, err := optionalUintQuery(c, "customer_id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	data, err := analyticsRepo.GetARAgeing(context.Background(), warehouseId, customerId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": data})
+}
+
+// reportPeriod reads ?from/?to, defaulting to the current month. It writes the error
+// response and returns false on a bad range.
+func reportPeriod(c *gin.Context) (time.Time, time.Time, bool) {
+	from, to, err := parseDateRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return time.Time{}, time.Time{}, false
+	}
+	if from.IsZero() {
+		now := time.Now()
+		from = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	}
+	if to.IsZero() {
+		to = time.Date(from.Year(), from.Month()+1, 1, 0, 0, 0, 0, time.Local)
+	}
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "from date must not be after to date"})
+		return time.Time{}, time.Time{}, false
+	}
+	return from, to, true
+}
+
+// GetTaxSummaryHandler returns output GST by HSN code and rate for ?from/?to, defaulting
+// to the current month
+func GetTaxSummaryHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	from, to, ok := reportPeriod(c)
+	if !ok {
+		return
+	}
+
+	data, err := analyticsRepo.GetTaxSummary(context.Background(), warehouseId, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": data})
+}
+
+// GetRealisedFXHandler returns the FX gain and loss realised on payments received in
+// ?from/?to, defaulting to the current month
+func GetRealisedFXHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	from, to, ok := reportPeriod(c)
+	if !ok {
+		return
+	}
+
+	data, err := analyticsRepo.GetRealisedFX(context.Background(), warehouseId, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": data})
+}
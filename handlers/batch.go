@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
+	"warehouse/fx"
 	"warehouse/models"
 	"warehouse/repo"
 
@@ -24,6 +26,10 @@ func CreateBatchHandler(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "invalid warehouse_id type"})
 		return
 	}
+	userId, ok := userIDFromToken(c)
+	if !ok {
+		return
+	}
 	var batchData models.Batch
 	if err := c.ShouldBindJSON(&batchData); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
@@ -31,13 +37,56 @@ func CreateBatchHandler(c *gin.Context) {
 	}
 	batchData.Status = "active"
 	batchData.WarehouseID = warehouseId
-	id, err := batchRepo.AddBatch(context.Background(), &batchData)
+	id, err := batchRepo.AddBatch(context.Background(), userId, &batchData)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+		c.JSON(batchErrorStatus(err), gin.H{"success": false, "message": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"success": true, "batch_id": id})
+	// Where each line went: the bins asked for, the rest as the putaway engine suggested
+	var putaway []models.EntryPutaway
+	for _, entry := range batchData.Products {
+		if len(entry.Putaway) > 0 {
+			putaway = append(putaway, models.EntryPutaway{EntryID: entry.ID, ProductID: entry.ProductID, Quantity: entry.Quantity, Locations: entry.Putaway})
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "batch_id": id, "putaway": putaway})
+}
+
+// SuggestBatchPutawayHandler previews the bins a batch would be put away in, without
+// storing it. Lines may ask for bins of their own, as on POST /batches.
+func SuggestBatchPutawayHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	var batchData models.Batch
+	if err := c.ShouldBindJSON(&batchData); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	batchData.WarehouseID = warehouseId
+
+	suggestions, err := batchRepo.SuggestPutaway(context.Background(), &batchData)
+	if err != nil {
+		c.JSON(batchErrorStatus(err), gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": suggestions})
+}
+
+// batchErrorStatus maps batch intake errors to HTTP status codes
+func batchErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, repo.ErrExpiredStock), errors.Is(err, fx.ErrInvalidCurrency), errors.Is(err, fx.ErrNoRate), errors.Is(err, repo.ErrInvalidLocation):
+		return http.StatusBadRequest
+	case errors.Is(err, repo.ErrLocationFull):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
 }
 
 // GetAllBatchesHandler fetches all batches
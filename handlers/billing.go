@@ -2,55 +2,119 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
+	"warehouse/allocation"
+	"warehouse/fx"
 	"warehouse/models"
 	"warehouse/repo"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 var billingRepo = repo.NewBillingRepo()
 
 func CreateBillingWithBatchId(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	userId, ok := userIDFromToken(c)
+	if !ok {
+		return
+	}
 	var input models.BillingInput
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	billing, err := billingRepo.CreateBillingWithBatchId(context.Background(), input)
+	billing, err := billingRepo.CreateBillingWithBatchId(context.Background(), warehouseId, userId, input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(billingErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{"message": "Billing created successfully", "data": billing})
 }
 func CreateBillingWithOutBatchId(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	userId, ok := userIDFromToken(c)
+	if !ok {
+		return
+	}
 	var input models.BillingInput
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	billing, err := billingRepo.CreateBillingWithOutBatchId(context.Background(), input)
+	billing, err := billingRepo.CreateBillingWithOutBatchId(context.Background(), warehouseId, userId, input)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(billingErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusCreated, gin.H{"message": "Billing created successfully", "data": billing})
 }
 
+// PreviewBillingHandler returns the bill a BillingInput would produce without writing anything
+func PreviewBillingHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	var input models.BillingInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	preview, err := billingRepo.PreviewBilling(context.Background(), warehouseId, input)
+	if err != nil {
+		c.JSON(billingErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Billing preview", "data": preview})
+}
+
+// billingErrorStatus maps billing repo errors to HTTP status codes
+func billingErrorStatus(err error) int {
+	if errors.Is(err, repo.ErrWarehouseMismatch) {
+		return http.StatusForbidden
+	}
+	if errors.Is(err, repo.ErrExpiredStock) || errors.Is(err, allocation.ErrInsufficientStock) || errors.Is(err, repo.ErrLocationFull) {
+		return http.StatusConflict
+	}
+	if errors.Is(err, fx.ErrInvalidCurrency) || errors.Is(err, fx.ErrNoRate) || errors.Is(err, repo.ErrMissingTaxRate) {
+		return http.StatusUnprocessableEntity
+	}
+	// Unknown customer, batch or product references are bad input
+	if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, repo.ErrInvalidBillingInput) || errors.Is(err, repo.ErrInvalidReversal) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
 func GetBillByIDHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
 	idStr := c.Param("id")
 	batchID, err := strconv.Atoi(idStr)
 	if err != nil {
 		c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: err.Error()})
 		return
 	}
-	batch, err := billingRepo.GetBillingCoreDataWithProductsByBillID(context.Background(), uint(batchID))
+	batch, err := billingRepo.GetBillingCoreDataWithProductsByBillID(context.Background(), warehouseId, uint(batchID))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": err.Error()})
 		return
@@ -59,6 +123,8 @@ func GetBillByIDHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": batch})
 }
 
+// GetAllBillsHandler lists the warehouse's bills, optionally by ?customer_id=, ?from=&to=,
+// ?payment_status= and ?invoice_number= (any part of the number)
 func GetAllBillsHandler(c *gin.Context) {
 	warehouseIdAny, exists := c.Get("warehouse_id")
 	if !exists {
@@ -71,7 +137,26 @@ func GetAllBillsHandler(c *gin.Context) {
 		return
 	}
 
-	batches, err := billingRepo.GetAllBillingCoreData(context.Background(),warehouseId)
+	customerId, err := optionalUintQuery(c, "customer_id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	from, to, err := parseDateRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	status := c.Query("payment_status")
+	switch status {
+	case "", models.PaymentUnpaid, models.PaymentPartial, models.PaymentPaid, models.PaymentOverdue:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "invalid payment_status, expected unpaid, partial, paid or overdue"})
+		return
+	}
+
+	batches, err := billingRepo.GetAllBillingCoreData(context.Background(), warehouseId, customerId, from, to, status, c.Query("invoice_number"))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
 		return
@@ -98,3 +183,57 @@ warehouseIdAny, exists := c.Get("warehouse_id")
 
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": data})
 }
+
+// ReverseBillingHandler issues a credit note for all or part of a bill and restores the stock
+func ReverseBillingHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	userId, ok := userIDFromToken(c)
+	if !ok {
+		return
+	}
+	idStr := c.Param("id")
+	billingID, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	// An empty body reverses everything still open on the bill
+	var input models.BillingReversalInput
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	creditNote, err := billingRepo.ReverseBilling(context.Background(), warehouseId, userId, uint(billingID), input)
+	if err != nil {
+		c.JSON(billingErrorStatus(err), gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"success": true, "message": "Credit note created successfully", "data": creditNote})
+}
+
+func GetCreditNotesByBillIDHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	idStr := c.Param("id")
+	billingID, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	notes, err := billingRepo.GetCreditNotesByBillID(context.Background(), warehouseId, uint(billingID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": notes})
+}
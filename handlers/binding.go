@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"log"
+	"reflect"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/shopspring/decimal"
+)
+
+// init lets binding tags such as gt=0 check decimal amounts by their value. Without it
+// the validator panics on every decimal.Decimal field with a comparison tag, so it is
+// registered with the handlers that bind them, not when the routes are set up.
+func init() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		log.Println("⚠️ unexpected binding validator, decimal amounts are not validated")
+		return
+	}
+	v.RegisterCustomTypeFunc(func(field reflect.Value) any {
+		if d, ok := field.Interface().(decimal.Decimal); ok {
+			f, _ := d.Float64()
+			return f
+		}
+		return nil
+	}, decimal.Decimal{})
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"warehouse/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/shopspring/decimal"
+)
+
+// bindRouter serves the handlers as an authenticated employee of warehouse 1. Only
+// requests rejected while binding are sent, so no database is needed.
+func bindRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("warehouse_id", uint(1))
+		c.Set("user_id", uint(1))
+		c.Next()
+	})
+	r.POST("/payments", RecordPaymentHandler)
+	r.POST("/purchase-orders", CreatePurchaseOrderHandler)
+	r.POST("/warehouses/:id/rent-rates", AddRentRateHandler)
+	r.POST("/exchange-rates", CreateExchangeRate)
+	r.POST("/billing/generate", CreateBillingWithOutBatchId)
+	return r
+}
+
+func TestDecimalBindingRejectsOutOfRangeAmounts(t *testing.T) {
+	r := bindRouter()
+
+	tests := []struct {
+		name, path, body string
+	}{
+		{"zero payment", "/payments", `{"customer_id": 1, "amount": "0", "method": "cash"}`},
+		{"negative payment", "/payments", `{"customer_id": 1, "amount": -5, "method": "cash"}`},
+		{"negative unit price", "/purchase-orders", `{"supplier_id": 1, "items": [{"product_id": 1, "quantity": 2, "unit_price": "-1"}]}`},
+		{"negative rent rate", "/warehouses/1/rent-rates", `{"rate_per_sqft": "-0.5"}`},
+		{"negative minimum charge", "/warehouses/1/rent-rates", `{"rate_per_sqft": 4, "minimum_charge": -1}`},
+		{"zero exchange rate", "/exchange-rates", `{"from_currency": "USD", "to_currency": "INR", "effective_from": "2025-04-01T00:00:00Z", "rate": 0}`},
+		{"negative exchange rate", "/exchange-rates", `{"from_currency": "USD", "to_currency": "INR", "effective_from": "2025-04-01T00:00:00Z", "rate": "-83.1"}`},
+		{"zero offboard quantity", "/billing/generate", `{"customer_id": 1, "items": [{"product_id": "1", "offboard_quantity": 0, "selling_price": 10}]}`},
+		{"negative offboard quantity", "/billing/generate", `{"customer_id": 1, "items": [{"product_id": "1", "offboard_quantity": -3, "selling_price": 10}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d (body %s)", w.Code, http.StatusBadRequest, w.Body)
+			}
+		})
+	}
+}
+
+func TestDecimalBindingAcceptsValidAmounts(t *testing.T) {
+	inputs := map[string]any{
+		"payment": models.PaymentInput{CustomerID: 1, Amount: decimal.RequireFromString("0.01"), Method: "cash"},
+		"purchase order": models.PurchaseOrderInput{SupplierID: 1, Items: []models.PurchaseOrderItemInput{
+			{ProductID: 1, Quantity: 2, UnitPrice: decimal.Zero},
+		}},
+		"rent rate":     models.RentRateVersionInput{RatePerSqft: decimal.NewFromInt(4)},
+		"exchange rate": models.ExchangeRate{FromCurrency: "USD", ToCurrency: "INR", EffectiveFrom: time.Now(), Rate: decimal.RequireFromString("83.12")},
+	}
+	for name, input := range inputs {
+		if err := binding.Validator.ValidateStruct(input); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
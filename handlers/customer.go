@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"warehouse/fx"
+	"warehouse/models"
+	"warehouse/repo"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var customerRepo = repo.NewCustomerRepo()
+
+func CreateCustomer(c *gin.Context) {
+	var p models.Customer
+	if err := c.ShouldBindJSON(&p); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	id, err := customerRepo.Create(context.Background(), &p)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, fx.ErrInvalidCurrency) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: id})
+}
+
+func GetCustomer(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	p, err := customerRepo.GetByID(context.Background(), uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: "customer not found"})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: p})
+}
+
+func GetAllCustomers(c *gin.Context) {
+	customers, err := customerRepo.GetAll(context.Background())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: customers})
+}
+
+func UpdateCustomer(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	var update models.Customer
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	update.ID = uint(id)
+	err = customerRepo.Update(context.Background(), update)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, fx.ErrInvalidCurrency):
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "customer updated"})
+}
+
+func DeleteCustomer(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	err = customerRepo.Delete(context.Background(), uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "customer deleted"})
+}
+
+// GetCustomerStatement returns the customer's bills and totals for the caller's warehouse,
+// optionally limited by ?from=&to=
+func GetCustomerStatement(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	from, to, err := parseDateRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	statement, err := customerRepo.GetStatement(context.Background(), warehouseId, uint(id), from, to)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, fx.ErrNoRate):
+			status = http.StatusUnprocessableEntity
+		}
+		c.JSON(status, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: statement})
+}
@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"warehouse/fx"
+	"warehouse/models"
+	"warehouse/repo"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+var exchangeRateRepo = repo.NewExchangeRateRepo()
+
+// Expected column order of a rate file (first row is the header):
+// from currency | to currency | rate | effective from
+const exchangeRateColumnCount = 4
+
+func CreateExchangeRate(c *gin.Context) {
+	userId, ok := userIDFromToken(c)
+	if !ok {
+		return
+	}
+	var rate models.ExchangeRate
+	if err := c.ShouldBindJSON(&rate); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	if err := exchangeRateRepo.Create(context.Background(), userId, &rate); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, fx.ErrInvalidCurrency) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: rate})
+}
+
+// GetAllExchangeRates lists the loaded rates; ?currency= keeps the pairs of one currency
+func GetAllExchangeRates(c *gin.Context) {
+	rates, err := exchangeRateRepo.GetAll(context.Background(), c.Query("currency"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: rates})
+}
+
+func DeleteExchangeRate(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	err = exchangeRateRepo.Delete(context.Background(), uint(id))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "exchange rate deleted"})
+}
+
+// ImportExchangeRates loads an .xlsx or .csv rate file. Pass ?dry_run=true to validate
+// the file without saving anything.
+func ImportExchangeRates(c *gin.Context) {
+	userId, ok := userIDFromToken(c)
+	if !ok {
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "file is required"})
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "failed to open file"})
+		return
+	}
+	defer f.Close()
+
+	var records [][]string
+	switch strings.ToLower(filepath.Ext(file.Filename)) {
+	case ".xlsx":
+		records, err = readExcelRecords(f)
+	case ".csv":
+		records, err = readCSVRecords(f)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "only .xlsx and .csv files are supported"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	rows := parseExchangeRateRecords(records)
+	if len(rows) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "file has no data rows"})
+		return
+	}
+
+	report, err := exchangeRateRepo.Import(context.Background(), userId, file.Filename, rows, dryRun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	if report.Invalid > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"success": false, "message": "file has invalid rows, no rates were loaded", "data": report})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": report})
+}
+
+// parseExchangeRateRecords converts raw rate file rows (header first) into rate rows,
+// recording per-row validation errors instead of dropping bad rows
+func parseExchangeRateRecords(records [][]string) []models.ExchangeRateRow {
+	var rows []models.ExchangeRateRow
+
+	for i := 1; i < len(records); i++ {
+		r := records[i]
+		if isBlankRecord(r) {
+			continue
+		}
+		for len(r) < exchangeRateColumnCount {
+			r = append(r, "")
+		}
+		for j := range r {
+			r[j] = strings.TrimSpace(r[j])
+		}
+
+		row := models.ExchangeRateRow{
+			RowNumber:    i + 1,
+			FromCurrency: r[0],
+			ToCurrency:   r[1],
+		}
+
+		rate, err := decimal.NewFromString(r[2])
+		if err != nil || !rate.IsPositive() {
+			row.Errors = append(row.Errors, "invalid rate "+strconv.Quote(r[2]))
+		}
+		row.Rate = rate
+
+		effectiveFrom, ok := parseImportDate(r[3])
+		if !ok {
+			row.Errors = append(row.Errors, "invalid effective date "+strconv.Quote(r[3]))
+		}
+		row.EffectiveFrom = effectiveFrom
+
+		rows = append(rows, row)
+	}
+
+	return rows
+}
@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -19,3 +21,73 @@ func PingHandler(c *gin.Context) {
 		"details": health,
 	})
 }
+
+// warehouseIDFromToken reads the caller's warehouse_id claim, writing the error
+// response itself when it is missing so handlers can simply return.
+func warehouseIDFromToken(c *gin.Context) (uint, bool) {
+	warehouseIdAny, exists := c.Get("warehouse_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "warehouse_id not found in token"})
+		return 0, false
+	}
+	warehouseId, ok := warehouseIdAny.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "invalid warehouse_id type"})
+		return 0, false
+	}
+	return warehouseId, true
+}
+
+// userIDFromToken reads the caller's user_id claim, writing the error response itself
+// when it is missing so handlers can simply return.
+func userIDFromToken(c *gin.Context) (uint, bool) {
+	userIdAny, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "user_id not found in token"})
+		return 0, false
+	}
+	userId, ok := userIdAny.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "invalid user_id type"})
+		return 0, false
+	}
+	return userId, true
+}
+
+// parseDateRange reads optional ?from= and ?to= query dates (YYYY-MM-DD). The returned
+// upper bound is exclusive, so ?to= includes the whole day.
+func parseDateRange(c *gin.Context) (time.Time, time.Time, error) {
+	var from, to time.Time
+	if v := c.Query("from"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid from date %q, expected YYYY-MM-DD", v)
+		}
+		from = t
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return from, to, fmt.Errorf("invalid to date %q, expected YYYY-MM-DD", v)
+		}
+		to = t.AddDate(0, 0, 1)
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return from, to, fmt.Errorf("from date must not be after to date")
+	}
+	return from, to, nil
+}
+
+// optionalUintQuery reads an optional numeric query parameter such as ?customer_id=,
+// returning 0 when it is absent.
+func optionalUintQuery(c *gin.Context, name string) (uint, error) {
+	v := c.Query(name)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", name, v)
+	}
+	return uint(n), nil
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"warehouse/models"
+	"warehouse/repo"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var invoiceRepo = repo.NewInvoiceRepo()
+
+// GetBillPDFHandler renders a bill of the caller's warehouse as a printable PDF invoice
+func GetBillPDFHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "invalid bill ID"})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := invoiceRepo.RenderBillPDF(context.Background(), warehouseId, uint(id), &buf); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="invoice-%d.pdf"`, id))
+	c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
+
+// GetInvoiceTemplateHandler returns a warehouse's invoice template (defaults if never saved)
+func GetInvoiceTemplateHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	tpl, err := invoiceRepo.GetTemplate(context.Background(), uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: tpl})
+}
+
+// UpdateInvoiceTemplateHandler replaces a warehouse's invoice template
+func UpdateInvoiceTemplateHandler(c *gin.Context) {
+	userId, ok := userIDFromToken(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	var input models.InvoiceTemplateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	tpl, err := invoiceRepo.SaveTemplate(context.Background(), uint(id), userId, input)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, repo.ErrInvalidInvoiceTemplate):
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "invoice template updated", Data: tpl})
+}
+
+// GetInvoiceSeriesHandler returns a warehouse's invoice numbering (defaults if never saved)
+func GetInvoiceSeriesHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	series, err := invoiceRepo.GetSeries(context.Background(), uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: series})
+}
+
+// UpdateInvoiceSeriesHandler changes a warehouse's invoice numbering
+func UpdateInvoiceSeriesHandler(c *gin.Context) {
+	userId, ok := userIDFromToken(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	var input models.InvoiceSeriesInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	series, err := invoiceRepo.SaveSeries(context.Background(), uint(id), userId, input)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "invoice series updated", Data: series})
+}
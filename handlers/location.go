@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"warehouse/models"
+	"warehouse/repo"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var locationRepo = repo.NewLocationRepo()
+
+// locationErrorStatus maps location repo errors to HTTP status codes
+func locationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repo.ErrLocationFull):
+		return http.StatusConflict
+	case errors.Is(err, repo.ErrInvalidLocation):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// CreateLocationHandler adds a zone, aisle, rack or bin to the caller's warehouse
+func CreateLocationHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+
+	var location models.Location
+	if err := c.ShouldBindJSON(&location); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	if err := locationRepo.Create(context.Background(), warehouseId, &location); err != nil {
+		c.JSON(locationErrorStatus(err), models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{Success: true, Data: location})
+}
+
+// GetAllLocationsHandler lists the warehouse's locations, optionally of one ?level=
+func GetAllLocationsHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+
+	locations, err := locationRepo.GetAll(context.Background(), warehouseId, c.Query("level"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: locations})
+}
+
+func UpdateLocationHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "invalid location ID"})
+		return
+	}
+
+	var input models.LocationUpdateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	location, err := locationRepo.Update(context.Background(), warehouseId, uint(id), input)
+	if err != nil {
+		c.JSON(locationErrorStatus(err), models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: location})
+}
+
+func DeleteLocationHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "invalid location ID"})
+		return
+	}
+
+	if err := locationRepo.Delete(context.Background(), warehouseId, uint(id)); err != nil {
+		c.JSON(locationErrorStatus(err), models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "location deleted"})
+}
+
+// MoveLocationStockHandler moves units of a batch entry into a bin
+func MoveLocationStockHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+
+	var input models.LocationMoveInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	if err := locationRepo.Move(context.Background(), warehouseId, input); err != nil {
+		c.JSON(locationErrorStatus(err), models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "stock moved"})
+}
+
+// GetLocationOccupancyHandler returns the warehouse's location tree with free space and
+// the stock in every bin
+func GetLocationOccupancyHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+
+	occupancy, err := locationRepo.Occupancy(context.Background(), warehouseId)
+	if err != nil {
+		c.JSON(locationErrorStatus(err), models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: occupancy})
+}
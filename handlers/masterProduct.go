@@ -2,8 +2,11 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strconv"
+	"warehouse/allocation"
+	"warehouse/capacity"
 	"warehouse/models"
 	"warehouse/repo"
 
@@ -21,7 +24,11 @@ func CreateProduct(c *gin.Context) {
 
 	id, err := productRepo.Create(context.Background(), &p)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: err.Error()})
+		status := http.StatusInternalServerError
+		if errors.Is(err, capacity.ErrUnknownUnit) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, models.APIResponse{Success: false, Message: err.Error()})
 		return
 	}
 
@@ -75,7 +82,11 @@ func UpdateProduct(c *gin.Context) {
 
 	err = productRepo.Update(context.Background(), uint(id), update)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: err.Error()})
+		status := http.StatusInternalServerError
+		if errors.Is(err, allocation.ErrUnknownStrategy) || errors.Is(err, capacity.ErrUnknownUnit) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, models.APIResponse{Success: false, Message: err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "Product updated"})
@@ -1,66 +1,216 @@
 package handlers
 
-// Handler: Accept Excel and send rows to service
-// func ImportProductsFromExcel(c *gin.Context) {
-// 	file, err := c.FormFile("file")
-// 	if err != nil {
-// 		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
-// 		return
-// 	}
-
-// 	f, err := file.Open()
-// 	if err != nil {
-// 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to open file"})
-// 		return
-// 	}
-// 	defer f.Close()
-
-// 	// Parse Excel
-// 	xlFile, err := excelize.OpenReader(f)
-// 	if err != nil {
-// 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid excel file"})
-// 		return
-// 	}
-
-// 	sheet := xlFile.GetSheetName(0)
-// 	rows, err := xlFile.GetRows(sheet)
-// 	if err != nil || len(rows) < 2 {
-// 		c.JSON(http.StatusBadRequest, gin.H{"error": "excel must contain header + data"})
-// 		return
-// 	}
-
-// 	// Convert rows → DTO
-// 	var items []models.ExcelProductRow
-
-// 	for i := 1; i < len(rows); i++ {
-// 		r := rows[i]
-// 		if len(r) < 8 {
-// 			continue
-// 		}
-
-// 		quantity, _ := strconv.Atoi(r[6])
-// 		storageArea, _ := strconv.ParseFloat(r[3], 64)
-// 		purchasePrice, _ := strconv.ParseFloat(r[5], 64)
-// 		purchaseDate, _ := time.Parse("2006-01-02", r[7])
-
-// 		items = append(items, models.ExcelProductRow{
-// 			ProductName:     r[0],
-// 			ProductCategory: r[1],
-// 			SupplierName:    r[2],
-// 			StorageArea:     storageArea,
-// 			WarehouseName:   r[4],
-// 			PurchasePrice:   purchasePrice,
-// 			Quantity:        quantity,
-// 			PurchaseDate:    purchaseDate,
-// 		})
-// 	}
-
-// 	// Send to service
-// 	err = h.ImportService.ProcessExcelRows(c, items)
-// 	if err != nil {
-// 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-// 		return
-// 	}
-
-// 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Excel imported successfully"})
-// }
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"warehouse/models"
+	"warehouse/repo"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+	"github.com/xuri/excelize/v2"
+)
+
+var importRepo = repo.NewImportRepo()
+
+// Expected column order (first row is the header):
+// product name | category | supplier name | storage area | warehouse name | purchase price | quantity | purchase date
+const importColumnCount = 8
+
+var importDateLayouts = []string{"2006-01-02", "02-01-2006", "02/01/2006", "01-02-06", "2006/01/02"}
+
+// ImportBatchesFromFile accepts an .xlsx or .csv supplier manifest and onboards it as batches.
+// Pass ?dry_run=true to validate the file without committing anything.
+func ImportBatchesFromFile(c *gin.Context) {
+	warehouseIdAny, exists := c.Get("warehouse_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "warehouse_id not found in token"})
+		return
+	}
+	warehouseId, ok := warehouseIdAny.(uint)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "invalid warehouse_id type"})
+		return
+	}
+
+	userId, ok := userIDFromToken(c)
+	if !ok {
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(c.DefaultQuery("dry_run", "false"))
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "file is required"})
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "failed to open file"})
+		return
+	}
+	defer f.Close()
+
+	var records [][]string
+	switch strings.ToLower(filepath.Ext(file.Filename)) {
+	case ".xlsx":
+		records, err = readExcelRecords(f)
+	case ".csv":
+		records, err = readCSVRecords(f)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "only .xlsx and .csv files are supported"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	if len(records) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "file must contain header + data"})
+		return
+	}
+
+	rows := parseImportRecords(records)
+	if len(rows) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "file has no data rows"})
+		return
+	}
+
+	report, err := importRepo.ImportBatches(context.Background(), userId, warehouseId, rows, dryRun)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	if report.Invalid > 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"success": false, "message": "import has invalid rows, nothing was imported", "data": report})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": report})
+}
+
+func readExcelRecords(r io.Reader) ([][]string, error) {
+	xlFile, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, errors.New("invalid excel file")
+	}
+	defer xlFile.Close()
+
+	sheet := xlFile.GetSheetName(0)
+	rows, err := xlFile.GetRows(sheet)
+	if err != nil {
+		return nil, errors.New("failed to read excel sheet")
+	}
+	return rows, nil
+}
+
+func readCSVRecords(r io.Reader) ([][]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid csv file: %w", err)
+	}
+	return records, nil
+}
+
+// parseImportRecords converts raw spreadsheet rows (header first) into import rows,
+// recording per-row validation errors instead of dropping bad rows.
+func parseImportRecords(records [][]string) []models.ExcelProductRow {
+	var items []models.ExcelProductRow
+
+	for i := 1; i < len(records); i++ {
+		r := records[i]
+		if isBlankRecord(r) {
+			continue
+		}
+		for len(r) < importColumnCount {
+			r = append(r, "")
+		}
+		for j := range r {
+			r[j] = strings.TrimSpace(r[j])
+		}
+
+		row := models.ExcelProductRow{
+			RowNumber:       i + 1,
+			ProductName:     r[0],
+			ProductCategory: r[1],
+			SupplierName:    r[2],
+			WarehouseName:   r[4],
+		}
+
+		if row.ProductName == "" {
+			row.Errors = append(row.Errors, "product name is required")
+		}
+		if row.SupplierName == "" {
+			row.Errors = append(row.Errors, "supplier name is required")
+		}
+
+		if r[3] != "" {
+			storageArea, err := strconv.ParseFloat(r[3], 64)
+			if err != nil || storageArea < 0 {
+				row.Errors = append(row.Errors, "invalid storage area "+strconv.Quote(r[3]))
+			}
+			row.StorageArea = storageArea
+		}
+
+		purchasePrice, err := decimal.NewFromString(strings.TrimSpace(r[5]))
+		if err != nil || purchasePrice.IsNegative() {
+			row.Errors = append(row.Errors, "invalid purchase price "+strconv.Quote(r[5]))
+		}
+		row.PurchasePrice = purchasePrice
+
+		quantity, err := strconv.Atoi(r[6])
+		if err != nil || quantity <= 0 {
+			row.Errors = append(row.Errors, "invalid quantity "+strconv.Quote(r[6]))
+		}
+		row.Quantity = quantity
+
+		if r[7] != "" {
+			purchaseDate, ok := parseImportDate(r[7])
+			if !ok {
+				row.Errors = append(row.Errors, "invalid purchase date "+strconv.Quote(r[7]))
+			}
+			row.PurchaseDate = purchaseDate
+		}
+
+		items = append(items, row)
+	}
+
+	return items
+}
+
+func parseImportDate(value string) (time.Time, bool) {
+	for _, layout := range importDateLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, true
+		}
+	}
+	// Excel serial date (unformatted cell)
+	if serial, err := strconv.ParseFloat(value, 64); err == nil {
+		if t, err := excelize.ExcelDateToTime(serial, false); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func isBlankRecord(r []string) bool {
+	for _, v := range r {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"warehouse/models"
+	"warehouse/repo"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var paymentRepo = repo.NewPaymentRepo()
+
+// paymentErrorStatus maps payment repo errors to HTTP status codes
+func paymentErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, repo.ErrWarehouseMismatch):
+		return http.StatusForbidden
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repo.ErrOverAllocation), errors.Is(err, repo.ErrCurrencyMismatch):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// RecordPaymentHandler records a customer payment and applies it to their bills
+func RecordPaymentHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	userId, ok := userIDFromToken(c)
+	if !ok {
+		return
+	}
+
+	var input models.PaymentInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	paidAt := time.Now()
+	if input.PaidAt != "" {
+		t, err := time.ParseInLocation("2006-01-02", input.PaidAt, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "invalid paid_at, expected YYYY-MM-DD"})
+			return
+		}
+		paidAt = t
+	}
+
+	payment, err := paymentRepo.RecordPayment(context.Background(), warehouseId, userId, input, paidAt)
+	if err != nil {
+		c.JSON(paymentErrorStatus(err), models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{Success: true, Message: "Payment recorded", Data: payment})
+}
+
+// GetAllPaymentsHandler lists payments, optionally by ?customer_id= and ?from=&to=
+func GetAllPaymentsHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	customerId, err := optionalUintQuery(c, "customer_id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	from, to, err := parseDateRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	payments, err := paymentRepo.GetAll(context.Background(), warehouseId, customerId, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: payments})
+}
+
+func GetPaymentHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "invalid payment ID"})
+		return
+	}
+
+	payment, err := paymentRepo.GetByID(context.Background(), warehouseId, uint(id))
+	if err != nil {
+		c.JSON(paymentErrorStatus(err), models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: payment})
+}
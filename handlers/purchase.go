@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"warehouse/models"
+	"warehouse/repo"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var purchaseRepo = repo.NewPurchaseRepo()
+
+// purchaseErrorStatus maps purchase repo errors to HTTP status codes
+func purchaseErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, repo.ErrWarehouseMismatch):
+		return http.StatusForbidden
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repo.ErrInvalidPurchaseOrderState):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// CreatePurchaseOrderHandler raises a purchase order for the caller's warehouse
+func CreatePurchaseOrderHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	userId, ok := userIDFromToken(c)
+	if !ok {
+		return
+	}
+
+	var input models.PurchaseOrderInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	order, err := purchaseRepo.CreateOrder(context.Background(), warehouseId, userId, input)
+	if err != nil {
+		c.JSON(purchaseErrorStatus(err), models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{Success: true, Data: order})
+}
+
+// GetAllPurchaseOrdersHandler lists purchase orders, optionally by ?supplier_id= and ?status=
+func GetAllPurchaseOrdersHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	supplierId, err := optionalUintQuery(c, "supplier_id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	orders, err := purchaseRepo.GetAll(context.Background(), warehouseId, supplierId, c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: orders})
+}
+
+// GetOpenPurchaseOrdersHandler reports open orders per supplier, optionally for ?supplier_id=
+func GetOpenPurchaseOrdersHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	supplierId, err := optionalUintQuery(c, "supplier_id")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	report, err := purchaseRepo.GetOpenOrdersBySupplier(context.Background(), warehouseId, supplierId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: report})
+}
+
+func GetPurchaseOrderHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "invalid purchase order ID"})
+		return
+	}
+
+	order, err := purchaseRepo.GetByID(context.Background(), warehouseId, uint(id))
+	if err != nil {
+		c.JSON(purchaseErrorStatus(err), models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: order})
+}
+
+// ReceiveGoodsHandler books a goods receipt note against a purchase order
+func ReceiveGoodsHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	userId, ok := userIDFromToken(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "invalid purchase order ID"})
+		return
+	}
+
+	var input models.GoodsReceiptInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	receipt, err := purchaseRepo.Receive(context.Background(), warehouseId, uint(id), userId, input)
+	if err != nil {
+		c.JSON(purchaseErrorStatus(err), models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{Success: true, Data: receipt})
+}
+
+// GetGoodsReceiptsHandler lists the goods receipts of a purchase order
+func GetGoodsReceiptsHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "invalid purchase order ID"})
+		return
+	}
+
+	receipts, err := purchaseRepo.GetReceipts(context.Background(), warehouseId, uint(id))
+	if err != nil {
+		c.JSON(purchaseErrorStatus(err), models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: receipts})
+}
+
+// ClosePurchaseOrderHandler short-closes an open purchase order (cancels it if nothing
+// was received)
+func ClosePurchaseOrderHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	userId, ok := userIDFromToken(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "invalid purchase order ID"})
+		return
+	}
+
+	// The reason is optional, so is the body
+	var input models.PurchaseOrderCloseInput
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	order, err := purchaseRepo.Close(context.Background(), warehouseId, uint(id), userId, input.Reason)
+	if err != nil {
+		c.JSON(purchaseErrorStatus(err), models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: order})
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"warehouse/models"
+	"warehouse/repo"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var rentInvoiceRepo = repo.NewRentInvoiceRepo()
+
+// rentInvoiceErrorStatus maps rent invoicing repo errors to HTTP status codes
+func rentInvoiceErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, repo.ErrWarehouseMismatch):
+		return http.StatusForbidden
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repo.ErrNothingToInvoice):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// RunRentInvoicingHandler invoices accrued rent of the caller's warehouse for a period
+func RunRentInvoicingHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	userId, ok := userIDFromToken(c)
+	if !ok {
+		return
+	}
+
+	var input models.RentInvoiceRunInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	periodStart, err := time.ParseInLocation("2006-01-02", input.PeriodStart, time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "invalid period_start, expected YYYY-MM-DD"})
+		return
+	}
+	periodEnd, err := time.ParseInLocation("2006-01-02", input.PeriodEnd, time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "invalid period_end, expected YYYY-MM-DD"})
+		return
+	}
+
+	invoice, err := rentInvoiceRepo.RunInvoicing(context.Background(), warehouseId, userId, periodStart, periodEnd)
+	if err != nil {
+		c.JSON(rentInvoiceErrorStatus(err), models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{Success: true, Message: "Rent invoice created", Data: invoice})
+}
+
+// GetAllRentInvoicesHandler lists rent invoices, optionally by ?from=&to= on the period end
+func GetAllRentInvoicesHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	from, to, err := parseDateRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	invoices, err := rentInvoiceRepo.GetAll(context.Background(), warehouseId, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: invoices})
+}
+
+func GetRentInvoiceHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "invalid rent invoice ID"})
+		return
+	}
+
+	invoice, err := rentInvoiceRepo.GetByID(context.Background(), warehouseId, uint(id))
+	if err != nil {
+		c.JSON(rentInvoiceErrorStatus(err), models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: invoice})
+}
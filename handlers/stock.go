@@ -11,6 +11,7 @@ import (
 )
 
 var productStockRepo = repo.NewProductStockRepo()
+var stockMovementRepo = repo.NewStockMovementRepo()
 
 func GetProductStockWithRentHandler(c *gin.Context) {
 	warehouseIdAny, exists := c.Get("warehouse_id")
@@ -118,3 +119,72 @@ func SearchStockProductData(c *gin.Context) {
 		"data":    stock,
 	})
 }
+
+// GetStockMovementsHandler returns the stock ledger of a product, optionally limited by ?from=&to=
+func GetStockMovementsHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+
+	productId, err := strconv.Atoi(c.Param("product_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "invalid product ID"})
+		return
+	}
+	from, to, err := parseDateRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	movements, err := stockMovementRepo.GetProductMovements(context.Background(), warehouseId, uint(productId), from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: movements})
+}
+
+// CheckStockConsistencyHandler replays the stock ledger against the current stock quantities
+func CheckStockConsistencyHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+
+	report, err := stockMovementRepo.CheckConsistency(context.Background(), warehouseId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: report})
+}
+
+// GetExpiringStockHandler lists stock expiring within ?days= (default 30), including expired stock
+func GetExpiringStockHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+
+	days := 30
+	if v := c.Query("days"); v != "" {
+		d, err := strconv.Atoi(v)
+		if err != nil || d < 0 {
+			c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "days must be a non-negative integer"})
+			return
+		}
+		days = d
+	}
+
+	report, err := productStockRepo.GetExpiringStock(context.Background(), warehouseId, days)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: report})
+}
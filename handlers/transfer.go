@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"warehouse/models"
+	"warehouse/repo"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var transferRepo = repo.NewTransferRepo()
+
+// transferErrorStatus maps transfer repo errors to HTTP status codes
+func transferErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, repo.ErrWarehouseMismatch):
+		return http.StatusForbidden
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repo.ErrInvalidTransferState):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// CreateTransferHandler drafts a transfer out of the caller's warehouse
+func CreateTransferHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	userId, ok := userIDFromToken(c)
+	if !ok {
+		return
+	}
+
+	var input models.TransferOrderInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	order, err := transferRepo.CreateTransfer(context.Background(), warehouseId, userId, input)
+	if err != nil {
+		c.JSON(transferErrorStatus(err), models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{Success: true, Data: order})
+}
+
+// GetAllTransfersHandler lists incoming and outgoing transfers, optionally by ?status=
+func GetAllTransfersHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+
+	orders, err := transferRepo.GetAll(context.Background(), warehouseId, c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: orders})
+}
+
+func GetTransferHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "invalid transfer ID"})
+		return
+	}
+
+	order, err := transferRepo.GetByID(context.Background(), warehouseId, uint(id))
+	if err != nil {
+		c.JSON(transferErrorStatus(err), models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: order})
+}
+
+// DispatchTransferHandler ships a draft transfer from the source warehouse
+func DispatchTransferHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	userId, ok := userIDFromToken(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "invalid transfer ID"})
+		return
+	}
+
+	order, err := transferRepo.Dispatch(context.Background(), warehouseId, uint(id), userId)
+	if err != nil {
+		c.JSON(transferErrorStatus(err), models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: order})
+}
+
+// ReceiveTransferHandler books a dispatched transfer into the destination warehouse
+func ReceiveTransferHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	userId, ok := userIDFromToken(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "invalid transfer ID"})
+		return
+	}
+
+	order, err := transferRepo.Receive(context.Background(), warehouseId, uint(id), userId)
+	if err != nil {
+		c.JSON(transferErrorStatus(err), models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: order})
+}
+
+// CancelTransferHandler drops a draft transfer
+func CancelTransferHandler(c *gin.Context) {
+	warehouseId, ok := warehouseIDFromToken(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: "invalid transfer ID"})
+		return
+	}
+
+	order, err := transferRepo.Cancel(context.Background(), warehouseId, uint(id))
+	if err != nil {
+		c.JSON(transferErrorStatus(err), models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: order})
+}
@@ -2,8 +2,11 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strconv"
+	"warehouse/capacity"
+	"warehouse/fx"
 	"warehouse/models"
 	"warehouse/repo"
 
@@ -11,6 +14,7 @@ import (
 )
 
 var warehouseRepo = repo.NewWarehouseRepo()
+var rentRateRepo = repo.NewRentRateRepo()
 
 func CreateWarehouse(c *gin.Context) {
 	var wh models.Warehouse
@@ -21,7 +25,11 @@ func CreateWarehouse(c *gin.Context) {
 
 	id, err := warehouseRepo.Create(context.Background(), &wh)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: err.Error()})
+		status := http.StatusInternalServerError
+		if errors.Is(err, fx.ErrInvalidCurrency) || errors.Is(err, capacity.ErrUnknownUnit) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, models.APIResponse{Success: false, Message: err.Error()})
 		return
 	}
 
@@ -67,7 +75,11 @@ func UpdateWarehouse(c *gin.Context) {
 	update.ID = uint(id)
 	err = warehouseRepo.Update(context.Background(), &update)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: err.Error()})
+		status := http.StatusInternalServerError
+		if errors.Is(err, fx.ErrInvalidCurrency) || errors.Is(err, capacity.ErrUnknownUnit) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, models.APIResponse{Success: false, Message: err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "Warehouse updated"})
@@ -87,3 +99,42 @@ func DeleteWarehouse(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, models.APIResponse{Success: true, Message: "Warehouse deleted"})
 }
+
+// GetRentRatesHandler lists a warehouse's effective-dated rent history
+func GetRentRatesHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	versions, err := rentRateRepo.GetVersions(context.Background(), uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.APIResponse{Success: true, Data: versions})
+}
+
+// AddRentRateHandler schedules a rent change from effective_from (default now)
+func AddRentRateHandler(c *gin.Context) {
+	userId, ok := userIDFromToken(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	var input models.RentRateVersionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	version, err := rentRateRepo.AddVersion(context.Background(), uint(id), userId, input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{Success: false, Message: err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, models.APIResponse{Success: true, Data: version})
+}
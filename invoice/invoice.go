@@ -0,0 +1,369 @@
+// Package invoice renders printable bill invoices as PDF in pure Go. Layout is fixed;
+// the wording, header and footer blocks, colours and optional columns come from a
+// per-warehouse Template whose text fields are Go text/templates over Document.
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/shopspring/decimal"
+)
+
+// Template is a warehouse's invoice customisation
+type Template struct {
+	Title           string // e.g. "INVOICE"
+	Header          string // text/template, rendered under the title
+	Footer          string // text/template, printed at the bottom of every page
+	Terms           string // text/template, printed after the totals
+	AccentColor     string // #RRGGBB for title and table header
+	ShowStorageCost bool
+	ShowBatch       bool
+	ShowExpenses    bool
+}
+
+// DefaultTemplate is used when a warehouse has not customised its invoice
+var DefaultTemplate = Template{
+	Title:           "INVOICE",
+	Header:          "{{.Warehouse.Name}}\n{{.Warehouse.Location}}{{if .Warehouse.GSTIN}}\nGSTIN: {{.Warehouse.GSTIN}}{{end}}",
+	Footer:          "{{.Warehouse.Name}} - Invoice {{.Number}}",
+	Terms:           "Payment due by {{date .DueDate}}. Please quote the invoice number with your payment.",
+	AccentColor:     "#1F4E79",
+	ShowStorageCost: true,
+	ShowBatch:       false,
+	ShowExpenses:    true,
+}
+
+type Party struct {
+	Name     string
+	Location string
+	Contact  string
+	Email    string
+	Phone    string
+	GSTIN    string
+}
+
+type Line struct {
+	Description string
+	BatchID     uint
+	Quantity    int
+	UnitPrice   decimal.Decimal
+	Amount      decimal.Decimal // taxable value
+	StorageCost decimal.Decimal
+	HSNCode     string
+	TaxRate     float64 // percent
+}
+
+type Expense struct {
+	Type   string
+	Notes  string
+	Amount decimal.Decimal
+}
+
+// Document is everything printed on one invoice
+type Document struct {
+	Number     string
+	Date       time.Time
+	DueDate    *time.Time
+	Status     string
+	Currency   string
+	Warehouse  Party
+	Customer   Party
+	Lines      []Line
+	Expenses   []Expense
+	Subtotal   decimal.Decimal // taxable value
+	CGST       decimal.Decimal
+	SGST       decimal.Decimal
+	IGST       decimal.Decimal
+	Tax        decimal.Decimal
+	Total      decimal.Decimal
+	Credited   decimal.Decimal
+	Paid       decimal.Decimal
+	BalanceDue decimal.Decimal
+}
+
+var funcs = template.FuncMap{
+	"date": func(t any) string {
+		switch v := t.(type) {
+		case time.Time:
+			return v.Format("02 Jan 2006")
+		case *time.Time:
+			if v != nil {
+				return v.Format("02 Jan 2006")
+			}
+		}
+		return ""
+	},
+	"money": money,
+}
+
+// Validate checks that the template's text blocks parse and render against a sample document
+func Validate(t Template) error {
+	if _, _, _, err := parseColor(t.AccentColor); err != nil {
+		return err
+	}
+	sample := Document{Number: "000001", Date: time.Now(), Currency: "INR"}
+	for name, text := range map[string]string{"header": t.Header, "footer": t.Footer, "terms": t.Terms} {
+		if _, err := execute(name, text, sample); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Render writes doc as a PDF laid out with t
+func Render(w io.Writer, t Template, doc Document) error {
+	header, err := execute("header", t.Header, doc)
+	if err != nil {
+		return err
+	}
+	footer, err := execute("footer", t.Footer, doc)
+	if err != nil {
+		return err
+	}
+	terms, err := execute("terms", t.Terms, doc)
+	if err != nil {
+		return err
+	}
+	r, g, b, err := parseColor(t.AccentColor)
+	if err != nil {
+		return err
+	}
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 20)
+	pdf.AliasNbPages("")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-15)
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.SetTextColor(120, 120, 120)
+		pdf.CellFormat(0, 5, tr(firstLine(footer)), "", 0, "L", false, 0, "")
+		pdf.CellFormat(0, 5, fmt.Sprintf("Page %d/{nb}", pdf.PageNo()), "", 0, "R", false, 0, "")
+	})
+	pdf.AddPage()
+
+	// Title and warehouse header
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.SetTextColor(r, g, b)
+	pdf.CellFormat(0, 10, tr(t.Title), "", 1, "L", false, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.MultiCell(110, 5, tr(header), "", "L", false)
+	pdf.Ln(4)
+
+	// Invoice facts (right) and bill-to (left)
+	top := pdf.GetY()
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(0, 5, "Bill to", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.MultiCell(100, 5, tr(partyBlock(doc.Customer)), "", "L", false)
+	left := pdf.GetY()
+
+	pdf.SetY(top)
+	facts := [][2]string{
+		{"Invoice no.", doc.Number},
+		{"Date", doc.Date.Format("02 Jan 2006")},
+	}
+	if doc.DueDate != nil {
+		facts = append(facts, [2]string{"Due date", doc.DueDate.Format("02 Jan 2006")})
+	}
+	if doc.Status != "" {
+		facts = append(facts, [2]string{"Status", strings.ToUpper(doc.Status)})
+	}
+	for _, f := range facts {
+		pdf.SetX(125)
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(28, 5, f[0], "", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.CellFormat(0, 5, tr(f[1]), "", 1, "R", false, 0, "")
+	}
+	if pdf.GetY() < left {
+		pdf.SetY(left)
+	}
+	pdf.Ln(6)
+
+	// Line items
+	type column struct {
+		title string
+		width float64
+		align string
+		value func(Line) string
+	}
+	cols := []column{
+		{"#", 8, "C", nil},
+		{"Item", 0, "L", func(l Line) string { return l.Description }},
+	}
+	if t.ShowBatch {
+		cols = append(cols, column{"Batch", 16, "C", func(l Line) string { return strconv.FormatUint(uint64(l.BatchID), 10) }})
+	}
+	if doc.hasTaxDetail() {
+		cols = append(cols,
+			column{"HSN/SAC", 18, "C", func(l Line) string { return l.HSNCode }},
+			column{"GST %", 14, "R", func(l Line) string { return strconv.FormatFloat(l.TaxRate, 'f', -1, 64) }},
+		)
+	}
+	cols = append(cols,
+		column{"Qty", 16, "R", func(l Line) string { return strconv.Itoa(l.Quantity) }},
+		column{"Price", 26, "R", func(l Line) string { return money(l.UnitPrice) }},
+	)
+	if t.ShowStorageCost {
+		cols = append(cols, column{"Storage", 24, "R", func(l Line) string { return money(l.StorageCost) }})
+	}
+	cols = append(cols, column{"Amount", 28, "R", func(l Line) string { return money(l.Amount) }})
+
+	pageWidth, _ := pdf.GetPageSize()
+	lm, _, rm, _ := pdf.GetMargins()
+	fixed := 0.0
+	for _, c := range cols {
+		fixed += c.width
+	}
+	for i := range cols {
+		if cols[i].width == 0 {
+			cols[i].width = pageWidth - lm - rm - fixed
+		}
+	}
+
+	pdf.SetFillColor(r, g, b)
+	pdf.SetTextColor(255, 255, 255)
+	pdf.SetFont("Helvetica", "B", 9)
+	for _, c := range cols {
+		pdf.CellFormat(c.width, 7, c.title, "", 0, c.align, true, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.SetTextColor(0, 0, 0)
+	pdf.SetFont("Helvetica", "", 9)
+	for i, line := range doc.Lines {
+		for _, c := range cols {
+			text := strconv.Itoa(i + 1)
+			if c.value != nil {
+				text = c.value(line)
+			}
+			pdf.CellFormat(c.width, 6, tr(text), "B", 0, c.align, false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+	pdf.Ln(4)
+
+	// Expenses recorded at offboarding
+	if t.ShowExpenses && len(doc.Expenses) > 0 {
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(0, 6, "Off-board expenses", "", 1, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 9)
+		for _, e := range doc.Expenses {
+			label := e.Type
+			if e.Notes != "" {
+				label += " - " + e.Notes
+			}
+			pdf.CellFormat(150, 5, tr(label), "", 0, "L", false, 0, "")
+			pdf.CellFormat(0, 5, money(e.Amount), "", 1, "R", false, 0, "")
+		}
+		pdf.Ln(4)
+	}
+
+	// Totals, with the GST split when the bill carries tax
+	totals := [][2]string{{"Taxable value", money(doc.Subtotal)}}
+	switch {
+	case !doc.IGST.IsZero():
+		totals = append(totals, [2]string{"IGST", money(doc.IGST)})
+	case !doc.CGST.IsZero() || !doc.SGST.IsZero():
+		totals = append(totals, [2]string{"CGST", money(doc.CGST)}, [2]string{"SGST", money(doc.SGST)})
+	default:
+		totals = append(totals, [2]string{"Tax", money(doc.Tax)})
+	}
+	totalRow := len(totals)
+	totals = append(totals, [2]string{"Invoice total (" + doc.Currency + ")", money(doc.Total)})
+	if doc.Credited.IsPositive() {
+		totals = append(totals, [2]string{"Less credit notes", "-" + money(doc.Credited)})
+	}
+	if doc.Paid.IsPositive() {
+		totals = append(totals, [2]string{"Less payments", "-" + money(doc.Paid)})
+	}
+	totals = append(totals, [2]string{"Balance due (" + doc.Currency + ")", money(doc.BalanceDue)})
+	for i, row := range totals {
+		style := ""
+		if i == totalRow || i == len(totals)-1 {
+			style = "B"
+		}
+		pdf.SetFont("Helvetica", style, 10)
+		pdf.SetX(105)
+		pdf.CellFormat(55, 6, tr(row[0]), "", 0, "R", false, 0, "")
+		pdf.CellFormat(0, 6, row[1], "", 1, "R", false, 0, "")
+	}
+
+	if terms != "" {
+		pdf.Ln(8)
+		pdf.SetFont("Helvetica", "", 9)
+		pdf.MultiCell(0, 5, tr(terms), "", "L", false)
+	}
+
+	if err := pdf.Error(); err != nil {
+		return fmt.Errorf("failed to render invoice: %w", err)
+	}
+	return pdf.Output(w)
+}
+
+func execute(name, text string, doc Document) (string, error) {
+	tpl, err := template.New(name).Funcs(funcs).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid invoice %s template: %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, doc); err != nil {
+		return "", fmt.Errorf("invalid invoice %s template: %w", name, err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+func parseColor(hex string) (int, int, int, error) {
+	h := strings.TrimPrefix(strings.TrimSpace(hex), "#")
+	if len(h) != 6 {
+		return 0, 0, 0, fmt.Errorf("invalid accent colour %q, expected #RRGGBB", hex)
+	}
+	v, err := strconv.ParseUint(h, 16, 32)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid accent colour %q, expected #RRGGBB", hex)
+	}
+	return int(v >> 16 & 0xff), int(v >> 8 & 0xff), int(v & 0xff), nil
+}
+
+func partyBlock(p Party) string {
+	lines := []string{p.Name}
+	for _, v := range []string{p.Contact, p.Location, p.Phone, p.Email} {
+		if v != "" {
+			lines = append(lines, v)
+		}
+	}
+	if p.GSTIN != "" {
+		lines = append(lines, "GSTIN: "+p.GSTIN)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// hasTaxDetail reports whether the HSN and GST rate columns are worth printing
+func (d Document) hasTaxDetail() bool {
+	for _, l := range d.Lines {
+		if l.HSNCode != "" || l.TaxRate != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+func money(v decimal.Decimal) string {
+	return v.StringFixed(2)
+}
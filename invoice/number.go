@@ -0,0 +1,53 @@
+package invoice
+
+import (
+	"fmt"
+	"time"
+)
+
+// Numbering is a warehouse's invoice number format
+type Numbering struct {
+	Prefix       string // e.g. "INV/"
+	Padding      int    // minimum digits of the sequence, zero padded
+	ResetEachFY  bool   // restart the sequence at 1 every financial year
+	FYStartMonth int    // first month of the financial year (4 = April)
+}
+
+// DefaultNumbering is used when a warehouse has not configured its invoice numbers:
+// INV/2026-27/00001, restarting every April.
+var DefaultNumbering = Numbering{
+	Prefix:       "INV/",
+	Padding:      5,
+	ResetEachFY:  true,
+	FYStartMonth: 4,
+}
+
+// FinancialYear labels the financial year t falls in, e.g. "2026-27" for a year
+// starting in April, or "2026" for a calendar financial year
+func FinancialYear(t time.Time, startMonth int) string {
+	if startMonth <= 1 || startMonth > 12 {
+		return fmt.Sprintf("%d", t.Year())
+	}
+	start := t.Year()
+	if int(t.Month()) < startMonth {
+		start--
+	}
+	return fmt.Sprintf("%d-%02d", start, (start+1)%100)
+}
+
+// Period is the sequence a number issued at t belongs to: the financial year when
+// numbers reset yearly, otherwise a single running sequence ("")
+func (n Numbering) Period(t time.Time) string {
+	if !n.ResetEachFY {
+		return ""
+	}
+	return FinancialYear(t, n.FYStartMonth)
+}
+
+// Format builds the invoice number for sequence seq of period
+func (n Numbering) Format(period string, seq int64) string {
+	if period == "" {
+		return fmt.Sprintf("%s%0*d", n.Prefix, n.Padding, seq)
+	}
+	return fmt.Sprintf("%s%s/%0*d", n.Prefix, period, n.Padding, seq)
+}
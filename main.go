@@ -11,6 +11,7 @@ import (
 	"warehouse/config"
 	dbconn "warehouse/config/dbConn"
 	"warehouse/helper"
+	"warehouse/middleware"
 	routes "warehouse/routers"
 
 	"github.com/gin-contrib/cors"
@@ -35,8 +36,8 @@ func main() {
 			return true // allow all origins (for dev)
 		},
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "Idempotency-Key"},
+		ExposeHeaders:    []string{"Content-Length", "Idempotent-Replayed"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -47,6 +48,9 @@ func main() {
 	// ✅ Start health check goroutine
 	go helper.StartHealthPing(config.Cfg.BaseUrl, 30*time.Second)
 
+	// ✅ Purge expired idempotency keys
+	go middleware.PurgeIdempotencyKeys(time.Hour)
+
 	// ✅ Create HTTP server
 	srv := &http.Server{
 		Addr:    ":" + config.Cfg.Port,
@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"warehouse/config"
+	"warehouse/models"
+	"warehouse/repo"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultIdempotencyTTL is used when IDEMPOTENCY_KEY_TTL is unset or invalid
+const defaultIdempotencyTTL = 24 * time.Hour
+
+// idempotencyStore claims keys and stores their responses; repo.IdempotencyRepo in
+// production
+type idempotencyStore interface {
+	Begin(ctx context.Context, userID uint, key, method, path, hash string, ttl time.Duration) (*models.IdempotencyKey, error)
+	Complete(ctx context.Context, userID uint, key string, status int, contentType string, body []byte) error
+	Release(ctx context.Context, userID uint, key string) error
+}
+
+// idempotencyRecorder keeps a copy of the response written by the handler
+type idempotencyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *idempotencyRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *idempotencyRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency makes a create endpoint safe to retry. A request carrying an
+// Idempotency-Key header runs once; repeating it with the same body replays the stored
+// response, and reusing the key with a different body is rejected. Server errors are
+// not stored, nor are panics, so those requests can be retried. Requests without the
+// header run as usual.
+func Idempotency() gin.HandlerFunc {
+	return idempotency(repo.NewIdempotencyRepo(), idempotencyTTL())
+}
+
+func idempotency(keys idempotencyStore, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := strings.TrimSpace(c.GetHeader("Idempotency-Key"))
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > 255 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"success": false, "message": "Idempotency-Key must be at most 255 characters"})
+			return
+		}
+		userID, ok := c.Get("user_id")
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"success": false, "message": "user_id not found in token"})
+			return
+		}
+		uid := userID.(uint)
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"success": false, "message": "failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		sum := sha256.New()
+		sum.Write([]byte(c.Request.Method + " " + c.Request.URL.Path + "\n"))
+		sum.Write(body)
+		hash := hex.EncodeToString(sum.Sum(nil))
+
+		// The key is settled even when the client has gone away
+		ctx := context.WithoutCancel(c.Request.Context())
+		stored, err := keys.Begin(ctx, uid, key, c.Request.Method, c.Request.URL.Path, hash, ttl)
+		switch {
+		case errors.Is(err, repo.ErrIdempotencyKeyReused):
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"success": false, "message": err.Error()})
+			return
+		case errors.Is(err, repo.ErrIdempotencyKeyInFlight):
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"success": false, "message": err.Error()})
+			return
+		case err != nil:
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+
+		// 🔁 Same request again: replay what the first one answered
+		if stored != nil {
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(stored.ResponseStatus, stored.ResponseType, stored.ResponseBody)
+			c.Abort()
+			return
+		}
+
+		release := func() {
+			if err := keys.Release(ctx, uid, key); err != nil {
+				log.Printf("⚠️ %v", err)
+			}
+		}
+		// A panicking handler gives the key back before Recovery answers with a 500
+		defer func() {
+			if p := recover(); p != nil {
+				release()
+				panic(p)
+			}
+		}()
+
+		rec := &idempotencyRecorder{ResponseWriter: c.Writer}
+		c.Writer = rec
+		c.Next()
+
+		if rec.Status() >= http.StatusInternalServerError {
+			release()
+			return
+		}
+		if err := keys.Complete(ctx, uid, key, rec.Status(), rec.Header().Get("Content-Type"), rec.body.Bytes()); err != nil {
+			log.Printf("⚠️ %v", err)
+		}
+	}
+}
+
+// idempotencyTTL is how long keys are kept, from IDEMPOTENCY_KEY_TTL (e.g. "24h")
+func idempotencyTTL() time.Duration {
+	v := strings.TrimSpace(config.Cfg.IdempotencyKeyTTL)
+	if v == "" {
+		return defaultIdempotencyTTL
+	}
+	ttl, err := time.ParseDuration(v)
+	if err != nil || ttl <= 0 {
+		log.Printf("⚠️ invalid IDEMPOTENCY_KEY_TTL %q, using %s", v, defaultIdempotencyTTL)
+		return defaultIdempotencyTTL
+	}
+	return ttl
+}
+
+// PurgeIdempotencyKeys deletes expired idempotency keys every interval
+func PurgeIdempotencyKeys(interval time.Duration) {
+	keys := repo.NewIdempotencyRepo()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		purged, err := keys.PurgeExpired(context.Background())
+		if err != nil {
+			log.Printf("⚠️ %v", err)
+			continue
+		}
+		if purged > 0 {
+			log.Printf("🧹 Purged %d expired idempotency keys", purged)
+		}
+	}
+}
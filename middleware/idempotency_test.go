@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"warehouse/models"
+	"warehouse/repo"
+
+	"github.com/gin-gonic/gin"
+)
+
+// memoryKeys is an in-memory idempotencyStore with the repo's claim rules
+type memoryKeys struct {
+	mu   sync.Mutex
+	keys map[string]*models.IdempotencyKey
+}
+
+func (m *memoryKeys) Begin(_ context.Context, userID uint, key, method, path, hash string, _ time.Duration) (*models.IdempotencyKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.keys[key]
+	if !ok {
+		m.keys[key] = &models.IdempotencyKey{UserID: userID, Key: key, Method: method, Path: path, RequestHash: hash, Status: models.IdempotencyProcessing}
+		return nil, nil
+	}
+	if existing.RequestHash != hash {
+		return nil, repo.ErrIdempotencyKeyReused
+	}
+	if existing.Status != models.IdempotencyCompleted {
+		return nil, repo.ErrIdempotencyKeyInFlight
+	}
+	return existing, nil
+}
+
+func (m *memoryKeys) Complete(_ context.Context, _ uint, key string, status int, contentType string, body []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	k := m.keys[key]
+	k.Status, k.ResponseStatus, k.ResponseType, k.ResponseBody = models.IdempotencyCompleted, status, contentType, body
+	return nil
+}
+
+func (m *memoryKeys) Release(_ context.Context, _ uint, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if k, ok := m.keys[key]; ok && k.Status == models.IdempotencyProcessing {
+		delete(m.keys, key)
+	}
+	return nil
+}
+
+// idempotentRouter serves POST /batches through the middleware as user 1
+func idempotentRouter(keys idempotencyStore, handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.Use(func(c *gin.Context) {
+		c.Set("user_id", uint(1))
+		c.Next()
+	})
+	r.POST("/batches", idempotency(keys, time.Hour), handler)
+	return r
+}
+
+func post(r http.Handler, key, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/batches", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", key)
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyReplaysTheFirstResponse(t *testing.T) {
+	calls := 0
+	r := idempotentRouter(&memoryKeys{keys: map[string]*models.IdempotencyKey{}}, func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusOK, gin.H{"success": true, "batch_id": calls})
+	})
+
+	first := post(r, "k1", `{"products": []}`)
+	again := post(r, "k1", `{"products": []}`)
+
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, want 1", calls)
+	}
+	if again.Code != first.Code || again.Body.String() != first.Body.String() {
+		t.Errorf("replay = %d %s, want %d %s", again.Code, again.Body, first.Code, first.Body)
+	}
+	if again.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("replay is not marked with Idempotent-Replayed")
+	}
+}
+
+func TestIdempotencyRejectsAKeyReusedWithAnotherBody(t *testing.T) {
+	calls := 0
+	r := idempotentRouter(&memoryKeys{keys: map[string]*models.IdempotencyKey{}}, func(c *gin.Context) {
+		calls++
+		c.JSON(http.StatusOK, gin.H{"success": true})
+	})
+
+	post(r, "k1", `{"products": [1]}`)
+	w := post(r, "k1", `{"products": [2]}`)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+	if calls != 1 {
+		t.Errorf("handler ran %d times, want 1", calls)
+	}
+}
+
+func TestIdempotencyReleasesTheKeyOnServerErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		failure gin.HandlerFunc
+	}{
+		{"5xx response", func(c *gin.Context) {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false})
+		}},
+		{"panic", func(*gin.Context) {
+			panic("handler crashed")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := &memoryKeys{keys: map[string]*models.IdempotencyKey{}}
+			calls := 0
+			r := idempotentRouter(keys, func(c *gin.Context) {
+				calls++
+				if calls == 1 {
+					tt.failure(c)
+					return
+				}
+				c.JSON(http.StatusOK, gin.H{"success": true})
+			})
+
+			if w := post(r, "k1", `{}`); w.Code != http.StatusInternalServerError {
+				t.Fatalf("first attempt = %d, want %d", w.Code, http.StatusInternalServerError)
+			}
+			if w := post(r, "k1", `{}`); w.Code != http.StatusOK {
+				t.Errorf("retry = %d, want %d (the key was not released)", w.Code, http.StatusOK)
+			}
+			if calls != 2 {
+				t.Errorf("handler ran %d times, want 2", calls)
+			}
+		})
+	}
+}
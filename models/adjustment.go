@@ -0,0 +1,91 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// Stock adjustment statuses
+const (
+	AdjustmentCounting        = "counting"
+	AdjustmentPendingApproval = "pending_approval"
+	AdjustmentApproved        = "approved"
+	AdjustmentRejected        = "rejected"
+	AdjustmentPosted          = "posted"
+)
+
+// StockAdjustment is a cycle-count sheet for a warehouse (or a single batch in it).
+// It moves counting → pending_approval → approved → posted, or is rejected by an admin.
+type StockAdjustment struct {
+	ID             uint                  `gorm:"primaryKey;autoIncrement" json:"id"`
+	WarehouseID    uint                  `gorm:"not null;index" json:"warehouse_id"`
+	BatchID        *uint                 `gorm:"index" json:"batch_id,omitempty"` // nil = whole warehouse
+	Status         string                `gorm:"type:varchar(30);not null;index" json:"status"`
+	Reason         string                `gorm:"type:varchar(255)" json:"reason"`
+	Notes          string                `gorm:"type:text" json:"notes"`
+	Lines          []StockAdjustmentLine `gorm:"foreignKey:AdjustmentID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"lines"`
+	GainQty        int                   `gorm:"not null;default:0" json:"gain_qty"`
+	ShrinkageQty   int                   `gorm:"not null;default:0" json:"shrinkage_qty"`
+	WriteOffAmount decimal.Decimal       `gorm:"type:decimal(12,2);not null;default:0" json:"write_off_amount"`
+	CreatedBy      uint                  `gorm:"index" json:"created_by"`
+	ApprovedBy     *uint                 `json:"approved_by,omitempty"`
+	ApprovedAt     *time.Time            `json:"approved_at,omitempty"`
+	PostedBy       *uint                 `json:"posted_by,omitempty"`
+	PostedAt       *time.Time            `json:"posted_at,omitempty"`
+	CreatedAt      time.Time             `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time             `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt      gorm.DeletedAt        `gorm:"index" json:"-"`
+}
+
+// StockAdjustmentLine holds the system snapshot and the physical count of one batch entry
+type StockAdjustmentLine struct {
+	ID            uint            `gorm:"primaryKey;autoIncrement" json:"id"`
+	AdjustmentID  uint            `gorm:"not null;index" json:"adjustment_id"`
+	EntryID       uint            `gorm:"not null;index" json:"entry_id"`
+	BatchID       uint            `gorm:"not null;index" json:"batch_id"`
+	ProductID     uint            `gorm:"not null;index" json:"product_id"`
+	SystemQty     int             `gorm:"not null" json:"system_qty"`
+	CountedQty    *int            `json:"counted_qty"` // nil until counted
+	VarianceQty   int             `gorm:"not null;default:0" json:"variance_qty"`
+	UnitCost      decimal.Decimal `gorm:"type:decimal(12,4);not null;default:0" json:"unit_cost"` // purchase price + intake cost
+	VarianceValue decimal.Decimal `gorm:"type:decimal(12,2);not null;default:0" json:"variance_value"`
+	Reason        string          `gorm:"type:varchar(50)" json:"reason"` // damaged, missing, found, ...
+	CreatedAt     time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// StockWriteOff books the cost of units lost in a posted adjustment as an expense
+type StockWriteOff struct {
+	ID           uint            `gorm:"primaryKey;autoIncrement" json:"id"`
+	AdjustmentID uint            `gorm:"not null;index" json:"adjustment_id"`
+	WarehouseID  uint            `gorm:"not null;index" json:"warehouse_id"`
+	BatchID      uint            `gorm:"not null;index" json:"batch_id"`
+	ProductID    uint            `gorm:"not null;index" json:"product_id"`
+	Quantity     int             `gorm:"not null" json:"quantity"`
+	Amount       decimal.Decimal `gorm:"type:decimal(12,2);not null" json:"amount"`
+	Reason       string          `gorm:"type:varchar(50)" json:"reason"`
+	CreatedAt    time.Time       `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// StockAdjustmentInput opens a count sheet; BatchID limits it to one batch
+type StockAdjustmentInput struct {
+	BatchID *uint  `json:"batch_id"`
+	Reason  string `json:"reason"`
+	Notes   string `json:"notes"`
+}
+
+type StockCountInput struct {
+	LineID     uint   `json:"line_id" binding:"required"`
+	CountedQty int    `json:"counted_qty" binding:"min=0"`
+	Reason     string `json:"reason"`
+}
+
+type StockCountSheetInput struct {
+	Lines []StockCountInput `json:"lines" binding:"required,dive"`
+}
+
+type StockAdjustmentDecisionInput struct {
+	Notes string `json:"notes"`
+}
@@ -1,6 +1,11 @@
 package models
 
+import "github.com/shopspring/decimal"
+
+// ProductAnalytics amounts are in Currency, the warehouse's reporting currency. Each
+// document is converted at the rate it was booked at.
 type ProductAnalytics struct {
+	Currency       string            `json:"currency"`
 	TotalAmounts   TotalAmounts      `json:"total_amounts"`
 	GodownData     GodownData        `json:"godown_data"`
 	ProductsData   []ProductWiseData `json:"products_data"`
@@ -13,12 +18,16 @@ type ProductCount struct {
 }
 
 type TotalAmounts struct {
-	OnBoardingAmount  float64 `json:"on_boarding_amount"`
-	OffBoardingAmount float64 `json:"off_boarding_amount"`
-	InStockAmount     float64 `json:"in_stock_amount"`
-	ProfitAmount      float64 `json:"profit_amount"`
-	NetProfitAmount   float64 `json:"net_profit_amount"`
-	ExpenseAmount     float64 `json:"expense_amount"`
+	OnBoardingAmount  decimal.Decimal `json:"on_boarding_amount"`
+	OffBoardingAmount decimal.Decimal `json:"off_boarding_amount"`
+	InStockAmount     decimal.Decimal `json:"in_stock_amount"`
+	ProfitAmount      decimal.Decimal `json:"profit_amount"`
+	NetProfitAmount   decimal.Decimal `json:"net_profit_amount"`
+	ExpenseAmount     decimal.Decimal `json:"expense_amount"`             // includes write-offs; stock reports add the intake cost of every unit received
+	SoldIntakeAmount  decimal.Decimal `json:"sold_intake_expense_amount"` // intake cost of the units sold, the part the profit figures deduct
+	WriteOffAmount    decimal.Decimal `json:"write_off_amount"`           // stock shrinkage from posted adjustments
+	FXGainAmount      decimal.Decimal `json:"fx_gain_amount"`             // realised on payments, kept out of profit
+	FXLossAmount      decimal.Decimal `json:"fx_loss_amount"`             // positive
 }
 
 type GodownData struct {
@@ -43,6 +52,7 @@ type ProductWiseData struct {
 	IsFastMoving bool                `json:"is_fast_moving"`
 }
 type ProductWiseAnalyticsData struct {
+	Currency     string              `json:"currency"`
 	ProductInfo  ProductData         `json:"product_info"`
 	Amounts      TotalProductAmounts `json:"amounts"`
 	Stock        Stock               `json:"stock"`
@@ -50,11 +60,12 @@ type ProductWiseAnalyticsData struct {
 }
 
 type TotalProductAmounts struct {
-	ProductOnBoardingAmount  float64 `json:"product_on_boarding_amount"`
-	ProductOffBoardingAmount float64 `json:"product_off_boarding_amount"`
-	ProductInStockAmount     float64 `json:"product_in_stock_amount"`
-	ProductProfitAmount      float64 `json:"product_profit_amount"`
-	ProductNetProfitAmount   float64 `json:"product_net_profit_amount"`
-	ProductExpenseAmount     float64 `json:"product_expense_amount"`
-	RentPerSpace             float64 `json:"rent_per_space"`
+	ProductOnBoardingAmount  decimal.Decimal `json:"product_on_boarding_amount"`
+	ProductOffBoardingAmount decimal.Decimal `json:"product_off_boarding_amount"`
+	ProductInStockAmount     decimal.Decimal `json:"product_in_stock_amount"`
+	ProductProfitAmount      decimal.Decimal `json:"product_profit_amount"`
+	ProductNetProfitAmount   decimal.Decimal `json:"product_net_profit_amount"`
+	ProductExpenseAmount     decimal.Decimal `json:"product_expense_amount"`
+	ProductWriteOffAmount    decimal.Decimal `json:"product_write_off_amount"`
+	RentPerSpace             decimal.Decimal `json:"rent_per_space"`
 }
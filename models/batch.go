@@ -3,43 +3,58 @@ package models
 import (
 	"time"
 
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
 type Batch struct {
-	ID          uint                `gorm:"primaryKey;autoIncrement" json:"id"`
-	WarehouseID uint                `gorm:"not null;index" json:"warehouse_id"`
-	Warehouse   Warehouse           `gorm:"foreignKey:WarehouseID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"warehouse"`
-	StoredAt    time.Time           `gorm:"autoCreateTime" json:"stored_at"`
-	Status      string              `gorm:"type:varchar(50)" json:"status"`
-	Products    []BatchProductEntry `gorm:"foreignKey:BatchID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"products"`
-	CreatedAt   time.Time           `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt   time.Time           `gorm:"autoUpdateTime" json:"updated_at"`
-	DeletedAt   gorm.DeletedAt      `gorm:"index" json:"-"`
+	ID           uint                `gorm:"primaryKey;autoIncrement" json:"id"`
+	WarehouseID  uint                `gorm:"not null;index" json:"warehouse_id"`
+	Warehouse    Warehouse           `gorm:"foreignKey:WarehouseID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"warehouse"`
+	StoredAt     time.Time           `gorm:"autoCreateTime" json:"stored_at"`
+	Status       string              `gorm:"type:varchar(50)" json:"status"`
+	Currency     string              `gorm:"type:varchar(3)" json:"currency"`                            // of purchase prices and onboarding expenses (default: the warehouse's reporting currency)
+	ExchangeRate decimal.Decimal     `gorm:"type:decimal(18,8);not null;default:1" json:"exchange_rate"` // to the warehouse's reporting currency on StoredAt
+	Products     []BatchProductEntry `gorm:"foreignKey:BatchID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"products"`
+	Expenses     []Expense           `gorm:"-" json:"expenses"` // onboarding expenses, persisted to OnBoardExpense
+	CreatedAt    time.Time           `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time           `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt    gorm.DeletedAt      `gorm:"index" json:"-"`
 }
 
 type BatchProductEntry struct {
-	ID             uint       `gorm:"primaryKey;autoIncrement" json:"id"`
-	BatchID        uint       `gorm:"not null;index" json:"batch_id"`
-	ProductID      uint       `gorm:"not null;index" json:"product_id"`
-	Product        Product    `gorm:"foreignKey:ProductID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"product"`
-	BillingPrice   float64    `gorm:"type:decimal(10,2);not null" json:"billing_price"`
-	SellingPrice   float64    `gorm:"type:decimal(10,2)" json:"selling_price"`
-	Quantity       int        `gorm:"not null" json:"quantity"`
-	StockQuantity  int        `gorm:"not null" json:"stock_quantity"`
-	CreatedAt      time.Time  `gorm:"autoCreateTime" json:"created_at"`
-	LastOffboarded *time.Time `json:"last_offboarded,omitempty"`
-	LastUpdated    *time.Time `gorm:"autoUpdateTime" json:"last_updated,omitempty"`
+	ID             uint            `gorm:"primaryKey;autoIncrement" json:"id"`
+	BatchID        uint            `gorm:"not null;index" json:"batch_id"`
+	ProductID      uint            `gorm:"not null;index" json:"product_id"`
+	Product        Product         `gorm:"foreignKey:ProductID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"product"`
+	BillingPrice   decimal.Decimal `gorm:"type:decimal(10,2);not null" json:"billing_price"`
+	SellingPrice   decimal.Decimal `gorm:"type:decimal(10,2)" json:"selling_price"`
+	Quantity       int             `gorm:"not null" json:"quantity"`
+	StockQuantity  int             `gorm:"not null" json:"stock_quantity"`
+	OnBoardCost    decimal.Decimal `gorm:"type:decimal(12,4);not null;default:0" json:"onboard_cost_per_unit"` // allocated intake expense per unit
+	RentBilledTo   *time.Time      `json:"rent_billed_to,omitempty"`                                           // rent invoiced for days before this date
+	RentMinimumMet bool            `gorm:"not null;default:false" json:"rent_minimum_met"`                     // an earlier offboarding settled the stay's minimum rent
+	LotNumber      string          `gorm:"type:varchar(100);index" json:"lot_number,omitempty"`
+	ManufacturedAt *time.Time      `json:"manufactured_at,omitempty"`
+	ExpiresAt      *time.Time      `gorm:"index" json:"expires_at,omitempty"` // expired stock cannot be billed
+	Putaway        []Putaway       `gorm:"-" json:"putaway,omitempty"`        // bins for the units; the rest are placed automatically
+	CreatedAt      time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	LastOffboarded *time.Time      `json:"last_offboarded,omitempty"`
+	LastUpdated    *time.Time      `gorm:"autoUpdateTime" json:"last_updated,omitempty"`
 }
 type BatchProductCoreData struct {
-	ProductID      uint        `json:"product_id"`
-	Product        ProductCore `json:"product"`
-	BillingPrice   float64     ` json:"billing_price"`
-	Quantity       int         `json:"quantity"`
-	StockQuantity  int         ` json:"stock_quantity"`
-	CreatedAt      time.Time   `json:"created_at"`
-	LastOffboarded *time.Time  `json:"last_offboarded,omitempty"`
-	LastUpdated    *time.Time  ` json:"last_updated,omitempty"`
+	ProductID      uint            `json:"product_id"`
+	Product        ProductCore     `json:"product"`
+	BillingPrice   decimal.Decimal ` json:"billing_price"`
+	Quantity       int             `json:"quantity"`
+	StockQuantity  int             ` json:"stock_quantity"`
+	OnBoardCost    decimal.Decimal `json:"onboard_cost_per_unit"`
+	LotNumber      string          `json:"lot_number,omitempty"`
+	ManufacturedAt *time.Time      `json:"manufactured_at,omitempty"`
+	ExpiresAt      *time.Time      `json:"expires_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	LastOffboarded *time.Time      `json:"last_offboarded,omitempty"`
+	LastUpdated    *time.Time      ` json:"last_updated,omitempty"`
 }
 
 type ProductCore struct {
@@ -52,16 +67,18 @@ type ProductCore struct {
 	UpdatedAt   time.Time ` json:"updated_at"`
 }
 type BatchCoreData struct {
-	ID               uint      `json:"id"`
-	WarehouseID      uint      `json:"warehouse_id"`
-	StoredAt         time.Time ` json:"stored_at"`
-	Status           string    `json:"status"`
-	CreatedAt        time.Time `json:"created_at"`
-	UpdatedAt        time.Time `json:"updated_at"`
-	BatchStock       int       `json:"batch_stock"`
-	AvailableStock   int       `json:"available_stock"`
-	OffBoardedAmount float64   `json:"off_boarded_amount"`
-	OnBoardedAmount  float64   `json:"on_boarded_amount"`
+	ID               uint            `json:"id"`
+	WarehouseID      uint            `json:"warehouse_id"`
+	StoredAt         time.Time       ` json:"stored_at"`
+	Status           string          `json:"status"`
+	Currency         string          `json:"currency"`
+	ExchangeRate     decimal.Decimal `json:"exchange_rate"`
+	CreatedAt        time.Time       `json:"created_at"`
+	UpdatedAt        time.Time       `json:"updated_at"`
+	BatchStock       int             `json:"batch_stock"`
+	AvailableStock   int             `json:"available_stock"`
+	OffBoardedAmount decimal.Decimal `json:"off_boarded_amount"`
+	OnBoardedAmount  decimal.Decimal `json:"on_boarded_amount"`
 }
 type BatchCoreDataWithProducts struct {
 	ID               uint                   `json:"id"`
@@ -69,10 +86,14 @@ type BatchCoreDataWithProducts struct {
 	Product          []BatchProductCoreData ` json:"product_data"`
 	StoredAt         time.Time              ` json:"stored_at"`
 	Status           string                 `json:"status"`
+	Currency         string                 `json:"currency"`
+	ExchangeRate     decimal.Decimal        `json:"exchange_rate"`
 	CreatedAt        time.Time              `json:"created_at"`
 	UpdatedAt        time.Time              `json:"updated_at"`
 	BatchStock       int                    `json:"batch_stock"`
 	AvailableStock   int                    `json:"available_stock"`
-	OffBoardedAmount float64                `json:"off_boarded_amount"`
-	OnBoardedAmount  float64                `json:"on_boarded_amount"`
+	OffBoardedAmount decimal.Decimal        `json:"off_boarded_amount"`
+	OnBoardedAmount  decimal.Decimal        `json:"on_boarded_amount"`
+	OnBoardExpense   decimal.Decimal        `json:"onboard_expense_amount"`
+	Expenses         []Expense              `json:"expenses"`
 }
@@ -3,38 +3,66 @@ package models
 import (
 	"time"
 
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
 type Billing struct {
-	ID            uint           `gorm:"primaryKey;autoIncrement" json:"id"`
-	Items         []BillingItem  `gorm:"foreignKey:BillingID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"items"`
-	TotalRent     float64        `gorm:"type:decimal(12,2)" json:"total_rent"`
-	TotalStorage  float64        `gorm:"type:decimal(12,2)" json:"total_storage"`
-	TotalBuying   float64        `gorm:"type:decimal(12,2)" json:"total_buying"`
-	TotalSelling  float64        `gorm:"type:decimal(12,2)" json:"total_selling"`
-	OtherExpenses float64        `gorm:"type:decimal(12,2)" json:"other_expenses"`
-	Margin        float64        `gorm:"type:decimal(12,2)" json:"margin"`
-	CreatedAt     time.Time      `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt     time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
-	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+	ID             uint            `gorm:"primaryKey;autoIncrement" json:"id"`
+	WarehouseID    uint            `gorm:"index;uniqueIndex:idx_billing_invoice_number,priority:1" json:"warehouse_id"`              // godown the stock was offboarded from
+	InvoiceNumber  string          `gorm:"type:varchar(50);uniqueIndex:idx_billing_invoice_number,priority:2" json:"invoice_number"` // legal number, sequential per warehouse
+	CustomerID     uint            `gorm:"index" json:"customer_id"`                                                                 // who the goods were sold or released to
+	Items          []BillingItem   `gorm:"foreignKey:BillingID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"items"`
+	TotalRent      decimal.Decimal `gorm:"type:decimal(12,2)" json:"total_rent"`
+	TotalStorage   float64         `gorm:"type:decimal(12,2)" json:"total_storage"`
+	TotalBuying    decimal.Decimal `gorm:"type:decimal(12,2)" json:"total_buying"`
+	TotalSelling   decimal.Decimal `gorm:"type:decimal(12,2)" json:"total_selling"`
+	OtherExpenses  decimal.Decimal `gorm:"type:decimal(12,2)" json:"other_expenses"`
+	Margin         decimal.Decimal `gorm:"type:decimal(12,2)" json:"margin"`
+	CreditedAmount decimal.Decimal `gorm:"type:decimal(12,2);not null;default:0" json:"credited_amount"` // invoice value (incl. tax) reversed by credit notes
+	PaidAmount     decimal.Decimal `gorm:"type:decimal(12,2);not null;default:0" json:"paid_amount"`     // payments allocated to the bill
+	DueDate        *time.Time      `gorm:"index" json:"due_date"`
+	PaymentStatus  string          `gorm:"-" json:"payment_status"`                                    // derived: unpaid, partial, paid, overdue
+	TaxInclusive   bool            `gorm:"not null;default:false" json:"tax_inclusive"`                // selling prices included GST
+	SupplyType     string          `gorm:"type:varchar(20)" json:"supply_type"`                        // intra_state or inter_state
+	Rounding       string          `gorm:"type:varchar(10)" json:"rounding"`                           // money rounding rule the bill was priced with: line or document
+	Currency       string          `gorm:"type:varchar(3)" json:"currency"`                            // every amount of the bill is in it
+	ExchangeRate   decimal.Decimal `gorm:"type:decimal(18,8);not null;default:1" json:"exchange_rate"` // to the warehouse's reporting currency on the bill date
+	CGSTAmount     decimal.Decimal `gorm:"type:decimal(12,2);not null;default:0" json:"cgst_amount"`
+	SGSTAmount     decimal.Decimal `gorm:"type:decimal(12,2);not null;default:0" json:"sgst_amount"`
+	IGSTAmount     decimal.Decimal `gorm:"type:decimal(12,2);not null;default:0" json:"igst_amount"`
+	TotalTax       decimal.Decimal `gorm:"type:decimal(12,2);not null;default:0" json:"total_tax"`
+	InvoiceTotal   decimal.Decimal `gorm:"type:decimal(12,2);not null;default:0" json:"invoice_total"` // TotalSelling (taxable value) + TotalTax
+	CreatedAt      time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt      gorm.DeletedAt  `gorm:"index" json:"-"`
 }
 
 type BillingItem struct {
-	ID           uint           `gorm:"primaryKey;autoIncrement" json:"id"`
-	BillingID    uint           `gorm:"not null;index" json:"billing_id"`
-	ProductID    uint           `gorm:"not null;index" json:"product_id"`
-	BatchID      uint           `gorm:"not null;index" json:"batch_id"`
-	OffboardQty  int            `gorm:"not null" json:"offboard_quantity"`
-	DurationDays float64        `gorm:"type:decimal(10,2)" json:"duration_days"`
-	StorageCost  float64        `gorm:"type:decimal(12,2)" json:"storage_cost"`
-	BuyingPrice  float64        `gorm:"type:decimal(10,2)" json:"buying_price"`
-	SellingPrice float64        `gorm:"type:decimal(10,2)" json:"selling_price"`
-	TotalSelling float64        `gorm:"type:decimal(10,2)" json:"total_selling"`
-	BatchStatus  string         `gorm:"type:varchar(50)" json:"batch_status"`
-	CreatedAt    time.Time      `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt    time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+	ID               uint            `gorm:"primaryKey;autoIncrement" json:"id"`
+	BillingID        uint            `gorm:"not null;index" json:"billing_id"`
+	ProductID        uint            `gorm:"not null;index" json:"product_id"`
+	BatchID          uint            `gorm:"not null;index" json:"batch_id"`
+	OffboardQty      int             `gorm:"not null" json:"offboard_quantity"`
+	ReversedQty      int             `gorm:"not null;default:0" json:"reversed_quantity"` // quantity returned through credit notes
+	DurationDays     float64         `gorm:"type:decimal(10,2)" json:"duration_days"`
+	StorageCost      decimal.Decimal `gorm:"type:decimal(12,2)" json:"storage_cost"`
+	BuyingPrice      decimal.Decimal `gorm:"type:decimal(12,4)" json:"buying_price"` // unit cost in the bill's currency, at the rate the batch was booked at
+	SellingPrice     decimal.Decimal `gorm:"type:decimal(10,2)" json:"selling_price"`
+	TotalSelling     decimal.Decimal `gorm:"type:decimal(10,2)" json:"total_selling"` // taxable value of the line
+	HSNCode          string          `gorm:"type:varchar(20)" json:"hsn_code"`
+	TaxRate          float64         `gorm:"type:decimal(5,2);not null;default:0" json:"tax_rate"` // percent
+	CGSTAmount       decimal.Decimal `gorm:"type:decimal(12,2);not null;default:0" json:"cgst_amount"`
+	SGSTAmount       decimal.Decimal `gorm:"type:decimal(12,2);not null;default:0" json:"sgst_amount"`
+	IGSTAmount       decimal.Decimal `gorm:"type:decimal(12,2);not null;default:0" json:"igst_amount"`
+	TaxAmount        decimal.Decimal `gorm:"type:decimal(12,2);not null;default:0" json:"tax_amount"`
+	BatchStatus      string          `gorm:"type:varchar(50)" json:"batch_status"`
+	Allocation       string          `gorm:"type:varchar(20)" json:"allocation,omitempty"` // strategy that picked the batch; empty when the caller named it
+	AllocationReason string          `gorm:"type:text" json:"allocation_reason,omitempty"`
+	PickFrom         []Putaway       `gorm:"-" json:"pick_from,omitempty"` // bins to take the units from, in warehouses with bins
+	CreatedAt        time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt        gorm.DeletedAt  `gorm:"index" json:"-"`
 }
 
 // ----------------------------------------------------
@@ -43,11 +71,11 @@ type BillingItem struct {
 
 // Expenses applied at the time of onboarding a product
 type OnBoardExpense struct {
-	ID      uint    `gorm:"primaryKey;autoIncrement" json:"id"`
-	BatchID uint    `gorm:"not null;index" json:"batch_id"` // FK → Batch table
-	Type    string  `gorm:"type:varchar(100);not null" json:"type"`
-	Amount  float64 `gorm:"not null" json:"amount"`
-	Notes   string  `gorm:"type:text" json:"notes"`
+	ID      uint            `gorm:"primaryKey;autoIncrement" json:"id"`
+	BatchID uint            `gorm:"not null;index" json:"batch_id"` // FK → Batch table
+	Type    string          `gorm:"type:varchar(100);not null" json:"type"`
+	Amount  decimal.Decimal `gorm:"not null" json:"amount"`
+	Notes   string          `gorm:"type:text" json:"notes"`
 
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
@@ -56,14 +84,13 @@ type OnBoardExpense struct {
 	Batch Batch `gorm:"foreignKey:BatchID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"batch"`
 }
 
-
 // Expenses applied at the time of offboarding a product
 type OffBoardExpense struct {
-	ID        uint    `gorm:"primaryKey;autoIncrement" json:"id"`
-	BillingID uint    `gorm:"not null;index" json:"billing_id"` // Foreign key to Billing
-	Type      string  `gorm:"type:varchar(100);not null" json:"type"`
-	Amount    float64 `gorm:"not null" json:"amount"`
-	Notes     string  `gorm:"type:text" json:"notes"`
+	ID        uint            `gorm:"primaryKey;autoIncrement" json:"id"`
+	BillingID uint            `gorm:"not null;index" json:"billing_id"` // Foreign key to Billing
+	Type      string          `gorm:"type:varchar(100);not null" json:"type"`
+	Amount    decimal.Decimal `gorm:"not null" json:"amount"`
+	Notes     string          `gorm:"type:text" json:"notes"`
 
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
@@ -72,57 +99,163 @@ type OffBoardExpense struct {
 	Billing Billing `gorm:"foreignKey:BillingID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"billing"`
 }
 
+// ----------------------------------------------------
+// 🔁 CREDIT NOTES (Billing reversal)
+// ----------------------------------------------------
+
+// CreditNote reverses all or part of a committed bill and restores its stock
+type CreditNote struct {
+	ID           uint             `gorm:"primaryKey;autoIncrement" json:"id"`
+	BillingID    uint             `gorm:"not null;index" json:"billing_id"` // original bill
+	Billing      Billing          `gorm:"foreignKey:BillingID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"-"`
+	Reason       string           `gorm:"type:text" json:"reason"`
+	Items        []CreditNoteItem `gorm:"foreignKey:CreditNoteID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"items"`
+	TotalRent    decimal.Decimal  `gorm:"type:decimal(12,2)" json:"total_rent"`
+	TotalStorage float64          `gorm:"type:decimal(12,2)" json:"total_storage"`
+	TotalBuying  decimal.Decimal  `gorm:"type:decimal(12,2)" json:"total_buying"`
+	TotalSelling decimal.Decimal  `gorm:"type:decimal(12,2)" json:"total_selling"`
+	TotalTax     decimal.Decimal  `gorm:"type:decimal(12,2);not null;default:0" json:"total_tax"`
+	CreatedAt    time.Time        `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time        `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt    gorm.DeletedAt   `gorm:"index" json:"-"`
+}
+
+type CreditNoteItem struct {
+	ID            uint            `gorm:"primaryKey;autoIncrement" json:"id"`
+	CreditNoteID  uint            `gorm:"not null;index" json:"credit_note_id"`
+	BillingItemID uint            `gorm:"not null;index" json:"billing_item_id"` // original line
+	ProductID     uint            `gorm:"not null;index" json:"product_id"`
+	BatchID       uint            `gorm:"not null;index" json:"batch_id"`
+	Quantity      int             `gorm:"not null" json:"quantity"`
+	StorageCost   decimal.Decimal `gorm:"type:decimal(12,2)" json:"storage_cost"`
+	BuyingPrice   decimal.Decimal `gorm:"type:decimal(12,4)" json:"buying_price"`
+	SellingPrice  decimal.Decimal `gorm:"type:decimal(10,2)" json:"selling_price"`
+	TotalSelling  decimal.Decimal `gorm:"type:decimal(10,2)" json:"total_selling"`
+	CGSTAmount    decimal.Decimal `gorm:"type:decimal(12,2);not null;default:0" json:"cgst_amount"`
+	SGSTAmount    decimal.Decimal `gorm:"type:decimal(12,2);not null;default:0" json:"sgst_amount"`
+	IGSTAmount    decimal.Decimal `gorm:"type:decimal(12,2);not null;default:0" json:"igst_amount"`
+	TaxAmount     decimal.Decimal `gorm:"type:decimal(12,2);not null;default:0" json:"tax_amount"`
+	CreatedAt     time.Time       `gorm:"autoCreateTime" json:"created_at"`
+}
+
+type CreditNoteItemInput struct {
+	BillingItemID uint `json:"billing_item_id"`
+	Quantity      int  `json:"quantity"`
+}
+
+// BillingReversalInput reverses the listed items; no items means the whole remaining bill
+type BillingReversalInput struct {
+	Reason string                `json:"reason"`
+	Items  []CreditNoteItemInput `json:"items"`
+}
+
 type BillingItemCoreData struct {
-	ID           uint        `json:"id"`
-	Product      ProductCore `json:"product"`
-	BatchID      uint        `json:"batch_id"`
-	OffboardQty  int         ` json:"offboard_quantity"`
-	DurationDays float64     `json:"duration_days"`
-	StorageCost  float64     ` json:"storage_cost"`
-	BuyingPrice  float64     `json:"buying_price"`
-	SellingPrice float64     `json:"selling_price"`
-	TotalSelling float64     `json:"total_selling"`
-	BatchStatus  string      ` json:"batch_status"`
-	CreatedAt    time.Time   `json:"created_at"`
-	UpdatedAt    time.Time   ` json:"updated_at"`
+	ID               uint            `json:"id"`
+	Product          ProductCore     `json:"product"`
+	BatchID          uint            `json:"batch_id"`
+	OffboardQty      int             ` json:"offboard_quantity"`
+	ReversedQty      int             `json:"reversed_quantity"`
+	DurationDays     float64         `json:"duration_days"`
+	StorageCost      decimal.Decimal ` json:"storage_cost"`
+	BuyingPrice      decimal.Decimal `json:"buying_price"`
+	SellingPrice     decimal.Decimal `json:"selling_price"`
+	TotalSelling     decimal.Decimal `json:"total_selling"`
+	HSNCode          string          `json:"hsn_code"`
+	TaxRate          float64         `json:"tax_rate"`
+	CGSTAmount       decimal.Decimal `json:"cgst_amount"`
+	SGSTAmount       decimal.Decimal `json:"sgst_amount"`
+	IGSTAmount       decimal.Decimal `json:"igst_amount"`
+	TaxAmount        decimal.Decimal `json:"tax_amount"`
+	BatchStatus      string          ` json:"batch_status"`
+	Allocation       string          `json:"allocation,omitempty"`
+	AllocationReason string          `json:"allocation_reason,omitempty"`
+	CreatedAt        time.Time       `json:"created_at"`
+	UpdatedAt        time.Time       ` json:"updated_at"`
 }
 
 type Expense struct {
-	Type   string  `bson:"type" json:"type"`
-	Amount float64 `bson:"amount" json:"amount"`
-	Notes  string  `bson:"notes" json:"notes"`
+	Type   string          `bson:"type" json:"type"`
+	Amount decimal.Decimal `bson:"amount" json:"amount"`
+	Notes  string          `bson:"notes" json:"notes"`
 }
 
 type BillingCoreData struct {
-	ID            uint      `json:"id"`
-	TotalRent     float64   `json:"total_rent"`
-	TotalStorage  float64   `json:"total_storage"`
-	TotalBuying   float64   `json:"total_buying"`
-	TotalSelling  float64   `json:"total_selling"`
-	OtherExpenses float64   ` json:"other_expenses"`
-	Margin        float64   ` json:"margin"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID             uint            `json:"id"`
+	WarehouseID    uint            `json:"warehouse_id"`
+	InvoiceNumber  string          `json:"invoice_number"`
+	CustomerID     uint            `json:"customer_id"`
+	CustomerName   string          `json:"customer_name"`
+	Currency       string          `json:"currency"`
+	ExchangeRate   decimal.Decimal `json:"exchange_rate"`
+	TotalRent      decimal.Decimal `json:"total_rent"`
+	TotalStorage   float64         `json:"total_storage"`
+	TotalBuying    decimal.Decimal `json:"total_buying"`
+	TotalSelling   decimal.Decimal `json:"total_selling"`
+	OtherExpenses  decimal.Decimal ` json:"other_expenses"`
+	Margin         decimal.Decimal ` json:"margin"`
+	TotalTax       decimal.Decimal `json:"total_tax"`
+	InvoiceTotal   decimal.Decimal `json:"invoice_total"`
+	CreditedAmount decimal.Decimal `json:"credited_amount"`
+	PaidAmount     decimal.Decimal `json:"paid_amount"`
+	Outstanding    decimal.Decimal `json:"outstanding"`
+	DueDate        *time.Time      `json:"due_date"`
+	PaymentStatus  string          `json:"payment_status"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
 }
 type BillingCoreDataWithProducts struct {
-	ID            uint                  `json:"id"`
-	Products      []BillingItemCoreData `json:"products"`
-	TotalRent     float64               `json:"total_rent"`
-	TotalStorage  float64               `json:"total_storage"`
-	TotalBuying   float64               `json:"total_buying"`
-	TotalSelling  float64               `json:"total_selling"`
-	OtherExpenses float64               ` json:"other_expenses"`
-	Margin        float64               ` json:"margin"`
-	CreatedAt     time.Time             `json:"created_at"`
-	UpdatedAt     time.Time             `json:"updated_at"`
+	ID             uint                  `json:"id"`
+	WarehouseID    uint                  `json:"warehouse_id"`
+	InvoiceNumber  string                `json:"invoice_number"`
+	CustomerID     uint                  `json:"customer_id"`
+	CustomerName   string                `json:"customer_name"`
+	Products       []BillingItemCoreData `json:"products"`
+	TotalRent      decimal.Decimal       `json:"total_rent"`
+	TotalStorage   float64               `json:"total_storage"`
+	TotalBuying    decimal.Decimal       `json:"total_buying"`
+	TotalSelling   decimal.Decimal       `json:"total_selling"`
+	OtherExpenses  decimal.Decimal       ` json:"other_expenses"`
+	Margin         decimal.Decimal       ` json:"margin"`
+	TaxInclusive   bool                  `json:"tax_inclusive"`
+	SupplyType     string                `json:"supply_type"`
+	Rounding       string                `json:"rounding"`
+	Currency       string                `json:"currency"`
+	ExchangeRate   decimal.Decimal       `json:"exchange_rate"`
+	CGSTAmount     decimal.Decimal       `json:"cgst_amount"`
+	SGSTAmount     decimal.Decimal       `json:"sgst_amount"`
+	IGSTAmount     decimal.Decimal       `json:"igst_amount"`
+	TotalTax       decimal.Decimal       `json:"total_tax"`
+	InvoiceTotal   decimal.Decimal       `json:"invoice_total"`
+	CreditedAmount decimal.Decimal       `json:"credited_amount"`
+	PaidAmount     decimal.Decimal       `json:"paid_amount"`
+	Outstanding    decimal.Decimal       `json:"outstanding"`
+	DueDate        *time.Time            `json:"due_date"`
+	PaymentStatus  string                `json:"payment_status"`
+	CreatedAt      time.Time             `json:"created_at"`
+	UpdatedAt      time.Time             `json:"updated_at"`
 }
 type BillingItemInput struct {
-	ProductID    string  `json:"product_id"`
-	BatchID      string  `json:"batch_id"`
-	OffboardQty  int     `json:"offboard_quantity"`
-	SellingPrice float64 `json:"selling_price"`
+	ProductID    string          `json:"product_id"`
+	BatchID      string          `json:"batch_id"`
+	OffboardQty  int             `json:"offboard_quantity" binding:"required,gt=0"`
+	SellingPrice decimal.Decimal `json:"selling_price"`
 }
+
+// BillingPreview is what a BillingInput would bill, calculated without touching stock.
+// The bill has no ID or invoice number yet.
+type BillingPreview struct {
+	Billing  Billing   `json:"billing"`
+	Profits  []Profit  `json:"profits"`
+	Expenses []Expense `json:"expenses"`
+}
+
 type BillingInput struct {
-	Items    []BillingItemInput `json:"items"`
-	Expenses []Expense          `json:"expenses"`
+	CustomerID   uint               `json:"customer_id" binding:"required"`
+	DueDate      *time.Time         `json:"due_date"`                                                                            // default: bill date + the customer's credit days
+	TaxInclusive bool               `json:"tax_inclusive"`                                                                       // selling prices include GST (default: tax is added on top)
+	Allocation   string             `json:"allocation" binding:"omitempty,oneof=fifo lifo fefo cheapest highest_rent close_out"` // batch picking without batch IDs (default: the product's strategy, then fifo)
+	Rounding     string             `json:"rounding" binding:"omitempty,oneof=line document"`                                    // money rounding rule (default: MONEY_ROUNDING, then line)
+	Currency     string             `json:"currency" binding:"omitempty,len=3"`                                                  // of the selling prices and expenses (default: the customer's currency, then the warehouse's)
+	Items        []BillingItemInput `json:"items" binding:"dive"`
+	Expenses     []Expense          `json:"expenses"`
 }
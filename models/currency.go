@@ -0,0 +1,78 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ExchangeRate is one effective-dated rate: 1 FromCurrency buys Rate ToCurrency from
+// EffectiveFrom until the next rate of the pair. The reverse pair is derived when it has
+// no rate of its own.
+type ExchangeRate struct {
+	ID            uint            `gorm:"primaryKey;autoIncrement" json:"id"`
+	FromCurrency  string          `gorm:"type:varchar(3);not null;uniqueIndex:idx_exchange_rate_pair,priority:1" json:"from_currency" binding:"required,len=3"`
+	ToCurrency    string          `gorm:"type:varchar(3);not null;uniqueIndex:idx_exchange_rate_pair,priority:2" json:"to_currency" binding:"required,len=3"`
+	EffectiveFrom time.Time       `gorm:"not null;uniqueIndex:idx_exchange_rate_pair,priority:3" json:"effective_from" binding:"required"`
+	Rate          decimal.Decimal `gorm:"type:decimal(18,8);not null" json:"rate" binding:"gt=0"`
+	Source        string          `gorm:"type:varchar(255)" json:"source"` // "manual" or the file the rate was loaded from
+	CreatedBy     uint            `json:"created_by"`
+	CreatedAt     time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// ExchangeRateRow is one parsed line of an exchange-rate file
+type ExchangeRateRow struct {
+	RowNumber     int
+	FromCurrency  string
+	ToCurrency    string
+	Rate          decimal.Decimal
+	EffectiveFrom time.Time
+	Errors        []string
+}
+
+// ExchangeRateImportRow is the outcome of one line of an exchange-rate file
+type ExchangeRateImportRow struct {
+	Row           int             `json:"row"`
+	FromCurrency  string          `json:"from_currency"`
+	ToCurrency    string          `json:"to_currency"`
+	Rate          decimal.Decimal `json:"rate"`
+	EffectiveFrom time.Time       `json:"effective_from"`
+	Status        string          `json:"status"` // valid | invalid | imported
+	Errors        []string        `json:"errors,omitempty"`
+}
+
+// ExchangeRateImportReport describes a rate file load. Rates already loaded for the same
+// pair and date are replaced.
+type ExchangeRateImportReport struct {
+	DryRun    bool                    `json:"dry_run"`
+	Committed bool                    `json:"committed"`
+	Source    string                  `json:"source"`
+	TotalRows int                     `json:"total_rows"`
+	ValidRows int                     `json:"valid_rows"`
+	Invalid   int                     `json:"invalid_rows"`
+	Rows      []ExchangeRateImportRow `json:"rows"`
+}
+
+// FXCurrencyResult is the realised exchange difference on payments in one currency
+type FXCurrencyResult struct {
+	Currency string          `json:"currency"`
+	Payments int             `json:"payments"`
+	Settled  decimal.Decimal `json:"settled"` // bill amounts settled, in Currency
+	Gain     decimal.Decimal `json:"gain"`
+	Loss     decimal.Decimal `json:"loss"` // positive
+	Net      decimal.Decimal `json:"net"`
+}
+
+// FXReport is the realised FX gain and loss of a warehouse for payments received in
+// [From, To), in its reporting currency
+type FXReport struct {
+	WarehouseID uint               `json:"warehouse_id"`
+	Currency    string             `json:"currency"`
+	From        time.Time          `json:"from"`
+	To          time.Time          `json:"to"` // exclusive
+	Gain        decimal.Decimal    `json:"gain"`
+	Loss        decimal.Decimal    `json:"loss"` // positive
+	Net         decimal.Decimal    `json:"net"`
+	Currencies  []FXCurrencyResult `json:"currencies"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// Customer is who goods are sold or released to when a bill offboards stock
+type Customer struct {
+	ID            uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name          string         `gorm:"type:varchar(255);not null" json:"name" binding:"required"`
+	ContactPerson string         `gorm:"type:varchar(255)" json:"contact_person"`
+	Email         string         `gorm:"type:varchar(255)" json:"email"`
+	Phone         string         `gorm:"type:varchar(50)" json:"phone"`
+	Address       string         `gorm:"type:text" json:"address"`
+	State         string         `gorm:"type:varchar(100)" json:"state"` // place of supply for GST
+	GSTIN         string         `gorm:"type:varchar(15)" json:"gstin"`
+	Description   string         `gorm:"type:text" json:"description"`
+	CreditDays    int            `gorm:"not null;default:30" json:"credit_days"` // payment term used for bill due dates
+	Currency      string         `gorm:"type:varchar(3)" json:"currency"`        // bills and payments default to it (default: the warehouse's reporting currency)
+	CreatedAt     time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// CustomerStatement lists a customer's bills in one warehouse with running totals
+type CustomerStatement struct {
+	Customer      Customer          `json:"customer"`
+	WarehouseID   uint              `json:"warehouse_id"`
+	Currency      string            `json:"currency"` // totals are in it; bills in other currencies are converted at their booked rates
+	From          *time.Time        `json:"from,omitempty"`
+	To            *time.Time        `json:"to,omitempty"` // exclusive
+	Bills         []BillingCoreData `json:"bills"`
+	BillCount     int               `json:"bill_count"`
+	TotalBilled   decimal.Decimal   `json:"total_billed"`
+	TotalCredited decimal.Decimal   `json:"total_credited"`
+	NetBilled     decimal.Decimal   `json:"net_billed"`
+	TotalPaid     decimal.Decimal   `json:"total_paid"`
+	Outstanding   decimal.Decimal   `json:"outstanding"`
+}
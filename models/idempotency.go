@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Idempotency key states
+const (
+	IdempotencyProcessing = "processing"
+	IdempotencyCompleted  = "completed"
+)
+
+// IdempotencyKey remembers a create request sent with an Idempotency-Key header so a
+// retry replays the first response instead of running the request again. Keys are
+// scoped to the user that sent them and expire after the configured window.
+type IdempotencyKey struct {
+	ID             uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID         uint      `gorm:"not null;uniqueIndex:idx_idempotency_user_key,priority:1" json:"user_id"`
+	Key            string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_idempotency_user_key,priority:2" json:"key"`
+	Method         string    `gorm:"type:varchar(10);not null" json:"method"`
+	Path           string    `gorm:"type:varchar(255);not null" json:"path"`
+	RequestHash    string    `gorm:"type:varchar(64);not null" json:"request_hash"` // sha256 of method, path and body
+	Status         string    `gorm:"type:varchar(20);not null" json:"status"`
+	ResponseStatus int       `json:"response_status"`
+	ResponseType   string    `gorm:"type:varchar(100)" json:"response_type"`
+	ResponseBody   []byte    `json:"-"`
+	CreatedAt      time.Time `gorm:"autoCreateTime" json:"created_at"`
+	ExpiresAt      time.Time `gorm:"not null;index" json:"expires_at"`
+}
@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// InvoiceTemplate customises a warehouse's printed invoices. Header, Footer and Terms are
+// Go text/templates over the invoice document, e.g. "{{.Warehouse.Name}}" or
+// "Due {{date .DueDate}}". Empty text fields fall back to the built-in defaults.
+type InvoiceTemplate struct {
+	ID              uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	WarehouseID     uint      `gorm:"not null;uniqueIndex" json:"warehouse_id"`
+	Title           string    `gorm:"type:varchar(100)" json:"title"`
+	Header          string    `gorm:"type:text" json:"header"`
+	Footer          string    `gorm:"type:text" json:"footer"`
+	Terms           string    `gorm:"type:text" json:"terms"`
+	AccentColor     string    `gorm:"type:varchar(7)" json:"accent_color"` // #RRGGBB
+	ShowStorageCost bool      `gorm:"not null" json:"show_storage_cost"`
+	ShowBatch       bool      `gorm:"not null" json:"show_batch"`
+	ShowExpenses    bool      `gorm:"not null" json:"show_expenses"`
+	UpdatedBy       uint      `json:"updated_by"`
+	CreatedAt       time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt       time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+type InvoiceTemplateInput struct {
+	Title           string `json:"title"`
+	Header          string `json:"header"`
+	Footer          string `json:"footer"`
+	Terms           string `json:"terms"`
+	AccentColor     string `json:"accent_color"`
+	ShowStorageCost *bool  `json:"show_storage_cost"`
+	ShowBatch       *bool  `json:"show_batch"`
+	ShowExpenses    *bool  `json:"show_expenses"`
+}
+
+// InvoiceSeries configures how a warehouse numbers its bills, e.g. INV/2026-27/00001
+type InvoiceSeries struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	WarehouseID  uint      `gorm:"not null;uniqueIndex" json:"warehouse_id"`
+	Prefix       string    `gorm:"type:varchar(20)" json:"prefix"`
+	Padding      int       `gorm:"not null" json:"padding"`        // minimum digits of the sequence
+	ResetEachFY  bool      `gorm:"not null" json:"reset_each_fy"`  // restart at 1 every financial year
+	FYStartMonth int       `gorm:"not null" json:"fy_start_month"` // 4 = April-March
+	UpdatedBy    uint      `json:"updated_by"`
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+type InvoiceSeriesInput struct {
+	Prefix       string `json:"prefix" binding:"max=20"`
+	Padding      int    `json:"padding" binding:"omitempty,min=1,max=12"`
+	ResetEachFY  *bool  `json:"reset_each_fy"`
+	FYStartMonth int    `json:"fy_start_month" binding:"omitempty,min=1,max=12"`
+}
+
+// InvoiceCounter is the last number issued in a warehouse's sequence. Period is the
+// financial year for yearly series and empty for a running one. The row is locked while
+// a bill is being created, so numbers are issued in order and a rolled-back bill gives
+// its number back.
+type InvoiceCounter struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	WarehouseID uint      `gorm:"not null;uniqueIndex:idx_invoice_counter_period,priority:1" json:"warehouse_id"`
+	Period      string    `gorm:"type:varchar(10);not null;uniqueIndex:idx_invoice_counter_period,priority:2" json:"period"`
+	LastNumber  int64     `gorm:"not null;default:0" json:"last_number"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
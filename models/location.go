@@ -0,0 +1,113 @@
+package models
+
+import "time"
+
+// Location levels, outermost first
+const (
+	LevelZone  = "zone"
+	LevelAisle = "aisle"
+	LevelRack  = "rack"
+	LevelBin   = "bin"
+)
+
+// LocationLevels is the zone → aisle → rack → bin hierarchy; each level's parent is the one before it
+var LocationLevels = []string{LevelZone, LevelAisle, LevelRack, LevelBin}
+
+// Location is a storage place inside a warehouse. Stock sits in bins; zones, aisles and
+// racks group them. Capacity and UsedCapacity are in the warehouse's capacity unit. A bin
+// must have a capacity; on the levels above it a capacity of 0 means no limit of its own.
+// Once a warehouse has bins, its TotalArea and AvailableArea are derived from them.
+// DispatchDistance ranks how close a location is to dispatch; a bin without one takes
+// its nearest parent's.
+type Location struct {
+	ID               uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	WarehouseID      uint      `gorm:"not null;uniqueIndex:idx_location_code" json:"warehouse_id"`
+	ParentID         *uint     `gorm:"index" json:"parent_id,omitempty"`
+	Level            string    `gorm:"type:varchar(10);not null" json:"level" binding:"required,oneof=zone aisle rack bin"`
+	Code             string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_location_code" json:"code" binding:"required"` // e.g. A-03-R2-B4, unique in the warehouse
+	Name             string    `gorm:"type:varchar(255)" json:"name"`
+	Capacity         float64   `gorm:"type:decimal(12,4);not null;default:0" json:"capacity" binding:"gte=0"`
+	UsedCapacity     float64   `gorm:"type:decimal(12,4);not null;default:0" json:"used_capacity"`
+	DispatchDistance float64   `gorm:"type:decimal(10,2);not null;default:0" json:"dispatch_distance" binding:"gte=0"` // e.g. metres or walking order from dispatch, smaller is nearer
+	CreatedAt        time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// LocationStock is the quantity of one batch entry sitting in one bin
+type LocationStock struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	WarehouseID uint      `gorm:"not null;index" json:"warehouse_id"`
+	LocationID  uint      `gorm:"not null;uniqueIndex:idx_location_entry" json:"location_id"`
+	EntryID     uint      `gorm:"not null;uniqueIndex:idx_location_entry;index" json:"entry_id"`
+	BatchID     uint      `gorm:"not null;index" json:"batch_id"`
+	ProductID   uint      `gorm:"not null;index" json:"product_id"`
+	Quantity    int       `gorm:"not null" json:"quantity"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// Putaway places a quantity of a batch entry in a bin. On a new batch it asks for bins,
+// overriding the suggested ones; in responses it tells staff where the units went or
+// should be picked from, and for suggestions why the bin was chosen.
+type Putaway struct {
+	LocationID   uint   `json:"location_id" binding:"required"`
+	LocationCode string `json:"location_code,omitempty"`
+	Quantity     int    `json:"quantity" binding:"required,gt=0"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// EntryPutaway is where the units of one batch line went or are suggested to go
+type EntryPutaway struct {
+	EntryID   uint      `json:"entry_id,omitempty"`
+	ProductID uint      `json:"product_id"`
+	Quantity  int       `json:"quantity"`
+	Locations []Putaway `json:"locations,omitempty"`
+}
+
+// LocationMoveInput moves units of a batch entry into a bin, from another bin or, without
+// FromLocationID, from no bin at all
+type LocationMoveInput struct {
+	EntryID        uint  `json:"entry_id" binding:"required"`
+	FromLocationID *uint `json:"from_location_id"`
+	ToLocationID   uint  `json:"to_location_id" binding:"required"`
+	Quantity       int   `json:"quantity" binding:"required,gt=0"`
+}
+
+type LocationUpdateInput struct {
+	Name             *string  `json:"name"`
+	Capacity         *float64 `json:"capacity" binding:"omitempty,gte=0"`
+	DispatchDistance *float64 `json:"dispatch_distance" binding:"omitempty,gte=0"`
+}
+
+// LocationOccupancy is one node of the occupancy map: a location, its free space and,
+// for bins, what is stored in it
+type LocationOccupancy struct {
+	ID               uint                `json:"id"`
+	Level            string              `json:"level"`
+	Code             string              `json:"code"`
+	Name             string              `json:"name,omitempty"`
+	Capacity         float64             `json:"capacity"`
+	UsedCapacity     float64             `json:"used_capacity"`
+	FreeCapacity     float64             `json:"free_capacity"`
+	Utilisation      float64             `json:"utilisation_pct"`
+	DispatchDistance float64             `json:"dispatch_distance"`
+	Stock            []LocationStockView `json:"stock,omitempty"`
+	Children         []LocationOccupancy `json:"children,omitempty"`
+}
+
+type LocationStockView struct {
+	EntryID     uint   `json:"entry_id"`
+	BatchID     uint   `json:"batch_id"`
+	ProductID   uint   `json:"product_id"`
+	ProductName string `json:"product_name"`
+	Category    string `json:"category"`
+	Quantity    int    `json:"quantity"`
+}
+
+type LocationOccupancyMap struct {
+	WarehouseID   uint                `json:"warehouse_id"`
+	CapacityUnit  string              `json:"capacity_unit"`
+	TotalArea     float64             `json:"total_area"`
+	AvailableArea float64             `json:"available_area"`
+	UnplacedUnits int                 `json:"unplaced_units"` // stock held before the warehouse had bins
+	Zones         []LocationOccupancy `json:"zones"`
+}
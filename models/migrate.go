@@ -1,14 +1,51 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
 
 type ExcelProductRow struct {
-    ProductName     string
-    ProductCategory string
-    SupplierName    string
-    StorageArea     float64
-    WarehouseName   string
-    PurchasePrice   float64
-    Quantity        int
-    PurchaseDate    time.Time
-}
\ No newline at end of file
+	RowNumber       int
+	ProductName     string
+	ProductCategory string
+	SupplierName    string
+	StorageArea     float64
+	WarehouseName   string
+	PurchasePrice   decimal.Decimal
+	Quantity        int
+	PurchaseDate    time.Time
+	Errors          []string
+}
+
+// ImportRowResult is the validation outcome of a single spreadsheet row
+type ImportRowResult struct {
+	Row           int      `json:"row"`
+	ProductName   string   `json:"product_name"`
+	SupplierName  string   `json:"supplier_name"`
+	WarehouseName string   `json:"warehouse_name"`
+	Quantity      int      `json:"quantity"`
+	Status        string   `json:"status"` // valid | invalid | imported
+	BatchID       uint     `json:"batch_id,omitempty"`
+	Errors        []string `json:"errors,omitempty"`
+}
+
+// ImportBatchSummary describes one batch built from a group of rows
+type ImportBatchSummary struct {
+	BatchID      uint      `json:"batch_id,omitempty"`
+	WarehouseID  uint      `json:"warehouse_id"`
+	PurchaseDate time.Time `json:"purchase_date"`
+	Rows         []int     `json:"rows"`
+	RequiredArea float64   `json:"required_area"`
+}
+
+type ImportReport struct {
+	DryRun    bool                 `json:"dry_run"`
+	Committed bool                 `json:"committed"`
+	TotalRows int                  `json:"total_rows"`
+	ValidRows int                  `json:"valid_rows"`
+	Invalid   int                  `json:"invalid_rows"`
+	Batches   []ImportBatchSummary `json:"batches"`
+	Rows      []ImportRowResult    `json:"rows"`
+}
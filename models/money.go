@@ -0,0 +1,9 @@
+package models
+
+import "github.com/shopspring/decimal"
+
+// Amounts are exact decimals but stay plain JSON numbers on the wire, as they were when
+// they were floats. Requests may send them as numbers or strings.
+func init() {
+	decimal.MarshalJSONWithoutQuotes = true
+}
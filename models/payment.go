@@ -0,0 +1,95 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// Payment status of a bill, derived from its amount due, payments and due date
+const (
+	PaymentUnpaid  = "unpaid"
+	PaymentPartial = "partial"
+	PaymentPaid    = "paid"
+	PaymentOverdue = "overdue"
+)
+
+// DefaultCreditDays is the payment term used when a customer has none configured
+const DefaultCreditDays = 30
+
+// Payment is money collected from a customer, applied to one or more bills. Any part not
+// allocated to a bill stays on account as UnallocatedAmount.
+type Payment struct {
+	ID                uint                `gorm:"primaryKey;autoIncrement" json:"id"`
+	WarehouseID       uint                `gorm:"not null;index" json:"warehouse_id"`
+	CustomerID        uint                `gorm:"not null;index" json:"customer_id"`
+	Amount            decimal.Decimal     `gorm:"type:decimal(12,2);not null" json:"amount"`
+	UnallocatedAmount decimal.Decimal     `gorm:"type:decimal(12,2);not null;default:0" json:"unallocated_amount"`
+	Currency          string              `gorm:"type:varchar(3)" json:"currency"`                            // only bills in the same currency are settled
+	ExchangeRate      decimal.Decimal     `gorm:"type:decimal(18,8);not null;default:1" json:"exchange_rate"` // to the warehouse's reporting currency on PaidAt
+	FXGainLoss        decimal.Decimal     `gorm:"type:decimal(12,2);not null;default:0" json:"fx_gain_loss"`  // realised on the allocations, in the reporting currency; negative is a loss
+	Method            string              `gorm:"type:varchar(50);not null" json:"method"`                    // cash, bank_transfer, upi, cheque, card, other
+	Reference         string              `gorm:"type:varchar(255);index" json:"reference"`
+	PaidAt            time.Time           `gorm:"not null;index" json:"paid_at"`
+	Notes             string              `gorm:"type:text" json:"notes"`
+	Allocations       []PaymentAllocation `gorm:"foreignKey:PaymentID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"allocations"`
+	CreatedBy         uint                `gorm:"index" json:"created_by"`
+	CreatedAt         time.Time           `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt         time.Time           `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt         gorm.DeletedAt      `gorm:"index" json:"-"`
+}
+
+// PaymentAllocation applies part of a payment to a bill
+type PaymentAllocation struct {
+	ID         uint            `gorm:"primaryKey;autoIncrement" json:"id"`
+	PaymentID  uint            `gorm:"not null;index" json:"payment_id"`
+	BillingID  uint            `gorm:"not null;index" json:"billing_id"`
+	Amount     decimal.Decimal `gorm:"type:decimal(12,2);not null" json:"amount"`
+	FXGainLoss decimal.Decimal `gorm:"type:decimal(12,2);not null;default:0" json:"fx_gain_loss"` // Amount at the payment rate less at the bill rate, in the reporting currency
+	CreatedAt  time.Time       `gorm:"autoCreateTime" json:"created_at"`
+}
+
+type PaymentAllocationInput struct {
+	BillingID uint            `json:"billing_id" binding:"required"`
+	Amount    decimal.Decimal `json:"amount" binding:"gt=0"`
+}
+
+// PaymentInput records a payment. Without allocations it is applied to the customer's
+// open bills in the warehouse, oldest due date first.
+type PaymentInput struct {
+	CustomerID  uint                     `json:"customer_id" binding:"required"`
+	Amount      decimal.Decimal          `json:"amount" binding:"gt=0"`
+	Currency    string                   `json:"currency" binding:"omitempty,len=3"` // default: the customer's currency, then the warehouse's
+	Method      string                   `json:"method" binding:"required,oneof=cash bank_transfer upi cheque card other"`
+	Reference   string                   `json:"reference"`
+	PaidAt      string                   `json:"paid_at"` // YYYY-MM-DD, default today
+	Notes       string                   `json:"notes"`
+	Allocations []PaymentAllocationInput `json:"allocations"`
+}
+
+// AgeingBuckets splits outstanding amounts by days since the bill date
+type AgeingBuckets struct {
+	Days0To30  decimal.Decimal `json:"0_30"`
+	Days31To60 decimal.Decimal `json:"31_60"`
+	Days61To90 decimal.Decimal `json:"61_90"`
+	Over90     decimal.Decimal `json:"90_plus"`
+	Total      decimal.Decimal `json:"total"`
+	Overdue    decimal.Decimal `json:"overdue"` // part of Total past its due date
+}
+
+type CustomerAgeing struct {
+	CustomerID   uint          `json:"customer_id"`
+	CustomerName string        `json:"customer_name"`
+	OpenBills    int           `json:"open_bills"`
+	Buckets      AgeingBuckets `json:"buckets"`
+}
+
+// ARAgeingReport is the accounts receivable ageing of one warehouse
+type ARAgeingReport struct {
+	WarehouseID uint             `json:"warehouse_id"`
+	Currency    string           `json:"currency"` // reporting currency; bills are converted at their booked rates
+	AsOf        time.Time        `json:"as_of"`
+	Totals      AgeingBuckets    `json:"totals"`
+	Customers   []CustomerAgeing `json:"customers"`
+}
@@ -3,19 +3,25 @@ package models
 import (
 	"time"
 
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
 type Product struct {
-	ID          uint           `gorm:"primaryKey;autoIncrement" json:"id"`
-	Name        string         `gorm:"type:varchar(255);not null" json:"name"`
-	SupplierID  uint           `gorm:"not null;index" json:"supplier_id"`
-	Supplier    Supplier       `gorm:"foreignKey:SupplierID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"supplier"`
-	Category    string         `gorm:"type:varchar(255)" json:"category"`
-	StorageArea float64        `gorm:"type:decimal(10,2);not null" json:"storage_area"`
-	CreatedAt   time.Time      `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt   time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
-	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                 uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name               string         `gorm:"type:varchar(255);not null" json:"name"`
+	SupplierID         uint           `gorm:"not null;index" json:"supplier_id"`
+	Supplier           Supplier       `gorm:"foreignKey:SupplierID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"supplier"`
+	Category           string         `gorm:"type:varchar(255)" json:"category"`
+	HSNCode            string         `gorm:"type:varchar(20)" json:"hsn_code"`                                                                                     // HSN for goods, SAC for services
+	StorageArea        float64        `gorm:"type:decimal(10,2);not null" json:"storage_area"`                                                                      // floor area of one unit, in AreaUnit
+	AreaUnit           string         `gorm:"type:varchar(10);not null;default:'sqft'" json:"area_unit"`                                                            // sqft or sqm
+	StorageVolume      float64        `gorm:"type:decimal(10,4);not null;default:0" json:"storage_volume"`                                                          // cubic metres per unit, for warehouses that count m3
+	UnitsPerPallet     int            `gorm:"not null;default:0" json:"units_per_pallet" binding:"gte=0"`                                                           // for warehouses that count pallet positions
+	AllocationStrategy string         `gorm:"type:varchar(20)" json:"allocation_strategy" binding:"omitempty,oneof=fifo lifo fefo cheapest highest_rent close_out"` // default batch picking for billing without batch IDs
+	CreatedAt          time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt          time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt          gorm.DeletedAt `gorm:"index" json:"-"`
 }
 type ProductData struct {
 	ID          uint      ` json:"id"`
@@ -29,14 +35,15 @@ type ProductData struct {
 }
 
 type ProductStockData struct {
-	BatchID     uint        `json:"batch_id"`
-	BuyingPrice float64     `json:"buying_price"`
-	ProductData ProductData ` json:"product_data"`
-	ExpenseData ExpenseData ` json:"expense_data"`
+	BatchID     uint            `json:"batch_id"`
+	BuyingPrice decimal.Decimal `json:"buying_price"`
+	ProductData ProductData     ` json:"product_data"`
+	ExpenseData ExpenseData     ` json:"expense_data"`
 }
 
 type ExpenseData struct {
-	RentPerProduct float64 ` json:"rent_per_product"`
-	StockQuatity   int     ` json:"stock_quantity"`
-	DurationInDays int     ` json:"duration_in_days"`
+	RentPerProduct decimal.Decimal ` json:"rent_per_product"`
+	AccruedRent    decimal.Decimal `json:"accrued_rent_per_unit"`
+	StockQuatity   int             ` json:"stock_quantity"`
+	DurationInDays int             ` json:"duration_in_days"`
 }
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// Purchase order statuses
+const (
+	PurchaseOrderOpen      = "open"
+	PurchaseOrderPartial   = "partially_received"
+	PurchaseOrderClosed    = "closed"
+	PurchaseOrderCancelled = "cancelled"
+)
+
+// PurchaseOrder is what a warehouse ordered from a supplier: open → partially_received →
+// closed. Goods arrive through goods receipt notes; the order closes itself once every
+// line is fully received, or can be closed short by hand.
+type PurchaseOrder struct {
+	ID           uint                `gorm:"primaryKey;autoIncrement" json:"id"`
+	WarehouseID  uint                `gorm:"not null;index" json:"warehouse_id"`
+	SupplierID   uint                `gorm:"not null;index" json:"supplier_id"`
+	Status       string              `gorm:"type:varchar(30);not null;index" json:"status"`
+	Currency     string              `gorm:"type:varchar(3)" json:"currency"` // of the agreed prices; receipts are booked in it
+	ExpectedDate *time.Time          `json:"expected_date,omitempty"`
+	Notes        string              `gorm:"type:text" json:"notes"`
+	Items        []PurchaseOrderItem `gorm:"foreignKey:PurchaseOrderID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"items"`
+	TotalValue   decimal.Decimal     `gorm:"type:decimal(12,2);not null;default:0" json:"total_value"` // ordered qty x agreed price
+	CreatedBy    uint                `gorm:"index" json:"created_by"`
+	ClosedBy     *uint               `json:"closed_by,omitempty"`
+	ClosedAt     *time.Time          `json:"closed_at,omitempty"`
+	CloseReason  string              `gorm:"type:text" json:"close_reason,omitempty"`
+	CreatedAt    time.Time           `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time           `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt    gorm.DeletedAt      `gorm:"index" json:"-"`
+}
+
+// PurchaseOrderItem is one ordered product. ReceivedQty counts accepted units only;
+// rejected units are tracked on the receipts and do not reduce what is still due.
+type PurchaseOrderItem struct {
+	ID              uint            `gorm:"primaryKey;autoIncrement" json:"id"`
+	PurchaseOrderID uint            `gorm:"not null;index" json:"purchase_order_id"`
+	ProductID       uint            `gorm:"not null;index" json:"product_id"`
+	OrderedQty      int             `gorm:"not null" json:"ordered_quantity"`
+	UnitPrice       decimal.Decimal `gorm:"type:decimal(10,2);not null" json:"unit_price"` // agreed price
+	ReceivedQty     int             `gorm:"not null;default:0" json:"received_quantity"`
+	RejectedQty     int             `gorm:"not null;default:0" json:"rejected_quantity"`
+	ExcessQty       int             `gorm:"not null;default:0" json:"excess_quantity"`
+	CreatedAt       time.Time       `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// GoodsReceipt (GRN) records one delivery against a purchase order. Accepted units
+// become a new batch.
+type GoodsReceipt struct {
+	ID              uint               `gorm:"primaryKey;autoIncrement" json:"id"`
+	PurchaseOrderID uint               `gorm:"not null;index" json:"purchase_order_id"`
+	WarehouseID     uint               `gorm:"not null;index" json:"warehouse_id"`
+	BatchID         *uint              `gorm:"index" json:"batch_id,omitempty"` // nil when everything was rejected
+	Notes           string             `gorm:"type:text" json:"notes"`
+	Items           []GoodsReceiptItem `gorm:"foreignKey:GoodsReceiptID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"items"`
+	ReceivedBy      uint               `gorm:"index" json:"received_by"`
+	CreatedAt       time.Time          `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// GoodsReceiptItem compares a delivered line with what was still due on the order:
+// Accepted = Received - Rejected, Short = due - Accepted, Excess = Accepted - due.
+type GoodsReceiptItem struct {
+	ID                  uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	GoodsReceiptID      uint       `gorm:"not null;index" json:"goods_receipt_id"`
+	PurchaseOrderItemID uint       `gorm:"not null;index" json:"purchase_order_item_id"`
+	ProductID           uint       `gorm:"not null;index" json:"product_id"`
+	DueQty              int        `gorm:"not null" json:"due_quantity"`
+	ReceivedQty         int        `gorm:"not null" json:"received_quantity"`
+	RejectedQty         int        `gorm:"not null;default:0" json:"rejected_quantity"`
+	AcceptedQty         int        `gorm:"not null" json:"accepted_quantity"`
+	ShortQty            int        `gorm:"not null;default:0" json:"short_quantity"`
+	ExcessQty           int        `gorm:"not null;default:0" json:"excess_quantity"`
+	RejectionReason     string     `gorm:"type:text" json:"rejection_reason,omitempty"`
+	LotNumber           string     `gorm:"type:varchar(100)" json:"lot_number,omitempty"`
+	ManufacturedAt      *time.Time `json:"manufactured_at,omitempty"`
+	ExpiresAt           *time.Time `json:"expires_at,omitempty"`
+}
+
+type PurchaseOrderItemInput struct {
+	ProductID uint            `json:"product_id" binding:"required"`
+	Quantity  int             `json:"quantity" binding:"required,gt=0"`
+	UnitPrice decimal.Decimal `json:"unit_price" binding:"gte=0"`
+}
+
+type PurchaseOrderInput struct {
+	SupplierID   uint                     `json:"supplier_id" binding:"required"`
+	ExpectedDate *time.Time               `json:"expected_date"`
+	Currency     string                   `json:"currency" binding:"omitempty,len=3"` // default: the warehouse's reporting currency
+	Notes        string                   `json:"notes"`
+	Items        []PurchaseOrderItemInput `json:"items" binding:"required,min=1,dive"`
+}
+
+type GoodsReceiptItemInput struct {
+	PurchaseOrderItemID uint       `json:"purchase_order_item_id" binding:"required"`
+	ReceivedQty         int        `json:"received_quantity" binding:"gte=0"`
+	RejectedQty         int        `json:"rejected_quantity" binding:"gte=0"`
+	RejectionReason     string     `json:"rejection_reason"`
+	LotNumber           string     `json:"lot_number"`
+	ManufacturedAt      *time.Time `json:"manufactured_at"`
+	ExpiresAt           *time.Time `json:"expires_at"`
+}
+
+type GoodsReceiptInput struct {
+	Notes    string                  `json:"notes"`
+	Items    []GoodsReceiptItemInput `json:"items" binding:"required,min=1,dive"`
+	Expenses []Expense               `json:"expenses"` // onboarding expenses of the new batch
+}
+
+type PurchaseOrderCloseInput struct {
+	Reason string `json:"reason"`
+}
+
+// OpenPurchaseOrders summarises what a supplier still owes the warehouse
+type OpenPurchaseOrders struct {
+	SupplierID    uint            `json:"supplier_id"`
+	SupplierName  string          `json:"supplier_name"`
+	OpenOrders    int             `json:"open_orders"`
+	OverdueOrders int             `json:"overdue_orders"` // past their expected date
+	OrderedQty    int             `json:"ordered_quantity"`
+	ReceivedQty   int             `json:"received_quantity"`
+	PendingQty    int             `json:"pending_quantity"`
+	PendingValue  decimal.Decimal `json:"pending_value"`
+	Orders        []PurchaseOrder `json:"orders"`
+}
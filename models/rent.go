@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// RentInvoice bills storage rent for goods still in stock up to PeriodEnd (inclusive)
+type RentInvoice struct {
+	ID          uint              `gorm:"primaryKey;autoIncrement" json:"id"`
+	WarehouseID uint              `gorm:"not null;index" json:"warehouse_id"`
+	PeriodStart time.Time         `gorm:"not null;index" json:"period_start"`
+	PeriodEnd   time.Time         `gorm:"not null;index" json:"period_end"`
+	Currency    string            `gorm:"type:varchar(10)" json:"currency"`
+	TotalArea   float64           `gorm:"type:decimal(12,2);not null;default:0" json:"total_area"`
+	TotalAmount decimal.Decimal   `gorm:"type:decimal(12,2);not null;default:0" json:"total_amount"`
+	Lines       []RentInvoiceLine `gorm:"foreignKey:RentInvoiceID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"lines"`
+	CreatedBy   uint              `gorm:"index" json:"created_by"`
+	CreatedAt   time.Time         `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time         `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt   gorm.DeletedAt    `gorm:"index" json:"-"`
+}
+
+// RentInvoiceLine is the rent of one batch entry for the days [From, To)
+type RentInvoiceLine struct {
+	ID            uint            `gorm:"primaryKey;autoIncrement" json:"id"`
+	RentInvoiceID uint            `gorm:"not null;index" json:"rent_invoice_id"`
+	BatchID       uint            `gorm:"not null;index" json:"batch_id"`
+	EntryID       uint            `gorm:"not null;index" json:"entry_id"`
+	ProductID     uint            `gorm:"not null;index" json:"product_id"`
+	Quantity      int             `gorm:"not null" json:"quantity"`
+	Area          float64         `gorm:"type:decimal(12,2);not null" json:"area"`
+	From          time.Time       `gorm:"not null" json:"from"`
+	To            time.Time       `gorm:"not null" json:"to"`
+	Days          int             `gorm:"not null" json:"days"`
+	Amount        decimal.Decimal `gorm:"type:decimal(12,2);not null" json:"amount"`
+	CreatedAt     time.Time       `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// RentInvoiceRunInput selects the period to invoice (dates as YYYY-MM-DD, end inclusive)
+type RentInvoiceRunInput struct {
+	PeriodStart string `json:"period_start" binding:"required"`
+	PeriodEnd   string `json:"period_end" binding:"required"`
+}
@@ -3,6 +3,7 @@ package models
 import (
 	"time"
 
+	"github.com/shopspring/decimal"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -10,7 +11,7 @@ type ProductStock struct {
 	ProductID     primitive.ObjectID `json:"product_id"`
 	ProductName   string             `json:"product_name"`
 	TotalQuantity int                `json:"total_quantity"`
-	TotalRent     float64            `json:"total_rent"`
+	TotalRent     decimal.Decimal    `json:"total_rent"`
 	TotalSpace    float64            `json:"total_space"`
 	Currency      string             `json:"currency"`
 }
@@ -24,35 +25,38 @@ type BatchProduct struct {
 }
 
 type ProductStockView struct {
-	BatchID       uint       `json:"batch_id"`
-	WarehouseID   uint       `json:"warehouse_id"`
-	WarehouseName string     `json:"warehouse_name"`
-	ProductID     uint       `json:"product_id"`
-	ProductName   string     `json:"product_name"`
-	Category      string     `json:"category"`
-	SupplierName  string     `json:"supplier_name"`
-	StorageArea   float64    `json:"storage_area"`
-	Quantity      int        `json:"quantity"`
-	StockQuantity int        `json:"stock_quantity"`
-	BillingPrice  float64    `json:"billing_price"`
-	CreatedAt     time.Time  `json:"created_at"`
-	LastUpdated   *time.Time `json:"last_updated,omitempty"`
-	RatePerSqft   float64    `json:"rate_per_sqft"`
-	Currency      string     `json:"currency"`
-	BillingCycle  string     `json:"billing_cycle"`
+	BatchID       uint            `json:"batch_id"`
+	WarehouseID   uint            `json:"warehouse_id"`
+	WarehouseName string          `json:"warehouse_name"`
+	ProductID     uint            `json:"product_id"`
+	ProductName   string          `json:"product_name"`
+	Category      string          `json:"category"`
+	SupplierName  string          `json:"supplier_name"`
+	StorageArea   float64         `json:"storage_area"`
+	SpacePerUnit  float64         `json:"space_per_unit"` // one unit's footprint in CapacityUnit
+	CapacityUnit  string          `json:"capacity_unit"`
+	Quantity      int             `json:"quantity"`
+	StockQuantity int             `json:"stock_quantity"`
+	BillingPrice  decimal.Decimal `json:"billing_price"`
+	CreatedAt     time.Time       `json:"created_at"`
+	StoredAt      time.Time       `json:"stored_at"`
+	LastUpdated   *time.Time      `json:"last_updated,omitempty"`
+	RatePerSqft   decimal.Decimal `json:"rate_per_sqft"`
+	Currency      string          `json:"currency"`
+	BillingCycle  string          `json:"billing_cycle"`
 }
 type BasicProductStockView struct {
-	WarehouseID         uint    `json:"warehouse_id"`
-	WarehouseName       string  `json:"warehouse_name"`
-	ProductID           uint    `json:"product_id"`
-	ProductName         string  `json:"product_name"`
-	Category            string  `json:"category"`
-	AverageStorageArea  float64 `json:"average_storage_area"`
-	StockQuantity       int     `json:"stock_quantity"`
-	AverageBillingPrice float64 `json:"average_billing_price"`
-	AverageRatePerSqft  float64 `json:"average_rate_per_sqft"`
-	Currency            string  `json:"currency"`
-	BillingCycle        string  `json:"billing_cycle"`
+	WarehouseID         uint            `json:"warehouse_id"`
+	WarehouseName       string          `json:"warehouse_name"`
+	ProductID           uint            `json:"product_id"`
+	ProductName         string          `json:"product_name"`
+	Category            string          `json:"category"`
+	AverageStorageArea  float64         `json:"average_storage_area"`
+	StockQuantity       int             `json:"stock_quantity"`
+	AverageBillingPrice decimal.Decimal `json:"average_billing_price"`
+	AverageRatePerSqft  decimal.Decimal `json:"average_rate_per_sqft"`
+	Currency            string          `json:"currency"`
+	BillingCycle        string          `json:"billing_cycle"`
 }
 
 type StockSearchData struct {
@@ -69,10 +73,10 @@ type StockSearchData struct {
 }
 
 type StockData struct {
-	BatchID    uint         `json:"batch_id"`
-	StockCount Stock        `json:"stock_count"`
-	Amounts    TotalAmounts `json:"amounts"`
-	RentAmount float64      `json:"rent_amount"`
+	BatchID    uint            `json:"batch_id"`
+	StockCount Stock           `json:"stock_count"`
+	Amounts    TotalAmounts    `json:"amounts"`
+	RentAmount decimal.Decimal `json:"rent_amount"`
 }
 
 type ProductStockDatas struct {
@@ -84,3 +88,90 @@ type ProductStockDatas struct {
 	TotalAmounts TotalAmounts ` json:"total_amounts"`
 	StokCount    Stock        ` json:"stock_count"`
 }
+
+// Stock movement reason codes
+const (
+	MovementOpeningBalance  = "opening_balance"
+	MovementBatchIntake     = "batch_intake"
+	MovementBillingOffboard = "billing_offboard"
+	MovementBillingReversal = "billing_reversal"
+	MovementAdjustment      = "stock_adjustment"
+	MovementTransferOut     = "transfer_out"
+	MovementTransferIn      = "transfer_in"
+)
+
+// Stock movement source documents
+const (
+	SourceBatch      = "batch"
+	SourceBilling    = "billing"
+	SourceCreditNote = "credit_note"
+	SourceAdjustment = "stock_adjustment"
+	SourceTransfer   = "transfer_order"
+)
+
+// StockMovement is an append-only ledger row for every change to BatchProductEntry.StockQuantity.
+// Quantity is signed: positive for inbound, negative for outbound.
+type StockMovement struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	WarehouseID  uint      `gorm:"not null;index" json:"warehouse_id"`
+	ProductID    uint      `gorm:"not null;index" json:"product_id"`
+	BatchID      uint      `gorm:"not null;index" json:"batch_id"`
+	EntryID      uint      `gorm:"not null;index" json:"entry_id"`
+	Quantity     int       `gorm:"not null" json:"quantity"`
+	BalanceAfter int       `gorm:"not null" json:"balance_after"`
+	Reason       string    `gorm:"size:50;not null;index" json:"reason"`
+	SourceType   string    `gorm:"size:50;not null;index:idx_stock_movement_source" json:"source_type"`
+	SourceID     uint      `gorm:"not null;index:idx_stock_movement_source" json:"source_id"`
+	UserID       uint      `gorm:"index" json:"user_id"`
+	Notes        string    `gorm:"type:text" json:"notes,omitempty"`
+	CreatedAt    time.Time `gorm:"index" json:"created_at"`
+}
+
+// StockConsistencyIssue reports an entry whose ledger balance does not match its StockQuantity
+type StockConsistencyIssue struct {
+	EntryID       uint `json:"entry_id"`
+	BatchID       uint `json:"batch_id"`
+	ProductID     uint `json:"product_id"`
+	StockQuantity int  `json:"stock_quantity"`
+	LedgerBalance int  `json:"ledger_balance"`
+	Difference    int  `json:"difference"`
+	Movements     int  `json:"movements"`
+}
+
+// StockConsistencyReport is the result of replaying the ledger against current stock
+type StockConsistencyReport struct {
+	WarehouseID    uint                    `json:"warehouse_id"`
+	CheckedEntries int                     `json:"checked_entries"`
+	Consistent     bool                    `json:"consistent"`
+	Issues         []StockConsistencyIssue `json:"issues"`
+}
+
+// ExpiringStock is one batch entry that has expired or expires within the report window
+type ExpiringStock struct {
+	BatchID        uint            `json:"batch_id"`
+	EntryID        uint            `json:"entry_id"`
+	ProductID      uint            `json:"product_id"`
+	ProductName    string          `json:"product_name"`
+	Category       string          `json:"category"`
+	LotNumber      string          `json:"lot_number"`
+	ManufacturedAt *time.Time      `json:"manufactured_at,omitempty"`
+	ExpiresAt      time.Time       `json:"expires_at"`
+	DaysLeft       int             `json:"days_left"` // negative once expired
+	Expired        bool            `json:"expired"`
+	StockQuantity  int             `json:"stock_quantity"`
+	BillingPrice   decimal.Decimal `json:"billing_price"`
+	StockValue     decimal.Decimal `json:"stock_value"`
+}
+
+// ExpiringStockReport lists stock of a warehouse expiring within Days, soonest first.
+// Stock that has already expired is included and can no longer be billed.
+type ExpiringStockReport struct {
+	WarehouseID   uint            `json:"warehouse_id"`
+	Days          int             `json:"days"`
+	AsOf          time.Time       `json:"as_of"`
+	ExpiredQty    int             `json:"expired_quantity"`
+	ExpiredValue  decimal.Decimal `json:"expired_value"`
+	ExpiringQty   int             `json:"expiring_quantity"`
+	ExpiringValue decimal.Decimal `json:"expiring_value"`
+	Items         []ExpiringStock `json:"items"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// TaxRate is the GST rate applied to products of a category. Categories without a rate
+// are billed at 0%.
+type TaxRate struct {
+	ID          uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	Category    string         `gorm:"type:varchar(255);not null;uniqueIndex" json:"category" binding:"required"` // matches Product.Category, case-insensitive
+	Rate        float64        `gorm:"type:decimal(5,2);not null" json:"rate" binding:"gte=0,lte=100"`            // percent
+	Description string         `gorm:"type:text" json:"description"`
+	CreatedAt   time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// TaxSummaryRow totals output tax for one HSN/SAC code and rate
+type TaxSummaryRow struct {
+	HSNCode      string          `json:"hsn_code"`
+	TaxRate      float64         `json:"tax_rate"`
+	TaxableValue decimal.Decimal `json:"taxable_value"`
+	CGSTAmount   decimal.Decimal `json:"cgst_amount"`
+	SGSTAmount   decimal.Decimal `json:"sgst_amount"`
+	IGSTAmount   decimal.Decimal `json:"igst_amount"`
+	TotalTax     decimal.Decimal `json:"total_tax"`
+}
+
+// TaxSummary is the output tax of a warehouse for a period: bills less credit notes
+type TaxSummary struct {
+	WarehouseID uint            `json:"warehouse_id"`
+	Currency    string          `json:"currency"` // reporting currency; bills are converted at their booked rates
+	From        time.Time       `json:"from"`
+	To          time.Time       `json:"to"` // exclusive
+	BillCount   int64           `json:"bill_count"`
+	CreditNotes int64           `json:"credit_note_count"`
+	Rows        []TaxSummaryRow `json:"rows"`
+	Totals      TaxSummaryRow   `json:"totals"`
+}
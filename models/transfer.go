@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// Transfer order statuses
+const (
+	TransferDraft      = "draft"
+	TransferDispatched = "dispatched"
+	TransferReceived   = "received"
+	TransferCancelled  = "cancelled"
+)
+
+// TransferOrder moves stock between warehouses: draft → dispatched → received.
+// Dispatch takes the stock out of the source; receipt books it into the destination.
+type TransferOrder struct {
+	ID                uint                `gorm:"primaryKey;autoIncrement" json:"id"`
+	SourceWarehouseID uint                `gorm:"not null;index" json:"source_warehouse_id"`
+	DestWarehouseID   uint                `gorm:"not null;index" json:"dest_warehouse_id"`
+	Status            string              `gorm:"type:varchar(30);not null;index" json:"status"`
+	Notes             string              `gorm:"type:text" json:"notes"`
+	Items             []TransferOrderItem `gorm:"foreignKey:TransferOrderID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"items"`
+	TotalArea         float64             `gorm:"type:decimal(12,2);not null;default:0" json:"total_area"` // in the source warehouse's capacity unit
+	CreatedBy         uint                `gorm:"index" json:"created_by"`
+	DispatchedBy      *uint               `json:"dispatched_by,omitempty"`
+	DispatchedAt      *time.Time          `json:"dispatched_at,omitempty"`
+	ReceivedBy        *uint               `json:"received_by,omitempty"`
+	ReceivedAt        *time.Time          `json:"received_at,omitempty"`
+	CreatedAt         time.Time           `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt         time.Time           `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt         gorm.DeletedAt      `gorm:"index" json:"-"`
+}
+
+// TransferOrderItem moves a quantity of one source batch entry. StoredAt carries the
+// source batch age over so rent keeps accruing from the original intake date;
+// RentBilledTo and RentMinimumMet are taken from the source entry at dispatch.
+type TransferOrderItem struct {
+	ID              uint            `gorm:"primaryKey;autoIncrement" json:"id"`
+	TransferOrderID uint            `gorm:"not null;index" json:"transfer_order_id"`
+	SourceBatchID   uint            `gorm:"not null;index" json:"source_batch_id"`
+	SourceEntryID   uint            `gorm:"not null;index" json:"source_entry_id"`
+	ProductID       uint            `gorm:"not null;index" json:"product_id"`
+	Quantity        int             `gorm:"not null" json:"quantity"`
+	BillingPrice    decimal.Decimal `gorm:"type:decimal(10,2);not null" json:"billing_price"`
+	OnBoardCost     decimal.Decimal `gorm:"type:decimal(12,4);not null;default:0" json:"onboard_cost_per_unit"`
+	StoredAt        time.Time       `json:"stored_at"`
+	RentBilledTo    *time.Time      `json:"rent_billed_to,omitempty"`
+	RentMinimumMet  bool            `gorm:"not null;default:false" json:"rent_minimum_met"`
+	LotNumber       string          `gorm:"type:varchar(100)" json:"lot_number,omitempty"`
+	ManufacturedAt  *time.Time      `json:"manufactured_at,omitempty"`
+	ExpiresAt       *time.Time      `json:"expires_at,omitempty"`
+	DestBatchID     *uint           `gorm:"index" json:"dest_batch_id,omitempty"`
+	DestEntryID     *uint           `json:"dest_entry_id,omitempty"`
+	CreatedAt       time.Time       `gorm:"autoCreateTime" json:"created_at"`
+}
+
+type TransferItemInput struct {
+	BatchID   uint `json:"batch_id" binding:"required"`
+	ProductID uint `json:"product_id" binding:"required"`
+	Quantity  int  `json:"quantity" binding:"required,gt=0"`
+}
+
+type TransferOrderInput struct {
+	DestWarehouseID uint                `json:"dest_warehouse_id" binding:"required"`
+	Notes           string              `json:"notes"`
+	Items           []TransferItemInput `json:"items" binding:"required,min=1,dive"`
+}
@@ -3,28 +3,58 @@ package models
 import (
 	"time"
 
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
 type Warehouse struct {
-	ID            uint           `gorm:"primaryKey;autoIncrement" json:"id"`
-	Name          string         `gorm:"type:varchar(255);not null" json:"name"`
-	Location      string         `gorm:"type:varchar(255)" json:"location"`
-	TotalArea     float64        `gorm:"type:decimal(10,2);not null" json:"total_area"`
-	AvailableArea float64        `gorm:"type:decimal(10,2);not null" json:"available_area"`
-	RentConfigID  uint           `gorm:"not null" json:"rent_config_id"`
-	RentConfig    RentRate       `gorm:"foreignKey:RentConfigID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"rent_config"`
-	CreatedAt     time.Time      `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt     time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
-	DeletedAt     gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                uint           `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name              string         `gorm:"type:varchar(255);not null" json:"name"`
+	Location          string         `gorm:"type:varchar(255)" json:"location"`
+	State             string         `gorm:"type:varchar(100)" json:"state"` // decides CGST/SGST vs IGST on bills
+	GSTIN             string         `gorm:"type:varchar(15)" json:"gstin"`
+	ReportingCurrency string         `gorm:"type:varchar(3)" json:"reporting_currency"`                     // analytics are converted into it (default: BASE_CURRENCY)
+	CapacityUnit      string         `gorm:"type:varchar(10);not null;default:'sqft'" json:"capacity_unit"` // sqft, sqm, m3 or pallet; areas and rent rates are in it
+	TotalArea         float64        `gorm:"type:decimal(10,2);not null" json:"total_area"`
+	AvailableArea     float64        `gorm:"type:decimal(10,2);not null" json:"available_area"`
+	RentConfigID      uint           `gorm:"not null" json:"rent_config_id"`
+	RentConfig        RentRate       `gorm:"foreignKey:RentConfigID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"rent_config"`
+	CreatedAt         time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt         time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
+// RentRate is the warehouse's latest rent configuration. Rent is always calculated from
+// the effective-dated RentRateVersion history, so editing it never reprices past days.
 type RentRate struct {
-	ID           uint           `gorm:"primaryKey;autoIncrement" json:"id"`
-	RatePerSqft  float64        `gorm:"type:decimal(10,2);not null" json:"rate_per_sqft"`
-	Currency     string         `gorm:"type:varchar(10);default:'INR'" json:"currency"`
-	BillingCycle string         `gorm:"type:varchar(50);default:'monthly'" json:"billing_cycle"`
-	CreatedAt    time.Time      `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt    time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+	ID            uint            `gorm:"primaryKey;autoIncrement" json:"id"`
+	RatePerSqft   decimal.Decimal `gorm:"type:decimal(10,2);not null" json:"rate_per_sqft"` // per capacity unit of the warehouse; the name predates other units
+	Currency      string          `gorm:"type:varchar(10);default:'INR'" json:"currency"`
+	BillingCycle  string          `gorm:"type:varchar(50);default:'monthly'" json:"billing_cycle"` // daily, weekly, monthly, quarterly
+	MinimumCharge decimal.Decimal `gorm:"type:decimal(10,2);not null;default:0" json:"minimum_charge"`
+	EffectiveFrom *time.Time      `gorm:"-" json:"effective_from,omitempty"` // when a change takes effect (default: now)
+	CreatedAt     time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt     gorm.DeletedAt  `gorm:"index" json:"-"`
+}
+
+// RentRateVersion is one effective-dated entry of a warehouse's rent history
+type RentRateVersion struct {
+	ID            uint            `gorm:"primaryKey;autoIncrement" json:"id"`
+	WarehouseID   uint            `gorm:"not null;index:idx_rent_version_effective" json:"warehouse_id"`
+	RatePerSqft   decimal.Decimal `gorm:"type:decimal(10,2);not null" json:"rate_per_sqft"` // per capacity unit of the warehouse
+	Currency      string          `gorm:"type:varchar(10);default:'INR'" json:"currency"`
+	BillingCycle  string          `gorm:"type:varchar(50);default:'monthly'" json:"billing_cycle"`
+	MinimumCharge decimal.Decimal `gorm:"type:decimal(10,2);not null;default:0" json:"minimum_charge"`
+	EffectiveFrom time.Time       `gorm:"not null;index:idx_rent_version_effective" json:"effective_from"`
+	CreatedBy     uint            `json:"created_by"`
+	CreatedAt     time.Time       `gorm:"autoCreateTime" json:"created_at"`
+}
+
+type RentRateVersionInput struct {
+	RatePerSqft   decimal.Decimal `json:"rate_per_sqft" binding:"gte=0"`
+	Currency      string          `json:"currency"`
+	BillingCycle  string          `json:"billing_cycle" binding:"omitempty,oneof=daily weekly monthly quarterly"`
+	MinimumCharge decimal.Decimal `json:"minimum_charge" binding:"gte=0"`
+	EffectiveFrom *time.Time      `json:"effective_from"`
 }
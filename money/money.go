@@ -0,0 +1,105 @@
+// Package money holds the rules for amounts: exact decimals kept to two places (rupees
+// and paise) and an explicit choice of where a document rounds them.
+package money
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Places is the number of decimal places amounts are stored and shown with
+const Places = 2
+
+// UnitPlaces is the precision of per-unit costs that are spread over many units, like the
+// onboarding cost of a batch entry
+const UnitPlaces = 4
+
+// Rounding rules. Either way a document total is the exact sum of its rounded lines;
+// the rules differ in which figure is rounded.
+const (
+	// PerLine rounds every line on its own; the total is whatever the lines add up to
+	PerLine = "line"
+	// PerDocument rounds the exact total once and spreads the paise over the lines, so
+	// the lines still add up to it
+	PerDocument = "document"
+)
+
+// DefaultRounding is used when neither the configuration nor the request picks a rule
+const DefaultRounding = PerLine
+
+// cent is the smallest amount kept
+var cent = decimal.New(1, -Places)
+
+// Round rounds an amount to Places, half away from zero
+func Round(d decimal.Decimal) decimal.Decimal {
+	return d.Round(Places)
+}
+
+// Qty converts a quantity for multiplication with a price
+func Qty(n int) decimal.Decimal {
+	return decimal.NewFromInt(int64(n))
+}
+
+// Sum adds amounts exactly
+func Sum(amounts ...decimal.Decimal) decimal.Decimal {
+	total := decimal.Zero
+	for _, a := range amounts {
+		total = total.Add(a)
+	}
+	return total
+}
+
+// ValidRounding reports whether rule is a supported rounding rule
+func ValidRounding(rule string) bool {
+	return rule == PerLine || rule == PerDocument
+}
+
+// NormalizeRounding maps a configured rounding rule to a supported one
+func NormalizeRounding(rule string) string {
+	if r := strings.ToLower(strings.TrimSpace(rule)); ValidRounding(r) {
+		return r
+	}
+	return DefaultRounding
+}
+
+// RoundLines rounds the exact amounts of a document's lines under rule. Per line each
+// amount is rounded on its own. Per document the lines are rounded first and the paise
+// still missing from the rounded exact total go to the lines that lost the most in
+// rounding (the earliest line on a tie), so the result adds up to Round(Sum(exact)).
+func RoundLines(exact []decimal.Decimal, rule string) []decimal.Decimal {
+	rounded := make([]decimal.Decimal, len(exact))
+	for i, a := range exact {
+		rounded[i] = Round(a)
+	}
+	if rule != PerDocument || len(exact) == 0 {
+		return rounded
+	}
+
+	missing := Round(Sum(exact...)).Sub(Sum(rounded...)).Div(cent).IntPart()
+	if missing == 0 {
+		return rounded
+	}
+	step := cent
+	if missing < 0 {
+		step, missing = cent.Neg(), -missing
+	}
+
+	// Lines ordered by how much rounding took away from them in the direction of step
+	order := make([]int, len(exact))
+	for i := range order {
+		order[i] = i
+	}
+	lost := func(i int) decimal.Decimal {
+		return exact[i].Sub(rounded[i]).Mul(step)
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return lost(order[a]).GreaterThan(lost(order[b]))
+	})
+	for k := int64(0); k < missing; k++ {
+		i := order[k%int64(len(order))]
+		rounded[i] = rounded[i].Add(step)
+	}
+	return rounded
+}
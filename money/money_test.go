@@ -0,0 +1,56 @@
+package money
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func amounts(values ...string) []decimal.Decimal {
+	out := make([]decimal.Decimal, len(values))
+	for i, v := range values {
+		out[i] = decimal.RequireFromString(v)
+	}
+	return out
+}
+
+func TestRoundLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		exact []decimal.Decimal
+		rule  string
+		want  []decimal.Decimal
+	}{
+		{"per line rounds each", amounts("0.005", "0.005", "0.005"), PerLine, amounts("0.01", "0.01", "0.01")},
+		{"per document rounds the total", amounts("0.005", "0.005", "0.005"), PerDocument, amounts("0", "0.01", "0.01")},
+		{"per document adds missing paise", amounts("0.334", "0.334", "0.334"), PerDocument, amounts("0.34", "0.33", "0.33")},
+		{"largest loss gets the paisa", amounts("1.001", "2.004", "3.003"), PerDocument, amounts("1", "2.01", "3")},
+		{"negative amounts", amounts("-0.334", "-0.334", "-0.334"), PerDocument, amounts("-0.34", "-0.33", "-0.33")},
+		{"already exact", amounts("10.10", "0.99"), PerDocument, amounts("10.10", "0.99")},
+		{"no lines", nil, PerDocument, []decimal.Decimal{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RoundLines(tt.exact, tt.rule)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d lines, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("line %d = %s, want %s", i, got[i], tt.want[i])
+				}
+			}
+			if tt.rule == PerDocument && !Sum(got...).Equal(Round(Sum(tt.exact...))) {
+				t.Errorf("lines add up to %s, want %s", Sum(got...), Round(Sum(tt.exact...)))
+			}
+		})
+	}
+}
+
+func TestNormalizeRounding(t *testing.T) {
+	for in, want := range map[string]string{"": PerLine, "line": PerLine, " Document ": PerDocument, "bogus": PerLine} {
+		if got := NormalizeRounding(in); got != want {
+			t.Errorf("NormalizeRounding(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+// Package putaway proposes the bins goods coming into a warehouse are stored in. Each
+// item is placed bin by bin, preferring in order:
+//
+//  1. bins that already hold the same product, then the same category, then empty
+//     bins, so goods stay together and categories do not mix;
+//  2. a bin that takes everything left of the item, so it is split over as few bins
+//     as possible;
+//  3. for fast movers the bins nearest dispatch, for slow movers the farthest, which
+//     keeps the near bins free for the goods picked most;
+//  4. the tightest bin that takes the rest whole, else the roomiest one, so large free
+//     bins are not broken up for small lots.
+//
+// No bin, or location around it with a capacity of its own, is filled past its free
+// space. Ties are broken by bin code, so a plan is deterministic.
+package putaway
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Movement classes of a product
+const (
+	Fast   = "fast"
+	Slow   = "slow"
+	Normal = ""
+)
+
+// Tolerance absorbs the rounding of fractional footprints when space is compared with
+// free capacity
+const Tolerance = 0.001
+
+// ErrNoRoom is returned when the bins cannot take an item
+var ErrNoRoom = errors.New("no room")
+
+// Bin is a storage bin and what it already holds. Space is in the warehouse's capacity
+// unit.
+type Bin struct {
+	ID         uint
+	Code       string
+	Free       float64
+	Distance   float64         // from dispatch, smaller is nearer
+	Limits     []uint          // enclosing locations with a capacity of their own
+	Products   map[uint]bool   // products stored in it
+	Categories map[string]bool // categories stored in it
+}
+
+// Item is a quantity of one product to put away
+type Item struct {
+	ProductID    uint
+	Category     string
+	Quantity     int
+	SpacePerUnit float64
+	Movement     string // Fast, Slow or Normal
+}
+
+// Placement is a quantity put into a bin and why the bin was chosen
+type Placement struct {
+	BinID    uint
+	Code     string
+	Quantity int
+	Reason   string
+}
+
+// Layout is the free space of a warehouse's bins while a plan is made
+type Layout struct {
+	bins   []*Bin
+	limits map[uint]float64
+}
+
+// NewLayout takes the bins and the free space of the enclosing locations in their
+// Limits. The bins are copied, so planning does not change the caller's.
+func NewLayout(bins []Bin, limits map[uint]float64) *Layout {
+	l := &Layout{limits: map[uint]float64{}}
+	for id, free := range limits {
+		l.limits[id] = free
+	}
+	for _, b := range bins {
+		b := b
+		b.Products = copySet(b.Products)
+		b.Categories = copySet(b.Categories)
+		l.bins = append(l.bins, &b)
+	}
+	sort.SliceStable(l.bins, func(i, j int) bool { return l.bins[i].Code < l.bins[j].Code })
+	return l
+}
+
+// Take records qty units of item put into a bin chosen by the caller. It fails when the
+// bin is unknown or has no room for them.
+func (l *Layout) Take(binID uint, item Item, qty int) (Placement, error) {
+	for _, b := range l.bins {
+		if b.ID != binID {
+			continue
+		}
+		if l.fits(b, item.SpacePerUnit) < qty {
+			return Placement{}, fmt.Errorf("%w in bin %s for %d units of product %d", ErrNoRoom, b.Code, qty, item.ProductID)
+		}
+		l.place(b, item, qty)
+		return Placement{BinID: b.ID, Code: b.Code, Quantity: qty, Reason: "requested"}, nil
+	}
+	return Placement{}, fmt.Errorf("%w: bin %d is not in the layout", ErrNoRoom, binID)
+}
+
+// Place plans item's quantity over the bins and records it in the layout, so the next
+// item sees what is left
+func (l *Layout) Place(item Item) ([]Placement, error) {
+	var placements []Placement
+	remaining := item.Quantity
+	for remaining > 0 {
+		var (
+			best     *Bin
+			bestFits int
+		)
+		for _, b := range l.bins {
+			fits := l.fits(b, item.SpacePerUnit)
+			if fits <= 0 {
+				continue
+			}
+			if best == nil || l.better(b, fits, best, bestFits, item, remaining) {
+				best, bestFits = b, fits
+			}
+		}
+		if best == nil {
+			return nil, fmt.Errorf("%w left in the bins for %d units of product %d", ErrNoRoom, remaining, item.ProductID)
+		}
+
+		qty := min(bestFits, remaining)
+		reason := l.reason(best, item, qty == remaining)
+		l.place(best, item, qty)
+		placements = append(placements, Placement{BinID: best.ID, Code: best.Code, Quantity: qty, Reason: reason})
+		remaining -= qty
+	}
+	return placements, nil
+}
+
+// fits is how many units of the given footprint a bin still takes
+func (l *Layout) fits(b *Bin, perUnit float64) int {
+	free := b.Free
+	for _, id := range b.Limits {
+		free = math.Min(free, l.limits[id])
+	}
+	if perUnit <= 0 {
+		if free > 0 {
+			return math.MaxInt32
+		}
+		return 0
+	}
+	return int(math.Floor((free + Tolerance) / perUnit))
+}
+
+func (l *Layout) place(b *Bin, item Item, qty int) {
+	space := item.SpacePerUnit * float64(qty)
+	b.Free -= space
+	for _, id := range b.Limits {
+		l.limits[id] -= space
+	}
+	if b.Products == nil {
+		b.Products = map[uint]bool{}
+	}
+	if b.Categories == nil {
+		b.Categories = map[string]bool{}
+	}
+	b.Products[item.ProductID] = true
+	b.Categories[item.Category] = true
+}
+
+// affinity ranks how well an item goes with what a bin holds
+func affinity(b *Bin, item Item) int {
+	switch {
+	case b.Products[item.ProductID]:
+		return 3
+	case item.Category != "" && b.Categories[item.Category]:
+		return 2
+	case len(b.Products) == 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (l *Layout) better(a *Bin, aFits int, b *Bin, bFits int, item Item, remaining int) bool {
+	if x, y := affinity(a, item), affinity(b, item); x != y {
+		return x > y
+	}
+	aWhole, bWhole := aFits >= remaining, bFits >= remaining
+	if aWhole != bWhole {
+		return aWhole
+	}
+	if a.Distance != b.Distance {
+		switch item.Movement {
+		case Fast:
+			return a.Distance < b.Distance
+		case Slow:
+			return a.Distance > b.Distance
+		}
+	}
+	if aFits != bFits {
+		if aWhole {
+			return aFits < bFits
+		}
+		return aFits > bFits
+	}
+	return a.Code < b.Code
+}
+
+func (l *Layout) reason(b *Bin, item Item, whole bool) string {
+	var parts []string
+	switch affinity(b, item) {
+	case 3:
+		parts = append(parts, "holds the same product")
+	case 2:
+		parts = append(parts, "holds the same category")
+	case 1:
+		parts = append(parts, "empty bin")
+	default:
+		parts = append(parts, "shared with other goods")
+	}
+	switch item.Movement {
+	case Fast:
+		parts = append(parts, "fast mover near dispatch")
+	case Slow:
+		parts = append(parts, "slow mover away from dispatch")
+	}
+	if whole {
+		parts = append(parts, "takes the rest of the lot")
+	} else {
+		parts = append(parts, "most free space")
+	}
+	return strings.Join(parts, ", ")
+}
+
+func copySet[K comparable](set map[K]bool) map[K]bool {
+	out := make(map[K]bool, len(set))
+	for k, v := range set {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,78 @@
+package putaway
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func codes(placements []Placement) []string {
+	var out []string
+	for _, p := range placements {
+		out = append(out, p.Code)
+	}
+	return out
+}
+
+func TestPlace(t *testing.T) {
+	bins := []Bin{
+		{ID: 1, Code: "A-1", Free: 10, Distance: 5},
+		{ID: 2, Code: "A-2", Free: 4, Distance: 5, Products: map[uint]bool{7: true}, Categories: map[string]bool{"oil": true}},
+		{ID: 3, Code: "B-1", Free: 10, Distance: 50},
+		{ID: 4, Code: "B-2", Free: 3, Distance: 50, Products: map[uint]bool{9: true}, Categories: map[string]bool{"grain": true}},
+	}
+
+	tests := []struct {
+		name string
+		item Item
+		want []string
+	}{
+		{"same product first, rest into the tightest empty bin", Item{ProductID: 7, Category: "oil", Quantity: 6, SpacePerUnit: 1}, []string{"A-2", "A-1"}},
+		{"same category before empty bins", Item{ProductID: 8, Category: "grain", Quantity: 2, SpacePerUnit: 1}, []string{"B-2"}},
+		{"whole fit beats a split", Item{ProductID: 8, Category: "spice", Quantity: 9, SpacePerUnit: 1}, []string{"A-1"}},
+		{"fast movers near dispatch", Item{ProductID: 8, Category: "spice", Quantity: 2, SpacePerUnit: 1, Movement: Fast}, []string{"A-1"}},
+		{"slow movers away from dispatch", Item{ProductID: 8, Category: "spice", Quantity: 2, SpacePerUnit: 1, Movement: Slow}, []string{"B-1"}},
+		{"too big for one bin splits roomiest first", Item{ProductID: 8, Category: "spice", Quantity: 15, SpacePerUnit: 1}, []string{"A-1", "B-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewLayout(bins, nil).Place(tt.item)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if g := codes(got); !slices.Equal(g, tt.want) {
+				t.Errorf("bins = %v, want %v", g, tt.want)
+			}
+			total := 0
+			for _, p := range got {
+				total += p.Quantity
+			}
+			if total != tt.item.Quantity {
+				t.Errorf("placed %d units, want %d", total, tt.item.Quantity)
+			}
+		})
+	}
+}
+
+func TestPlaceRespectsCapacity(t *testing.T) {
+	// Two empty bins in a rack that has room for 5 more units only
+	layout := NewLayout([]Bin{
+		{ID: 1, Code: "R-1", Free: 4, Limits: []uint{100}},
+		{ID: 2, Code: "R-2", Free: 4, Limits: []uint{100}},
+	}, map[uint]float64{100: 5})
+
+	placed, err := layout.Place(Item{ProductID: 1, Quantity: 5, SpacePerUnit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(placed) != 2 || placed[0].Quantity != 4 || placed[1].Quantity != 1 {
+		t.Errorf("placements = %+v, want 4 in R-1 and 1 in R-2", placed)
+	}
+
+	if _, err := layout.Place(Item{ProductID: 1, Quantity: 1, SpacePerUnit: 1}); !errors.Is(err, ErrNoRoom) {
+		t.Errorf("full rack: err = %v, want ErrNoRoom", err)
+	}
+	if _, err := layout.Take(2, Item{ProductID: 2, SpacePerUnit: 0.5}, 1); !errors.Is(err, ErrNoRoom) {
+		t.Errorf("requested bin in a full rack: err = %v, want ErrNoRoom", err)
+	}
+}
@@ -0,0 +1,252 @@
+// Package rent is the single place storage rent is calculated. It works on calendar
+// days over half-open ranges [from, to), splits a stay across effective-dated rate
+// versions and prorates each piece according to the version's billing cycle.
+//
+// A stay is the time goods spend in the warehouse, from intake until they are
+// offboarded. The minimum charge is a floor for the rent of a whole stay, paid once: the
+// charge made when goods first leave (ForStay or Unbilled) is raised so the stay, together
+// with what periodic rent invoices already charged for it, costs at least the minimum.
+// Later offboardings of the same stay and periodic invoices (Calculate) never apply it.
+package rent
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Billing cycles
+const (
+	Daily     = "daily"
+	Weekly    = "weekly"
+	Monthly   = "monthly"
+	Quarterly = "quarterly"
+)
+
+// Rate is one version of a warehouse's rent configuration
+type Rate struct {
+	RatePerUnit   decimal.Decimal // per unit of the warehouse's capacity (sqft, sqm, m3 or pallet) and cycle
+	Currency      string
+	Cycle         string
+	MinimumCharge decimal.Decimal // floor for the rent of a whole stay, 0 = none
+	EffectiveFrom time.Time
+}
+
+// Segment is the part of a charge priced with a single rate version
+type Segment struct {
+	From        time.Time       `json:"from"`
+	To          time.Time       `json:"to"`
+	Days        int             `json:"days"`
+	Periods     decimal.Decimal `json:"periods"` // billing cycles covered, prorated
+	RatePerUnit decimal.Decimal `json:"rate_per_unit"`
+	Cycle       string          `json:"cycle"`
+	Amount      decimal.Decimal `json:"amount"` // unrounded; documents round it
+}
+
+// Charge is the rent for an area over a date range. Amount is exact; the bill or rent
+// invoice it lands on decides where it is rounded.
+type Charge struct {
+	From           time.Time       `json:"from"`
+	To             time.Time       `json:"to"`
+	Days           int             `json:"days"`
+	Area           float64         `json:"area"` // in the warehouse's capacity unit
+	Amount         decimal.Decimal `json:"amount"`
+	Currency       string          `json:"currency"`
+	MinimumApplied bool            `json:"minimum_applied"`
+	Segments       []Segment       `json:"segments"`
+}
+
+// NormalizeCycle maps a configured billing cycle to a supported one (monthly by default)
+func NormalizeCycle(cycle string) string {
+	switch c := strings.ToLower(strings.TrimSpace(cycle)); c {
+	case Daily, Weekly, Monthly, Quarterly:
+		return c
+	default:
+		return Monthly
+	}
+}
+
+// Day truncates t to the start of its calendar day
+func Day(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// DaysBetween counts the calendar days in [from, to)
+func DaysBetween(from, to time.Time) int {
+	from, to = Day(from), Day(to)
+	if !to.After(from) {
+		return 0
+	}
+	// Round to absorb DST shifts
+	return int(to.Sub(from).Hours()/24 + 0.5)
+}
+
+// Calculate charges area, a quantity of the warehouse's capacity unit, for the calendar
+// days in [from, to). Days before the first rate version are priced with that first
+// version. Only the days are priced; the minimum charge is left to the end of the stay.
+func Calculate(rates []Rate, area float64, from, to time.Time) Charge {
+	charge, _ := calculate(rates, area, from, to)
+	return charge
+}
+
+// calculate is Calculate that also returns the rate version in effect at the end of the
+// range
+func calculate(rates []Rate, area float64, from, to time.Time) (Charge, Rate) {
+	from, to = Day(from), Day(to)
+	charge := Charge{From: from, To: to, Area: area, Days: DaysBetween(from, to), Segments: []Segment{}}
+	if len(rates) == 0 {
+		return charge, Rate{}
+	}
+
+	sorted := make([]Rate, len(rates))
+	copy(sorted, rates)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].EffectiveFrom.Before(sorted[j].EffectiveFrom)
+	})
+
+	last := sorted[0]
+	for i, rate := range sorted {
+		if i > 0 && Day(rate.EffectiveFrom).Before(to) {
+			last = rate
+		}
+		segFrom := from
+		if i > 0 {
+			if start := Day(rate.EffectiveFrom); start.After(segFrom) {
+				segFrom = start
+			}
+		}
+		segTo := to
+		if i+1 < len(sorted) {
+			if next := Day(sorted[i+1].EffectiveFrom); next.Before(segTo) {
+				segTo = next
+			}
+		}
+		if !segTo.After(segFrom) {
+			continue
+		}
+
+		cycle := NormalizeCycle(rate.Cycle)
+		periods := Periods(cycle, segFrom, segTo)
+		amount := rate.RatePerUnit.Mul(decimal.NewFromFloat(area)).Mul(periods)
+
+		charge.Segments = append(charge.Segments, Segment{
+			From:        segFrom,
+			To:          segTo,
+			Days:        DaysBetween(segFrom, segTo),
+			Periods:     periods,
+			RatePerUnit: rate.RatePerUnit,
+			Cycle:       cycle,
+			Amount:      amount,
+		})
+		charge.Amount = charge.Amount.Add(amount)
+	}
+	charge.Currency = last.Currency
+	return charge, last
+}
+
+// ForStay charges a whole stay from intake until the given time, at least the minimum
+// charge. The intake day is always billed, so same-day offboarding is charged one day.
+func ForStay(rates []Rate, area float64, storedAt, until time.Time) Charge {
+	charge, last := calculate(rates, area, storedAt, stayEnd(storedAt, until))
+	return withMinimum(charge, decimal.Zero, last.MinimumCharge)
+}
+
+// Unbilled charges the part of a stay not yet covered by periodic rent invoices. A nil
+// billedTo means nothing was invoiced and the whole stay is charged. Unless minimumMet
+// says an earlier offboarding already settled it, the charge is raised, when needed, so
+// that with what was invoiced the stay pays the minimum charge.
+func Unbilled(rates []Rate, area float64, storedAt time.Time, billedTo *time.Time, until time.Time, minimumMet bool) Charge {
+	var (
+		charge   Charge
+		last     Rate
+		invoiced = decimal.Zero
+	)
+	if billedTo == nil || !Day(*billedTo).After(Day(storedAt)) {
+		charge, last = calculate(rates, area, storedAt, stayEnd(storedAt, until))
+	} else {
+		charge, last = calculate(rates, area, *billedTo, until)
+		invoiced = Calculate(rates, area, storedAt, *billedTo).Amount
+	}
+	if minimumMet {
+		return charge
+	}
+	return withMinimum(charge, invoiced, last.MinimumCharge)
+}
+
+// stayEnd is the end of a stay offboarded at until; it covers at least the intake day
+func stayEnd(storedAt, until time.Time) time.Time {
+	end := Day(until)
+	if minEnd := Day(storedAt).AddDate(0, 0, 1); end.Before(minEnd) {
+		end = minEnd
+	}
+	return end
+}
+
+// withMinimum raises the last charge of a stay so the stay, with what was invoiced for
+// it before, costs at least minimum
+func withMinimum(charge Charge, invoiced, minimum decimal.Decimal) Charge {
+	if charge.Area <= 0 || !minimum.IsPositive() {
+		return charge
+	}
+	if short := minimum.Sub(invoiced); charge.Amount.LessThan(short) {
+		charge.Amount = short
+		charge.MinimumApplied = true
+	}
+	return charge
+}
+
+// Periods returns how many billing cycles [from, to) covers. Monthly and quarterly
+// cycles are prorated by the actual length of each calendar month or quarter.
+func Periods(cycle string, from, to time.Time) decimal.Decimal {
+	from, to = Day(from), Day(to)
+	days := DaysBetween(from, to)
+	if days == 0 {
+		return decimal.Zero
+	}
+
+	switch NormalizeCycle(cycle) {
+	case Daily:
+		return decimal.NewFromInt(int64(days))
+	case Weekly:
+		return share(days, 7)
+	case Quarterly:
+		return prorate(from, to, quarterBounds)
+	default:
+		return prorate(from, to, monthBounds)
+	}
+}
+
+// prorate sums, for every calendar period touched by [from, to), the share of that
+// period's days that fall inside the range.
+func prorate(from, to time.Time, bounds func(time.Time) (time.Time, time.Time)) decimal.Decimal {
+	periods := decimal.Zero
+	for cur := from; cur.Before(to); {
+		start, end := bounds(cur)
+		segEnd := end
+		if to.Before(segEnd) {
+			segEnd = to
+		}
+		periods = periods.Add(share(DaysBetween(cur, segEnd), DaysBetween(start, end)))
+		cur = segEnd
+	}
+	return periods
+}
+
+// share is days out of a period of length days, to the decimal division precision
+func share(days, length int) decimal.Decimal {
+	return decimal.NewFromInt(int64(days)).Div(decimal.NewFromInt(int64(length)))
+}
+
+func monthBounds(t time.Time) (time.Time, time.Time) {
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	return start, start.AddDate(0, 1, 0)
+}
+
+func quarterBounds(t time.Time) (time.Time, time.Time) {
+	firstMonth := time.Month((int(t.Month())-1)/3*3 + 1)
+	start := time.Date(t.Year(), firstMonth, 1, 0, 0, 0, 0, t.Location())
+	return start, start.AddDate(0, 3, 0)
+}
@@ -0,0 +1,157 @@
+package rent
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/shopspring/decimal"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func frac(days, length int64) decimal.Decimal {
+	return decimal.NewFromInt(days).Div(decimal.NewFromInt(length))
+}
+
+func TestPeriods(t *testing.T) {
+	tests := []struct {
+		name     string
+		cycle    string
+		from, to time.Time
+		want     decimal.Decimal
+	}{
+		{"empty range", Monthly, date(2025, 1, 10), date(2025, 1, 10), decimal.Zero},
+		{"daily", Daily, date(2025, 1, 10), date(2025, 1, 13), decimal.NewFromInt(3)},
+		{"weekly", Weekly, date(2025, 1, 1), date(2025, 1, 11), frac(10, 7)},
+		{"whole month", Monthly, date(2025, 1, 1), date(2025, 2, 1), decimal.NewFromInt(1)},
+		{"whole leap February", Monthly, date(2024, 2, 1), date(2024, 3, 1), decimal.NewFromInt(1)},
+		{"across a month end", Monthly, date(2025, 1, 15), date(2025, 2, 15), frac(17, 31).Add(frac(14, 28))},
+		{"last day of a month", Monthly, date(2025, 4, 30), date(2025, 5, 1), frac(1, 30)},
+		{"unknown cycle is monthly", "fortnightly", date(2025, 1, 1), date(2025, 2, 1), decimal.NewFromInt(1)},
+		{"whole quarter", Quarterly, date(2025, 1, 1), date(2025, 4, 1), decimal.NewFromInt(1)},
+		{"across a quarter end", Quarterly, date(2025, 3, 2), date(2025, 4, 2), frac(30, 90).Add(frac(1, 91))},
+		{"across a year end", Quarterly, date(2024, 12, 31), date(2025, 1, 2), frac(1, 92).Add(frac(1, 90))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Periods(tt.cycle, tt.from, tt.to); !got.Equal(tt.want) {
+				t.Errorf("Periods = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDaysBetweenAcrossDST(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     int
+	}{
+		{"clocks go forward", time.Date(2025, 3, 8, 15, 0, 0, 0, ny), time.Date(2025, 3, 10, 9, 0, 0, 0, ny), 2},
+		{"clocks go back", time.Date(2025, 11, 1, 0, 0, 0, 0, ny), time.Date(2025, 11, 3, 0, 0, 0, 0, ny), 2},
+		{"same day", time.Date(2025, 3, 9, 1, 0, 0, 0, ny), time.Date(2025, 3, 9, 23, 0, 0, 0, ny), 0},
+		{"backwards", time.Date(2025, 3, 10, 0, 0, 0, 0, ny), time.Date(2025, 3, 8, 0, 0, 0, 0, ny), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DaysBetween(tt.from, tt.to); got != tt.want {
+				t.Errorf("DaysBetween = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	// A whole March is still one month though it is an hour short
+	if got := Periods(Monthly, time.Date(2025, 3, 1, 0, 0, 0, 0, ny), time.Date(2025, 4, 1, 0, 0, 0, 0, ny)); !got.Equal(decimal.NewFromInt(1)) {
+		t.Errorf("Periods over DST = %s, want 1", got)
+	}
+}
+
+func TestCalculateSplitsAtVersionBoundaries(t *testing.T) {
+	rates := []Rate{
+		// Given out of order on purpose
+		{RatePerUnit: decimal.NewFromInt(2), Currency: "INR", Cycle: Daily, EffectiveFrom: date(2025, 1, 10)},
+		{RatePerUnit: decimal.NewFromInt(1), Currency: "INR", Cycle: Daily, EffectiveFrom: date(2025, 1, 1)},
+	}
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     int64
+		segments int
+	}{
+		{"before the change", date(2025, 1, 2), date(2025, 1, 5), 3, 1},
+		{"across the change", date(2025, 1, 5), date(2025, 1, 15), 5*1 + 5*2, 2},
+		{"ends on the change", date(2025, 1, 5), date(2025, 1, 10), 5, 1},
+		{"starts on the change", date(2025, 1, 10), date(2025, 1, 12), 4, 1},
+		{"before the first version", date(2024, 12, 30), date(2025, 1, 2), 3, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			charge := Calculate(rates, 1, tt.from, tt.to)
+			if !charge.Amount.Equal(decimal.NewFromInt(tt.want)) {
+				t.Errorf("Amount = %s, want %d", charge.Amount, tt.want)
+			}
+			if len(charge.Segments) != tt.segments {
+				t.Errorf("%d segments, want %d", len(charge.Segments), tt.segments)
+			}
+		})
+	}
+}
+
+func TestMinimumChargeIsPaidOncePerStay(t *testing.T) {
+	rates := []Rate{{RatePerUnit: decimal.NewFromInt(1), Currency: "INR", Cycle: Daily, MinimumCharge: decimal.NewFromInt(8), EffectiveFrom: date(2025, 1, 1)}}
+	storedAt := date(2025, 1, 1)
+	billedTo := date(2025, 1, 6) // 5 days invoiced
+
+	tests := []struct {
+		name    string
+		charge  Charge
+		want    int64
+		applied bool
+	}{
+		{"same-day stay is one day, raised to the minimum", ForStay(rates, 1, storedAt.Add(9*time.Hour), storedAt.Add(17*time.Hour)), 8, true},
+		{"stay above the minimum", ForStay(rates, 1, storedAt, date(2025, 1, 11)), 10, false},
+		{"empty area pays nothing", ForStay(rates, 0, storedAt, date(2025, 1, 3)), 0, false},
+		{"periodic invoices never apply it", Calculate(rates, 1, storedAt, billedTo), 5, false},
+		{"offboarding tops up what was invoiced", Unbilled(rates, 1, storedAt, &billedTo, date(2025, 1, 7), false), 3, true},
+		{"offboarding after the minimum was reached", Unbilled(rates, 1, storedAt, &billedTo, date(2025, 1, 10), false), 4, false},
+		{"nothing invoiced is the whole stay", Unbilled(rates, 1, storedAt, nil, date(2025, 1, 3), false), 8, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.charge.Amount.Equal(decimal.NewFromInt(tt.want)) || tt.charge.MinimumApplied != tt.applied {
+				t.Errorf("charge = %s (minimum applied %v), want %d (%v)", tt.charge.Amount, tt.charge.MinimumApplied, tt.want, tt.applied)
+			}
+		})
+	}
+
+	// Invoiced days and the offboarding charge together pay the minimum exactly once
+	invoiced := Calculate(rates, 1, storedAt, billedTo).Amount
+	rest := Unbilled(rates, 1, storedAt, &billedTo, date(2025, 1, 7), false).Amount
+	if total := invoiced.Add(rest); !total.Equal(decimal.NewFromInt(8)) {
+		t.Errorf("stay paid %s in total, want the minimum of 8", total)
+	}
+}
+
+func TestMinimumChargeIsNotPaidAgainOnALaterOffboarding(t *testing.T) {
+	rates := []Rate{{RatePerUnit: decimal.NewFromInt(1), Currency: "INR", Cycle: Daily, MinimumCharge: decimal.NewFromInt(8), EffectiveFrom: date(2025, 1, 1)}}
+	storedAt := date(2025, 1, 1)
+
+	// Half the entry leaves after 3 days and settles the minimum, the rest after 5
+	first := Unbilled(rates, 1, storedAt, nil, date(2025, 1, 4), false)
+	second := Unbilled(rates, 1, storedAt, nil, date(2025, 1, 6), true)
+
+	if !first.Amount.Equal(decimal.NewFromInt(8)) || !first.MinimumApplied {
+		t.Errorf("first offboarding = %s (minimum applied %v), want 8 (true)", first.Amount, first.MinimumApplied)
+	}
+	if !second.Amount.Equal(decimal.NewFromInt(5)) || second.MinimumApplied {
+		t.Errorf("second offboarding = %s (minimum applied %v), want 5 (false)", second.Amount, second.MinimumApplied)
+	}
+}
@@ -0,0 +1,414 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	dbconn "warehouse/config/dbConn"
+	"warehouse/models"
+	"warehouse/money"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+type StockAdjustmentRepo struct{}
+
+// NewStockAdjustmentRepo initializes the cycle-count / stock adjustment repository
+func NewStockAdjustmentRepo() *StockAdjustmentRepo {
+	return &StockAdjustmentRepo{}
+}
+
+// ErrInvalidAdjustmentState is returned when a workflow step is not allowed in the current status
+var ErrInvalidAdjustmentState = errors.New("invalid adjustment state")
+
+// CreateCountSheet opens a stock adjustment with one line per in-stock batch entry,
+// snapshotting the system quantity the physical count will be compared against.
+func (r *StockAdjustmentRepo) CreateCountSheet(ctx context.Context, warehouseId, userID uint, input models.StockAdjustmentInput) (*models.StockAdjustment, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	adjustment := models.StockAdjustment{
+		WarehouseID: warehouseId,
+		BatchID:     input.BatchID,
+		Status:      models.AdjustmentCounting,
+		Reason:      input.Reason,
+		Notes:       input.Notes,
+		CreatedBy:   userID,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Table(ns.TableName("BatchProductEntry")+" AS be").
+			Select("be.*").
+			Joins("JOIN "+ns.TableName("Batch")+" AS b ON b.id = be.batch_id").
+			Where("b.warehouse_id = ? AND b.deleted_at IS NULL", warehouseId)
+		if input.BatchID != nil {
+			var batch models.Batch
+			if err := tx.Table(ns.TableName("Batch")).First(&batch, *input.BatchID).Error; err != nil {
+				return fmt.Errorf("batch not found (ID=%d): %w", *input.BatchID, err)
+			}
+			if batch.WarehouseID != warehouseId {
+				return fmt.Errorf("%w: batch %d belongs to warehouse %d", ErrWarehouseMismatch, batch.ID, batch.WarehouseID)
+			}
+			query = query.Where("be.batch_id = ?", *input.BatchID)
+		} else {
+			query = query.Where("be.stock_quantity > 0")
+		}
+
+		var entries []models.BatchProductEntry
+		if err := query.Order("be.batch_id, be.product_id").Find(&entries).Error; err != nil {
+			return fmt.Errorf("failed to load stock for count sheet: %w", err)
+		}
+		if len(entries) == 0 {
+			return errors.New("no stock to count")
+		}
+
+		for _, entry := range entries {
+			adjustment.Lines = append(adjustment.Lines, models.StockAdjustmentLine{
+				EntryID:   entry.ID,
+				BatchID:   entry.BatchID,
+				ProductID: entry.ProductID,
+				SystemQty: entry.StockQuantity,
+				UnitCost:  entry.BillingPrice.Add(entry.OnBoardCost),
+			})
+		}
+
+		if err := tx.Table(ns.TableName("StockAdjustment")).Create(&adjustment).Error; err != nil {
+			return fmt.Errorf("failed to create stock adjustment: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("❌ Count sheet creation failed: %v", err)
+		return nil, err
+	}
+
+	log.Printf("📋 Count sheet %d opened for warehouse %d (%d lines)", adjustment.ID, warehouseId, len(adjustment.Lines))
+	return &adjustment, nil
+}
+
+// RecordCounts stores physical counts and recalculates the variance against system stock
+func (r *StockAdjustmentRepo) RecordCounts(ctx context.Context, warehouseId, adjustmentID uint, input models.StockCountSheetInput) (*models.StockAdjustment, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		adjustment, err := loadAdjustment(forUpdate(tx), warehouseId, adjustmentID)
+		if err != nil {
+			return err
+		}
+		if adjustment.Status != models.AdjustmentCounting {
+			return fmt.Errorf("%w: counts can only be recorded while counting (status=%s)", ErrInvalidAdjustmentState, adjustment.Status)
+		}
+
+		linesByID := make(map[uint]models.StockAdjustmentLine, len(adjustment.Lines))
+		for _, line := range adjustment.Lines {
+			linesByID[line.ID] = line
+		}
+
+		for _, in := range input.Lines {
+			line, ok := linesByID[in.LineID]
+			if !ok {
+				return fmt.Errorf("line %d does not belong to adjustment %d", in.LineID, adjustmentID)
+			}
+			if in.CountedQty < 0 {
+				return fmt.Errorf("counted quantity for line %d cannot be negative", in.LineID)
+			}
+
+			variance := in.CountedQty - line.SystemQty
+			if err := tx.Table(ns.TableName("StockAdjustmentLine")).
+				Where("id = ?", line.ID).
+				Updates(map[string]any{
+					"counted_qty":    in.CountedQty,
+					"variance_qty":   variance,
+					"variance_value": money.Round(line.UnitCost.Mul(money.Qty(variance))),
+					"reason":         in.Reason,
+				}).Error; err != nil {
+				return fmt.Errorf("failed to record count for line %d: %w", line.ID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetByID(ctx, warehouseId, adjustmentID)
+}
+
+// Submit sends a fully counted sheet for admin approval
+func (r *StockAdjustmentRepo) Submit(ctx context.Context, warehouseId, adjustmentID uint) (*models.StockAdjustment, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		adjustment, err := loadAdjustment(forUpdate(tx), warehouseId, adjustmentID)
+		if err != nil {
+			return err
+		}
+		if adjustment.Status != models.AdjustmentCounting {
+			return fmt.Errorf("%w: only counting sheets can be submitted (status=%s)", ErrInvalidAdjustmentState, adjustment.Status)
+		}
+		for _, line := range adjustment.Lines {
+			if line.CountedQty == nil {
+				return fmt.Errorf("line %d (batch %d, product %d) has not been counted", line.ID, line.BatchID, line.ProductID)
+			}
+		}
+
+		return tx.Table(ns.TableName("StockAdjustment")).
+			Where("id = ?", adjustmentID).
+			Update("status", models.AdjustmentPendingApproval).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetByID(ctx, warehouseId, adjustmentID)
+}
+
+// Decide approves or rejects a submitted sheet (admin only)
+func (r *StockAdjustmentRepo) Decide(ctx context.Context, warehouseId, adjustmentID, adminID uint, approve bool, notes string) (*models.StockAdjustment, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	status := models.AdjustmentRejected
+	if approve {
+		status = models.AdjustmentApproved
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		adjustment, err := loadAdjustment(forUpdate(tx), warehouseId, adjustmentID)
+		if err != nil {
+			return err
+		}
+		if adjustment.Status != models.AdjustmentPendingApproval {
+			return fmt.Errorf("%w: only submitted sheets can be approved or rejected (status=%s)", ErrInvalidAdjustmentState, adjustment.Status)
+		}
+
+		updates := map[string]any{
+			"status":      status,
+			"approved_by": adminID,
+			"approved_at": time.Now(),
+		}
+		if notes != "" {
+			updates["notes"] = notes
+		}
+		return tx.Table(ns.TableName("StockAdjustment")).
+			Where("id = ?", adjustmentID).
+			Updates(updates).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("🧾 Stock adjustment %d %s by user %d", adjustmentID, status, adminID)
+	return r.GetByID(ctx, warehouseId, adjustmentID)
+}
+
+// binMove is a counted variance of one entry still to be put away into or picked from
+// the bins
+type binMove struct {
+	entry   models.BatchProductEntry
+	product models.Product
+	qty     int
+}
+
+// Post applies an approved sheet: each variance is added to the current stock, the
+// warehouse area follows the quantity change, every change is written to the stock
+// ledger and shrinkage is booked as a write-off expense.
+func (r *StockAdjustmentRepo) Post(ctx context.Context, warehouseId, adjustmentID, userID uint) (*models.StockAdjustment, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		adjustment, err := loadAdjustment(forUpdate(tx), warehouseId, adjustmentID)
+		if err != nil {
+			return err
+		}
+		if adjustment.Status != models.AdjustmentApproved {
+			return fmt.Errorf("%w: only approved sheets can be posted (status=%s)", ErrInvalidAdjustmentState, adjustment.Status)
+		}
+
+		unit, err := warehouseCapacityUnit(tx, warehouseId)
+		if err != nil {
+			return err
+		}
+
+		var (
+			areaTaken       float64 // net space the variances take up, negative when freed
+			movements       []models.StockMovement
+			gain, shrinkage int
+			writeOffTotal   decimal.Decimal
+			touchedBatches  = map[uint]bool{}
+			binMoves        []binMove // applied once the area is reserved
+		)
+		for _, line := range adjustment.Lines {
+			if line.VarianceQty == 0 {
+				continue
+			}
+
+			var entry models.BatchProductEntry
+			if err := tx.Table(ns.TableName("BatchProductEntry")).First(&entry, line.EntryID).Error; err != nil {
+				return fmt.Errorf("batch entry not found (ID=%d): %w", line.EntryID, err)
+			}
+
+			var product models.Product
+			if err := tx.Table(ns.TableName("Product")).First(&product, entry.ProductID).Error; err != nil {
+				return fmt.Errorf("product not found (ID=%d): %w", entry.ProductID, err)
+			}
+
+			// ✅ Space follows the stock: found units take space, lost units free it
+			space, err := spaceUsed(product, line.VarianceQty, unit)
+			if err != nil {
+				return err
+			}
+			areaTaken += space
+
+			// ✅ Update stock; the guarded update refuses to go below zero
+			entry, err = changeStock(tx, entry.ID, line.VarianceQty, nil)
+			if err != nil {
+				return fmt.Errorf("adjustment would make stock negative: %w", err)
+			}
+			movement := newStockMovement(entry, warehouseId, line.VarianceQty, models.MovementAdjustment, models.SourceAdjustment, userID)
+			movement.Notes = line.Reason
+			movements = append(movements, movement)
+			touchedBatches[entry.BatchID] = true
+
+			binMoves = append(binMoves, binMove{entry: entry, product: product, qty: line.VarianceQty})
+
+			if line.VarianceQty > 0 {
+				gain += line.VarianceQty
+				continue
+			}
+
+			// ✅ Shrinkage is an expense, not a sale
+			lost := -line.VarianceQty
+			writeOff := models.StockWriteOff{
+				AdjustmentID: adjustment.ID,
+				WarehouseID:  warehouseId,
+				BatchID:      entry.BatchID,
+				ProductID:    entry.ProductID,
+				Quantity:     lost,
+				Amount:       money.Round(line.UnitCost.Mul(money.Qty(lost))),
+				Reason:       line.Reason,
+			}
+			if err := tx.Table(ns.TableName("StockWriteOff")).Create(&writeOff).Error; err != nil {
+				return fmt.Errorf("failed to record write-off: %w", err)
+			}
+			shrinkage += lost
+			writeOffTotal = writeOffTotal.Add(writeOff.Amount)
+		}
+
+		if areaTaken > 0 {
+			if err := reserveWarehouseArea(tx, warehouseId, areaTaken); err != nil {
+				return fmt.Errorf("no space for found stock: %w", err)
+			}
+		} else if areaTaken < 0 {
+			if err := releaseWarehouseArea(tx, warehouseId, -areaTaken); err != nil {
+				return err
+			}
+		}
+
+		// ✅ Lost units come out of their bins, then found ones are put away. Putting away
+		// lowers the available area itself, so it must follow the reservation above.
+		for _, m := range binMoves {
+			if m.qty < 0 {
+				if _, err := pickFromBins(tx, warehouseId, m.entry, m.product, -m.qty); err != nil {
+					return err
+				}
+			}
+		}
+		planner, err := newPutawayPlanner(tx, warehouseId)
+		if err != nil {
+			return err
+		}
+		for _, m := range binMoves {
+			if m.qty > 0 {
+				if _, err := planner.putAway(m.entry, m.product, m.qty, nil); err != nil {
+					return fmt.Errorf("no space for found stock: %w", err)
+				}
+			}
+		}
+		if err := recordStockMovements(tx, adjustment.ID, movements); err != nil {
+			return err
+		}
+
+		// ✅ Keep batch status in line with its remaining stock
+		for batchID := range touchedBatches {
+			var remaining int64
+			if err := tx.Table(ns.TableName("BatchProductEntry")).
+				Where("batch_id = ? AND stock_quantity > 0", batchID).
+				Count(&remaining).Error; err != nil {
+				return fmt.Errorf("failed to count stock left in batch %d: %w", batchID, err)
+			}
+			status := "active"
+			if remaining == 0 {
+				status = "inactive"
+			}
+			if err := tx.Table(ns.TableName("Batch")).
+				Where("id = ?", batchID).
+				Update("status", status).Error; err != nil {
+				return fmt.Errorf("failed to update batch %d status: %w", batchID, err)
+			}
+		}
+
+		now := time.Now()
+		return tx.Table(ns.TableName("StockAdjustment")).
+			Where("id = ?", adjustment.ID).
+			Updates(map[string]any{
+				"status":           models.AdjustmentPosted,
+				"gain_qty":         gain,
+				"shrinkage_qty":    shrinkage,
+				"write_off_amount": writeOffTotal,
+				"posted_by":        userID,
+				"posted_at":        now,
+			}).Error
+	})
+	if err != nil {
+		log.Printf("❌ Posting stock adjustment %d failed: %v", adjustmentID, err)
+		return nil, err
+	}
+
+	log.Printf("✅ Stock adjustment %d posted", adjustmentID)
+	return r.GetByID(ctx, warehouseId, adjustmentID)
+}
+
+// GetByID returns an adjustment with its lines
+func (r *StockAdjustmentRepo) GetByID(ctx context.Context, warehouseId, adjustmentID uint) (*models.StockAdjustment, error) {
+	db := dbconn.DB.WithContext(ctx)
+	return loadAdjustment(db, warehouseId, adjustmentID)
+}
+
+// GetAll lists the warehouse's adjustments, optionally filtered by status
+func (r *StockAdjustmentRepo) GetAll(ctx context.Context, warehouseId uint, status string) ([]models.StockAdjustment, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	query := db.Table(ns.TableName("StockAdjustment")).Where("warehouse_id = ?", warehouseId)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var adjustments []models.StockAdjustment
+	if err := query.Order("created_at DESC").Find(&adjustments).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch stock adjustments: %w", err)
+	}
+	return adjustments, nil
+}
+
+func loadAdjustment(db *gorm.DB, warehouseId, adjustmentID uint) (*models.StockAdjustment, error) {
+	ns := db.NamingStrategy
+
+	var adjustment models.StockAdjustment
+	if err := db.Table(ns.TableName("StockAdjustment")).
+		Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		First(&adjustment, adjustmentID).Error; err != nil {
+		return nil, fmt.Errorf("stock adjustment not found (ID=%d): %w", adjustmentID, err)
+	}
+	if adjustment.WarehouseID != warehouseId {
+		return nil, fmt.Errorf("%w: stock adjustment %d belongs to warehouse %d", ErrWarehouseMismatch, adjustmentID, adjustment.WarehouseID)
+	}
+	return &adjustment, nil
+}
@@ -0,0 +1,157 @@
+package repo
+
+import (
+	"fmt"
+	"time"
+	"warehouse/allocation"
+	"warehouse/models"
+	"warehouse/rent"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// allocatedStock is a batch entry picked for a bill line, with its batch
+type allocatedStock struct {
+	Entry    models.BatchProductEntry
+	Batch    models.Batch
+	Quantity int
+	Strategy string
+	Reason   string
+}
+
+// allocationStrategy is the request's strategy, else the product's, else the default
+func allocationStrategy(requested string, product models.Product) string {
+	if requested != "" {
+		return requested
+	}
+	if product.AllocationStrategy != "" {
+		return product.AllocationStrategy
+	}
+	return allocation.Default
+}
+
+// allocateStock picks qty units of a product from the unexpired stock of a warehouse
+// with the given strategy, leaving out what earlier lines of the bill have taken. It
+// does not write, so a preview makes the same picks as the bill that follows it. With
+// lock the candidate entries are locked for the rest of the transaction.
+func allocateStock(tx *gorm.DB, rentRates *rentRateCache, warehouseId uint, product models.Product, qty int, strategy string, taken map[uint]int, lock bool, now time.Time) ([]allocatedStock, error) {
+	ns := tx.NamingStrategy
+	be := ns.TableName("BatchProductEntry")
+
+	var entries []models.BatchProductEntry
+	if err := lockRows(tx.Table(be), lock, be).
+		Joins("JOIN "+ns.TableName("Batch")+" AS b ON b.id = "+be+".batch_id").
+		Where(be+".product_id = ? AND "+be+".stock_quantity > 0", product.ID).
+		Where("("+be+".expires_at IS NULL OR "+be+".expires_at > ?)", now).
+		Where("b.warehouse_id = ?", warehouseId).
+		Order(be + ".id ASC"). // one lock order for every bill
+		Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("no batches available for product %v: %w", product.ID, err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%w: no available stock for product %v", allocation.ErrInsufficientStock, product.ID)
+	}
+
+	batchIDs := make([]uint, 0, len(entries))
+	for _, e := range entries {
+		batchIDs = append(batchIDs, e.BatchID)
+	}
+	var batches []models.Batch
+	if err := tx.Table(ns.TableName("Batch")).Where("id IN ?", batchIDs).Find(&batches).Error; err != nil {
+		return nil, fmt.Errorf("failed to load batches: %w", err)
+	}
+	batchByID := make(map[uint]models.Batch, len(batches))
+	for _, b := range batches {
+		batchByID[b.ID] = b
+	}
+
+	// Units of every product left in each batch, for close-out
+	var stocks []struct {
+		BatchID uint
+		Stock   int
+	}
+	if err := tx.Table(be).
+		Select("batch_id, COALESCE(SUM(stock_quantity), 0) AS stock").
+		Where("batch_id IN ?", batchIDs).
+		Group("batch_id").
+		Scan(&stocks).Error; err != nil {
+		return nil, fmt.Errorf("failed to load batch stock: %w", err)
+	}
+	batchStock := make(map[uint]int, len(stocks))
+	for _, s := range stocks {
+		batchStock[s.BatchID] = s.Stock
+	}
+
+	rates, err := rentRates.forWarehouse(warehouseId)
+	if err != nil {
+		return nil, err
+	}
+	unit, err := rentRates.capacityUnit(warehouseId)
+	if err != nil {
+		return nil, err
+	}
+	space, err := spaceUsed(product, 1, unit)
+	if err != nil {
+		return nil, err
+	}
+
+	entryByID := make(map[uint]models.BatchProductEntry, len(entries))
+	lots := make([]allocation.Lot, 0, len(entries))
+	for _, e := range entries {
+		batch := batchByID[e.BatchID]
+		entryByID[e.ID] = e
+		// Batches bought in different currencies compare on their booked reporting cost
+		unitCost := e.BillingPrice.Add(e.OnBoardCost)
+		if batch.ExchangeRate.IsPositive() {
+			unitCost = unitCost.Mul(batch.ExchangeRate)
+		}
+		lots = append(lots, allocation.Lot{
+			EntryID:     e.ID,
+			BatchID:     e.BatchID,
+			Available:   e.StockQuantity - taken[e.ID],
+			StoredAt:    batch.StoredAt,
+			ExpiresAt:   e.ExpiresAt,
+			UnitCost:    unitCost,
+			RentPerUnit: rent.Unbilled(rates, space, batch.StoredAt, e.RentBilledTo, now, e.RentMinimumMet).Amount,
+			BatchStock:  batchStock[e.BatchID],
+		})
+	}
+
+	picks, err := allocation.Allocate(strategy, lots, qty)
+	if err != nil {
+		return nil, fmt.Errorf("product %v: %w", product.ID, err)
+	}
+
+	allocated := make([]allocatedStock, 0, len(picks))
+	for _, p := range picks {
+		allocated = append(allocated, allocatedStock{
+			Entry:    entryByID[p.Lot.EntryID],
+			Batch:    batchByID[p.Lot.BatchID],
+			Quantity: p.Quantity,
+			Strategy: strategy,
+			Reason:   p.Reason,
+		})
+	}
+	return allocated, nil
+}
+
+// lockRows adds FOR UPDATE to a query when lock is set, limited to table when the query
+// joins others
+func lockRows(q *gorm.DB, lock bool, table string) *gorm.DB {
+	if !lock {
+		return q
+	}
+	locking := clause.Locking{Strength: "UPDATE"}
+	if table != "" {
+		locking.Table = clause.Table{Name: table}
+	}
+	return q.Clauses(locking)
+}
+
+// forUpdate locks the rows the next query reads until the transaction ends. Documents
+// are loaded through it before a state change, so two requests cannot both post,
+// dispatch or receive the same one.
+func forUpdate(tx *gorm.DB) *gorm.DB {
+	return lockRows(tx, true, "")
+}
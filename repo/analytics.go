@@ -8,6 +8,12 @@ import (
 	"time"
 	dbconn "warehouse/config/dbConn"
 	"warehouse/models"
+	"warehouse/money"
+	"warehouse/rent"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
 )
 
 type AnalyticsRepo struct {
@@ -17,12 +23,42 @@ func NewAnalyticsRepo() *AnalyticsRepo {
 	return &AnalyticsRepo{}
 }
 
-// 🔍 Get Analytics Data
-func (r *AnalyticsRepo) GetAnalytics(ctx context.Context, warehouseID uint, duration string) (*models.ProductAnalytics, error) {
+// billedToCustomer limits bill-derived rows to one customer's bills (0 = all customers)
+func billedToCustomer(ns schema.Namer, billingIDColumn string, customerID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if customerID == 0 {
+			return db
+		}
+		return db.Where(billingIDColumn+" IN (SELECT id FROM "+ns.TableName("Billing")+" WHERE customer_id = ?)", customerID)
+	}
+}
+
+// billRate is the rate to the reporting currency of the bill billingIDColumn refers to,
+// so amounts of bills in other currencies add up at the rate they were issued at
+func billRate(ns schema.Namer, billingIDColumn string) string {
+	table := ns.TableName("Billing")
+	return "COALESCE((SELECT " + table + ".exchange_rate FROM " + table + " WHERE " + table + ".id = " + billingIDColumn + "), 1)"
+}
+
+// batchRate is the rate to the reporting currency the batch batchIDColumn refers to was
+// booked at
+func batchRate(ns schema.Namer, batchIDColumn string) string {
+	table := ns.TableName("Batch")
+	return "COALESCE((SELECT " + table + ".exchange_rate FROM " + table + " WHERE " + table + ".id = " + batchIDColumn + "), 1)"
+}
+
+// 🔍 Get Analytics Data. A non-zero customerID limits the sales figures (offboarding,
+// profit, bill expenses) to that customer's bills; stock and write-offs stay warehouse-wide.
+func (r *AnalyticsRepo) GetAnalytics(ctx context.Context, warehouseID, customerID uint, duration string) (*models.ProductAnalytics, error) {
 	db := dbconn.DB.WithContext(ctx)
 	ns := db.NamingStrategy
 
 	var analytics models.ProductAnalytics
+	currency, err := reportingCurrency(db, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	analytics.Currency = currency
 
 	// 🕒 Duration filter (used for flow metrics only)
 	startDate := time.Now().AddDate(0, 0, -7) // default last 7 days
@@ -39,39 +75,65 @@ func (r *AnalyticsRepo) GetAnalytics(ctx context.Context, warehouseID uint, dura
 	db.Table(ns.TableName("BatchProductEntry")+" AS be").
 		Joins("JOIN "+ns.TableName("Batch")+" AS b ON be.batch_id = b.id").
 		Where("b.warehouse_id = ? AND be.created_at >= ?", warehouseID, startDate).
-		Select("COALESCE(SUM(be.billing_price * be.quantity), 0)").
+		Select("COALESCE(ROUND(SUM(be.billing_price * be.quantity * b.exchange_rate), 2), 0)").
 		Scan(&analytics.TotalAmounts.OnBoardingAmount)
 
 	db.Table(ns.TableName("BillingItem")+" AS bi").
 		Joins("JOIN "+ns.TableName("Batch")+" AS b ON bi.batch_id = b.id").
+		Scopes(billedToCustomer(ns, "bi.billing_id", customerID)).
 		Where("b.warehouse_id = ? AND bi.created_at >= ?", warehouseID, startDate).
-		Select("COALESCE(SUM(bi.selling_price * bi.offboard_qty), 0)").
+		Select("COALESCE(ROUND(SUM(bi.selling_price * bi.offboard_qty * " + billRate(ns, "bi.billing_id") + "), 2), 0)").
 		Scan(&analytics.TotalAmounts.OffBoardingAmount)
 
 	// InStockAmount for totals — use the snapshot of stock entries created/updated since startDate (keeps consistency with flow), but you can remove the created_at filter if you want absolute current stock value.
 	db.Table(ns.TableName("BatchProductEntry")+" AS be").
 		Joins("JOIN "+ns.TableName("Batch")+" AS b ON be.batch_id = b.id").
 		Where("b.warehouse_id = ? AND be.created_at >= ?", warehouseID, startDate).
-		Select("COALESCE(SUM(be.billing_price * be.stock_quantity), 0)").
+		Select("COALESCE(ROUND(SUM(be.billing_price * be.stock_quantity * b.exchange_rate), 2), 0)").
 		Scan(&analytics.TotalAmounts.InStockAmount)
 
 	db.Table(ns.TableName("Profit")+" AS p").
 		Joins("JOIN "+ns.TableName("Batch")+" AS b ON p.batch_id = b.id").
+		Scopes(billedToCustomer(ns, "p.billing_id", customerID)).
 		Where("b.warehouse_id = ? AND p.created_at >= ?", warehouseID, startDate).
-		Select("COALESCE(SUM(p.profit), 0)").
+		Select("COALESCE(ROUND(SUM(p.profit * " + billRate(ns, "p.billing_id") + "), 2), 0)").
 		Scan(&analytics.TotalAmounts.ProfitAmount)
 
 	db.Table(ns.TableName("Profit")+" AS p").
 		Joins("JOIN "+ns.TableName("Batch")+" AS b ON p.batch_id = b.id").
+		Scopes(billedToCustomer(ns, "p.billing_id", customerID)).
 		Where("b.warehouse_id = ? AND p.created_at >= ?", warehouseID, startDate).
-		Select("COALESCE(SUM(p.net_profit), 0)").
+		Select("COALESCE(ROUND(SUM(p.net_profit * " + billRate(ns, "p.billing_id") + "), 2), 0)").
 		Scan(&analytics.TotalAmounts.NetProfitAmount)
 
 	db.Table(ns.TableName("Billing")+" AS bl").
-		Where("bl.created_at >= ?", startDate).
-		Select("COALESCE(SUM(bl.other_expenses + bl.total_rent), 0)").
+		Where("bl.warehouse_id = ? AND bl.created_at >= ?", warehouseID, startDate).
+		Scopes(billedToCustomer(ns, "bl.id", customerID)).
+		Select("COALESCE(ROUND(SUM((bl.other_expenses + bl.total_rent) * bl.exchange_rate), 2), 0)").
 		Scan(&analytics.TotalAmounts.ExpenseAmount)
 
+	// Stock shrinkage is an expense, never a sale
+	db.Table(ns.TableName("StockWriteOff")).
+		Where("warehouse_id = ? AND created_at >= ?", warehouseID, startDate).
+		Select("COALESCE(ROUND(SUM(amount * " + batchRate(ns, ns.TableName("StockWriteOff")+".batch_id") + "), 2), 0)").
+		Scan(&analytics.TotalAmounts.WriteOffAmount)
+	analytics.TotalAmounts.ExpenseAmount = analytics.TotalAmounts.ExpenseAmount.Add(analytics.TotalAmounts.WriteOffAmount)
+
+	// 💱 Exchange differences realised on payments; they are not trading profit
+	var fxRes struct {
+		Gain decimal.Decimal
+		Loss decimal.Decimal
+	}
+	db.Table(ns.TableName("PaymentAllocation")+" AS pa").
+		Joins("JOIN "+ns.TableName("Payment")+" AS pm ON pm.id = pa.payment_id").
+		Scopes(billedToCustomer(ns, "pa.billing_id", customerID)).
+		Where("pm.warehouse_id = ? AND pm.paid_at >= ?", warehouseID, startDate).
+		Select(`COALESCE(SUM(CASE WHEN pa.fx_gain_loss > 0 THEN pa.fx_gain_loss ELSE 0 END), 0) AS gain,
+			COALESCE(SUM(CASE WHEN pa.fx_gain_loss < 0 THEN -pa.fx_gain_loss ELSE 0 END), 0) AS loss`).
+		Scan(&fxRes)
+	analytics.TotalAmounts.FXGainAmount = fxRes.Gain
+	analytics.TotalAmounts.FXLossAmount = fxRes.Loss
+
 	// ===================================================
 	// 🏭 GODOWN DATA
 	// ===================================================
@@ -125,42 +187,45 @@ func (r *AnalyticsRepo) GetAnalytics(ctx context.Context, warehouseID uint, dura
 		db.Table(ns.TableName("BatchProductEntry")+" AS be").
 			Joins("JOIN "+ns.TableName("Batch")+" AS b ON be.batch_id = b.id").
 			Where("b.warehouse_id = ? AND be.product_id = ? AND be.created_at >= ?", warehouseID, p.ID, startDate).
-			Select("COALESCE(SUM(be.billing_price * be.quantity), 0)").
+			Select("COALESCE(ROUND(SUM(be.billing_price * be.quantity * b.exchange_rate), 2), 0)").
 			Scan(&pdata.Amounts.ProductOnBoardingAmount)
 
 		db.Table(ns.TableName("BillingItem")+" AS bi").
 			Joins("JOIN "+ns.TableName("Batch")+" AS b ON bi.batch_id = b.id").
+			Scopes(billedToCustomer(ns, "bi.billing_id", customerID)).
 			Where("b.warehouse_id = ? AND bi.product_id = ? AND bi.created_at >= ?", warehouseID, p.ID, startDate).
-			Select("COALESCE(SUM(bi.selling_price * bi.offboard_qty), 0)").
+			Select("COALESCE(ROUND(SUM(bi.selling_price * bi.offboard_qty * " + billRate(ns, "bi.billing_id") + "), 2), 0)").
 			Scan(&pdata.Amounts.ProductOffBoardingAmount)
 
 		// In-stock amount: use current stock snapshot (no created_at) so it reflects present inventory
 		db.Table(ns.TableName("BatchProductEntry")+" AS be").
 			Joins("JOIN "+ns.TableName("Batch")+" AS b ON be.batch_id = b.id").
 			Where("b.warehouse_id = ? AND be.product_id = ? AND be.stock_quantity > 0", warehouseID, p.ID).
-			Select("COALESCE(SUM(be.billing_price * be.stock_quantity), 0)").
+			Select("COALESCE(ROUND(SUM(be.billing_price * be.stock_quantity * b.exchange_rate), 2), 0)").
 			Scan(&pdata.Amounts.ProductInStockAmount)
 
 		// Profit sums (use startDate for flow profit)
 		var profitRes struct {
-			Profit    float64
-			NetProfit float64
+			Profit    decimal.Decimal
+			NetProfit decimal.Decimal
 		}
 		db.Table(ns.TableName("Profit")+" AS pr").
 			Joins("JOIN "+ns.TableName("Batch")+" AS b ON pr.batch_id = b.id").
+			Scopes(billedToCustomer(ns, "pr.billing_id", customerID)).
 			Where("b.warehouse_id = ? AND pr.product_id = ? AND pr.created_at >= ?", warehouseID, p.ID, startDate).
-			Select("COALESCE(SUM(pr.profit),0) AS profit, COALESCE(SUM(pr.net_profit),0) AS net_profit").
+			Select("COALESCE(ROUND(SUM(pr.profit * " + billRate(ns, "pr.billing_id") + "), 2), 0) AS profit, " +
+				"COALESCE(ROUND(SUM(pr.net_profit * " + billRate(ns, "pr.billing_id") + "), 2), 0) AS net_profit").
 			Scan(&profitRes)
 
 		pdata.Amounts.ProductProfitAmount = profitRes.Profit
 		pdata.Amounts.ProductNetProfitAmount = profitRes.NetProfit
 
 		// Expense: storage_cost + shared other_expenses (use startDate to keep consistent)
-		var productExpense float64
+		var productExpense decimal.Decimal
 		db.Raw(fmt.Sprintf(`
 			SELECT 
-				COALESCE(SUM(bi.storage_cost), 0) +
-				COALESCE(SUM(bl.other_expenses / NULLIF(prod_count.cnt, 0)), 0)
+				COALESCE(ROUND(SUM(bi.storage_cost * bl.exchange_rate), 2), 0) +
+				COALESCE(ROUND(SUM(bl.other_expenses * bl.exchange_rate / NULLIF(prod_count.cnt, 0)), 2), 0)
 			FROM %s AS bi
 			JOIN %s AS b ON bi.batch_id = b.id
 			JOIN %s AS bl ON bi.billing_id = bl.id
@@ -169,15 +234,21 @@ func (r *AnalyticsRepo) GetAnalytics(ctx context.Context, warehouseID uint, dura
 				FROM %s
 				GROUP BY billing_id
 			) AS prod_count ON prod_count.billing_id = bl.id
-			WHERE b.warehouse_id = ? AND bi.product_id = ? AND bl.created_at >= ?;
+			WHERE b.warehouse_id = ? AND bi.product_id = ? AND bl.created_at >= ?
+			AND (? = 0 OR bl.customer_id = ?);
 		`,
 			ns.TableName("BillingItem"),
 			ns.TableName("Batch"),
 			ns.TableName("Billing"),
 			ns.TableName("BillingItem")),
-			warehouseID, p.ID, startDate).Scan(&productExpense)
+			warehouseID, p.ID, startDate, customerID, customerID).Scan(&productExpense)
+
+		db.Table(ns.TableName("StockWriteOff")).
+			Where("warehouse_id = ? AND product_id = ? AND created_at >= ?", warehouseID, p.ID, startDate).
+			Select("COALESCE(ROUND(SUM(amount * " + batchRate(ns, ns.TableName("StockWriteOff")+".batch_id") + "), 2), 0)").
+			Scan(&pdata.Amounts.ProductWriteOffAmount)
 
-		pdata.Amounts.ProductExpenseAmount = productExpense
+		pdata.Amounts.ProductExpenseAmount = productExpense.Add(pdata.Amounts.ProductWriteOffAmount)
 
 		// -----------------------
 		// Stock counts (current state)
@@ -196,6 +267,7 @@ func (r *AnalyticsRepo) GetAnalytics(ctx context.Context, warehouseID uint, dura
 
 		db.Table(ns.TableName("BillingItem")+" AS bi").
 			Joins("JOIN "+ns.TableName("Batch")+" AS b ON bi.batch_id = b.id").
+			Scopes(billedToCustomer(ns, "bi.billing_id", customerID)).
 			Where("b.warehouse_id = ? AND bi.product_id = ?", warehouseID, p.ID).
 			Select("COALESCE(SUM(bi.offboard_qty),0) AS off_board").
 			Scan(&stockRes.OffBoard)
@@ -258,11 +330,17 @@ func (r *AnalyticsRepo) GetAnalytics(ctx context.Context, warehouseID uint, dura
 	return &analytics, nil
 }
 
-func (r *AnalyticsRepo) GetProductAnalyticsById(ctx context.Context, warehouseID, productID uint) (*models.ProductWiseAnalyticsData, error) {
+// GetProductAnalyticsById reports one product; customerID works as in GetAnalytics
+func (r *AnalyticsRepo) GetProductAnalyticsById(ctx context.Context, warehouseID, productID, customerID uint) (*models.ProductWiseAnalyticsData, error) {
 	db := dbconn.DB.WithContext(ctx)
 	ns := db.NamingStrategy
 
 	var pdata models.ProductWiseAnalyticsData
+	currency, err := reportingCurrency(db, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	pdata.Currency = currency
 
 	// 🧱 Step 1: Get product info with supplier
 	var product models.Product
@@ -288,41 +366,44 @@ func (r *AnalyticsRepo) GetProductAnalyticsById(ctx context.Context, warehouseID
 	db.Table(ns.TableName("BatchProductEntry")+" AS be").
 		Joins("JOIN "+ns.TableName("Batch")+" AS b ON be.batch_id = b.id").
 		Where("be.product_id = ? AND b.warehouse_id = ?", productID, warehouseID).
-		Select("COALESCE(SUM(be.billing_price * be.quantity), 0)").
+		Select("COALESCE(ROUND(SUM(be.billing_price * be.quantity * b.exchange_rate), 2), 0)").
 		Scan(&pdata.Amounts.ProductOnBoardingAmount)
 
 	db.Table(ns.TableName("BillingItem")+" AS bi").
 		Joins("JOIN "+ns.TableName("Batch")+" AS b ON bi.batch_id = b.id").
+		Scopes(billedToCustomer(ns, "bi.billing_id", customerID)).
 		Where("bi.product_id = ? AND b.warehouse_id = ?", productID, warehouseID).
-		Select("COALESCE(SUM(bi.selling_price * bi.offboard_qty), 0)").
+		Select("COALESCE(ROUND(SUM(bi.selling_price * bi.offboard_qty * " + billRate(ns, "bi.billing_id") + "), 2), 0)").
 		Scan(&pdata.Amounts.ProductOffBoardingAmount)
 
 	db.Table(ns.TableName("BatchProductEntry")+" AS be").
 		Joins("JOIN "+ns.TableName("Batch")+" AS b ON be.batch_id = b.id").
 		Where("be.product_id = ? AND b.warehouse_id = ? AND be.stock_quantity > 0", productID, warehouseID).
-		Select("COALESCE(SUM(be.billing_price * be.stock_quantity), 0)").
+		Select("COALESCE(ROUND(SUM(be.billing_price * be.stock_quantity * b.exchange_rate), 2), 0)").
 		Scan(&pdata.Amounts.ProductInStockAmount)
 
 	// 🧮 Step 3: Profit + NetProfit
 	var profitRes struct {
-		Profit    float64
-		NetProfit float64
+		Profit    decimal.Decimal
+		NetProfit decimal.Decimal
 	}
 	db.Table(ns.TableName("Profit")+" AS pr").
 		Joins("JOIN "+ns.TableName("Batch")+" AS b ON pr.batch_id = b.id").
+		Scopes(billedToCustomer(ns, "pr.billing_id", customerID)).
 		Where("pr.product_id = ? AND b.warehouse_id = ?", productID, warehouseID).
-		Select("COALESCE(SUM(pr.profit),0) AS profit, COALESCE(SUM(pr.net_profit),0) AS net_profit").
+		Select("COALESCE(ROUND(SUM(pr.profit * " + billRate(ns, "pr.billing_id") + "), 2), 0) AS profit, " +
+			"COALESCE(ROUND(SUM(pr.net_profit * " + billRate(ns, "pr.billing_id") + "), 2), 0) AS net_profit").
 		Scan(&profitRes)
 
 	pdata.Amounts.ProductProfitAmount = profitRes.Profit
 	pdata.Amounts.ProductNetProfitAmount = profitRes.NetProfit
 
 	// 🧮 Step 4: Product Expense (storage_cost + shared other_expenses)
-	var productExpense float64
+	var productExpense decimal.Decimal
 	db.Raw(fmt.Sprintf(`
 		SELECT 
-			COALESCE(SUM(bi.storage_cost), 0) +
-			COALESCE(SUM(bl.other_expenses / NULLIF(prod_count.cnt, 0)), 0)
+			COALESCE(ROUND(SUM(bi.storage_cost * bl.exchange_rate), 2), 0) +
+			COALESCE(ROUND(SUM(bl.other_expenses * bl.exchange_rate / NULLIF(prod_count.cnt, 0)), 2), 0)
 		FROM %s AS bi
 		JOIN %s AS b ON bi.batch_id = b.id
 		JOIN %s AS bl ON bi.billing_id = bl.id
@@ -331,15 +412,21 @@ func (r *AnalyticsRepo) GetProductAnalyticsById(ctx context.Context, warehouseID
 			FROM %s
 			GROUP BY billing_id
 		) AS prod_count ON prod_count.billing_id = bl.id
-		WHERE bi.product_id = ? AND b.warehouse_id = ?;
+		WHERE bi.product_id = ? AND b.warehouse_id = ?
+		AND (? = 0 OR bl.customer_id = ?);
 	`,
 		ns.TableName("BillingItem"),
 		ns.TableName("Batch"),
 		ns.TableName("Billing"),
 		ns.TableName("BillingItem")),
-		productID, warehouseID).Scan(&productExpense)
+		productID, warehouseID, customerID, customerID).Scan(&productExpense)
+
+	db.Table(ns.TableName("StockWriteOff")).
+		Where("warehouse_id = ? AND product_id = ?", warehouseID, productID).
+		Select("COALESCE(ROUND(SUM(amount * " + batchRate(ns, ns.TableName("StockWriteOff")+".batch_id") + "), 2), 0)").
+		Scan(&pdata.Amounts.ProductWriteOffAmount)
 
-	pdata.Amounts.ProductExpenseAmount = productExpense
+	pdata.Amounts.ProductExpenseAmount = productExpense.Add(pdata.Amounts.ProductWriteOffAmount)
 
 	// 🧮 Step 5: Stock Counts
 	var stockRes struct {
@@ -356,6 +443,7 @@ func (r *AnalyticsRepo) GetProductAnalyticsById(ctx context.Context, warehouseID
 
 	db.Table(ns.TableName("BillingItem")+" AS bi").
 		Joins("JOIN "+ns.TableName("Batch")+" AS b ON bi.batch_id = b.id").
+		Scopes(billedToCustomer(ns, "bi.billing_id", customerID)).
 		Where("b.warehouse_id = ? AND bi.product_id = ?", warehouseID, productID).
 		Select("COALESCE(SUM(bi.offboard_qty),0) AS off_board").
 		Scan(&stockRes.OffBoard)
@@ -386,18 +474,27 @@ func (r *AnalyticsRepo) GetFastAndSlowMovingProductAnalytics(ctx context.Context
 	// ----------------------------------------------
 	// Get rent rate for this warehouse
 	// ----------------------------------------------
-	var rentRate float64
-	db.Raw(`
-		SELECT rr.rate_per_sqft
-		FROM `+ns.TableName("Warehouse")+` w
-		JOIN `+ns.TableName("RentRate")+` rr ON rr.id = w.rent_config_id
-		WHERE w.id = ?
-		LIMIT 1
-	`, warehouseID).Scan(&rentRate)
+	rentRates, err := warehouseRentRates(db, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	currency, err := reportingCurrency(db, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	fxRates, err := exchangeRates(db, currency)
+	if err != nil {
+		return nil, err
+	}
+	unit, err := warehouseCapacityUnit(db, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
 
 	type candidate struct {
 		Data         models.ProductWiseData
-		RentPerSpace float64
+		RentPerSpace decimal.Decimal
 		OffBoard     int
 	}
 
@@ -457,33 +554,34 @@ func (r *AnalyticsRepo) GetFastAndSlowMovingProductAnalytics(ctx context.Context
 		// Onboarding Amount
 		db.Table(ns.TableName("BatchProductEntry")+" be").
 			Joins("JOIN "+ns.TableName("Batch")+" b ON be.batch_id = b.id").
-			Select("COALESCE(SUM(be.billing_price * be.quantity),0)").
+			Select("COALESCE(ROUND(SUM(be.billing_price * be.quantity * b.exchange_rate), 2), 0)").
 			Where("be.product_id = ? AND b.warehouse_id = ?", p.ID, warehouseID).
 			Scan(&pdata.Amounts.ProductOnBoardingAmount)
 
 		// Offboarding Amount
 		db.Table(ns.TableName("BillingItem")+" bi").
 			Joins("JOIN "+ns.TableName("Batch")+" b ON bi.batch_id = b.id").
-			Select("COALESCE(SUM(bi.selling_price * bi.offboard_qty),0)").
+			Select("COALESCE(ROUND(SUM(bi.selling_price * bi.offboard_qty * "+billRate(ns, "bi.billing_id")+"), 2), 0)").
 			Where("bi.product_id = ? AND b.warehouse_id = ?", p.ID, warehouseID).
 			Scan(&pdata.Amounts.ProductOffBoardingAmount)
 
 		// Instock Amount
 		db.Table(ns.TableName("BatchProductEntry")+" be").
 			Joins("JOIN "+ns.TableName("Batch")+" b ON be.batch_id = b.id").
-			Select("COALESCE(SUM(be.billing_price * be.stock_quantity),0)").
+			Select("COALESCE(ROUND(SUM(be.billing_price * be.stock_quantity * b.exchange_rate), 2), 0)").
 			Where("be.product_id = ? AND b.warehouse_id = ? AND be.stock_quantity > 0", p.ID, warehouseID).
 			Scan(&pdata.Amounts.ProductInStockAmount)
 
 		// Profit + Net Profit
 		var profitRes struct {
-			Profit    float64
-			NetProfit float64
+			Profit    decimal.Decimal
+			NetProfit decimal.Decimal
 		}
 
 		db.Table(ns.TableName("Profit")+" pr").
 			Joins("JOIN "+ns.TableName("Batch")+" b ON pr.batch_id = b.id").
-			Select("COALESCE(SUM(pr.profit),0) AS profit, COALESCE(SUM(pr.net_profit),0) AS net_profit").
+			Select("COALESCE(ROUND(SUM(pr.profit * "+billRate(ns, "pr.billing_id")+"), 2), 0) AS profit, "+
+				"COALESCE(ROUND(SUM(pr.net_profit * "+billRate(ns, "pr.billing_id")+"), 2), 0) AS net_profit").
 			Where("pr.product_id = ? AND b.warehouse_id = ?", p.ID, warehouseID).
 			Scan(&profitRes)
 
@@ -493,19 +591,44 @@ func (r *AnalyticsRepo) GetFastAndSlowMovingProductAnalytics(ctx context.Context
 		// Expense (storage cost)
 		db.Table(ns.TableName("BillingItem")+" bi").
 			Joins("JOIN "+ns.TableName("Batch")+" b ON bi.batch_id = b.id").
-			Select("COALESCE(SUM(bi.storage_cost),0)").
+			Select("COALESCE(ROUND(SUM(bi.storage_cost * "+billRate(ns, "bi.billing_id")+"), 2), 0)").
 			Where("bi.product_id = ? AND b.warehouse_id = ?", p.ID, warehouseID).
 			Scan(&pdata.Amounts.ProductExpenseAmount)
 
 		// ----------------------------------------------
 		// RENT PER SPACE
 		// ----------------------------------------------
-		rentPerUnit := rentRate * p.StorageArea
+		var inStock []struct {
+			StoredAt      time.Time
+			StockQuantity int
+		}
+		db.Table(ns.TableName("BatchProductEntry")+" be").
+			Joins("JOIN "+ns.TableName("Batch")+" b ON be.batch_id = b.id").
+			Select("b.stored_at, be.stock_quantity").
+			Where("be.product_id = ? AND b.warehouse_id = ? AND be.stock_quantity > 0", p.ID, warehouseID).
+			Scan(&inStock)
+
+		// Space per unit in the warehouse's capacity unit; none without a footprint in it
+		spacePerUnit, _ := productFootprint(p).PerUnit(unit)
+
 		offboardRent := pdata.Amounts.ProductExpenseAmount
-		instockRent := rentPerUnit * float64(pdata.Stock.InStockCount)
-		totalRent := offboardRent + instockRent
+		var instockRent decimal.Decimal
+		for _, e := range inStock {
+			charge := rent.ForStay(rentRates, spacePerUnit*float64(e.StockQuantity), e.StoredAt, now)
+			amount := charge.Amount
+			if charge.Currency != "" {
+				if amount, err = fxRates.Convert(charge.Amount, charge.Currency, currency, now); err != nil {
+					return nil, err
+				}
+			}
+			instockRent = instockRent.Add(amount)
+		}
+		totalRent := offboardRent.Add(instockRent)
 
-		rentPerSpace := totalRent / (float64(pdata.Stock.OnBoardCount) * p.StorageArea)
+		rentPerSpace := decimal.Zero
+		if space := decimal.NewFromFloat(float64(pdata.Stock.OnBoardCount) * spacePerUnit); space.IsPositive() {
+			rentPerSpace = money.Round(totalRent.Div(space))
+		}
 		pdata.Amounts.RentPerSpace = rentPerSpace
 
 		candidates = append(candidates, candidate{
@@ -519,7 +642,7 @@ func (r *AnalyticsRepo) GetFastAndSlowMovingProductAnalytics(ctx context.Context
 	// SORT ASCENDING BY RentPerSpace
 	// ----------------------------------------------
 	sort.SliceStable(candidates, func(i, j int) bool {
-		return candidates[i].RentPerSpace < candidates[j].RentPerSpace
+		return candidates[i].RentPerSpace.LessThan(candidates[j].RentPerSpace)
 	})
 
 	// ----------------------------------------------
@@ -560,3 +683,215 @@ func (r *AnalyticsRepo) GetFastAndSlowMovingProductAnalytics(ctx context.Context
 
 
 
+
+// GetARAgeing buckets every open bill of the warehouse by days since the bill date
+// (0-30, 31-60, 61-90, 90+), per customer and in total. Overdue is the part already past
+// its due date. A non-zero customerID limits the report to that customer.
+func (r *AnalyticsRepo) GetARAgeing(ctx context.Context, warehouseID, customerID uint) (*models.ARAgeingReport, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	var rows []struct {
+		CustomerID     uint
+		CustomerName   string
+		InvoiceTotal   decimal.Decimal
+		CreditedAmount decimal.Decimal
+		PaidAmount     decimal.Decimal
+		ExchangeRate   decimal.Decimal
+		DueDate        *time.Time
+		CreatedAt      time.Time
+	}
+	currency, err := reportingCurrency(db, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	query := db.Table(ns.TableName("Billing")+" AS bl").
+		Joins("LEFT JOIN "+ns.TableName("Customer")+" AS cu ON cu.id = bl.customer_id").
+		Where("bl.warehouse_id = ? AND bl.deleted_at IS NULL", warehouseID).
+		Where("bl.invoice_total - bl.credited_amount - bl.paid_amount > 0")
+	if customerID != 0 {
+		query = query.Where("bl.customer_id = ?", customerID)
+	}
+	if err := query.
+		Select(`COALESCE(bl.customer_id, 0) AS customer_id, COALESCE(cu.name, '') AS customer_name,
+			bl.invoice_total, bl.credited_amount, bl.paid_amount, bl.exchange_rate, bl.due_date, bl.created_at`).
+		Order("cu.name ASC, bl.created_at ASC").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to load open bills: %w", err)
+	}
+
+	now := time.Now()
+	report := models.ARAgeingReport{WarehouseID: warehouseID, Currency: currency, AsOf: now, Customers: []models.CustomerAgeing{}}
+	byCustomer := map[uint]int{}
+
+	add := func(b *models.AgeingBuckets, age int, amount decimal.Decimal, overdue bool) {
+		switch {
+		case age <= 30:
+			b.Days0To30 = b.Days0To30.Add(amount)
+		case age <= 60:
+			b.Days31To60 = b.Days31To60.Add(amount)
+		case age <= 90:
+			b.Days61To90 = b.Days61To90.Add(amount)
+		default:
+			b.Over90 = b.Over90.Add(amount)
+		}
+		b.Total = b.Total.Add(amount)
+		if overdue {
+			b.Overdue = b.Overdue.Add(amount)
+		}
+	}
+
+	for _, row := range rows {
+		// Open amounts are in the bill's currency; report them at the rate it was issued at
+		open := billOutstanding(row.InvoiceTotal, row.CreditedAmount, row.PaidAmount)
+		if row.ExchangeRate.IsPositive() {
+			open = money.Round(open.Mul(row.ExchangeRate))
+		}
+		age := rent.DaysBetween(row.CreatedAt, now)
+		overdue := row.DueDate != nil && now.After(*row.DueDate)
+
+		idx, ok := byCustomer[row.CustomerID]
+		if !ok {
+			idx = len(report.Customers)
+			byCustomer[row.CustomerID] = idx
+			report.Customers = append(report.Customers, models.CustomerAgeing{CustomerID: row.CustomerID, CustomerName: row.CustomerName})
+		}
+		report.Customers[idx].OpenBills++
+		add(&report.Customers[idx].Buckets, age, open, overdue)
+		add(&report.Totals, age, open, overdue)
+	}
+
+	log.Printf("📒 AR ageing for Warehouse %d: %d customers, outstanding %s %s", warehouseID, len(report.Customers), report.Totals.Total.StringFixed(2), currency)
+	return &report, nil
+}
+
+// GetTaxSummary totals the output GST of a warehouse for bills raised in [from, to) by
+// HSN code and rate, less the tax reversed by credit notes issued in the same period.
+// Bills in other currencies count at the rate they were issued at.
+func (r *AnalyticsRepo) GetTaxSummary(ctx context.Context, warehouseID uint, from, to time.Time) (*models.TaxSummary, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	currency, err := reportingCurrency(db, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	summary := models.TaxSummary{WarehouseID: warehouseID, Currency: currency, From: from, To: to, Rows: []models.TaxSummaryRow{}}
+
+	if err := db.Table(ns.TableName("Billing")).
+		Where("warehouse_id = ? AND created_at >= ? AND created_at < ?", warehouseID, from, to).
+		Count(&summary.BillCount).Error; err != nil {
+		return nil, fmt.Errorf("failed to count bills: %w", err)
+	}
+	if err := db.Table(ns.TableName("CreditNote")+" AS cn").
+		Joins("JOIN "+ns.TableName("Billing")+" AS bl ON bl.id = cn.billing_id").
+		Where("bl.warehouse_id = ? AND cn.created_at >= ? AND cn.created_at < ?", warehouseID, from, to).
+		Count(&summary.CreditNotes).Error; err != nil {
+		return nil, fmt.Errorf("failed to count credit notes: %w", err)
+	}
+
+	var billed []models.TaxSummaryRow
+	if err := db.Table(ns.TableName("BillingItem")+" AS bi").
+		Joins("JOIN "+ns.TableName("Billing")+" AS bl ON bl.id = bi.billing_id").
+		Where("bl.warehouse_id = ? AND bl.deleted_at IS NULL AND bl.created_at >= ? AND bl.created_at < ?", warehouseID, from, to).
+		Select(`COALESCE(bi.hsn_code, '') AS hsn_code, bi.tax_rate,
+			ROUND(SUM(bi.total_selling * bl.exchange_rate), 2) AS taxable_value,
+			ROUND(SUM(bi.cgst_amount * bl.exchange_rate), 2) AS cgst_amount,
+			ROUND(SUM(bi.sgst_amount * bl.exchange_rate), 2) AS sgst_amount,
+			ROUND(SUM(bi.igst_amount * bl.exchange_rate), 2) AS igst_amount,
+			ROUND(SUM(bi.tax_amount * bl.exchange_rate), 2) AS total_tax`).
+		Group("COALESCE(bi.hsn_code, ''), bi.tax_rate").
+		Scan(&billed).Error; err != nil {
+		return nil, fmt.Errorf("failed to total billed tax: %w", err)
+	}
+
+	var credited []models.TaxSummaryRow
+	if err := db.Table(ns.TableName("CreditNoteItem")+" AS ci").
+		Joins("JOIN "+ns.TableName("CreditNote")+" AS cn ON cn.id = ci.credit_note_id").
+		Joins("JOIN "+ns.TableName("BillingItem")+" AS bi ON bi.id = ci.billing_item_id").
+		Joins("JOIN "+ns.TableName("Billing")+" AS bl ON bl.id = cn.billing_id").
+		Where("bl.warehouse_id = ? AND cn.created_at >= ? AND cn.created_at < ?", warehouseID, from, to).
+		Select(`COALESCE(bi.hsn_code, '') AS hsn_code, bi.tax_rate,
+			ROUND(SUM(ci.total_selling * bl.exchange_rate), 2) AS taxable_value,
+			ROUND(SUM(ci.cgst_amount * bl.exchange_rate), 2) AS cgst_amount,
+			ROUND(SUM(ci.sgst_amount * bl.exchange_rate), 2) AS sgst_amount,
+			ROUND(SUM(ci.igst_amount * bl.exchange_rate), 2) AS igst_amount,
+			ROUND(SUM(ci.tax_amount * bl.exchange_rate), 2) AS total_tax`).
+		Group("COALESCE(bi.hsn_code, ''), bi.tax_rate").
+		Scan(&credited).Error; err != nil {
+		return nil, fmt.Errorf("failed to total credited tax: %w", err)
+	}
+
+	type key struct {
+		hsn  string
+		rate float64
+	}
+	index := map[key]int{}
+	add := func(row models.TaxSummaryRow, sign int64) {
+		k := key{row.HSNCode, row.TaxRate}
+		i, ok := index[k]
+		if !ok {
+			i = len(summary.Rows)
+			index[k] = i
+			summary.Rows = append(summary.Rows, models.TaxSummaryRow{HSNCode: row.HSNCode, TaxRate: row.TaxRate})
+		}
+		for _, t := range []*models.TaxSummaryRow{&summary.Rows[i], &summary.Totals} {
+			t.TaxableValue = t.TaxableValue.Add(row.TaxableValue.Mul(decimal.NewFromInt(sign)))
+			t.CGSTAmount = t.CGSTAmount.Add(row.CGSTAmount.Mul(decimal.NewFromInt(sign)))
+			t.SGSTAmount = t.SGSTAmount.Add(row.SGSTAmount.Mul(decimal.NewFromInt(sign)))
+			t.IGSTAmount = t.IGSTAmount.Add(row.IGSTAmount.Mul(decimal.NewFromInt(sign)))
+			t.TotalTax = t.TotalTax.Add(row.TotalTax.Mul(decimal.NewFromInt(sign)))
+		}
+	}
+	for _, row := range billed {
+		add(row, 1)
+	}
+	for _, row := range credited {
+		add(row, -1)
+	}
+	sort.Slice(summary.Rows, func(i, j int) bool {
+		if summary.Rows[i].HSNCode != summary.Rows[j].HSNCode {
+			return summary.Rows[i].HSNCode < summary.Rows[j].HSNCode
+		}
+		return summary.Rows[i].TaxRate < summary.Rows[j].TaxRate
+	})
+
+	log.Printf("🧾 Tax summary for Warehouse %d: %d bills, %d credit notes, tax %s", warehouseID, summary.BillCount, summary.CreditNotes, summary.Totals.TotalTax.StringFixed(2))
+	return &summary, nil
+}
+
+// GetRealisedFX totals the exchange differences realised on payments received in
+// [from, to), per payment currency, in the warehouse's reporting currency
+func (r *AnalyticsRepo) GetRealisedFX(ctx context.Context, warehouseID uint, from, to time.Time) (*models.FXReport, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	currency, err := reportingCurrency(db, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	report := models.FXReport{WarehouseID: warehouseID, Currency: currency, From: from, To: to, Currencies: []models.FXCurrencyResult{}}
+
+	if err := db.Table(ns.TableName("PaymentAllocation")+" AS pa").
+		Joins("JOIN "+ns.TableName("Payment")+" AS pm ON pm.id = pa.payment_id").
+		Where("pm.warehouse_id = ? AND pm.paid_at >= ? AND pm.paid_at < ?", warehouseID, from, to).
+		Select(`pm.currency, COUNT(DISTINCT pm.id) AS payments, COALESCE(SUM(pa.amount), 0) AS settled,
+			COALESCE(SUM(CASE WHEN pa.fx_gain_loss > 0 THEN pa.fx_gain_loss ELSE 0 END), 0) AS gain,
+			COALESCE(SUM(CASE WHEN pa.fx_gain_loss < 0 THEN -pa.fx_gain_loss ELSE 0 END), 0) AS loss`).
+		Group("pm.currency").
+		Order("pm.currency ASC").
+		Scan(&report.Currencies).Error; err != nil {
+		return nil, fmt.Errorf("failed to total realised FX: %w", err)
+	}
+
+	for i := range report.Currencies {
+		c := &report.Currencies[i]
+		c.Net = c.Gain.Sub(c.Loss)
+		report.Gain = report.Gain.Add(c.Gain)
+		report.Loss = report.Loss.Add(c.Loss)
+	}
+	report.Net = report.Gain.Sub(report.Loss)
+
+	log.Printf("💱 Realised FX for Warehouse %d: gain %s, loss %s %s", warehouseID, report.Gain.StringFixed(2), report.Loss.StringFixed(2), currency)
+	return &report, nil
+}
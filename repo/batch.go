@@ -7,7 +7,9 @@ import (
 	"time"
 	dbconn "warehouse/config/dbConn"
 	"warehouse/models"
+	"warehouse/money"
 
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
@@ -18,78 +20,263 @@ func NewBatchRepo() *BatchRepo {
 }
 
 // ➕ Add new batch
-func (r *BatchRepo) AddBatch(ctx context.Context, batch *models.Batch) (uint, error) {
+func (r *BatchRepo) AddBatch(ctx context.Context, userID uint, batch *models.Batch) (uint, error) {
+	db := dbconn.DB.WithContext(ctx)
+
+	batch.StoredAt = time.Now()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return addBatchTx(tx, userID, batch)
+	})
+
+	if err != nil {
+		log.Printf("🔥 Failed to add batch: %v", err)
+		return 0, err
+	}
+
+	return batch.ID, nil
+}
+
+// SuggestPutaway proposes bins for the lines of a batch that has not been stored yet,
+// honouring the bins already asked for. Nothing is written; POST /batches places the
+// units the same way unless its lines ask for other bins.
+func (r *BatchRepo) SuggestPutaway(ctx context.Context, batch *models.Batch) ([]models.EntryPutaway, error) {
 	db := dbconn.DB.WithContext(ctx)
 	ns := db.NamingStrategy
 
+	planner, err := newPutawayPlanner(db, batch.WarehouseID)
+	if err != nil {
+		return nil, err
+	}
+	suggestions := make([]models.EntryPutaway, 0, len(batch.Products))
+	for _, entry := range batch.Products {
+		var product models.Product
+		if err := db.Table(ns.TableName("Product")).First(&product, entry.ProductID).Error; err != nil {
+			return nil, fmt.Errorf("product not found for ID %d: %w", entry.ProductID, err)
+		}
+		plan, err := planner.suggest(product, entry.Quantity, entry.Putaway)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, models.EntryPutaway{ProductID: entry.ProductID, Quantity: entry.Quantity, Locations: plan})
+	}
+	return suggestions, nil
+}
+
+// addBatchTx validates warehouse space, deducts it and creates the batch with its
+// product entries inside the caller's transaction. A zero StoredAt defaults to now.
+func addBatchTx(tx *gorm.DB, userID uint, batch *models.Batch) error {
+	ns := tx.NamingStrategy
+
 	batch.Status = "active"
 	now := time.Now()
-	var returnID uint
+	if batch.StoredAt.IsZero() {
+		batch.StoredAt = now
+	}
+	if err := bookBatchCurrency(tx, batch); err != nil {
+		return err
+	}
 
-	err := db.Transaction(func(tx *gorm.DB) error {
-		// Step 1️⃣: Load warehouse
-		var warehouse models.Warehouse
-		if err := tx.Table(ns.TableName("Warehouse")).
-			Preload("RentConfig").
-			First(&warehouse, batch.WarehouseID).Error; err != nil {
-			return fmt.Errorf("warehouse not found with ID %d", batch.WarehouseID)
+	// Step 1️⃣: Calculate total used space, in the warehouse's capacity unit
+	unit, err := warehouseCapacityUnit(tx, batch.WarehouseID)
+	if err != nil {
+		return err
+	}
+	var totalUsedArea float64
+	products := make([]models.Product, len(batch.Products))
+	for i := range batch.Products {
+		productEntry := &batch.Products[i]
+
+		// Fetch product to get its footprint
+		var product models.Product
+		if err := tx.Table(ns.TableName("Product")).
+			First(&product, productEntry.ProductID).Error; err != nil {
+			return fmt.Errorf("product not found for ID %d", productEntry.ProductID)
 		}
 
-		// Step 2️⃣: Calculate total used space
-		var totalUsedArea float64
-		for i := range batch.Products {
-			productEntry := &batch.Products[i]
-
-			// Fetch product to get its storage area
-			var product models.Product
-			if err := tx.Table(ns.TableName("Product")).
-				First(&product, productEntry.ProductID).Error; err != nil {
-				return fmt.Errorf("product not found for ID %d", productEntry.ProductID)
+		// Perishables: expiry must follow manufacture and not have passed already
+		if productEntry.ExpiresAt != nil {
+			if productEntry.ManufacturedAt != nil && !productEntry.ExpiresAt.After(*productEntry.ManufacturedAt) {
+				return fmt.Errorf("product %d: expiry date must be after the manufacture date", productEntry.ProductID)
 			}
+			if !productEntry.ExpiresAt.After(now) {
+				return fmt.Errorf("%w: product %d (lot %q) expired on %s", ErrExpiredStock,
+					productEntry.ProductID, productEntry.LotNumber, productEntry.ExpiresAt.Format("2006-01-02"))
+			}
+		}
 
-			// Initialize stock info
-			productEntry.StockQuantity = productEntry.Quantity
-			productEntry.LastUpdated = &now
-
-			// Calculate space usage
-			usedArea := product.StorageArea * float64(productEntry.Quantity)
-			totalUsedArea += usedArea
+		// Initialize stock info
+		productEntry.StockQuantity = productEntry.Quantity
+		productEntry.LastUpdated = &now
 
-			log.Printf("📦 Product %d → Qty %d × %.2f sqft = %.2f sqft used",
-				productEntry.ProductID, productEntry.Quantity, product.StorageArea, usedArea)
+		// Calculate space usage
+		products[i] = product
+		usedArea, err := spaceUsed(product, productEntry.Quantity, unit)
+		if err != nil {
+			return err
 		}
+		totalUsedArea += usedArea
 
-		// Step 3️⃣: Validate warehouse space
-		if warehouse.AvailableArea < totalUsedArea {
-			return fmt.Errorf("❌ insufficient warehouse space (available: %.2f, required: %.2f sqft)",
-				warehouse.AvailableArea, totalUsedArea)
-		}
+		log.Printf("📦 Product %d → Qty %d = %.2f %s used",
+			productEntry.ProductID, productEntry.Quantity, usedArea, unit)
+	}
+
+	// Step 2️⃣: Spread onboarding expenses over the per-unit cost basis
+	totalExpense, err := allocateOnBoardExpenses(batch)
+	if err != nil {
+		return err
+	}
+
+	// Step 3️⃣: Validate and deduct warehouse space
+	if err := reserveWarehouseArea(tx, batch.WarehouseID, totalUsedArea); err != nil {
+		return err
+	}
+
+	// Step 4️⃣: Create batch
+	if err := tx.Table(ns.TableName("Batch")).
+		Create(batch).Error; err != nil {
+		return fmt.Errorf("failed to create batch: %w", err)
+	}
 
-		// Step 4️⃣: Deduct used area and update warehouse
-		warehouse.AvailableArea -= totalUsedArea
-		if err := tx.Table(ns.TableName("Warehouse")).
-			Save(&warehouse).Error; err != nil {
-			return fmt.Errorf("failed to update warehouse space: %w", err)
+	// Step 5️⃣: Put the entries away into bins, where asked or else where suggested
+	planner, err := newPutawayPlanner(tx, batch.WarehouseID)
+	if err != nil {
+		return err
+	}
+	for i := range batch.Products {
+		entry := &batch.Products[i]
+		placed, err := planner.putAway(*entry, products[i], entry.Quantity, entry.Putaway)
+		if err != nil {
+			return err
 		}
+		entry.Putaway = placed
+	}
 
-		// Step 5️⃣: Create batch
-		batch.StoredAt = now
-		if err := tx.Table(ns.TableName("Batch")).
-			Create(batch).Error; err != nil {
-			return fmt.Errorf("failed to create batch: %w", err)
+	// Step 6️⃣: Write inbound ledger rows
+	movements := make([]models.StockMovement, 0, len(batch.Products))
+	for _, entry := range batch.Products {
+		movements = append(movements, newStockMovement(entry, batch.WarehouseID, entry.Quantity, models.MovementBatchIntake, models.SourceBatch, userID))
+	}
+	if err := recordStockMovements(tx, batch.ID, movements); err != nil {
+		return err
+	}
+
+	// Step 7️⃣: Record onboarding expenses
+	for _, exp := range batch.Expenses {
+		onExp := models.OnBoardExpense{
+			BatchID: batch.ID,
+			Type:    exp.Type,
+			Amount:  exp.Amount,
+			Notes:   exp.Notes,
 		}
+		if err := tx.Table(ns.TableName("OnBoardExpense")).Create(&onExp).Error; err != nil {
+			return fmt.Errorf("failed to record onboard expense: %w", err)
+		}
+	}
 
-		returnID = batch.ID
-		log.Printf("✅ Batch created (ID=%d) | Used %.2f sqft | Warehouse %d", batch.ID, totalUsedArea, batch.WarehouseID)
+	log.Printf("✅ Batch created (ID=%d) | Used %.2f %s | Expenses %s %s | Warehouse %d", batch.ID, totalUsedArea, unit, totalExpense.StringFixed(2), batch.Currency, batch.WarehouseID)
+	return nil
+}
+
+// reserveWarehouseArea deducts area from the warehouse if it has room. The check and
+// the deduction are one UPDATE, so concurrent intakes cannot overbook the space. In a
+// warehouse with bins only the room is checked: the bins the stock is put away into
+// take the space, and the warehouse area is derived from them.
+func reserveWarehouseArea(tx *gorm.DB, warehouseID uint, area float64) error {
+	binned, err := warehouseHasBins(tx, warehouseID)
+	if err != nil {
+		return err
+	}
+	deduct := area
+	if binned {
+		deduct = 0
+	}
+	res := tx.Model(&models.Warehouse{}).
+		Where("id = ? AND available_area >= ?", warehouseID, area).
+		Update("available_area", gorm.Expr("available_area - ?", deduct))
+	if res.Error != nil {
+		return fmt.Errorf("failed to update warehouse space: %w", res.Error)
+	}
+	if res.RowsAffected == 1 {
 		return nil
-	})
+	}
 
+	var warehouse models.Warehouse
+	if err := tx.Model(&models.Warehouse{}).First(&warehouse, warehouseID).Error; err != nil {
+		return fmt.Errorf("warehouse not found with ID %d", warehouseID)
+	}
+	return fmt.Errorf("❌ insufficient warehouse space (available: %.2f, required: %.2f %s)",
+		warehouse.AvailableArea, area, warehouse.CapacityUnit)
+}
+
+// releaseWarehouseArea gives area back to the warehouse, never beyond its total area.
+// A warehouse with bins gets it back as the stock is picked from them.
+func releaseWarehouseArea(tx *gorm.DB, warehouseID uint, area float64) error {
+	binned, err := warehouseHasBins(tx, warehouseID)
 	if err != nil {
-		log.Printf("🔥 Failed to add batch: %v", err)
-		return 0, err
+		return err
+	}
+	if binned {
+		area = 0
+	}
+	res := tx.Model(&models.Warehouse{}).
+		Where("id = ?", warehouseID).
+		Update("available_area", gorm.Expr(
+			"CASE WHEN available_area + ? > total_area THEN total_area ELSE available_area + ? END", area, area))
+	if res.Error != nil {
+		return fmt.Errorf("failed to update warehouse space: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("warehouse not found with ID %d", warehouseID)
+	}
+	return nil
+}
+
+// allocateOnBoardExpenses spreads intake costs (freight, loading, handling…) over the
+// batch entries in proportion to their purchase value, falling back to quantity when the
+// batch has no purchase value. The share is stored per unit so it can be booked against
+// profit as units are offboarded.
+func allocateOnBoardExpenses(batch *models.Batch) (decimal.Decimal, error) {
+	totalExpense := decimal.Zero
+	for i := range batch.Expenses {
+		exp := &batch.Expenses[i]
+		if exp.Type == "" {
+			return decimal.Zero, fmt.Errorf("onboard expense type is required")
+		}
+		if exp.Amount.IsNegative() {
+			return decimal.Zero, fmt.Errorf("onboard expense %q cannot be negative", exp.Type)
+		}
+		exp.Amount = money.Round(exp.Amount)
+		totalExpense = totalExpense.Add(exp.Amount)
+	}
+	if totalExpense.IsZero() || len(batch.Products) == 0 {
+		return totalExpense, nil
+	}
+
+	totalValue := decimal.Zero
+	var totalQty int
+	for _, entry := range batch.Products {
+		totalValue = totalValue.Add(entry.BillingPrice.Mul(money.Qty(entry.Quantity)))
+		totalQty += entry.Quantity
+	}
+	if totalQty == 0 {
+		return decimal.Zero, fmt.Errorf("cannot allocate onboard expenses to a batch without quantity")
+	}
+
+	for i := range batch.Products {
+		entry := &batch.Products[i]
+		if entry.Quantity == 0 {
+			continue
+		}
+		var share decimal.Decimal
+		if totalValue.IsPositive() {
+			share = totalExpense.Mul(entry.BillingPrice.Mul(money.Qty(entry.Quantity))).Div(totalValue)
+		} else {
+			share = totalExpense.Mul(money.Qty(entry.Quantity)).Div(money.Qty(totalQty))
+		}
+		entry.OnBoardCost = share.Div(money.Qty(entry.Quantity)).Round(money.UnitPlaces)
 	}
 
-	return returnID, nil
+	return totalExpense, nil
 }
 
 // 📦 Get all batches
@@ -121,12 +308,14 @@ func (r *BatchRepo) GetAllBatchesCoreData(ctx context.Context, warehouseId uint)
 		WarehouseID      uint
 		StoredAt         time.Time
 		Status           string
+		Currency         string
+		ExchangeRate     decimal.Decimal
 		CreatedAt        time.Time
 		UpdatedAt        time.Time
 		BatchStock       int
 		AvailableStock   int
-		OnBoardedAmount  float64
-		OffBoardedAmount float64
+		OnBoardedAmount  decimal.Decimal
+		OffBoardedAmount decimal.Decimal
 	}
 
 	var rows []rawData
@@ -138,6 +327,8 @@ func (r *BatchRepo) GetAllBatchesCoreData(ctx context.Context, warehouseId uint)
 			b.warehouse_id,
 			b.stored_at,
 			b.status,
+			b.currency,
+			b.exchange_rate,
 			b.created_at,
 			b.updated_at,
 			COALESCE(SUM(be.quantity), 0) AS batch_stock,
@@ -148,7 +339,7 @@ func (r *BatchRepo) GetAllBatchesCoreData(ctx context.Context, warehouseId uint)
 		Joins("LEFT JOIN " + ns.TableName("BatchProductEntry") + " AS be ON be.batch_id = b.id").
 		Joins("LEFT JOIN " + ns.TableName("BillingItem") + " AS bi ON bi.batch_id = b.id").
 		Where("b.warehouse_id = ?", warehouseId). // ✅ ← Warehouse filter added
-		Group("b.id, b.warehouse_id, b.stored_at, b.status, b.created_at, b.updated_at").
+		Group("b.id, b.warehouse_id, b.stored_at, b.status, b.currency, b.exchange_rate, b.created_at, b.updated_at").
 		Order("b.created_at DESC").
 		Scan(&rows).Error
 
@@ -163,6 +354,8 @@ func (r *BatchRepo) GetAllBatchesCoreData(ctx context.Context, warehouseId uint)
 			WarehouseID:      row.WarehouseID,
 			StoredAt:         row.StoredAt,
 			Status:           row.Status,
+			Currency:         row.Currency,
+			ExchangeRate:     row.ExchangeRate,
 			CreatedAt:        row.CreatedAt,
 			UpdatedAt:        row.UpdatedAt,
 			BatchStock:       row.BatchStock,
@@ -205,12 +398,14 @@ func (r *BatchRepo) GetBatchCoreDataByID(ctx context.Context, id uint) (*models.
 		WarehouseID      uint
 		StoredAt         time.Time
 		Status           string
+		Currency         string
+		ExchangeRate     decimal.Decimal
 		CreatedAt        time.Time
 		UpdatedAt        time.Time
 		BatchStock       int
 		AvailableStock   int
-		OffBoardedAmount float64
-		OnBoardedAmount  float64
+		OffBoardedAmount decimal.Decimal
+		OnBoardedAmount  decimal.Decimal
 	}
 
 	var batchRow batchCoreRow
@@ -222,6 +417,8 @@ func (r *BatchRepo) GetBatchCoreDataByID(ctx context.Context, id uint) (*models.
 			b.warehouse_id,
 			b.stored_at,
 			b.status,
+			b.currency,
+			b.exchange_rate,
 			b.created_at,
 			b.updated_at,
 			COALESCE(SUM(be.quantity), 0) AS batch_stock,
@@ -232,7 +429,7 @@ func (r *BatchRepo) GetBatchCoreDataByID(ctx context.Context, id uint) (*models.
 		Joins("LEFT JOIN " + ns.TableName("BatchProductEntry") + " AS be ON be.batch_id = b.id").
 		Joins("LEFT JOIN " + ns.TableName("BillingItem") + " AS bi ON bi.batch_id = b.id").
 		Where("b.id = ?", id).
-		Group("b.id, b.warehouse_id, b.stored_at, b.status, b.created_at, b.updated_at").
+		Group("b.id, b.warehouse_id, b.stored_at, b.status, b.currency, b.exchange_rate, b.created_at, b.updated_at").
 		Scan(&batchRow).Error
 
 	if err != nil {
@@ -251,9 +448,13 @@ func (r *BatchRepo) GetBatchCoreDataByID(ctx context.Context, id uint) (*models.
 		StorageArea    float64
 		ProductCreated time.Time
 		ProductUpdated time.Time
-		BillingPrice   float64
+		BillingPrice   decimal.Decimal
 		Quantity       int
 		StockQuantity  int
+		OnBoardCost    decimal.Decimal
+		LotNumber      string
+		ManufacturedAt *time.Time
+		ExpiresAt      *time.Time
 		CreatedAt      time.Time
 		LastOffboarded *time.Time
 		LastUpdated    *time.Time
@@ -273,6 +474,10 @@ func (r *BatchRepo) GetBatchCoreDataByID(ctx context.Context, id uint) (*models.
 			be.billing_price,
 			be.quantity,
 			be.stock_quantity,
+			be.on_board_cost,
+			COALESCE(be.lot_number, '') AS lot_number,
+			be.manufactured_at,
+			be.expires_at,
 			be.created_at,
 			be.last_offboarded,
 			be.last_updated
@@ -303,24 +508,48 @@ func (r *BatchRepo) GetBatchCoreDataByID(ctx context.Context, id uint) (*models.
 			BillingPrice:   pr.BillingPrice,
 			Quantity:       pr.Quantity,
 			StockQuantity:  pr.StockQuantity,
+			OnBoardCost:    pr.OnBoardCost,
+			LotNumber:      pr.LotNumber,
+			ManufacturedAt: pr.ManufacturedAt,
+			ExpiresAt:      pr.ExpiresAt,
 			CreatedAt:      pr.CreatedAt,
 			LastOffboarded: pr.LastOffboarded,
 			LastUpdated:    pr.LastUpdated,
 		})
 	}
 
+	// ✅ Step 4.5: Fetch onboarding expenses
+	var expenseRows []models.OnBoardExpense
+	if err := db.Table(ns.TableName("OnBoardExpense")).
+		Where("batch_id = ?", id).
+		Order("id ASC").
+		Find(&expenseRows).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch batch expenses: %w", err)
+	}
+
+	expenses := make([]models.Expense, 0, len(expenseRows))
+	var expenseTotal decimal.Decimal
+	for _, e := range expenseRows {
+		expenses = append(expenses, models.Expense{Type: e.Type, Amount: e.Amount, Notes: e.Notes})
+		expenseTotal = expenseTotal.Add(e.Amount)
+	}
+
 	// ✅ Step 5: Build final struct safely
 	batchCore := models.BatchCoreDataWithProducts{
 		ID:               batchRow.ID,
 		WarehouseID:      batchRow.WarehouseID,
 		StoredAt:         batchRow.StoredAt,
 		Status:           batchRow.Status,
+		Currency:         batchRow.Currency,
+		ExchangeRate:     batchRow.ExchangeRate,
 		CreatedAt:        batchRow.CreatedAt,
 		UpdatedAt:        batchRow.UpdatedAt,
 		BatchStock:       batchRow.BatchStock,
 		AvailableStock:   batchRow.AvailableStock,
 		OffBoardedAmount: batchRow.OffBoardedAmount,
 		OnBoardedAmount:  batchRow.OnBoardedAmount,
+		OnBoardExpense:   expenseTotal,
+		Expenses:         expenses,
 		Product:          batchProducts,
 	}
 
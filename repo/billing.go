@@ -2,19 +2,38 @@ package repo
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"strings"
 	"time"
+	"warehouse/allocation"
+	"warehouse/config"
 	dbconn "warehouse/config/dbConn"
 	"warehouse/models"
+	"warehouse/money"
+	"warehouse/rent"
+	"warehouse/tax"
 
+	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 )
 
 type BillingRepo struct {
 }
 
+// ErrWarehouseMismatch is returned when a bill touches stock outside the caller's warehouse
+var ErrWarehouseMismatch = errors.New("warehouse mismatch")
+
+// ErrExpiredStock is returned when expired stock would be billed or taken in
+var ErrExpiredStock = errors.New("stock has expired")
+
+// ErrInvalidBillingInput is returned for bill lines that cannot be billed as given
+var ErrInvalidBillingInput = errors.New("invalid billing input")
+
+// ErrInvalidReversal is returned when a reversal names items or quantities the bill
+// cannot give back
+var ErrInvalidReversal = errors.New("invalid billing reversal")
+
 // NewBillingRepo initializes the billing repo
 func NewBillingRepo() *BillingRepo {
 	return &BillingRepo{}
@@ -23,365 +42,714 @@ func NewBillingRepo() *BillingRepo {
 // ===============================
 // 💳 Create Billing (With BatchID)
 // ===============================
-func (r *BillingRepo) CreateBillingWithBatchId(ctx context.Context, billingInput models.BillingInput) (*models.Billing, error) {
+func (r *BillingRepo) CreateBillingWithBatchId(ctx context.Context, warehouseId, userID uint, billingInput models.BillingInput) (*models.Billing, error) {
 	db := dbconn.DB.WithContext(ctx)
-	ns := db.NamingStrategy
 
-	var billing models.Billing
+	var billing *models.Billing
 	err := db.Transaction(func(tx *gorm.DB) error {
-		var (
-			totalRent, totalStorage, totalBuying, totalSelling, otherExpenses, margin, avgExpense float64
-			billingItems                                                                          []models.BillingItem
-		)
-
-		// 🧮 Calculate average expense
-		if len(billingInput.Expenses) > 0 {
-			var expSum float64
-			for _, exp := range billingInput.Expenses {
-				expSum += exp.Amount
-				otherExpenses += exp.Amount
-			}
-			avgExpense = expSum / float64(len(billingInput.Expenses))
+		now := time.Now()
+		draft, err := draftBilling(tx, warehouseId, billingInput, true, true, now)
+		if err != nil {
+			return err
 		}
+		billing, err = applyBillDraft(tx, warehouseId, userID, draft, billingInput.Expenses, now)
+		return err
+	})
 
-		for _, item := range billingInput.Items {
-			var entry models.BatchProductEntry
-			if err := tx.Table(ns.TableName("BatchProductEntry")).
-				Where("batch_id = ? AND product_id = ?", item.BatchID, item.ProductID).
-				First(&entry).Error; err != nil {
-				return fmt.Errorf("invalid batch or product reference (batch_id=%v, product_id=%v): %w", item.BatchID, item.ProductID, err)
-			}
+	if err != nil {
+		log.Printf("❌ Billing creation (BatchID mode) failed: %v", err)
+		return nil, err
+	}
 
-			if entry.StockQuantity < item.OffboardQty {
-				return fmt.Errorf("insufficient stock for product %v in batch %v", item.ProductID, item.BatchID)
-			}
+	log.Printf("✅ Billing created successfully (ID=%d)", billing.ID)
+	return billing, nil
+}
 
-			var product models.Product
-			if err := tx.Table(ns.TableName("Product")).First(&product, entry.ProductID).Error; err != nil {
-				return fmt.Errorf("product not found (ID=%d): %w", entry.ProductID, err)
-			}
+// ===============================
+// 💳 Create Billing (allocation strategy picks the batches)
+// ===============================
+func (r *BillingRepo) CreateBillingWithOutBatchId(ctx context.Context, warehouseId, userID uint, billingInput models.BillingInput) (*models.Billing, error) {
+	db := dbconn.DB.WithContext(ctx)
 
-			var batch models.Batch
-			if err := tx.Table(ns.TableName("Batch")).
-				Preload("Warehouse.RentConfig").
-				First(&batch, item.BatchID).Error; err != nil {
-				return fmt.Errorf("batch not found (ID=%v): %w", item.BatchID, err)
-			}
+	var billing *models.Billing
+	err := db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		draft, err := draftBilling(tx, warehouseId, billingInput, false, true, now)
+		if err != nil {
+			return err
+		}
+		billing, err = applyBillDraft(tx, warehouseId, userID, draft, billingInput.Expenses, now)
+		return err
+	})
 
-			// Rent details
-			rate := batch.Warehouse.RentConfig.RatePerSqft
-			cycle := strings.ToLower(batch.Warehouse.RentConfig.BillingCycle)
+	if err != nil {
+		log.Printf("❌ Billing creation (allocated batches) failed: %v", err)
+		return nil, err
+	}
 
-			// Duration calculation
-			durationDays := time.Since(batch.CreatedAt).Hours() / 24
-			if durationDays < 1 {
-				durationDays = 1
-			} else if durationDays > 365 {
-				durationDays = 365
-			}
+	log.Printf("✅ Billing created successfully (allocated batches, ID=%d)", billing.ID)
+	return billing, nil
+}
 
-			var rentMultiplier float64
-			switch cycle {
-			case "daily":
-				rentMultiplier = durationDays
-			case "weekly":
-				rentMultiplier = durationDays / 7
-			case "monthly":
-				rentMultiplier = durationDays / 30
-			default:
-				rentMultiplier = durationDays / 30
-			}
+// ===============================
+// 👀 Preview Billing (no writes)
+// ===============================
 
-			// Cost computations
-			areaUsed := product.StorageArea * float64(item.OffboardQty)
-			storageCost := rate * areaUsed * rentMultiplier
-			totalBuy := float64(item.OffboardQty) * entry.BillingPrice
-			totalSell := float64(item.OffboardQty) * item.SellingPrice
-
-			totalStorage += areaUsed
-			totalBuying += totalBuy
-			totalSelling += totalSell
-			totalRent += storageCost
-
-			// ✅ Update stock
-			entry.StockQuantity -= item.OffboardQty
-			now := time.Now()
-			entry.LastOffboarded = &now
-			if err := tx.Save(&entry).Error; err != nil {
-				return fmt.Errorf("failed to update stock: %w", err)
-			}
+// PreviewBilling runs the full bill calculation, batch allocation included, without
+// touching stock. Items that all carry a batch ID are billed from those batches, like
+// /billing/generate/batch; otherwise the allocation strategy picks them.
+func (r *BillingRepo) PreviewBilling(ctx context.Context, warehouseId uint, billingInput models.BillingInput) (*models.BillingPreview, error) {
+	db := dbconn.DB.WithContext(ctx)
 
-			// ✅ Update warehouse area
-			var warehouse models.Warehouse
-			if err := tx.Table(ns.TableName("Warehouse")).First(&warehouse, batch.WarehouseID).Error; err == nil {
-				warehouse.AvailableArea += areaUsed
-				if warehouse.AvailableArea > warehouse.TotalArea {
-					warehouse.AvailableArea = warehouse.TotalArea
-				}
-				tx.Save(&warehouse)
-			}
+	byBatch := len(billingInput.Items) > 0
+	for _, item := range billingInput.Items {
+		if item.BatchID == "" {
+			byBatch = false
+		}
+	}
 
-			// ✅ Record profit
-			profit := (item.SellingPrice - entry.BillingPrice) * float64(item.OffboardQty)
-			netProfit := profit - storageCost - avgExpense
+	draft, err := draftBilling(db, warehouseId, billingInput, byBatch, false, time.Now())
+	if err != nil {
+		log.Printf("❌ Billing preview failed: %v", err)
+		return nil, err
+	}
 
-			if err := tx.Table(ns.TableName("Profit")).Create(&models.Profit{
-				BatchID:   entry.BatchID,
-				ProductID: entry.ProductID,
-				Profit:    profit,
-				NetProfit: netProfit,
-			}).Error; err != nil {
-				return fmt.Errorf("failed to record profit: %w", err)
-			}
+	return &models.BillingPreview{
+		Billing:  draft.billing,
+		Profits:  draft.profits,
+		Expenses: billingInput.Expenses,
+	}, nil
+}
 
-			// ✅ Add billing item
-			billingItems = append(billingItems, models.BillingItem{
-				ProductID:    entry.ProductID,
-				BatchID:      entry.BatchID,
-				OffboardQty:  item.OffboardQty,
-				DurationDays: durationDays,
-				StorageCost:  storageCost,
-				BuyingPrice:  entry.BillingPrice,
-				SellingPrice: item.SellingPrice,
-				TotalSelling: totalSell,
-				BatchStatus:  "offboarded",
-			})
+// billLine is the stock one bill line takes out of a batch entry
+type billLine struct {
+	allocatedStock
+	Product      models.Product
+	SellingPrice decimal.Decimal
+	item         int // index of the bill item the line was picked for
+}
 
-			// ✅ Mark batch inactive if all products sold
-			var remaining int64
-			tx.Table(ns.TableName("BatchProductEntry")).
-				Where("batch_id = ? AND stock_quantity > 0", entry.BatchID).
-				Count(&remaining)
-			if remaining == 0 {
-				batch.Status = "inactive"
-				tx.Save(&batch)
-			}
+// billDraft is a fully calculated bill that has not touched stock yet. The preview
+// returns it as it is; the create paths apply it.
+type billDraft struct {
+	billing models.Billing // Items are in the same order as lines
+	lines   []billLine
+	profits []models.Profit
+}
+
+// draftBilling prices a bill: picks the stock of every item, charges the unbilled rent,
+// taxes the lines and apportions the offboarding expenses into the profit rows. It only
+// reads, so the preview and the create paths share it. With lock the picked entries
+// stay locked until the transaction ends, so a concurrent bill waits and then sees
+// what is left instead of picking the same units.
+func draftBilling(tx *gorm.DB, warehouseId uint, billingInput models.BillingInput, byBatch, lock bool, now time.Time) (*billDraft, error) {
+	// Checked here too as the repo is not only called through binding; a quantity below
+	// one would put stock back and free area instead of taking them
+	for _, item := range billingInput.Items {
+		if item.OffboardQty <= 0 {
+			return nil, fmt.Errorf("%w: offboard quantity must be positive (product %s, quantity %d)", ErrInvalidBillingInput, item.ProductID, item.OffboardQty)
 		}
+	}
+
+	rentRates := newRentRateCache(tx)
+	customer, err := loadCustomer(tx, billingInput.CustomerID)
+	if err != nil {
+		return nil, err
+	}
+	taxes, err := newBillTax(tx, warehouseId, customer, billingInput.TaxInclusive)
+	if err != nil {
+		return nil, err
+	}
+	conv, err := newBillFX(tx, warehouseId, customer, billingInput.Currency, now)
+	if err != nil {
+		return nil, err
+	}
 
-		// Final margin
-		margin = totalSelling - (totalBuying + totalRent + otherExpenses)
-
-		billing = models.Billing{
-			Items:         billingItems,
-			TotalRent:     totalRent,
-			TotalStorage:  totalStorage,
-			TotalBuying:   totalBuying,
-			TotalSelling:  totalSelling,
-			OtherExpenses: otherExpenses,
-			Margin:        margin,
-		}
-
-		if err := tx.Table(ns.TableName("Billing")).Create(&billing).Error; err != nil {
-			return fmt.Errorf("failed to create billing: %w", err)
-		}
-		
-		// -------------------------------------------------------------
-		// ⭐ INSERT OFFBOARD EXPENSE ROWS
-		// -------------------------------------------------------------
-		for _, exp := range billingInput.Expenses {
-			offExp := models.OffBoardExpense{
-				BillingID: billing.ID,
-				Type:      exp.Type,
-				Amount:    exp.Amount,
-				Notes:     exp.Notes,
+	// Stock already taken by earlier lines of this bill, so repeated lines see what is left
+	taken := map[uint]int{}
+	var lines []billLine
+	for n, item := range billingInput.Items {
+		var picked []billLine
+		if byBatch {
+			line, err := batchBillLine(tx, warehouseId, item, taken, lock, now)
+			if err != nil {
+				return nil, err
 			}
+			picked = []billLine{line}
+		} else {
+			picked, err = allocatedBillLines(tx, rentRates, warehouseId, billingInput.Allocation, item, taken, lock, now)
+			if err != nil {
+				return nil, err
+			}
+		}
+		for k := range picked {
+			picked[k].item = n
+			taken[picked[k].Entry.ID] += picked[k].Quantity
+		}
+		lines = append(lines, picked...)
+	}
+
+	draft, err := priceBill(rentRates, taxes, conv, lines, billingInput.Expenses, roundingRule(billingInput.Rounding), now)
+	if err != nil {
+		return nil, err
+	}
+
+	// 📍 Bins to pick each line from; applying the bill takes the units in the same order
+	held := map[uint]int{}
+	for i, line := range lines {
+		if draft.billing.Items[i].PickFrom, err = pickPlan(tx, line.Entry.ID, line.Quantity, held, lock); err != nil {
+			return nil, err
+		}
+	}
+
+	dueDate := billDueDate(now, customer, billingInput.DueDate)
+	draft.billing.WarehouseID = warehouseId
+	draft.billing.CustomerID = billingInput.CustomerID
+	draft.billing.DueDate = &dueDate
+	draft.billing.PaymentStatus = models.PaymentUnpaid
+	return draft, nil
+}
+
+// roundingRule is the requested money rounding rule, else the configured one (per line by
+// default)
+func roundingRule(requested string) string {
+	if requested != "" {
+		return money.NormalizeRounding(requested)
+	}
+	return money.NormalizeRounding(config.Cfg.MoneyRounding)
+}
+
+// priceBill prices picked lines: the unbilled rent, the cost, the taxed selling value
+// and the profit of each line, rounded under the rounding rule. The bill totals are the
+// sums of its rounded items, so they always match the lines exactly. Everything is in
+// the bill's currency: selling prices are given in it, batch costs are rebooked into it
+// and rent is converted on the bill date.
+func priceBill(rentRates *rentRateCache, taxes *billTax, conv *billFX, lines []billLine, expenses []models.Expense, rounding string, now time.Time) (*billDraft, error) {
+	var (
+		charges  = make([]rent.Charge, len(lines))
+		rents    = make([]decimal.Decimal, len(lines))
+		units    = make([]decimal.Decimal, len(lines))
+		buying   = make([]decimal.Decimal, len(lines))
+		selling  = make([]tax.Line, len(lines))
+		areaUsed = make([]float64, len(lines))
+
+		settledEntries = map[uint]bool{}
+		settledItems   = map[int]bool{}
+	)
+	for i, line := range lines {
+		// Rent for the days not yet invoiced, priced by the rent engine
+		rates, err := rentRates.forWarehouse(line.Batch.WarehouseID)
+		if err != nil {
+			return nil, err
+		}
+		unit, err := rentRates.capacityUnit(line.Batch.WarehouseID)
+		if err != nil {
+			return nil, err
+		}
+		if areaUsed[i], err = spaceUsed(line.Product, line.Quantity, unit); err != nil {
+			return nil, err
+		}
+		// The minimum rent is checked once per stay: not for entries an earlier bill
+		// offboarded, and once for a bill item split across entries or repeated lines
+		met := line.Entry.RentMinimumMet || settledEntries[line.Entry.ID] || settledItems[line.item]
+		charges[i] = rent.Unbilled(rates, areaUsed[i], line.Batch.StoredAt, line.Entry.RentBilledTo, now, met)
+		settledEntries[line.Entry.ID], settledItems[line.item] = true, true
+		if rents[i], err = conv.convert(charges[i].Amount, charges[i].Currency, now); err != nil {
+			return nil, err
+		}
+
+		// Cost computations; selling is the taxable value, GST is kept apart
+		qty := money.Qty(line.Quantity)
+		units[i] = conv.cost(line.Entry.BillingPrice, line.Batch)
+		buying[i] = units[i].Mul(qty)
+		if selling[i], err = taxes.line(line.Product, line.SellingPrice.Mul(qty)); err != nil {
+			return nil, err
+		}
+	}
+	rents = money.RoundLines(rents, rounding)
+	buying = money.RoundLines(buying, rounding)
+	lineTaxes := tax.Document(selling, taxes.inclusive, taxes.supplyType, rounding)
+
+	billing := models.Billing{
+		Currency:     conv.currency,
+		ExchangeRate: conv.rate,
+		TaxInclusive: taxes.inclusive,
+		SupplyType:   taxes.supplyType,
+		Rounding:     rounding,
+	}
+
+	// 🧮 Average expense calculation
+	avgExpense := decimal.Zero
+	if len(expenses) > 0 {
+		for _, exp := range expenses {
+			billing.OtherExpenses = billing.OtherExpenses.Add(money.Round(exp.Amount))
+		}
+		avgExpense = money.Round(billing.OtherExpenses.Div(money.Qty(len(expenses))))
+	}
+
+	profits := make([]models.Profit, 0, len(lines))
+	for i, line := range lines {
+		entry, lineTax := line.Entry, lineTaxes[i]
+
+		billing.TotalStorage += areaUsed[i]
+		billing.TotalBuying = billing.TotalBuying.Add(buying[i])
+		billing.TotalSelling = billing.TotalSelling.Add(lineTax.Taxable)
+		billing.TotalRent = billing.TotalRent.Add(rents[i])
+		billing.CGSTAmount = billing.CGSTAmount.Add(lineTax.CGST)
+		billing.SGSTAmount = billing.SGSTAmount.Add(lineTax.SGST)
+		billing.IGSTAmount = billing.IGSTAmount.Add(lineTax.IGST)
+		billing.TotalTax = billing.TotalTax.Add(lineTax.Tax)
+
+		// ✅ Profit
+		profit := lineTax.Taxable.Sub(buying[i])
+		intakeCost := money.Round(conv.cost(entry.OnBoardCost, line.Batch).Mul(money.Qty(line.Quantity)))
+		netProfit := profit.Sub(rents[i]).Sub(avgExpense).Sub(intakeCost)
+
+		profits = append(profits, models.Profit{
+			BatchID:   entry.BatchID,
+			ProductID: entry.ProductID,
+			Profit:    profit,
+			NetProfit: netProfit,
+		})
+
+		billing.Items = append(billing.Items, models.BillingItem{
+			ProductID:        entry.ProductID,
+			BatchID:          entry.BatchID,
+			OffboardQty:      line.Quantity,
+			DurationDays:     float64(charges[i].Days),
+			StorageCost:      rents[i],
+			BuyingPrice:      units[i],
+			SellingPrice:     line.SellingPrice,
+			TotalSelling:     lineTax.Taxable,
+			HSNCode:          line.Product.HSNCode,
+			TaxRate:          lineTax.Rate,
+			CGSTAmount:       lineTax.CGST,
+			SGSTAmount:       lineTax.SGST,
+			IGSTAmount:       lineTax.IGST,
+			TaxAmount:        lineTax.Tax,
+			BatchStatus:      "offboarded",
+			Allocation:       line.Strategy,
+			AllocationReason: line.Reason,
+		})
+	}
+
+	// Final margin
+	billing.Margin = billing.TotalSelling.Sub(billing.TotalBuying.Add(billing.TotalRent).Add(billing.OtherExpenses))
+	billing.InvoiceTotal = billing.TotalSelling.Add(billing.TotalTax)
+
+	return &billDraft{billing: billing, lines: lines, profits: profits}, nil
+}
+
+// batchBillLine takes an item from the batch the caller named
+func batchBillLine(tx *gorm.DB, warehouseId uint, item models.BillingItemInput, taken map[uint]int, lock bool, now time.Time) (billLine, error) {
+	ns := tx.NamingStrategy
+
+	var entry models.BatchProductEntry
+	if err := lockRows(tx.Table(ns.TableName("BatchProductEntry")), lock, "").
+		Where("batch_id = ? AND product_id = ?", item.BatchID, item.ProductID).
+		First(&entry).Error; err != nil {
+		return billLine{}, fmt.Errorf("invalid batch or product reference (batch_id=%v, product_id=%v): %w", item.BatchID, item.ProductID, err)
+	}
+
+	if entry.StockQuantity-taken[entry.ID] < item.OffboardQty {
+		return billLine{}, fmt.Errorf("%w: insufficient stock for product %v in batch %v", allocation.ErrInsufficientStock, item.ProductID, item.BatchID)
+	}
+	if entry.ExpiresAt != nil && !entry.ExpiresAt.After(now) {
+		return billLine{}, fmt.Errorf("%w: product %v in batch %v (lot %q) expired on %s", ErrExpiredStock,
+			item.ProductID, item.BatchID, entry.LotNumber, entry.ExpiresAt.Format("2006-01-02"))
+	}
+
+	var product models.Product
+	if err := tx.Table(ns.TableName("Product")).First(&product, entry.ProductID).Error; err != nil {
+		return billLine{}, fmt.Errorf("product not found (ID=%d): %w", entry.ProductID, err)
+	}
+
+	var batch models.Batch
+	if err := tx.Table(ns.TableName("Batch")).
+		First(&batch, entry.BatchID).Error; err != nil {
+		return billLine{}, fmt.Errorf("batch not found (ID=%v): %w", item.BatchID, err)
+	}
+
+	// 🔒 A bill may only offboard stock from the caller's warehouse
+	if batch.WarehouseID != warehouseId {
+		return billLine{}, fmt.Errorf("%w: batch %v belongs to warehouse %d, bill is for warehouse %d (mixed-warehouse bills are not allowed)",
+			ErrWarehouseMismatch, item.BatchID, batch.WarehouseID, warehouseId)
+	}
+
+	return billLine{
+		allocatedStock: allocatedStock{Entry: entry, Batch: batch, Quantity: item.OffboardQty},
+		Product:        product,
+		SellingPrice:   item.SellingPrice,
+	}, nil
+}
+
+// allocatedBillLines lets the allocation strategy pick the batches of an item: the
+// request's strategy, else the product's (FIFO by default)
+func allocatedBillLines(tx *gorm.DB, rentRates *rentRateCache, warehouseId uint, requested string, item models.BillingItemInput, taken map[uint]int, lock bool, now time.Time) ([]billLine, error) {
+	ns := tx.NamingStrategy
+
+	var product models.Product
+	if err := tx.Table(ns.TableName("Product")).Where("id = ?", item.ProductID).First(&product).Error; err != nil {
+		return nil, fmt.Errorf("product not found (ID=%v): %w", item.ProductID, err)
+	}
+
+	picks, err := allocateStock(tx, rentRates, warehouseId, product, item.OffboardQty, allocationStrategy(requested, product), taken, lock, now)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]billLine, 0, len(picks))
+	for _, pick := range picks {
+		lines = append(lines, billLine{allocatedStock: pick, Product: product, SellingPrice: item.SellingPrice})
+	}
+	return lines, nil
+}
 
-			if err := tx.Table(ns.TableName("OffBoardExpense")).Create(&offExp).Error; err != nil {
-				return fmt.Errorf("failed to record offboard expense: %w", err)
+// applyBillDraft takes the drafted stock out of the warehouse and saves the bill with
+// its invoice number, profit rows, stock movements and offboarding expenses
+func applyBillDraft(tx *gorm.DB, warehouseId, userID uint, draft *billDraft, expenses []models.Expense, now time.Time) (*models.Billing, error) {
+	ns := tx.NamingStrategy
+
+	unit, err := warehouseCapacityUnit(tx, warehouseId)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		movements    []models.StockMovement
+		releasedArea float64
+	)
+	for _, line := range draft.lines {
+		// ✅ Update stock; the guarded update fails rather than oversell
+		entry, err := changeStock(tx, line.Entry.ID, -line.Quantity, map[string]any{"last_offboarded": now, "rent_minimum_met": true})
+		if err != nil {
+			return nil, err
+		}
+		movements = append(movements, newStockMovement(entry, line.Batch.WarehouseID, -line.Quantity, models.MovementBillingOffboard, models.SourceBilling, userID))
+		if _, err := pickFromBins(tx, warehouseId, entry, line.Product, line.Quantity); err != nil {
+			return nil, err
+		}
+		space, err := spaceUsed(line.Product, line.Quantity, unit)
+		if err != nil {
+			return nil, err
+		}
+		releasedArea += space
+
+		// ✅ Mark batch inactive if all products sold
+		var remaining int64
+		if err := tx.Table(ns.TableName("BatchProductEntry")).
+			Where("batch_id = ? AND stock_quantity > 0", entry.BatchID).
+			Count(&remaining).Error; err != nil {
+			return nil, fmt.Errorf("failed to count stock left in batch %d: %w", entry.BatchID, err)
+		}
+		if remaining == 0 {
+			if err := tx.Table(ns.TableName("Batch")).
+				Where("id = ?", entry.BatchID).
+				Update("status", "inactive").Error; err != nil {
+				return nil, fmt.Errorf("failed to update batch %d status: %w", entry.BatchID, err)
 			}
 		}
+	}
 
-		return nil
-	})
+	// ✅ Update warehouse area
+	if err := releaseWarehouseArea(tx, warehouseId, releasedArea); err != nil {
+		return nil, err
+	}
 
+	// 🔢 Issued last so the sequence stays locked for as little of the bill as possible
+	invoiceNo, err := nextInvoiceNumber(tx, warehouseId, now)
 	if err != nil {
-		log.Printf("❌ Billing creation (BatchID mode) failed: %v", err)
 		return nil, err
 	}
 
-	log.Printf("✅ Billing created successfully (ID=%d)", billing.ID)
+	billing := draft.billing
+	billing.InvoiceNumber = invoiceNo
+	if err := tx.Table(ns.TableName("Billing")).Create(&billing).Error; err != nil {
+		return nil, fmt.Errorf("failed to create billing: %w", err)
+	}
+
+	// ✅ Record profit and stock movements against the bill
+	if err := recordBillingProfits(tx, billing.ID, draft.profits); err != nil {
+		return nil, err
+	}
+	if err := recordStockMovements(tx, billing.ID, movements); err != nil {
+		return nil, err
+	}
+
+	// -------------------------------------------------------------
+	// ⭐ INSERT OFFBOARD EXPENSE ROWS
+	// -------------------------------------------------------------
+	for _, exp := range expenses {
+		offExp := models.OffBoardExpense{
+			BillingID: billing.ID,
+			Type:      exp.Type,
+			Amount:    exp.Amount,
+			Notes:     exp.Notes,
+		}
+
+		if err := tx.Table(ns.TableName("OffBoardExpense")).Create(&offExp).Error; err != nil {
+			return nil, fmt.Errorf("failed to record offboard expense: %w", err)
+		}
+	}
+
 	return &billing, nil
 }
 
+// recordBillingProfits stores the per-entry profit rows once the bill ID is known
+func recordBillingProfits(tx *gorm.DB, billingID uint, profits []models.Profit) error {
+	ns := tx.NamingStrategy
+	for i := range profits {
+		profits[i].BillingID = billingID
+		if err := tx.Table(ns.TableName("Profit")).Create(&profits[i]).Error; err != nil {
+			return fmt.Errorf("failed to record profit: %w", err)
+		}
+	}
+	return nil
+}
+
 // ===============================
-// 💳 Create Billing (FIFO Mode)
+// 🔁 Reverse Billing (Credit Note)
 // ===============================
-func (r *BillingRepo) CreateBillingWithOutBatchId(ctx context.Context, billingInput models.BillingInput) (*models.Billing, error) {
+func (r *BillingRepo) ReverseBilling(ctx context.Context, warehouseId, userID, billingID uint, input models.BillingReversalInput) (*models.CreditNote, error) {
 	db := dbconn.DB.WithContext(ctx)
 	ns := db.NamingStrategy
 
-	var billing models.Billing
+	var creditNote models.CreditNote
 	err := db.Transaction(func(tx *gorm.DB) error {
-		var (
-			totalRent, totalStorage, totalBuying, totalSelling, otherExpenses, margin, avgExpense float64
-			billingItems                                                                          []models.BillingItem
-		)
-
-		// 🧮 Average expense calculation
-		if len(billingInput.Expenses) > 0 {
-			var expSum float64
-			for _, exp := range billingInput.Expenses {
-				expSum += exp.Amount
-				otherExpenses += exp.Amount
-			}
-			avgExpense = expSum / float64(len(billingInput.Expenses))
+		// Step 1️⃣: Load the original bill with its items
+		// The bill row stays locked so concurrent reversals cannot both return the same units
+		var billing models.Billing
+		if err := forUpdate(tx).Table(ns.TableName("Billing")).
+			Preload("Items").
+			First(&billing, billingID).Error; err != nil {
+			return fmt.Errorf("billing not found (ID=%d): %w", billingID, err)
+		}
+		if billing.WarehouseID != warehouseId {
+			return fmt.Errorf("%w: billing %d belongs to warehouse %d", ErrWarehouseMismatch, billingID, billing.WarehouseID)
 		}
 
-		for _, item := range billingInput.Items {
-			remainingQty := item.OffboardQty
-			var batchEntries []models.BatchProductEntry
+		// Costs are reversed in the bill's currency at the rate it was issued at
+		conv := billFX{currency: billing.Currency, rate: billing.ExchangeRate}
 
-			// FIFO: fetch oldest first
-			if err := tx.Table(ns.TableName("BatchProductEntry")).
-				Joins("JOIN "+ns.TableName("Batch")+" AS b ON b.id = "+ns.TableName("BatchProductEntry")+".batch_id").
-				Where(ns.TableName("BatchProductEntry")+".product_id = ? AND "+ns.TableName("BatchProductEntry")+".stock_quantity > 0", item.ProductID).
-				Order("b.created_at ASC").
-				Find(&batchEntries).Error; err != nil {
-				return fmt.Errorf("no batches available for product %v: %w", item.ProductID, err)
-			}
+		itemsByID := make(map[uint]models.BillingItem, len(billing.Items))
+		for _, it := range billing.Items {
+			itemsByID[it.ID] = it
+		}
 
-			if len(batchEntries) == 0 {
-				return fmt.Errorf("no available stock for product %v", item.ProductID)
+		// Step 2️⃣: Work out what to reverse (no items → everything still open)
+		type reversal struct {
+			Item models.BillingItem
+			Qty  int
+		}
+		var reversals []reversal
+		if len(input.Items) == 0 {
+			for _, it := range billing.Items {
+				if open := it.OffboardQty - it.ReversedQty; open > 0 {
+					reversals = append(reversals, reversal{Item: it, Qty: open})
+				}
 			}
-
-			for _, entry := range batchEntries {
-				if remainingQty <= 0 {
-					break
+		} else {
+			seen := map[uint]bool{}
+			for _, in := range input.Items {
+				it, ok := itemsByID[in.BillingItemID]
+				if !ok {
+					return fmt.Errorf("%w: billing item %d does not belong to billing %d", ErrInvalidReversal, in.BillingItemID, billingID)
 				}
-
-				qtyToOffboard := entry.StockQuantity
-				if qtyToOffboard > remainingQty {
-					qtyToOffboard = remainingQty
+				if seen[in.BillingItemID] {
+					return fmt.Errorf("%w: billing item %d listed more than once", ErrInvalidReversal, in.BillingItemID)
 				}
+				seen[in.BillingItemID] = true
 
-				var product models.Product
-				if err := tx.Table(ns.TableName("Product")).First(&product, entry.ProductID).Error; err != nil {
-					return fmt.Errorf("product not found (ID=%d): %w", entry.ProductID, err)
+				open := it.OffboardQty - it.ReversedQty
+				if in.Quantity <= 0 || in.Quantity > open {
+					return fmt.Errorf("%w: quantity %d for billing item %d (reversible: %d)", ErrInvalidReversal, in.Quantity, in.BillingItemID, open)
 				}
+				reversals = append(reversals, reversal{Item: it, Qty: in.Quantity})
+			}
+		}
+		if len(reversals) == 0 {
+			return fmt.Errorf("%w: billing %d has nothing left to reverse", ErrInvalidReversal, billingID)
+		}
 
-				var batch models.Batch
-				if err := tx.Table(ns.TableName("Batch")).
-					Preload("Warehouse.RentConfig").
-					First(&batch, entry.BatchID).Error; err != nil {
-					return fmt.Errorf("batch not found (ID=%d): %w", entry.BatchID, err)
-				}
+		// Step 3️⃣: Create the credit note header linked to the original bill
+		creditNote = models.CreditNote{
+			BillingID: billingID,
+			Reason:    input.Reason,
+		}
+		if err := tx.Table(ns.TableName("CreditNote")).Create(&creditNote).Error; err != nil {
+			return fmt.Errorf("failed to create credit note: %w", err)
+		}
 
-				rate := batch.Warehouse.RentConfig.RatePerSqft
-				cycle := strings.ToLower(batch.Warehouse.RentConfig.BillingCycle)
+		for _, rv := range reversals {
+			item := rv.Item
+			qty := rv.Qty
 
-				durationDays := time.Since(batch.CreatedAt).Hours() / 24
-				if durationDays < 1 {
-					durationDays = 1
-				} else if durationDays > 365 {
-					durationDays = 365
-				}
+			// ✅ Put the stock back into the original batch entry
+			var entry models.BatchProductEntry
+			if err := tx.Table(ns.TableName("BatchProductEntry")).
+				Where("batch_id = ? AND product_id = ?", item.BatchID, item.ProductID).
+				First(&entry).Error; err != nil {
+				return fmt.Errorf("original batch entry not found (batch_id=%d, product_id=%d): %w", item.BatchID, item.ProductID, err)
+			}
+			entry, err := changeStock(tx, entry.ID, qty, nil)
+			if err != nil {
+				return fmt.Errorf("failed to restore stock: %w", err)
+			}
 
-				var rentMultiplier float64
-				switch cycle {
-				case "daily":
-					rentMultiplier = durationDays
-				case "weekly":
-					rentMultiplier = durationDays / 7
-				case "monthly":
-					rentMultiplier = durationDays / 30
-				default:
-					rentMultiplier = durationDays / 30
-				}
+			var product models.Product
+			if err := tx.Table(ns.TableName("Product")).First(&product, item.ProductID).Error; err != nil {
+				return fmt.Errorf("product not found (ID=%d): %w", item.ProductID, err)
+			}
 
-				areaUsed := product.StorageArea * float64(qtyToOffboard)
-				storageCost := rate * areaUsed * rentMultiplier
-				totalBuy := float64(qtyToOffboard) * entry.BillingPrice
-				totalSell := float64(qtyToOffboard) * item.SellingPrice
-
-				totalStorage += areaUsed
-				totalBuying += totalBuy
-				totalSelling += totalSell
-				totalRent += storageCost
-
-				entry.StockQuantity -= qtyToOffboard
-				now := time.Now()
-				entry.LastOffboarded = &now
-				tx.Save(&entry)
-
-				// ✅ Update warehouse
-				var warehouse models.Warehouse
-				if err := tx.Table(ns.TableName("Warehouse")).First(&warehouse, batch.WarehouseID).Error; err == nil {
-					warehouse.AvailableArea += areaUsed
-					if warehouse.AvailableArea > warehouse.TotalArea {
-						warehouse.AvailableArea = warehouse.TotalArea
-					}
-					tx.Save(&warehouse)
-				}
+			var batch models.Batch
+			if err := tx.Table(ns.TableName("Batch")).First(&batch, item.BatchID).Error; err != nil {
+				return fmt.Errorf("batch not found (ID=%d): %w", item.BatchID, err)
+			}
 
-				// ✅ Record profit
-				profit := (item.SellingPrice - entry.BillingPrice) * float64(qtyToOffboard)
-				netProfit := profit - storageCost - avgExpense
-
-				if err := tx.Table(ns.TableName("Profit")).Create(&models.Profit{
-					BatchID:   entry.BatchID,
-					ProductID: entry.ProductID,
-					Profit:    profit,
-					NetProfit: netProfit,
-				}).Error; err != nil {
-					return fmt.Errorf("failed to record profit: %w", err)
-				}
+			// ✅ Ledger the returned stock against the credit note
+			movement := newStockMovement(entry, batch.WarehouseID, qty, models.MovementBillingReversal, models.SourceCreditNote, userID)
+			movement.Notes = input.Reason
+			if err := recordStockMovements(tx, creditNote.ID, []models.StockMovement{movement}); err != nil {
+				return err
+			}
+
+			// ✅ Take the freed area back off the warehouse
+			unit, err := warehouseCapacityUnit(tx, batch.WarehouseID)
+			if err != nil {
+				return err
+			}
+			areaUsed, err := spaceUsed(product, qty, unit)
+			if err != nil {
+				return err
+			}
+			if err := reserveWarehouseArea(tx, batch.WarehouseID, areaUsed); err != nil {
+				return fmt.Errorf("cannot restore stock: %w", err)
+			}
+			if _, err := putAway(tx, batch.WarehouseID, entry, product, qty, nil); err != nil {
+				return fmt.Errorf("cannot restore stock: %w", err)
+			}
 
-				billingItems = append(billingItems, models.BillingItem{
-					ProductID:    entry.ProductID,
-					BatchID:      entry.BatchID,
-					OffboardQty:  qtyToOffboard,
-					DurationDays: durationDays,
-					StorageCost:  storageCost,
-					BuyingPrice:  entry.BillingPrice,
-					SellingPrice: item.SellingPrice,
-					TotalSelling: totalSell,
-					BatchStatus:  "offboarded",
-				})
-
-				remainingQty -= qtyToOffboard
-
-				// ✅ Mark batch inactive
-				var remaining int64
-				tx.Table(ns.TableName("BatchProductEntry")).
-					Where("batch_id = ? AND stock_quantity > 0", entry.BatchID).
-					Count(&remaining)
-				if remaining == 0 {
-					batch.Status = "inactive"
-					tx.Save(&batch)
+			// ✅ Reactivate the batch
+			if batch.Status != "active" {
+				if err := tx.Table(ns.TableName("Batch")).
+					Where("id = ?", batch.ID).
+					Update("status", "active").Error; err != nil {
+					return fmt.Errorf("failed to reactivate batch %d: %w", batch.ID, err)
 				}
 			}
 
-			if remainingQty > 0 {
-				return fmt.Errorf("not enough stock for product %v (needed: %d)", item.ProductID, item.OffboardQty)
+			// ✅ The returned share of the line's storage, taxable value and GST
+			storageCost := decimal.Zero
+			if item.OffboardQty > 0 {
+				storageCost = money.Round(item.StorageCost.Mul(money.Qty(qty)).Div(money.Qty(item.OffboardQty)))
 			}
-		}
+			lineTax := tax.Breakdown{
+				Rate:    item.TaxRate,
+				Taxable: item.TotalSelling,
+				CGST:    item.CGSTAmount,
+				SGST:    item.SGSTAmount,
+				IGST:    item.IGSTAmount,
+				Tax:     item.TaxAmount,
+			}.Scale(qty, item.OffboardQty)
+			totalSell := lineTax.Taxable
+
+			// ✅ Negate the profit for the returned quantity (offboard expenses stay incurred)
+			buying := item.BuyingPrice.Mul(money.Qty(qty))
+			profit := totalSell.Sub(buying)
+			intakeCost := money.Round(conv.cost(entry.OnBoardCost, batch).Mul(money.Qty(qty)))
+			netProfit := profit.Sub(storageCost).Sub(intakeCost)
 
-		margin = totalSelling - (totalBuying + totalRent + otherExpenses)
+			if err := tx.Table(ns.TableName("Profit")).Create(&models.Profit{
+				BatchID:      item.BatchID,
+				ProductID:    item.ProductID,
+				BillingID:    billingID,
+				CreditNoteID: &creditNote.ID,
+				Profit:       profit.Neg(),
+				NetProfit:    netProfit.Neg(),
+			}).Error; err != nil {
+				return fmt.Errorf("failed to record profit reversal: %w", err)
+			}
 
-		billing = models.Billing{
-			Items:         billingItems,
-			TotalRent:     totalRent,
-			TotalStorage:  totalStorage,
-			TotalBuying:   totalBuying,
-			TotalSelling:  totalSelling,
-			OtherExpenses: otherExpenses,
-			Margin:        margin,
+			// ✅ Track the reversed quantity on the original line
+			if err := tx.Table(ns.TableName("BillingItem")).
+				Where("id = ?", item.ID).
+				Update("reversed_qty", gorm.Expr("reversed_qty + ?", qty)).Error; err != nil {
+				return fmt.Errorf("failed to update billing item %d: %w", item.ID, err)
+			}
+
+			creditNote.Items = append(creditNote.Items, models.CreditNoteItem{
+				CreditNoteID:  creditNote.ID,
+				BillingItemID: item.ID,
+				ProductID:     item.ProductID,
+				BatchID:       item.BatchID,
+				Quantity:      qty,
+				StorageCost:   storageCost,
+				BuyingPrice:   item.BuyingPrice,
+				SellingPrice:  item.SellingPrice,
+				TotalSelling:  totalSell,
+				CGSTAmount:    lineTax.CGST,
+				SGSTAmount:    lineTax.SGST,
+				IGSTAmount:    lineTax.IGST,
+				TaxAmount:     lineTax.Tax,
+			})
+
+			creditNote.TotalStorage += areaUsed
+			creditNote.TotalRent = creditNote.TotalRent.Add(storageCost)
+			creditNote.TotalBuying = creditNote.TotalBuying.Add(buying)
+			creditNote.TotalSelling = creditNote.TotalSelling.Add(totalSell)
+			creditNote.TotalTax = creditNote.TotalTax.Add(lineTax.Tax)
+		}
+
+		// Step 4️⃣: Persist credit note lines and totals
+		if err := tx.Table(ns.TableName("CreditNoteItem")).Create(&creditNote.Items).Error; err != nil {
+			return fmt.Errorf("failed to record credit note items: %w", err)
+		}
+		if err := tx.Table(ns.TableName("CreditNote")).
+			Where("id = ?", creditNote.ID).
+			Updates(map[string]any{
+				"total_rent":    creditNote.TotalRent,
+				"total_storage": creditNote.TotalStorage,
+				"total_buying":  creditNote.TotalBuying,
+				"total_selling": creditNote.TotalSelling,
+				"total_tax":     creditNote.TotalTax,
+			}).Error; err != nil {
+			return fmt.Errorf("failed to update credit note totals: %w", err)
 		}
 
-		if err := tx.Table(ns.TableName("Billing")).Create(&billing).Error; err != nil {
-			return fmt.Errorf("failed to create billing: %w", err)
+		if err := tx.Table(ns.TableName("Billing")).
+			Where("id = ?", billingID).
+			Update("credited_amount", gorm.Expr("credited_amount + ?", creditNote.TotalSelling.Add(creditNote.TotalTax))).Error; err != nil {
+			return fmt.Errorf("failed to update billing %d: %w", billingID, err)
 		}
 
 		return nil
 	})
 
 	if err != nil {
-		log.Printf("❌ Billing creation (FIFO) failed: %v", err)
+		log.Printf("❌ Billing reversal failed (BillingID=%d): %v", billingID, err)
 		return nil, err
 	}
 
-	log.Printf("✅ Billing created successfully (FIFO mode, ID=%d)", billing.ID)
-	return &billing, nil
+	log.Printf("🔁 Credit note %d created for billing %d (%d items)", creditNote.ID, billingID, len(creditNote.Items))
+	return &creditNote, nil
+}
+
+// GetCreditNotesByBillID lists the credit notes issued against a bill
+func (r *BillingRepo) GetCreditNotesByBillID(ctx context.Context, warehouseId, billingID uint) ([]models.CreditNote, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	var notes []models.CreditNote
+	if err := db.Table(ns.TableName("CreditNote")+" AS cn").
+		Preload("Items").
+		Joins("JOIN "+ns.TableName("Billing")+" AS bl ON bl.id = cn.billing_id").
+		Where("cn.billing_id = ? AND bl.warehouse_id = ?", billingID, warehouseId).
+		Select("cn.*").
+		Order("cn.created_at ASC").
+		Find(&notes).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch credit notes for billing %d: %w", billingID, err)
+	}
+	return notes, nil
 }
 
 // ===============================
@@ -401,21 +769,38 @@ func (r *BillingRepo) GetByID(ctx context.Context, id uint) (*models.Billing, er
 
 	return &billing, nil
 }
-func (r *BillingRepo) GetBillingCoreDataWithProductsByBillID(ctx context.Context, id uint) (models.BillingCoreDataWithProducts, error) {
+func (r *BillingRepo) GetBillingCoreDataWithProductsByBillID(ctx context.Context, warehouseId, id uint) (models.BillingCoreDataWithProducts, error) {
 	db := dbconn.DB.WithContext(ctx)
 	ns := db.NamingStrategy
 
 	// Step 1️⃣: Temporary struct for scalar fields only (no slice)
 	type billingRow struct {
-		ID            uint
-		TotalRent     float64
-		TotalStorage  float64
-		TotalBuying   float64
-		TotalSelling  float64
-		OtherExpenses float64
-		Margin        float64
-		CreatedAt     time.Time
-		UpdatedAt     time.Time
+		ID             uint
+		WarehouseID    uint
+		InvoiceNumber  string
+		CustomerID     uint
+		CustomerName   string
+		Currency       string
+		ExchangeRate   decimal.Decimal
+		TotalRent      decimal.Decimal
+		TotalStorage   float64
+		TotalBuying    decimal.Decimal
+		TotalSelling   decimal.Decimal
+		OtherExpenses  decimal.Decimal
+		Margin         decimal.Decimal
+		TaxInclusive   bool
+		SupplyType     string
+		Rounding       string
+		CGSTAmount     decimal.Decimal
+		SGSTAmount     decimal.Decimal
+		IGSTAmount     decimal.Decimal
+		TotalTax       decimal.Decimal
+		InvoiceTotal   decimal.Decimal
+		CreditedAmount decimal.Decimal
+		PaidAmount     decimal.Decimal
+		DueDate        *time.Time
+		CreatedAt      time.Time
+		UpdatedAt      time.Time
 	}
 
 	var row billingRow
@@ -424,23 +809,41 @@ func (r *BillingRepo) GetBillingCoreDataWithProductsByBillID(ctx context.Context
 	err := db.Table(ns.TableName("Billing")+" AS b").
 		Select(`
 			b.id,
+			b.warehouse_id,
+			COALESCE(b.invoice_number, '') AS invoice_number,
+			COALESCE(b.customer_id, 0) AS customer_id,
+			COALESCE(cu.name, '') AS customer_name,
+			COALESCE(b.currency, '') AS currency,
+			COALESCE(b.exchange_rate, 1) AS exchange_rate,
 			COALESCE(b.total_rent, 0) AS total_rent,
 			COALESCE(b.total_storage, 0) AS total_storage,
 			COALESCE(b.total_buying, 0) AS total_buying,
 			COALESCE(b.total_selling, 0) AS total_selling,
 			COALESCE(b.other_expenses, 0) AS other_expenses,
 			COALESCE(b.margin, 0) AS margin,
+			b.tax_inclusive,
+			COALESCE(b.supply_type, '') AS supply_type,
+			COALESCE(b.rounding, '') AS rounding,
+			COALESCE(b.cgst_amount, 0) AS cgst_amount,
+			COALESCE(b.sgst_amount, 0) AS sgst_amount,
+			COALESCE(b.igst_amount, 0) AS igst_amount,
+			COALESCE(b.total_tax, 0) AS total_tax,
+			COALESCE(b.invoice_total, 0) AS invoice_total,
+			COALESCE(b.credited_amount, 0) AS credited_amount,
+			COALESCE(b.paid_amount, 0) AS paid_amount,
+			b.due_date,
 			b.created_at,
 			b.updated_at
 		`).
-		Where("b.id = ?", id).
+		Joins("LEFT JOIN "+ns.TableName("Customer")+" AS cu ON cu.id = b.customer_id").
+		Where("b.id = ? AND b.warehouse_id = ?", id, warehouseId).
 		Scan(&row).Error
 
 	if err != nil {
 		return models.BillingCoreDataWithProducts{}, fmt.Errorf("failed to fetch billing data: %w", err)
 	}
 	if row.ID == 0 {
-		return models.BillingCoreDataWithProducts{}, fmt.Errorf("billing not found with id %d", id)
+		return models.BillingCoreDataWithProducts{}, fmt.Errorf("billing not found with id %d: %w", id, gorm.ErrRecordNotFound)
 	}
 
 	// ✅ Step 3: Struct for joined billing items + product info
@@ -455,12 +858,21 @@ func (r *BillingRepo) GetBillingCoreDataWithProductsByBillID(ctx context.Context
 		ProductUpdatedAt time.Time
 		BatchID          uint
 		OffboardQty      int
+		ReversedQty      int
 		DurationDays     float64
-		StorageCost      float64
-		BuyingPrice      float64
-		SellingPrice     float64
-		TotalSelling     float64
+		StorageCost      decimal.Decimal
+		BuyingPrice      decimal.Decimal
+		SellingPrice     decimal.Decimal
+		TotalSelling     decimal.Decimal
+		HSNCode          string
+		TaxRate          float64
+		CGSTAmount       decimal.Decimal
+		SGSTAmount       decimal.Decimal
+		IGSTAmount       decimal.Decimal
+		TaxAmount        decimal.Decimal
 		BatchStatus      string
+		Allocation       string
+		AllocationReason string
 		CreatedAt        time.Time
 		UpdatedAt        time.Time
 	}
@@ -480,12 +892,21 @@ func (r *BillingRepo) GetBillingCoreDataWithProductsByBillID(ctx context.Context
 			p.updated_at AS product_updated_at,
 			bi.batch_id,
 			bi.offboard_qty,
+			bi.reversed_qty,
 			bi.duration_days,
 			bi.storage_cost,
 			bi.buying_price,
 			bi.selling_price,
 			bi.total_selling,
+			COALESCE(bi.hsn_code, '') AS hsn_code,
+			COALESCE(bi.tax_rate, 0) AS tax_rate,
+			COALESCE(bi.cgst_amount, 0) AS cgst_amount,
+			COALESCE(bi.sgst_amount, 0) AS sgst_amount,
+			COALESCE(bi.igst_amount, 0) AS igst_amount,
+			COALESCE(bi.tax_amount, 0) AS tax_amount,
 			bi.batch_status,
+			COALESCE(bi.allocation, '') AS allocation,
+			COALESCE(bi.allocation_reason, '') AS allocation_reason,
 			bi.created_at,
 			bi.updated_at
 		`).
@@ -512,31 +933,59 @@ func (r *BillingRepo) GetBillingCoreDataWithProductsByBillID(ctx context.Context
 				CreatedAt:   ir.ProductCreatedAt,
 				UpdatedAt:   ir.ProductUpdatedAt,
 			},
-			BatchID:      ir.BatchID,
-			OffboardQty:  ir.OffboardQty,
-			DurationDays: ir.DurationDays,
-			StorageCost:  ir.StorageCost,
-			BuyingPrice:  ir.BuyingPrice,
-			SellingPrice: ir.SellingPrice,
-			TotalSelling: ir.TotalSelling,
-			BatchStatus:  ir.BatchStatus,
-			CreatedAt:    ir.CreatedAt,
-			UpdatedAt:    ir.UpdatedAt,
+			BatchID:          ir.BatchID,
+			OffboardQty:      ir.OffboardQty,
+			ReversedQty:      ir.ReversedQty,
+			DurationDays:     ir.DurationDays,
+			StorageCost:      ir.StorageCost,
+			BuyingPrice:      ir.BuyingPrice,
+			SellingPrice:     ir.SellingPrice,
+			TotalSelling:     ir.TotalSelling,
+			HSNCode:          ir.HSNCode,
+			TaxRate:          ir.TaxRate,
+			CGSTAmount:       ir.CGSTAmount,
+			SGSTAmount:       ir.SGSTAmount,
+			IGSTAmount:       ir.IGSTAmount,
+			TaxAmount:        ir.TaxAmount,
+			BatchStatus:      ir.BatchStatus,
+			Allocation:       ir.Allocation,
+			AllocationReason: ir.AllocationReason,
+			CreatedAt:        ir.CreatedAt,
+			UpdatedAt:        ir.UpdatedAt,
 		})
 	}
 
 	// ✅ Step 6: Construct the final output struct
 	result := models.BillingCoreDataWithProducts{
-		ID:            row.ID,
-		TotalRent:     row.TotalRent,
-		TotalStorage:  row.TotalStorage,
-		TotalBuying:   row.TotalBuying,
-		TotalSelling:  row.TotalSelling,
-		OtherExpenses: row.OtherExpenses,
-		Margin:        row.Margin,
-		CreatedAt:     row.CreatedAt,
-		UpdatedAt:     row.UpdatedAt,
-		Products:      items,
+		ID:             row.ID,
+		WarehouseID:    row.WarehouseID,
+		InvoiceNumber:  row.InvoiceNumber,
+		CustomerID:     row.CustomerID,
+		CustomerName:   row.CustomerName,
+		Currency:       row.Currency,
+		ExchangeRate:   row.ExchangeRate,
+		TotalRent:      row.TotalRent,
+		TotalStorage:   row.TotalStorage,
+		TotalBuying:    row.TotalBuying,
+		TotalSelling:   row.TotalSelling,
+		OtherExpenses:  row.OtherExpenses,
+		Margin:         row.Margin,
+		TaxInclusive:   row.TaxInclusive,
+		SupplyType:     row.SupplyType,
+		Rounding:       row.Rounding,
+		CGSTAmount:     row.CGSTAmount,
+		SGSTAmount:     row.SGSTAmount,
+		IGSTAmount:     row.IGSTAmount,
+		TotalTax:       row.TotalTax,
+		InvoiceTotal:   row.InvoiceTotal,
+		CreditedAmount: row.CreditedAmount,
+		PaidAmount:     row.PaidAmount,
+		Outstanding:    billOutstanding(row.InvoiceTotal, row.CreditedAmount, row.PaidAmount),
+		DueDate:        row.DueDate,
+		PaymentStatus:  paymentStatus(row.InvoiceTotal, row.CreditedAmount, row.PaidAmount, row.DueDate, time.Now()),
+		CreatedAt:      row.CreatedAt,
+		UpdatedAt:      row.UpdatedAt,
+		Products:       items,
 	}
 
 	log.Printf("🧾 Billing %d fetched successfully with %d products", id, len(items))
@@ -553,11 +1002,8 @@ func (r *BillingRepo) GetAll(ctx context.Context, warehouseId uint) ([]models.Bi
 	var billings []models.Billing
 
 	err := db.Table(ns.TableName("Billing")+" AS bl").
-		Joins("LEFT JOIN "+ns.TableName("BillingItem")+" AS bi ON bi.billing_id = bl.id").
-		Joins("LEFT JOIN "+ns.TableName("Batch")+" AS b ON b.id = bi.batch_id").
-		Where("b.warehouse_id = ?", warehouseId). // ✅ Apply warehouse filter
+		Where("bl.warehouse_id = ?", warehouseId). // ✅ Apply warehouse filter
 		Preload("Items").
-		Preload("Items.Batch").
 		Find(&billings).Error
 
 	if err != nil {
@@ -568,24 +1014,72 @@ func (r *BillingRepo) GetAll(ctx context.Context, warehouseId uint) ([]models.Bi
 	return billings, nil
 }
 
-func (r *BillingRepo) GetAllBillingCoreData(ctx context.Context, warehouseId uint) ([]models.BillingCoreData, error) {
+// GetAllBillingCoreData lists the warehouse's bills, newest first. A non-zero customerID,
+// non-zero dates and a payment status narrow the list to that customer, to bills created
+// in [from, to) and to bills currently in that status.
+func (r *BillingRepo) GetAllBillingCoreData(ctx context.Context, warehouseId, customerID uint, from, to time.Time, status, number string) ([]models.BillingCoreData, error) {
 	db := dbconn.DB.WithContext(ctx)
+
+	results, err := billingCoreData(db, warehouseId, customerID, from, to, number)
+	if err != nil {
+		return nil, err
+	}
+	if status != "" {
+		filtered := results[:0]
+		for _, b := range results {
+			if b.PaymentStatus == status {
+				filtered = append(filtered, b)
+			}
+		}
+		results = filtered
+	}
+
+	log.Printf("🧾 Retrieved %d billing core records for WarehouseID=%d", len(results), warehouseId)
+	return results, nil
+}
+
+// billingCoreData lists a warehouse's bills; number matches any part of the invoice number
+func billingCoreData(db *gorm.DB, warehouseId, customerID uint, from, to time.Time, number string) ([]models.BillingCoreData, error) {
 	ns := db.NamingStrategy
 
 	var results []models.BillingCoreData
 
-	err := db.Table(ns.TableName("Billing")+" AS bl").
-		Joins("LEFT JOIN "+ns.TableName("BillingItem")+" AS bi ON bi.billing_id = bl.id").
-		Joins("LEFT JOIN "+ns.TableName("Batch")+" AS b ON b.id = bi.batch_id").
-		Where("b.warehouse_id = ?", warehouseId). // ✅ Warehouse filter
+	query := db.Table(ns.TableName("Billing")+" AS bl").
+		Joins("LEFT JOIN "+ns.TableName("Customer")+" AS cu ON cu.id = bl.customer_id").
+		Where("bl.warehouse_id = ? AND bl.deleted_at IS NULL", warehouseId) // ✅ Warehouse filter
+	if customerID != 0 {
+		query = query.Where("bl.customer_id = ?", customerID)
+	}
+	if !from.IsZero() {
+		query = query.Where("bl.created_at >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("bl.created_at < ?", to)
+	}
+	if number != "" {
+		query = query.Where("bl.invoice_number ILIKE ?", "%"+number+"%")
+	}
+
+	err := query.
 		Select(`
-			DISTINCT bl.id,
+			bl.id,
+			bl.warehouse_id,
+			COALESCE(bl.invoice_number, '') AS invoice_number,
+			COALESCE(bl.customer_id, 0) AS customer_id,
+			COALESCE(cu.name, '') AS customer_name,
+			COALESCE(bl.currency, '') AS currency,
+			COALESCE(bl.exchange_rate, 1) AS exchange_rate,
 			COALESCE(bl.total_rent, 0) AS total_rent,
 			COALESCE(bl.total_storage, 0) AS total_storage,
 			COALESCE(bl.total_buying, 0) AS total_buying,
 			COALESCE(bl.total_selling, 0) AS total_selling,
 			COALESCE(bl.other_expenses, 0) AS other_expenses,
 			COALESCE(bl.margin, 0) AS margin,
+			COALESCE(bl.total_tax, 0) AS total_tax,
+			COALESCE(bl.invoice_total, 0) AS invoice_total,
+			COALESCE(bl.credited_amount, 0) AS credited_amount,
+			COALESCE(bl.paid_amount, 0) AS paid_amount,
+			bl.due_date,
 			bl.created_at,
 			bl.updated_at
 		`).
@@ -596,7 +1090,12 @@ func (r *BillingRepo) GetAllBillingCoreData(ctx context.Context, warehouseId uin
 		return nil, fmt.Errorf("failed to fetch billing core data for warehouse %d: %w", warehouseId, err)
 	}
 
-	log.Printf("🧾 Retrieved %d billing core records for WarehouseID=%d", len(results), warehouseId)
+	now := time.Now()
+	for i := range results {
+		b := &results[i]
+		b.Outstanding = billOutstanding(b.InvoiceTotal, b.CreditedAmount, b.PaidAmount)
+		b.PaymentStatus = paymentStatus(b.InvoiceTotal, b.CreditedAmount, b.PaidAmount, b.DueDate, now)
+	}
 	return results, nil
 }
 
@@ -610,15 +1109,18 @@ func (r *BillingRepo) GetAllProductsForBilling(ctx context.Context, warehouseId
 		ProductName     string
 		Category        string
 		StorageArea     float64
+		SpacePerUnit    float64
 		SupplierID      uint
 		SupplierName    string
 		SupplierDesc    string
 		SupplierCreated time.Time
 		SupplierUpdated time.Time
-		RentPerSqft     float64
+		RentPerSqft     decimal.Decimal
 		StockQuantity   int
 		BatchCreated    time.Time
-		BuyingPrice     float64
+		RentBilledTo    *time.Time
+		RentMinimumMet  bool
+		BuyingPrice     decimal.Decimal
 		WarehouseID     uint
 	}
 
@@ -632,6 +1134,7 @@ func (r *BillingRepo) GetAllProductsForBilling(ctx context.Context, warehouseId
 			p.name AS product_name,
 			p.category,
 			p.storage_area,
+			`+spacePerUnit("p", "w")+` AS space_per_unit,
 			s.id AS supplier_id,
 			s.name AS supplier_name,
 			s.description AS supplier_desc,
@@ -640,7 +1143,9 @@ func (r *BillingRepo) GetAllProductsForBilling(ctx context.Context, warehouseId
 			rr.rate_per_sqft AS rent_per_sqft,
 			be.stock_quantity,
 			be.billing_price AS buying_price,
-			b.created_at AS batch_created,
+			b.stored_at AS batch_created,
+			be.rent_billed_to,
+			be.rent_minimum_met,
 			b.warehouse_id
 		`).
 		Joins("JOIN "+ns.TableName("Batch")+" AS b ON be.batch_id = b.id").
@@ -653,7 +1158,7 @@ func (r *BillingRepo) GetAllProductsForBilling(ctx context.Context, warehouseId
 			AND b.status = 'active'
 			AND b.warehouse_id = ?
 		`, warehouseId). // ✅ Filtering by warehouse
-		Order("b.created_at ASC").
+		Order("b.stored_at ASC").
 		Scan(&rows).Error
 
 	if err != nil {
@@ -667,16 +1172,18 @@ func (r *BillingRepo) GetAllProductsForBilling(ctx context.Context, warehouseId
 
 	now := time.Now()
 	results := make([]models.ProductStockData, 0, len(rows))
+	rentRates := newRentRateCache(db)
 
 	for _, row := range rows {
-		// Days in warehouse
-		duration := int(now.Sub(row.BatchCreated).Hours() / 24)
-		if duration < 1 {
-			duration = 1
+		// Rent per unit not yet invoiced, priced by the rent engine
+		rates, err := rentRates.forWarehouse(row.WarehouseID)
+		if err != nil {
+			return nil, err
 		}
+		charge := rent.Unbilled(rates, row.SpacePerUnit, row.BatchCreated, row.RentBilledTo, now, row.RentMinimumMet)
 
-		// Rent per unit
-		rentPerProduct := row.StorageArea * row.RentPerSqft
+		// Rent per unit per billing cycle at the current rate
+		rentPerProduct := money.Round(row.RentPerSqft.Mul(decimal.NewFromFloat(row.SpacePerUnit)))
 
 		productData := models.ProductData{
 			ID:         row.ProductID,
@@ -697,8 +1204,9 @@ func (r *BillingRepo) GetAllProductsForBilling(ctx context.Context, warehouseId
 
 		expenseData := models.ExpenseData{
 			RentPerProduct: rentPerProduct,
+			AccruedRent:    money.Round(charge.Amount),
 			StockQuatity:   row.StockQuantity,
-			DurationInDays: duration,
+			DurationInDays: charge.Days,
 		}
 
 		results = append(results, models.ProductStockData{
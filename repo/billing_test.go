@@ -0,0 +1,281 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+	"warehouse/capacity"
+	"warehouse/fx"
+	"warehouse/models"
+	"warehouse/money"
+	"warehouse/rent"
+	"warehouse/tax"
+
+	"github.com/shopspring/decimal"
+)
+
+// pricingLines is a bill with prices, quantities, areas and tax rates picked to leave
+// fractions of a paisa everywhere
+func pricingLines(now time.Time) []billLine {
+	products := []models.Product{
+		{ID: 1, Category: "grain", StorageArea: 0.37},
+		{ID: 2, Category: "oil", StorageArea: 1.15},
+		{ID: 3, Category: "spice", StorageArea: 0.05},
+		{ID: 4, Category: "exempt", StorageArea: 2.5},
+	}
+	specs := []struct {
+		product       int
+		qty           int
+		buying        string
+		selling       string
+		onboardCost   string
+		storedDaysAgo int
+	}{
+		{0, 3, "21.17", "33.33", "0.1234", 10},
+		{1, 7, "99.99", "119.99", "1.0001", 45},
+		{2, 1, "0.07", "0.10", "0", 3},
+		{2, 1, "0.07", "0.10", "0", 3},
+		{2, 1, "0.07", "0.10", "0", 3},
+		{3, 13, "4.44", "5.55", "0.3333", 100},
+		{0, 11, "18.18", "27.27", "0.0909", 1},
+	}
+
+	lines := make([]billLine, 0, len(specs))
+	for i, s := range specs {
+		product := products[s.product]
+		lines = append(lines, billLine{
+			allocatedStock: allocatedStock{
+				Entry: models.BatchProductEntry{
+					ID:           uint(i + 1),
+					BatchID:      uint(i + 1),
+					ProductID:    product.ID,
+					BillingPrice: decimal.RequireFromString(s.buying),
+					OnBoardCost:  decimal.RequireFromString(s.onboardCost),
+				},
+				Batch: models.Batch{
+					ID: uint(i + 1), WarehouseID: 1, StoredAt: now.AddDate(0, 0, -s.storedDaysAgo),
+					Currency: "INR", ExchangeRate: decimal.NewFromInt(1),
+				},
+				Quantity: s.qty,
+			},
+			Product:      product,
+			SellingPrice: decimal.RequireFromString(s.selling),
+			item:         i,
+		})
+	}
+	return lines
+}
+
+func pricingRates(now time.Time) *rentRateCache {
+	return &rentRateCache{rates: map[uint][]rent.Rate{1: {
+		{RatePerUnit: decimal.RequireFromString("1.37"), Currency: "INR", Cycle: rent.Weekly, EffectiveFrom: now.AddDate(-1, 0, 0)},
+		{RatePerUnit: decimal.RequireFromString("0.2333"), Currency: "INR", Cycle: rent.Monthly, EffectiveFrom: now.AddDate(0, 0, -20)},
+	}}, units: map[uint]string{1: capacity.Sqft}}
+}
+
+func pricingTax(inclusive bool, supplyType string) *billTax {
+	return &billTax{
+		rates:      map[string]float64{"grain": 5, "oil": 18, "spice": 12, "exempt": 0},
+		inclusive:  inclusive,
+		supplyType: supplyType,
+	}
+}
+
+// pricingFX issues the bill in the reporting currency
+func pricingFX() *billFX {
+	return &billFX{currency: "INR", rate: decimal.NewFromInt(1), rates: fx.NewTable(nil, "INR")}
+}
+
+func isPaise(d decimal.Decimal) bool {
+	return d.Equal(money.Round(d))
+}
+
+// TestBillTotalsEqualSumOfItems prices the same bill under every pricing mode and
+// rounding rule and checks that each bill total is exactly the sum of its items
+func TestBillTotalsEqualSumOfItems(t *testing.T) {
+	now := time.Date(2026, 3, 14, 15, 0, 0, 0, time.UTC)
+	expenses := []models.Expense{
+		{Type: "freight", Amount: decimal.RequireFromString("100")},
+		{Type: "loading", Amount: decimal.RequireFromString("33.335")},
+		{Type: "handling", Amount: decimal.RequireFromString("0.01")},
+	}
+
+	for _, rounding := range []string{money.PerLine, money.PerDocument} {
+		for _, inclusive := range []bool{false, true} {
+			for _, supplyType := range []string{tax.IntraState, tax.InterState} {
+				name := fmt.Sprintf("%s/inclusive=%v/%s", rounding, inclusive, supplyType)
+				t.Run(name, func(t *testing.T) {
+					lines := pricingLines(now)
+					draft, err := priceBill(pricingRates(now), pricingTax(inclusive, supplyType), pricingFX(), lines, expenses, rounding, now)
+					if err != nil {
+						t.Fatal(err)
+					}
+					bill := draft.billing
+					if bill.Rounding != rounding {
+						t.Errorf("bill rounding = %q, want %q", bill.Rounding, rounding)
+					}
+					if len(bill.Items) != len(lines) {
+						t.Fatalf("got %d items for %d lines", len(bill.Items), len(lines))
+					}
+
+					var rent, buying, selling, cgst, sgst, igst, taxes, invoice, profit, gross decimal.Decimal
+					for i, item := range bill.Items {
+						for field, v := range map[string]decimal.Decimal{
+							"storage_cost": item.StorageCost, "total_selling": item.TotalSelling, "cgst": item.CGSTAmount,
+							"sgst": item.SGSTAmount, "igst": item.IGSTAmount, "tax": item.TaxAmount,
+						} {
+							if !isPaise(v) {
+								t.Errorf("item %d %s = %s is not in paise", i, field, v)
+							}
+						}
+						if !item.CGSTAmount.Add(item.SGSTAmount).Add(item.IGSTAmount).Equal(item.TaxAmount) {
+							t.Errorf("item %d: CGST %s + SGST %s + IGST %s != tax %s", i, item.CGSTAmount, item.SGSTAmount, item.IGSTAmount, item.TaxAmount)
+						}
+						lineGross := item.SellingPrice.Mul(money.Qty(item.OffboardQty))
+						if inclusive && rounding == money.PerLine && !item.TotalSelling.Add(item.TaxAmount).Equal(lineGross) {
+							t.Errorf("item %d: taxable %s + tax %s != price x qty %s", i, item.TotalSelling, item.TaxAmount, lineGross)
+						}
+
+						rent = rent.Add(item.StorageCost)
+						buying = buying.Add(money.Round(item.BuyingPrice.Mul(money.Qty(item.OffboardQty))))
+						selling = selling.Add(item.TotalSelling)
+						cgst = cgst.Add(item.CGSTAmount)
+						sgst = sgst.Add(item.SGSTAmount)
+						igst = igst.Add(item.IGSTAmount)
+						taxes = taxes.Add(item.TaxAmount)
+						invoice = invoice.Add(item.TotalSelling).Add(item.TaxAmount)
+						profit = profit.Add(draft.profits[i].Profit)
+						gross = gross.Add(lineGross)
+					}
+
+					for field, pair := range map[string][2]decimal.Decimal{
+						"total_rent":    {bill.TotalRent, rent},
+						"total_buying":  {bill.TotalBuying, buying},
+						"total_selling": {bill.TotalSelling, selling},
+						"cgst_amount":   {bill.CGSTAmount, cgst},
+						"sgst_amount":   {bill.SGSTAmount, sgst},
+						"igst_amount":   {bill.IGSTAmount, igst},
+						"total_tax":     {bill.TotalTax, taxes},
+						"invoice_total": {bill.InvoiceTotal, invoice},
+					} {
+						if !pair[0].Equal(pair[1]) {
+							t.Errorf("%s = %s, items add up to %s", field, pair[0], pair[1])
+						}
+					}
+					if want := bill.TotalSelling.Sub(bill.TotalBuying); !profit.Equal(want) {
+						t.Errorf("profits add up to %s, want taxable %s - buying %s", profit, bill.TotalSelling, bill.TotalBuying)
+					}
+					if want := bill.TotalSelling.Sub(bill.TotalBuying).Sub(bill.TotalRent).Sub(bill.OtherExpenses); !bill.Margin.Equal(want) {
+						t.Errorf("margin = %s, want %s", bill.Margin, want)
+					}
+					if inclusive && !bill.InvoiceTotal.Equal(money.Round(gross)) {
+						t.Errorf("tax-inclusive invoice total = %s, want price x qty %s", bill.InvoiceTotal, money.Round(gross))
+					}
+				})
+			}
+		}
+	}
+}
+
+// TestRoundingRules checks where each rule rounds: three 0.10 lines at 5% carry 0.005 of
+// tax each, which is 0.01 per line but 0.015 = 0.02 on the document
+func TestRoundingRules(t *testing.T) {
+	now := time.Date(2026, 3, 14, 15, 0, 0, 0, time.UTC)
+	lines := pricingLines(now)[2:5]
+	for i := range lines {
+		lines[i].Product.Category = "grain"
+	}
+
+	for rounding, want := range map[string]string{money.PerLine: "0.03", money.PerDocument: "0.02"} {
+		draft, err := priceBill(pricingRates(now), pricingTax(false, tax.InterState), pricingFX(), lines, nil, rounding, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !draft.billing.TotalTax.Equal(decimal.RequireFromString(want)) {
+			t.Errorf("%s rounding: total tax = %s, want %s", rounding, draft.billing.TotalTax, want)
+		}
+	}
+}
+
+func TestCategoryWithoutTaxRateFailsTheBill(t *testing.T) {
+	now := time.Date(2026, 3, 14, 15, 0, 0, 0, time.UTC)
+	lines := pricingLines(now)
+	lines[1].Product.Category = "unlisted"
+
+	_, err := priceBill(pricingRates(now), pricingTax(false, tax.IntraState), pricingFX(), lines, nil, money.PerLine, now)
+	if !errors.Is(err, ErrMissingTaxRate) {
+		t.Errorf("err = %v, want ErrMissingTaxRate", err)
+	}
+
+	// An explicit 0% rate is an exemption and bills
+	lines[1].Product.Category = "Exempt"
+	if _, err := priceBill(pricingRates(now), pricingTax(false, tax.IntraState), pricingFX(), lines, nil, money.PerLine, now); err != nil {
+		t.Errorf("exempt category: %v", err)
+	}
+}
+
+func TestMinimumRentIsChargedOncePerStay(t *testing.T) {
+	now := time.Date(2026, 3, 14, 15, 0, 0, 0, time.UTC)
+	rates := &rentRateCache{rates: map[uint][]rent.Rate{1: {
+		{RatePerUnit: decimal.NewFromInt(1), Currency: "INR", Cycle: rent.Daily, MinimumCharge: decimal.NewFromInt(1000), EffectiveFrom: now.AddDate(-1, 0, 0)},
+	}}, units: map[uint]string{1: capacity.Sqft}}
+
+	lines := pricingLines(now)[:5]
+	lines[1].item = lines[0].item        // one item split across two entries
+	lines[2].Entry.RentMinimumMet = true // an earlier bill settled this stay
+	lines[4].Entry = lines[3].Entry      // another item taking more of the same entry
+	lines[4].Batch = lines[3].Batch
+
+	draft, err := priceBill(rates, pricingTax(false, tax.IntraState), pricingFX(), lines, nil, money.PerLine, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	minimum := decimal.NewFromInt(1000)
+	for i, raised := range []bool{true, false, false, true, false} {
+		if got := draft.billing.Items[i].StorageCost.Equal(minimum); got != raised {
+			t.Errorf("line %d: storage cost %s, raised to the minimum %v, want %v", i, draft.billing.Items[i].StorageCost, got, raised)
+		}
+	}
+}
+
+// TestForeignCurrencyBill issues a bill in USD against stock booked in INR: costs move
+// over at the bill's rate and rent is converted on the bill date
+func TestForeignCurrencyBill(t *testing.T) {
+	now := time.Date(2026, 3, 14, 15, 0, 0, 0, time.UTC)
+	usdRate := decimal.RequireFromString("83")
+	conv := &billFX{
+		currency: "USD",
+		rate:     usdRate,
+		rates:    fx.NewTable([]fx.Rate{{From: "USD", To: "INR", Rate: usdRate, EffectiveFrom: now.AddDate(0, -1, 0)}}, "INR"),
+	}
+	lines := pricingLines(now)
+
+	inr, err := priceBill(pricingRates(now), pricingTax(false, tax.IntraState), pricingFX(), lines, nil, money.PerLine, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	usd, err := priceBill(pricingRates(now), pricingTax(false, tax.IntraState), conv, lines, nil, money.PerLine, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if usd.billing.Currency != "USD" || !usd.billing.ExchangeRate.Equal(usdRate) {
+		t.Errorf("bill is in %s at %s, want USD at %s", usd.billing.Currency, usd.billing.ExchangeRate, usdRate)
+	}
+	for i, item := range usd.billing.Items {
+		want := lines[i].Entry.BillingPrice.Div(usdRate).Round(money.UnitPlaces)
+		if !item.BuyingPrice.Equal(want) {
+			t.Errorf("item %d buying price = %s USD, want %s", i, item.BuyingPrice, want)
+		}
+		if !isPaise(item.StorageCost) {
+			t.Errorf("item %d storage cost = %s is not in cents", i, item.StorageCost)
+		}
+	}
+	// Rent converted back at the same rate lands within rounding of the INR rent
+	back := usd.billing.TotalRent.Mul(usdRate)
+	if diff := back.Sub(inr.billing.TotalRent).Abs(); diff.GreaterThan(usdRate.Mul(decimal.RequireFromString("0.005")).Mul(money.Qty(len(lines)))) {
+		t.Errorf("USD rent %s x %s = %s, INR rent %s", usd.billing.TotalRent, usdRate, back, inr.billing.TotalRent)
+	}
+}
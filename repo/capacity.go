@@ -0,0 +1,69 @@
+package repo
+
+import (
+	"fmt"
+	"strconv"
+	"warehouse/capacity"
+	"warehouse/models"
+
+	"gorm.io/gorm"
+)
+
+// productFootprint is the space one unit of a product declares
+func productFootprint(product models.Product) capacity.Footprint {
+	return capacity.Footprint{
+		Area:           product.StorageArea,
+		AreaUnit:       product.AreaUnit,
+		Volume:         product.StorageVolume,
+		UnitsPerPallet: product.UnitsPerPallet,
+	}
+}
+
+// spaceUsed is the capacity qty units of a product take in a warehouse counting unit
+func spaceUsed(product models.Product, qty int, unit string) (float64, error) {
+	space, err := productFootprint(product).For(qty, unit)
+	if err != nil {
+		return 0, fmt.Errorf("product %d: %w", product.ID, err)
+	}
+	return space, nil
+}
+
+// warehouseCapacityUnit is the unit a warehouse counts its space and charges rent in
+func warehouseCapacityUnit(db *gorm.DB, warehouseID uint) (string, error) {
+	ns := db.NamingStrategy
+
+	var warehouse models.Warehouse
+	if err := db.Table(ns.TableName("Warehouse")).Select("id", "capacity_unit").First(&warehouse, warehouseID).Error; err != nil {
+		return "", fmt.Errorf("warehouse not found (ID=%d): %w", warehouseID, err)
+	}
+	return capacity.Normalize(warehouse.CapacityUnit)
+}
+
+// checkProductFootprint normalises a product's area unit and checks its dimensions
+func checkProductFootprint(product *models.Product) error {
+	unit, err := capacity.Normalize(product.AreaUnit)
+	if err != nil {
+		return err
+	}
+	if !capacity.IsArea(unit) {
+		return fmt.Errorf("%w: area unit must be sqft or sqm, got %q", capacity.ErrUnknownUnit, product.AreaUnit)
+	}
+	product.AreaUnit = unit
+	if product.StorageArea < 0 || product.StorageVolume < 0 || product.UnitsPerPallet < 0 {
+		return fmt.Errorf("product footprint cannot be negative")
+	}
+	return nil
+}
+
+// spacePerUnit is a SQL expression for the capacity one unit of product p takes in the
+// capacity unit of warehouse w; products without a footprint in that unit take none
+func spacePerUnit(p, w string) string {
+	sqmPerSqft := strconv.FormatFloat(1/capacity.SqftPerSqm, 'g', -1, 64)
+	sqftPerSqm := strconv.FormatFloat(capacity.SqftPerSqm, 'g', -1, 64)
+	return fmt.Sprintf(`(CASE COALESCE(NULLIF(%[2]s.capacity_unit, ''), 'sqft')
+		WHEN 'sqft' THEN %[1]s.storage_area * (CASE WHEN %[1]s.area_unit = 'sqm' THEN %[3]s ELSE 1 END)
+		WHEN 'sqm' THEN %[1]s.storage_area * (CASE WHEN %[1]s.area_unit = 'sqm' THEN 1 ELSE %[4]s END)
+		WHEN 'm3' THEN %[1]s.storage_volume
+		WHEN 'pallet' THEN (CASE WHEN %[1]s.units_per_pallet > 0 THEN 1.0 / %[1]s.units_per_pallet ELSE 0 END)
+		ELSE 0 END)`, p, w, sqftPerSqm, sqmPerSqft)
+}
@@ -0,0 +1,411 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"warehouse/allocation"
+	dbconn "warehouse/config/dbConn"
+	"warehouse/models"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// The TestConcurrent* tests race real transactions and need a Postgres database, as do
+// the other tests that call testDB. They are opt-in: without TEST_DATABASE_DSN they are
+// skipped, and CI does not set it.
+//
+//	TEST_DATABASE_DSN="host=localhost user=postgres password=postgres dbname=warehouse_test sslmode=disable" go test ./repo -run Concurrent
+//
+// The TestGuard* tests always run. They build the statements on a dry-run connection
+// and check the guards and lock order the concurrent tests rely on.
+var openTestDB = func() (*gorm.DB, error) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		return nil, nil
+	}
+	return gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+		NamingStrategy: schema.NamingStrategy{
+			TablePrefix:   "mys_",
+			SingularTable: true,
+		},
+	})
+}
+
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := openTestDB()
+	if err != nil {
+		t.Fatalf("connect test database: %v", err)
+	}
+	if db == nil {
+		t.Skip("TEST_DATABASE_DSN not set")
+	}
+	if err := db.AutoMigrate(
+		&models.RentRate{},
+		&models.Warehouse{},
+		&models.RentRateVersion{},
+		&models.Supplier{},
+		&models.Product{},
+		&models.Batch{},
+		&models.BatchProductEntry{},
+		&models.Customer{},
+		&models.TaxRate{},
+		&models.InvoiceSeries{},
+		&models.InvoiceCounter{},
+		&models.Billing{},
+		&models.BillingItem{},
+		&models.Profit{},
+		&models.StockMovement{},
+		&models.OffBoardExpense{},
+		&models.ExchangeRate{},
+		&models.Location{},
+		&models.LocationStock{},
+		&models.TransferOrder{},
+		&models.TransferOrderItem{},
+		&models.RentInvoice{},
+		&models.RentInvoiceLine{},
+	); err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+	dbconn.DB = db
+	return db
+}
+
+// sqlRecorder collects the statements a dry-run connection builds
+type sqlRecorder struct {
+	logger.Interface
+	mu   sync.Mutex
+	sqls []string
+}
+
+func (r *sqlRecorder) LogMode(logger.LogLevel) logger.Interface { return r }
+
+func (r *sqlRecorder) Trace(_ context.Context, _ time.Time, fc func() (string, int64), _ error) {
+	sql, _ := fc()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sqls = append(r.sqls, sql)
+}
+
+// statement is the first recorded statement starting with prefix
+func (r *sqlRecorder) statement(t *testing.T, prefix string) string {
+	t.Helper()
+	for _, sql := range r.sqls {
+		if strings.HasPrefix(sql, prefix) {
+			return sql
+		}
+	}
+	t.Fatalf("no %s statement in %q", prefix, r.sqls)
+	return ""
+}
+
+// dryRunDB builds Postgres statements without a server; nothing is sent or executed
+func dryRunDB(t *testing.T) (*gorm.DB, *sqlRecorder) {
+	t.Helper()
+	rec := &sqlRecorder{Interface: logger.Discard}
+	db, err := gorm.Open(postgres.Open("host=localhost dbname=dry_run"), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+		Logger:                 rec,
+		NamingStrategy: schema.NamingStrategy{
+			TablePrefix:   "mys_",
+			SingularTable: true,
+		},
+	})
+	if err != nil {
+		t.Fatalf("open dry-run database: %v", err)
+	}
+	return db, rec
+}
+
+func TestGuardStockChangeRefusesToGoNegative(t *testing.T) {
+	db, rec := dryRunDB(t)
+
+	// The dry run changes no row, which is how a refused change looks
+	if _, err := changeStock(db, 7, -3, nil); !errors.Is(err, allocation.ErrInsufficientStock) {
+		t.Errorf("err = %v, want ErrInsufficientStock", err)
+	}
+
+	update := rec.statement(t, "UPDATE")
+	for _, want := range []string{`"stock_quantity"=stock_quantity + -3`, "WHERE id = 7 AND stock_quantity + -3 >= 0"} {
+		if !strings.Contains(update, want) {
+			t.Errorf("stock update %q does not contain %q", update, want)
+		}
+	}
+}
+
+func TestGuardAreaReservationRefusesToOverfill(t *testing.T) {
+	db, rec := dryRunDB(t)
+
+	if err := reserveWarehouseArea(db, 4, 5); err == nil {
+		t.Error("reservation with no row updated succeeded")
+	}
+
+	update := rec.statement(t, "UPDATE")
+	for _, want := range []string{`"available_area"=available_area - 5`, "id = 4 AND available_area >= 5"} {
+		if !strings.Contains(update, want) {
+			t.Errorf("area update %q does not contain %q", update, want)
+		}
+	}
+}
+
+func TestGuardAllocationLocksEntriesInIDOrder(t *testing.T) {
+	db, rec := dryRunDB(t)
+
+	_, err := allocateStock(db, newRentRateCache(db), 1, models.Product{ID: 9}, 5, allocation.Default, map[uint]int{}, true, time.Now())
+	if !errors.Is(err, allocation.ErrInsufficientStock) {
+		t.Errorf("err = %v, want ErrInsufficientStock", err)
+	}
+
+	// Every bill locks candidate entries in one order, so two bills cannot deadlock
+	load := rec.statement(t, "SELECT")
+	for _, want := range []string{`ORDER BY mys_batch_product_entry.id ASC`, `FOR UPDATE OF "mys_batch_product_entry"`} {
+		if !strings.Contains(load, want) {
+			t.Errorf("entry load %q does not contain %q", load, want)
+		}
+	}
+}
+
+func TestGuardBillingRefusesNonPositiveQuantities(t *testing.T) {
+	for _, qty := range []int{0, -4} {
+		db, rec := dryRunDB(t)
+		input := models.BillingInput{CustomerID: 1, Items: []models.BillingItemInput{{ProductID: "9", OffboardQty: qty}}}
+
+		if _, err := draftBilling(db, 1, input, false, true, time.Now()); !errors.Is(err, ErrInvalidBillingInput) {
+			t.Errorf("quantity %d: err = %v, want ErrInvalidBillingInput", qty, err)
+		}
+		if len(rec.sqls) != 0 {
+			t.Errorf("quantity %d: refused bill still ran %q", qty, rec.sqls)
+		}
+	}
+}
+
+// stockFixture is one warehouse holding a single batch entry of one product
+type stockFixture struct {
+	warehouse models.Warehouse
+	product   models.Product
+	entry     models.BatchProductEntry
+	customer  models.Customer
+}
+
+func seedStock(t *testing.T, db *gorm.DB, stock int, area float64) stockFixture {
+	t.Helper()
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 36)
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+	}
+
+	rate := models.RentRate{RatePerSqft: decimal.NewFromInt(1), Currency: "INR", BillingCycle: "daily"}
+	must(db.Create(&rate).Error)
+	used := area * float64(stock)
+	warehouse := models.Warehouse{Name: "concurrency " + suffix, TotalArea: used + 100, AvailableArea: 100, RentConfigID: rate.ID}
+	must(db.Create(&warehouse).Error)
+	must(db.Create(&models.RentRateVersion{
+		WarehouseID: warehouse.ID, RatePerSqft: decimal.NewFromInt(1), Currency: "INR", BillingCycle: "daily",
+		EffectiveFrom: time.Now().AddDate(0, -1, 0),
+	}).Error)
+
+	supplier := models.Supplier{Name: "supplier " + suffix}
+	must(db.Create(&supplier).Error)
+	product := models.Product{Name: "product " + suffix, SupplierID: supplier.ID, Category: "category " + suffix, StorageArea: area}
+	must(db.Omit("Supplier").Create(&product).Error)
+	must(db.Create(&models.TaxRate{Category: product.Category, Rate: 5}).Error)
+
+	batch := models.Batch{WarehouseID: warehouse.ID, Status: "active"}
+	must(db.Omit("Warehouse", "Products").Create(&batch).Error)
+	entry := models.BatchProductEntry{
+		BatchID: batch.ID, ProductID: product.ID, BillingPrice: decimal.NewFromInt(10),
+		Quantity: stock, StockQuantity: stock,
+	}
+	must(db.Omit("Product").Create(&entry).Error)
+
+	customer := models.Customer{Name: "customer " + suffix}
+	must(db.Create(&customer).Error)
+
+	return stockFixture{warehouse: warehouse, product: product, entry: entry, customer: customer}
+}
+
+// hammer runs fn from n goroutines released at the same moment
+func hammer(n int, fn func(i int) error) []error {
+	var (
+		wg    sync.WaitGroup
+		start = make(chan struct{})
+		errs  = make([]error, n)
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			errs[i] = fn(i)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+	return errs
+}
+
+// countOutcomes splits results into successes and insufficient-stock refusals and fails
+// on anything else
+func countOutcomes(t *testing.T, errs []error) (ok, refused int) {
+	t.Helper()
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			ok++
+		case errors.Is(err, allocation.ErrInsufficientStock):
+			refused++
+		default:
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	return ok, refused
+}
+
+func TestConcurrentStockChangesNeverGoNegative(t *testing.T) {
+	db := testDB(t)
+	f := seedStock(t, db, 50, 1)
+
+	const workers, take = 40, 3
+	errs := hammer(workers, func(int) error {
+		return db.Transaction(func(tx *gorm.DB) error {
+			entry, err := changeStock(tx, f.entry.ID, -take, nil)
+			if err != nil {
+				return err
+			}
+			if entry.StockQuantity < 0 {
+				return fmt.Errorf("stock went negative: %d", entry.StockQuantity)
+			}
+			return nil
+		})
+	})
+
+	ok, refused := countOutcomes(t, errs)
+	if ok != 50/take || refused != workers-50/take {
+		t.Errorf("got %d successful and %d refused changes, want %d and %d", ok, refused, 50/take, workers-50/take)
+	}
+
+	var entry models.BatchProductEntry
+	if err := db.First(&entry, f.entry.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if want := 50 - ok*take; entry.StockQuantity != want {
+		t.Errorf("stock = %d, want %d", entry.StockQuantity, want)
+	}
+}
+
+func TestConcurrentBillingDoesNotOversell(t *testing.T) {
+	db := testDB(t)
+	const stock, area = 50, 2.0
+	f := seedStock(t, db, stock, area)
+
+	const workers, take = 25, 3
+	billing := NewBillingRepo()
+	errs := hammer(workers, func(int) error {
+		_, err := billing.CreateBillingWithOutBatchId(context.Background(), f.warehouse.ID, 1, models.BillingInput{
+			CustomerID: f.customer.ID,
+			Items: []models.BillingItemInput{{
+				ProductID:    strconv.FormatUint(uint64(f.product.ID), 10),
+				OffboardQty:  take,
+				SellingPrice: decimal.NewFromInt(15),
+			}},
+		})
+		return err
+	})
+
+	ok, _ := countOutcomes(t, errs)
+	if ok != stock/take {
+		t.Errorf("%d bills went through, want %d", ok, stock/take)
+	}
+
+	var entry models.BatchProductEntry
+	if err := db.First(&entry, f.entry.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if want := stock - ok*take; entry.StockQuantity != want {
+		t.Errorf("stock = %d, want %d", entry.StockQuantity, want)
+	}
+
+	// Every offboarded unit is on a bill, in the ledger and back in the free area
+	var billed, ledger int64
+	db.Model(&models.BillingItem{}).Where("batch_id = ? AND product_id = ?", f.entry.BatchID, f.product.ID).
+		Select("COALESCE(SUM(offboard_qty), 0)").Scan(&billed)
+	db.Model(&models.StockMovement{}).Where("entry_id = ?", f.entry.ID).
+		Select("COALESCE(SUM(quantity), 0)").Scan(&ledger)
+	if billed != int64(ok*take) || ledger != -billed {
+		t.Errorf("billed %d units with ledger total %d, want %d and %d", billed, ledger, ok*take, -ok*take)
+	}
+
+	var warehouse models.Warehouse
+	if err := db.First(&warehouse, f.warehouse.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if want := f.warehouse.AvailableArea + area*float64(ok*take); warehouse.AvailableArea != want {
+		t.Errorf("available area = %.2f, want %.2f", warehouse.AvailableArea, want)
+	}
+}
+
+func TestConcurrentPutawayRespectsBinCapacity(t *testing.T) {
+	db := testDB(t)
+	f := seedStock(t, db, 50, 1)
+
+	var parent *uint
+	var bin models.Location
+	for _, level := range models.LocationLevels {
+		bin = models.Location{WarehouseID: f.warehouse.ID, ParentID: parent, Level: level, Code: level}
+		if level == models.LevelBin {
+			bin.Capacity = 10
+		}
+		if err := db.Create(&bin).Error; err != nil {
+			t.Fatalf("seed: %v", err)
+		}
+		parent = &bin.ID
+	}
+
+	const workers, put = 8, 3
+	errs := hammer(workers, func(int) error {
+		return db.Transaction(func(tx *gorm.DB) error {
+			return placeInBin(tx, bin, f.entry, put, 1)
+		})
+	})
+
+	var ok int
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			ok++
+		case !errors.Is(err, ErrLocationFull):
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if ok != 10/put {
+		t.Errorf("%d putaways went through, want %d", ok, 10/put)
+	}
+
+	if err := db.First(&bin, bin.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	var placed models.LocationStock
+	if err := db.Where("location_id = ? AND entry_id = ?", bin.ID, f.entry.ID).First(&placed).Error; err != nil {
+		t.Fatal(err)
+	}
+	if bin.UsedCapacity != float64(ok*put) || placed.Quantity != ok*put {
+		t.Errorf("bin holds %d units using %.2f, want %d", placed.Quantity, bin.UsedCapacity, ok*put)
+	}
+}
@@ -0,0 +1,171 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+	dbconn "warehouse/config/dbConn"
+	"warehouse/fx"
+	"warehouse/models"
+	"warehouse/money"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+type CustomerRepo struct {
+}
+
+// NewCustomerRepo initializes the repository
+func NewCustomerRepo() *CustomerRepo {
+	return &CustomerRepo{}
+}
+
+// Create inserts a new customer
+func (r *CustomerRepo) Create(ctx context.Context, customer *models.Customer) (uint, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+	table := ns.TableName("Customer")
+
+	if err := checkCustomerCurrency(customer); err != nil {
+		return 0, err
+	}
+	if err := db.Table(table).Create(&customer).Error; err != nil {
+		return 0, fmt.Errorf("failed to create customer: %w", err)
+	}
+
+	log.Printf("🤝 New customer created: ID=%d, Name=%s", customer.ID, customer.Name)
+	return customer.ID, nil
+}
+
+// GetByID fetches a customer by ID
+func (r *CustomerRepo) GetByID(ctx context.Context, id uint) (*models.Customer, error) {
+	db := dbconn.DB.WithContext(ctx)
+	return loadCustomer(db, id)
+}
+
+// GetAll fetches all customers
+func (r *CustomerRepo) GetAll(ctx context.Context) ([]models.Customer, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+	table := ns.TableName("Customer")
+
+	var customers []models.Customer
+	if err := db.Table(table).Order("name ASC").Find(&customers).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch customers: %w", err)
+	}
+
+	log.Printf("🤝 Retrieved %d customers", len(customers))
+	return customers, nil
+}
+
+// Update modifies a customer
+func (r *CustomerRepo) Update(ctx context.Context, update models.Customer) error {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+	table := ns.TableName("Customer")
+
+	if err := checkCustomerCurrency(&update); err != nil {
+		return err
+	}
+	res := db.Table(table).
+		Where("id = ?", update.ID).
+		Updates(update)
+	if res.Error != nil {
+		return fmt.Errorf("failed to update customer ID %d: %w", update.ID, res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("customer not found (ID=%d): %w", update.ID, gorm.ErrRecordNotFound)
+	}
+
+	log.Printf("🔄 Customer updated: ID=%d, Name=%s", update.ID, update.Name)
+	return nil
+}
+
+// Delete removes a customer
+func (r *CustomerRepo) Delete(ctx context.Context, id uint) error {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+	table := ns.TableName("Customer")
+
+	if err := db.Table(table).Delete(&models.Customer{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete customer ID %d: %w", id, err)
+	}
+
+	log.Printf("🗑️ Customer deleted: ID=%d", id)
+	return nil
+}
+
+// GetStatement lists the customer's bills in the warehouse, optionally limited to bills
+// created in [from, to), with billed, credited, paid and outstanding totals.
+func (r *CustomerRepo) GetStatement(ctx context.Context, warehouseId, customerID uint, from, to time.Time) (*models.CustomerStatement, error) {
+	db := dbconn.DB.WithContext(ctx)
+
+	customer, err := loadCustomer(db, customerID)
+	if err != nil {
+		return nil, err
+	}
+
+	bills, err := billingCoreData(db, warehouseId, customerID, from, to, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// Totals are in the customer's currency; bills issued in another one are moved into it
+	// at their booked rate against today's rate of the statement currency
+	conv, err := newBillFX(db, warehouseId, customer, "", time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	statement := models.CustomerStatement{
+		Customer:    *customer,
+		WarehouseID: warehouseId,
+		Currency:    conv.currency,
+		Bills:       bills,
+		BillCount:   len(bills),
+	}
+	if !from.IsZero() {
+		statement.From = &from
+	}
+	if !to.IsZero() {
+		statement.To = &to
+	}
+	for _, bill := range bills {
+		in := func(amount decimal.Decimal) decimal.Decimal {
+			if bill.Currency == conv.currency {
+				return amount
+			}
+			return money.Round(fx.Rebook(amount, bill.ExchangeRate, conv.rate))
+		}
+		statement.TotalBilled = statement.TotalBilled.Add(in(bill.InvoiceTotal))
+		statement.TotalCredited = statement.TotalCredited.Add(in(bill.CreditedAmount))
+		statement.TotalPaid = statement.TotalPaid.Add(in(bill.PaidAmount))
+		statement.Outstanding = statement.Outstanding.Add(in(bill.Outstanding))
+	}
+	statement.NetBilled = statement.TotalBilled.Sub(statement.TotalCredited)
+
+	log.Printf("📄 Statement for customer %d in warehouse %d: %d bills, net %s %s", customerID, warehouseId, len(bills), statement.NetBilled.StringFixed(2), statement.Currency)
+	return &statement, nil
+}
+
+// checkCustomerCurrency normalises the customer's currency code; empty leaves it unset
+func checkCustomerCurrency(customer *models.Customer) error {
+	customer.Currency = fx.Code(customer.Currency)
+	if customer.Currency != "" && !fx.ValidCode(customer.Currency) {
+		return fmt.Errorf("%w %q", fx.ErrInvalidCurrency, customer.Currency)
+	}
+	return nil
+}
+
+// loadCustomer fetches a customer, failing with gorm.ErrRecordNotFound when it does not exist
+func loadCustomer(db *gorm.DB, customerID uint) (*models.Customer, error) {
+	ns := db.NamingStrategy
+
+	var customer models.Customer
+	if err := db.Table(ns.TableName("Customer")).First(&customer, customerID).Error; err != nil {
+		return nil, fmt.Errorf("customer not found (ID=%d): %w", customerID, err)
+	}
+	return &customer, nil
+}
@@ -0,0 +1,313 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"warehouse/config"
+	dbconn "warehouse/config/dbConn"
+	"warehouse/fx"
+	"warehouse/models"
+	"warehouse/money"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ExchangeRateRepo struct{}
+
+// NewExchangeRateRepo initializes the exchange rate repository
+func NewExchangeRateRepo() *ExchangeRateRepo {
+	return &ExchangeRateRepo{}
+}
+
+// ErrCurrencyMismatch is returned when a payment is applied to a bill in another currency
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// upsertExchangeRates stores rates, replacing any already loaded for the same pair and
+// effective date
+func upsertExchangeRates(tx *gorm.DB, rates []models.ExchangeRate) error {
+	if len(rates) == 0 {
+		return nil
+	}
+	ns := tx.NamingStrategy
+	return tx.Table(ns.TableName("ExchangeRate")).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "from_currency"}, {Name: "to_currency"}, {Name: "effective_from"}},
+			DoUpdates: clause.AssignmentColumns([]string{"rate", "source", "created_by", "updated_at"}),
+		}).
+		Create(&rates).Error
+}
+
+// validateExchangeRate normalises the codes of a rate and checks it can be used
+func validateExchangeRate(rate *models.ExchangeRate) error {
+	rate.FromCurrency, rate.ToCurrency = fx.Code(rate.FromCurrency), fx.Code(rate.ToCurrency)
+	for _, code := range []string{rate.FromCurrency, rate.ToCurrency} {
+		if !fx.ValidCode(code) {
+			return fmt.Errorf("%w %q", fx.ErrInvalidCurrency, code)
+		}
+	}
+	if rate.FromCurrency == rate.ToCurrency {
+		return fmt.Errorf("%w: a rate needs two different currencies", fx.ErrInvalidCurrency)
+	}
+	if !rate.Rate.IsPositive() {
+		return fmt.Errorf("rate for %s/%s must be positive", rate.FromCurrency, rate.ToCurrency)
+	}
+	rate.Rate = rate.Rate.Round(fx.RatePlaces)
+	return nil
+}
+
+// Create records a single rate, replacing one already set for the pair on that date
+func (r *ExchangeRateRepo) Create(ctx context.Context, userID uint, rate *models.ExchangeRate) error {
+	db := dbconn.DB.WithContext(ctx)
+
+	if err := validateExchangeRate(rate); err != nil {
+		return err
+	}
+	rate.CreatedBy = userID
+	if rate.Source == "" {
+		rate.Source = "manual"
+	}
+	rates := []models.ExchangeRate{*rate}
+	if err := upsertExchangeRates(db, rates); err != nil {
+		return fmt.Errorf("failed to save exchange rate: %w", err)
+	}
+	*rate = rates[0]
+
+	log.Printf("💱 Exchange rate %s/%s = %s from %s", rate.FromCurrency, rate.ToCurrency, rate.Rate.String(), rate.EffectiveFrom.Format("2006-01-02"))
+	return nil
+}
+
+// GetAll lists rates, optionally for one currency on either side, newest first
+func (r *ExchangeRateRepo) GetAll(ctx context.Context, currency string) ([]models.ExchangeRate, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	query := db.Table(ns.TableName("ExchangeRate"))
+	if code := fx.Code(currency); code != "" {
+		query = query.Where("from_currency = ? OR to_currency = ?", code, code)
+	}
+
+	var rates []models.ExchangeRate
+	if err := query.Order("from_currency ASC, to_currency ASC, effective_from DESC").Find(&rates).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch exchange rates: %w", err)
+	}
+	return rates, nil
+}
+
+// Delete removes a rate; documents already booked keep the rate they were booked at
+func (r *ExchangeRateRepo) Delete(ctx context.Context, id uint) error {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	res := db.Table(ns.TableName("ExchangeRate")).Delete(&models.ExchangeRate{}, id)
+	if res.Error != nil {
+		return fmt.Errorf("failed to delete exchange rate ID %d: %w", id, res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("exchange rate not found (ID=%d): %w", id, gorm.ErrRecordNotFound)
+	}
+
+	log.Printf("🗑️ Exchange rate deleted: ID=%d", id)
+	return nil
+}
+
+// Import loads the rows of a rate file in one transaction. Nothing is saved when any row
+// is invalid or when dryRun is set.
+func (r *ExchangeRateRepo) Import(ctx context.Context, userID uint, source string, rows []models.ExchangeRateRow, dryRun bool) (*models.ExchangeRateImportReport, error) {
+	db := dbconn.DB.WithContext(ctx)
+
+	report := &models.ExchangeRateImportReport{
+		DryRun:    dryRun,
+		Source:    source,
+		TotalRows: len(rows),
+		Rows:      make([]models.ExchangeRateImportRow, len(rows)),
+	}
+
+	type pairDate struct {
+		from, to string
+		day      time.Time
+	}
+	seen := map[pairDate]int{}
+	rates := make([]models.ExchangeRate, 0, len(rows))
+	for i, row := range rows {
+		res := &report.Rows[i]
+		*res = models.ExchangeRateImportRow{
+			Row:           row.RowNumber,
+			FromCurrency:  fx.Code(row.FromCurrency),
+			ToCurrency:    fx.Code(row.ToCurrency),
+			Rate:          row.Rate,
+			EffectiveFrom: row.EffectiveFrom,
+			Errors:        append([]string(nil), row.Errors...),
+		}
+		if len(res.Errors) == 0 {
+			rate := models.ExchangeRate{
+				FromCurrency:  row.FromCurrency,
+				ToCurrency:    row.ToCurrency,
+				Rate:          row.Rate,
+				EffectiveFrom: row.EffectiveFrom,
+				Source:        source,
+				CreatedBy:     userID,
+			}
+			if err := validateExchangeRate(&rate); err != nil {
+				res.Errors = append(res.Errors, err.Error())
+			} else {
+				key := pairDate{rate.FromCurrency, rate.ToCurrency, rate.EffectiveFrom}
+				if first, dup := seen[key]; dup {
+					res.Errors = append(res.Errors, fmt.Sprintf("same pair and date as row %d", first))
+				} else {
+					seen[key] = row.RowNumber
+					rates = append(rates, rate)
+				}
+			}
+		}
+
+		if len(res.Errors) > 0 {
+			res.Status = "invalid"
+			report.Invalid++
+		} else {
+			res.Status = "valid"
+			report.ValidRows++
+		}
+	}
+	if report.Invalid > 0 || dryRun {
+		return report, nil
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return upsertExchangeRates(tx, rates)
+	}); err != nil {
+		return nil, fmt.Errorf("failed to save exchange rates: %w", err)
+	}
+
+	report.Committed = true
+	for i := range report.Rows {
+		report.Rows[i].Status = "imported"
+	}
+	log.Printf("💱 Loaded %d exchange rates from %s", len(rates), source)
+	return report, nil
+}
+
+// baseCurrency is BASE_CURRENCY, the reporting currency of warehouses that set none
+func baseCurrency() string {
+	if code := fx.Code(config.Cfg.BaseCurrency); code != "" {
+		return code
+	}
+	return fx.DefaultCurrency
+}
+
+// documentCurrency is the first code given, normalised and checked
+func documentCurrency(codes ...string) (string, error) {
+	for _, code := range codes {
+		if code = fx.Code(code); code == "" {
+			continue
+		}
+		if !fx.ValidCode(code) {
+			return "", fmt.Errorf("%w %q", fx.ErrInvalidCurrency, code)
+		}
+		return code, nil
+	}
+	return baseCurrency(), nil
+}
+
+// reportingCurrency is the currency a warehouse's documents are converted into
+func reportingCurrency(db *gorm.DB, warehouseID uint) (string, error) {
+	ns := db.NamingStrategy
+
+	var warehouse models.Warehouse
+	if err := db.Table(ns.TableName("Warehouse")).Select("id", "reporting_currency").First(&warehouse, warehouseID).Error; err != nil {
+		return "", fmt.Errorf("warehouse not found (ID=%d): %w", warehouseID, err)
+	}
+	return documentCurrency(warehouse.ReportingCurrency)
+}
+
+// exchangeRates loads the rate table, crossing pairs without a rate through pivot
+func exchangeRates(db *gorm.DB, pivot string) (*fx.Table, error) {
+	ns := db.NamingStrategy
+
+	var rows []models.ExchangeRate
+	if err := db.Table(ns.TableName("ExchangeRate")).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to load exchange rates: %w", err)
+	}
+	rates := make([]fx.Rate, 0, len(rows))
+	for _, row := range rows {
+		rates = append(rates, fx.Rate{From: row.FromCurrency, To: row.ToCurrency, Rate: row.Rate, EffectiveFrom: row.EffectiveFrom})
+	}
+	return fx.NewTable(rates, pivot), nil
+}
+
+// bookBatchCurrency defaults a batch to its warehouse's reporting currency and books the
+// rate into it on the intake date, so the purchase keeps its cost as the rates move
+func bookBatchCurrency(tx *gorm.DB, batch *models.Batch) error {
+	reporting, err := reportingCurrency(tx, batch.WarehouseID)
+	if err != nil {
+		return err
+	}
+	batch.Currency, err = documentCurrency(batch.Currency, reporting)
+	if err != nil {
+		return err
+	}
+	if batch.Currency == reporting {
+		batch.ExchangeRate = decimal.NewFromInt(1)
+		return nil
+	}
+
+	rates, err := exchangeRates(tx, reporting)
+	if err != nil {
+		return err
+	}
+	if batch.ExchangeRate, err = rates.Rate(batch.Currency, reporting, batch.StoredAt); err != nil {
+		return err
+	}
+	return nil
+}
+
+// billFX is the currency a bill is issued in and its rate to the warehouse's reporting
+// currency. Batch costs move into it at the rates their batches were booked at; rent is
+// converted at the bill date.
+type billFX struct {
+	currency string
+	rate     decimal.Decimal
+	rates    *fx.Table
+}
+
+// newBillFX resolves the bill currency (requested, else the customer's, else the
+// warehouse's) and its rate on the bill date
+func newBillFX(db *gorm.DB, warehouseID uint, customer *models.Customer, requested string, now time.Time) (*billFX, error) {
+	reporting, err := reportingCurrency(db, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	currency, err := documentCurrency(requested, customer.Currency, reporting)
+	if err != nil {
+		return nil, err
+	}
+	rates, err := exchangeRates(db, reporting)
+	if err != nil {
+		return nil, err
+	}
+	rate, err := rates.Rate(currency, reporting, now)
+	if err != nil {
+		return nil, err
+	}
+	return &billFX{currency: currency, rate: rate, rates: rates}, nil
+}
+
+// cost is a unit cost of a batch in the bill's currency
+func (c *billFX) cost(unit decimal.Decimal, batch models.Batch) decimal.Decimal {
+	if batch.Currency == c.currency {
+		return unit
+	}
+	return fx.Rebook(unit, batch.ExchangeRate, c.rate).Round(money.UnitPlaces)
+}
+
+// convert turns an amount in another currency into the bill's at the rate on at
+func (c *billFX) convert(amount decimal.Decimal, from string, at time.Time) (decimal.Decimal, error) {
+	if from == "" {
+		return amount, nil
+	}
+	return c.rates.Convert(amount, from, c.currency, at)
+}
@@ -0,0 +1,110 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	dbconn "warehouse/config/dbConn"
+	"warehouse/models"
+)
+
+// ErrIdempotencyKeyReused is returned when a key comes back with a different request
+var ErrIdempotencyKeyReused = errors.New("idempotency key was already used for a different request")
+
+// ErrIdempotencyKeyInFlight is returned while the first request with a key is still running
+var ErrIdempotencyKeyInFlight = errors.New("a request with this idempotency key is still being processed")
+
+// idempotencyLease is how long a claimed key may stay in processing. A request that
+// crashed without releasing its key blocks retries only until the lease runs out.
+const idempotencyLease = 5 * time.Minute
+
+type IdempotencyRepo struct{}
+
+// NewIdempotencyRepo initializes the idempotency key repository
+func NewIdempotencyRepo() *IdempotencyRepo {
+	return &IdempotencyRepo{}
+}
+
+// Begin claims a user's key for a request. It returns nil when the caller now owns the
+// key and should run the request, or the stored key when the same request already
+// completed and its response should be replayed. An expired key, or one left in
+// processing past its lease, is claimed afresh.
+func (r *IdempotencyRepo) Begin(ctx context.Context, userID uint, key, method, path, hash string, ttl time.Duration) (*models.IdempotencyKey, error) {
+	db := dbconn.DB.WithContext(ctx)
+	table := db.NamingStrategy.TableName("IdempotencyKey")
+	now := time.Now()
+
+	res := db.Exec(fmt.Sprintf(`
+		INSERT INTO %[1]s (user_id, "key", method, path, request_hash, status, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, "key") DO UPDATE SET
+			method = EXCLUDED.method, path = EXCLUDED.path, request_hash = EXCLUDED.request_hash,
+			status = EXCLUDED.status, response_status = 0, response_type = '', response_body = NULL,
+			created_at = EXCLUDED.created_at, expires_at = EXCLUDED.expires_at
+		WHERE %[1]s.expires_at <= ? OR (%[1]s.status = ? AND %[1]s.created_at <= ?)
+	`, table), userID, key, method, path, hash, models.IdempotencyProcessing, now, now.Add(ttl),
+		now, models.IdempotencyProcessing, now.Add(-idempotencyLease))
+	if res.Error != nil {
+		return nil, fmt.Errorf("failed to claim idempotency key: %w", res.Error)
+	}
+	if res.RowsAffected == 1 {
+		return nil, nil
+	}
+
+	var existing models.IdempotencyKey
+	if err := db.Table(table).
+		Where(`user_id = ? AND "key" = ?`, userID, key).
+		First(&existing).Error; err != nil {
+		return nil, fmt.Errorf("failed to load idempotency key: %w", err)
+	}
+	if existing.RequestHash != hash {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if existing.Status != models.IdempotencyCompleted {
+		return nil, ErrIdempotencyKeyInFlight
+	}
+	return &existing, nil
+}
+
+// Complete stores the response of a claimed key for replay
+func (r *IdempotencyRepo) Complete(ctx context.Context, userID uint, key string, status int, contentType string, body []byte) error {
+	db := dbconn.DB.WithContext(ctx)
+	table := db.NamingStrategy.TableName("IdempotencyKey")
+
+	if err := db.Table(table).
+		Where(`user_id = ? AND "key" = ?`, userID, key).
+		Updates(map[string]any{
+			"status":          models.IdempotencyCompleted,
+			"response_status": status,
+			"response_type":   contentType,
+			"response_body":   body,
+		}).Error; err != nil {
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+	return nil
+}
+
+// Release gives up a claimed key without a stored response, so the request can be retried
+func (r *IdempotencyRepo) Release(ctx context.Context, userID uint, key string) error {
+	db := dbconn.DB.WithContext(ctx)
+	table := db.NamingStrategy.TableName("IdempotencyKey")
+
+	if err := db.Exec(fmt.Sprintf(`DELETE FROM %s WHERE user_id = ? AND "key" = ? AND status = ?`, table),
+		userID, key, models.IdempotencyProcessing).Error; err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+// PurgeExpired deletes the keys whose replay window has passed
+func (r *IdempotencyRepo) PurgeExpired(ctx context.Context) (int64, error) {
+	db := dbconn.DB.WithContext(ctx)
+	table := db.NamingStrategy.TableName("IdempotencyKey")
+
+	res := db.Exec(fmt.Sprintf(`DELETE FROM %s WHERE expires_at <= ?`, table), time.Now())
+	if res.Error != nil {
+		return 0, fmt.Errorf("failed to purge expired idempotency keys: %w", res.Error)
+	}
+	return res.RowsAffected, nil
+}
@@ -0,0 +1,344 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+	dbconn "warehouse/config/dbConn"
+	"warehouse/invoice"
+	"warehouse/models"
+	"warehouse/money"
+
+	"gorm.io/gorm"
+)
+
+type InvoiceRepo struct{}
+
+// NewInvoiceRepo initializes the printable invoice repository
+func NewInvoiceRepo() *InvoiceRepo {
+	return &InvoiceRepo{}
+}
+
+// ErrInvalidInvoiceTemplate is returned when a template's text blocks or colour do not parse
+var ErrInvalidInvoiceTemplate = errors.New("invalid invoice template")
+
+// GetTemplate returns the warehouse's invoice template, or the defaults if it has none yet
+func (r *InvoiceRepo) GetTemplate(ctx context.Context, warehouseID uint) (*models.InvoiceTemplate, error) {
+	db := dbconn.DB.WithContext(ctx)
+	return loadInvoiceTemplate(db, warehouseID)
+}
+
+// SaveTemplate replaces the warehouse's invoice template. Empty text fields reset to the
+// defaults and omitted flags keep their current value.
+func (r *InvoiceRepo) SaveTemplate(ctx context.Context, warehouseID, userID uint, input models.InvoiceTemplateInput) (*models.InvoiceTemplate, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	var tpl *models.InvoiceTemplate
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var warehouse models.Warehouse
+		if err := tx.Table(ns.TableName("Warehouse")).First(&warehouse, warehouseID).Error; err != nil {
+			return fmt.Errorf("warehouse not found (ID=%d): %w", warehouseID, err)
+		}
+
+		current, err := loadInvoiceTemplate(tx, warehouseID)
+		if err != nil {
+			return err
+		}
+		tpl = current
+		tpl.Title = input.Title
+		tpl.Header = input.Header
+		tpl.Footer = input.Footer
+		tpl.Terms = input.Terms
+		tpl.AccentColor = input.AccentColor
+		if input.ShowStorageCost != nil {
+			tpl.ShowStorageCost = *input.ShowStorageCost
+		}
+		if input.ShowBatch != nil {
+			tpl.ShowBatch = *input.ShowBatch
+		}
+		if input.ShowExpenses != nil {
+			tpl.ShowExpenses = *input.ShowExpenses
+		}
+		tpl.UpdatedBy = userID
+
+		if err := invoice.Validate(invoiceTemplate(*tpl)); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidInvoiceTemplate, err)
+		}
+		if err := tx.Table(ns.TableName("InvoiceTemplate")).Save(tpl).Error; err != nil {
+			return fmt.Errorf("failed to save invoice template: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("🖨️ Invoice template saved for warehouse %d", warehouseID)
+	return tpl, nil
+}
+
+// RenderBillPDF writes bill billID of the warehouse as a PDF invoice using the
+// warehouse's template.
+func (r *InvoiceRepo) RenderBillPDF(ctx context.Context, warehouseId, billID uint, w io.Writer) error {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	bill, err := NewBillingRepo().GetBillingCoreDataWithProductsByBillID(ctx, warehouseId, billID)
+	if err != nil {
+		return err
+	}
+
+	var warehouse models.Warehouse
+	if err := db.Table(ns.TableName("Warehouse")).First(&warehouse, warehouseId).Error; err != nil {
+		return fmt.Errorf("warehouse not found (ID=%d): %w", warehouseId, err)
+	}
+	customer, err := loadCustomer(db, bill.CustomerID)
+	if err != nil {
+		return err
+	}
+
+	var expenses []models.OffBoardExpense
+	if err := db.Table(ns.TableName("OffBoardExpense")).
+		Where("billing_id = ?", billID).
+		Order("id").
+		Find(&expenses).Error; err != nil {
+		return fmt.Errorf("failed to load bill expenses: %w", err)
+	}
+
+	tpl, err := loadInvoiceTemplate(db, warehouseId)
+	if err != nil {
+		return err
+	}
+
+	doc := invoice.Document{
+		Number:    bill.InvoiceNumber,
+		Date:      bill.CreatedAt,
+		DueDate:   bill.DueDate,
+		Status:    bill.PaymentStatus,
+		Currency:  bill.Currency,
+		Warehouse: invoice.Party{Name: warehouse.Name, Location: warehouse.Location, GSTIN: warehouse.GSTIN},
+		Customer: invoice.Party{
+			Name:     customer.Name,
+			Location: customer.Address,
+			Contact:  customer.ContactPerson,
+			Email:    customer.Email,
+			Phone:    customer.Phone,
+			GSTIN:    customer.GSTIN,
+		},
+		CGST:       bill.CGSTAmount,
+		SGST:       bill.SGSTAmount,
+		IGST:       bill.IGSTAmount,
+		Tax:        bill.TotalTax,
+		Total:      bill.InvoiceTotal,
+		Credited:   bill.CreditedAmount,
+		Paid:       bill.PaidAmount,
+		BalanceDue: bill.Outstanding,
+	}
+	for _, item := range bill.Products {
+		// Tax-inclusive bills print the price net of GST so that qty x price = amount
+		unitPrice := item.SellingPrice
+		if bill.TaxInclusive && item.OffboardQty > 0 {
+			unitPrice = money.Round(item.TotalSelling.Div(money.Qty(item.OffboardQty)))
+		}
+		doc.Lines = append(doc.Lines, invoice.Line{
+			Description: item.Product.Name,
+			BatchID:     item.BatchID,
+			Quantity:    item.OffboardQty,
+			UnitPrice:   unitPrice,
+			Amount:      item.TotalSelling,
+			StorageCost: item.StorageCost,
+			HSNCode:     item.HSNCode,
+			TaxRate:     item.TaxRate,
+		})
+		doc.Subtotal = doc.Subtotal.Add(item.TotalSelling)
+	}
+	for _, e := range expenses {
+		doc.Expenses = append(doc.Expenses, invoice.Expense{Type: e.Type, Notes: e.Notes, Amount: e.Amount})
+	}
+	if err := invoice.Render(w, invoiceTemplate(*tpl), doc); err != nil {
+		return err
+	}
+
+	log.Printf("🖨️ Invoice PDF rendered for bill %d (warehouse %d)", billID, warehouseId)
+	return nil
+}
+
+// GetSeries returns the warehouse's invoice numbering, or the defaults if it has none yet
+func (r *InvoiceRepo) GetSeries(ctx context.Context, warehouseID uint) (*models.InvoiceSeries, error) {
+	db := dbconn.DB.WithContext(ctx)
+	return loadInvoiceSeries(db, warehouseID)
+}
+
+// SaveSeries replaces the warehouse's invoice numbering. Omitted fields keep their current
+// value; the sequence itself carries on from the last number issued in the period.
+func (r *InvoiceRepo) SaveSeries(ctx context.Context, warehouseID, userID uint, input models.InvoiceSeriesInput) (*models.InvoiceSeries, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	var series *models.InvoiceSeries
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var warehouse models.Warehouse
+		if err := tx.Table(ns.TableName("Warehouse")).First(&warehouse, warehouseID).Error; err != nil {
+			return fmt.Errorf("warehouse not found (ID=%d): %w", warehouseID, err)
+		}
+
+		current, err := loadInvoiceSeries(tx, warehouseID)
+		if err != nil {
+			return err
+		}
+		series = current
+		series.Prefix = input.Prefix
+		if input.Padding != 0 {
+			series.Padding = input.Padding
+		}
+		if input.ResetEachFY != nil {
+			series.ResetEachFY = *input.ResetEachFY
+		}
+		if input.FYStartMonth != 0 {
+			series.FYStartMonth = input.FYStartMonth
+		}
+		series.UpdatedBy = userID
+
+		if err := tx.Table(ns.TableName("InvoiceSeries")).Save(series).Error; err != nil {
+			return fmt.Errorf("failed to save invoice series: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("🔢 Invoice series saved for warehouse %d", warehouseID)
+	return series, nil
+}
+
+// nextInvoiceNumber issues the next number of the warehouse's series for a bill raised at
+// at. It must run inside the bill's transaction: the counter row stays locked until the
+// bill commits, so concurrent bills queue for their numbers and a rolled-back bill
+// releases its number instead of leaving a gap.
+func nextInvoiceNumber(tx *gorm.DB, warehouseID uint, at time.Time) (string, error) {
+	ns := tx.NamingStrategy
+	table := ns.TableName("InvoiceCounter")
+
+	series, err := loadInvoiceSeries(tx, warehouseID)
+	if err != nil {
+		return "", err
+	}
+	numbering := invoiceNumbering(*series)
+	period := numbering.Period(at)
+
+	if err := tx.Exec(fmt.Sprintf(`
+		INSERT INTO %s (warehouse_id, period, last_number, updated_at)
+		VALUES (?, ?, 0, NOW())
+		ON CONFLICT (warehouse_id, period) DO NOTHING
+	`, table), warehouseID, period).Error; err != nil {
+		return "", fmt.Errorf("failed to open invoice sequence: %w", err)
+	}
+
+	var seq int64
+	if err := tx.Raw(fmt.Sprintf(`
+		UPDATE %s SET last_number = last_number + 1, updated_at = NOW()
+		WHERE warehouse_id = ? AND period = ?
+		RETURNING last_number
+	`, table), warehouseID, period).Scan(&seq).Error; err != nil {
+		return "", fmt.Errorf("failed to issue invoice number: %w", err)
+	}
+
+	return numbering.Format(period, seq), nil
+}
+
+// loadInvoiceSeries returns the stored numbering of a warehouse, or an unsaved one with
+// the default settings
+func loadInvoiceSeries(db *gorm.DB, warehouseID uint) (*models.InvoiceSeries, error) {
+	ns := db.NamingStrategy
+
+	var series []models.InvoiceSeries
+	if err := db.Table(ns.TableName("InvoiceSeries")).
+		Where("warehouse_id = ?", warehouseID).
+		Limit(1).
+		Find(&series).Error; err != nil {
+		return nil, fmt.Errorf("failed to load invoice series: %w", err)
+	}
+	if len(series) > 0 {
+		return &series[0], nil
+	}
+
+	d := invoice.DefaultNumbering
+	return &models.InvoiceSeries{
+		WarehouseID:  warehouseID,
+		Prefix:       d.Prefix,
+		Padding:      d.Padding,
+		ResetEachFY:  d.ResetEachFY,
+		FYStartMonth: d.FYStartMonth,
+	}, nil
+}
+
+// invoiceNumbering converts a stored series
+func invoiceNumbering(m models.InvoiceSeries) invoice.Numbering {
+	return invoice.Numbering{
+		Prefix:       m.Prefix,
+		Padding:      m.Padding,
+		ResetEachFY:  m.ResetEachFY,
+		FYStartMonth: m.FYStartMonth,
+	}
+}
+
+// loadInvoiceTemplate returns the stored template of a warehouse, or an unsaved one with
+// the default settings
+func loadInvoiceTemplate(db *gorm.DB, warehouseID uint) (*models.InvoiceTemplate, error) {
+	ns := db.NamingStrategy
+
+	var tpls []models.InvoiceTemplate
+	if err := db.Table(ns.TableName("InvoiceTemplate")).
+		Where("warehouse_id = ?", warehouseID).
+		Limit(1).
+		Find(&tpls).Error; err != nil {
+		return nil, fmt.Errorf("failed to load invoice template: %w", err)
+	}
+	if len(tpls) > 0 {
+		return &tpls[0], nil
+	}
+
+	d := invoice.DefaultTemplate
+	return &models.InvoiceTemplate{
+		WarehouseID:     warehouseID,
+		ShowStorageCost: d.ShowStorageCost,
+		ShowBatch:       d.ShowBatch,
+		ShowExpenses:    d.ShowExpenses,
+	}, nil
+}
+
+// invoiceTemplate converts a stored template, filling empty text fields with defaults
+func invoiceTemplate(m models.InvoiceTemplate) invoice.Template {
+	t := invoice.Template{
+		Title:           m.Title,
+		Header:          m.Header,
+		Footer:          m.Footer,
+		Terms:           m.Terms,
+		AccentColor:     m.AccentColor,
+		ShowStorageCost: m.ShowStorageCost,
+		ShowBatch:       m.ShowBatch,
+		ShowExpenses:    m.ShowExpenses,
+	}
+	d := invoice.DefaultTemplate
+	if t.Title == "" {
+		t.Title = d.Title
+	}
+	if t.Header == "" {
+		t.Header = d.Header
+	}
+	if t.Footer == "" {
+		t.Footer = d.Footer
+	}
+	if t.Terms == "" {
+		t.Terms = d.Terms
+	}
+	if t.AccentColor == "" {
+		t.AccentColor = d.AccentColor
+	}
+	return t
+}
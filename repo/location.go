@@ -0,0 +1,567 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	dbconn "warehouse/config/dbConn"
+	"warehouse/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidLocation = errors.New("invalid location")
+	ErrLocationFull    = errors.New("not enough free capacity")
+)
+
+// capacityTolerance absorbs the rounding of fractional footprints (a third of a pallet…)
+// when space is checked against a capacity
+const capacityTolerance = 0.001
+
+type LocationRepo struct{}
+
+func NewLocationRepo() *LocationRepo {
+	return &LocationRepo{}
+}
+
+// Create adds a zone, aisle, rack or bin. Every level but a zone sits in a location of
+// the level above it.
+func (r *LocationRepo) Create(ctx context.Context, warehouseId uint, location *models.Location) error {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	location.WarehouseID = warehouseId
+	location.UsedCapacity = 0
+	level := levelIndex(location.Level)
+	if level < 0 {
+		return fmt.Errorf("%w: unknown level %q", ErrInvalidLocation, location.Level)
+	}
+	if location.Level == models.LevelBin && location.Capacity <= 0 {
+		return fmt.Errorf("%w: a bin needs a capacity", ErrInvalidLocation)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if level == 0 {
+			location.ParentID = nil
+		} else {
+			if location.ParentID == nil {
+				return fmt.Errorf("%w: a %s must be placed in a %s", ErrInvalidLocation, location.Level, models.LocationLevels[level-1])
+			}
+			parent, err := loadLocation(tx, warehouseId, *location.ParentID)
+			if err != nil {
+				return err
+			}
+			if parent.Level != models.LocationLevels[level-1] {
+				return fmt.Errorf("%w: a %s must be placed in a %s, not a %s", ErrInvalidLocation, location.Level, models.LocationLevels[level-1], parent.Level)
+			}
+		}
+
+		if err := tx.Table(ns.TableName("Location")).Create(location).Error; err != nil {
+			return fmt.Errorf("failed to create location %q: %w", location.Code, err)
+		}
+		return syncWarehouseArea(tx, warehouseId)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("📍 Location %s (%s) created in warehouse %d", location.Code, location.Level, warehouseId)
+	return nil
+}
+
+// GetAll lists the warehouse's locations by code, optionally of one level
+func (r *LocationRepo) GetAll(ctx context.Context, warehouseId uint, level string) ([]models.Location, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	q := db.Table(ns.TableName("Location")).Where("warehouse_id = ?", warehouseId)
+	if level != "" {
+		q = q.Where("level = ?", level)
+	}
+	var locations []models.Location
+	if err := q.Order("code ASC").Find(&locations).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch locations: %w", err)
+	}
+	return locations, nil
+}
+
+// Update renames a location, moves it nearer to or farther from dispatch or changes its
+// capacity, which cannot drop below what is already stored in it
+func (r *LocationRepo) Update(ctx context.Context, warehouseId, id uint, input models.LocationUpdateInput) (*models.Location, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	var location *models.Location
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		location, err = loadLocation(forUpdate(tx), warehouseId, id)
+		if err != nil {
+			return err
+		}
+
+		updates := map[string]any{}
+		if input.Name != nil {
+			updates["name"] = *input.Name
+			location.Name = *input.Name
+		}
+		if input.Capacity != nil {
+			capacity := *input.Capacity
+			if location.Level == models.LevelBin && capacity <= 0 {
+				return fmt.Errorf("%w: a bin needs a capacity", ErrInvalidLocation)
+			}
+			if capacity > 0 && capacity+capacityTolerance < location.UsedCapacity {
+				return fmt.Errorf("%w: location %s already holds %.2f", ErrLocationFull, location.Code, location.UsedCapacity)
+			}
+			updates["capacity"] = capacity
+			location.Capacity = capacity
+		}
+		if input.DispatchDistance != nil {
+			updates["dispatch_distance"] = *input.DispatchDistance
+			location.DispatchDistance = *input.DispatchDistance
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+
+		if err := tx.Table(ns.TableName("Location")).Where("id = ?", id).Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to update location %d: %w", id, err)
+		}
+		return syncWarehouseArea(tx, warehouseId)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return location, nil
+}
+
+// Delete removes an empty location that has nothing placed under it
+func (r *LocationRepo) Delete(ctx context.Context, warehouseId, id uint) error {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		location, err := loadLocation(forUpdate(tx), warehouseId, id)
+		if err != nil {
+			return err
+		}
+
+		var children, stocked int64
+		tx.Table(ns.TableName("Location")).Where("parent_id = ?", id).Count(&children)
+		tx.Table(ns.TableName("LocationStock")).Where("location_id = ?", id).Count(&stocked)
+		if children > 0 || stocked > 0 {
+			return fmt.Errorf("%w: location %s still has locations or stock in it", ErrInvalidLocation, location.Code)
+		}
+
+		if err := tx.Table(ns.TableName("Location")).Where("id = ?", id).Delete(&models.Location{}).Error; err != nil {
+			return fmt.Errorf("failed to delete location %d: %w", id, err)
+		}
+		return syncWarehouseArea(tx, warehouseId)
+	})
+}
+
+// Move shifts units of a batch entry into a bin. Without a source location it puts away
+// units that are not in any bin yet, such as stock held before the warehouse had bins.
+func (r *LocationRepo) Move(ctx context.Context, warehouseId uint, input models.LocationMoveInput) error {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		entry, err := loadWarehouseEntry(tx, warehouseId, input.EntryID)
+		if err != nil {
+			return err
+		}
+		var product models.Product
+		if err := tx.Table(ns.TableName("Product")).First(&product, entry.ProductID).Error; err != nil {
+			return fmt.Errorf("product not found (ID=%d): %w", entry.ProductID, err)
+		}
+		unit, err := warehouseCapacityUnit(tx, warehouseId)
+		if err != nil {
+			return err
+		}
+		perUnit, err := spaceUsed(product, 1, unit)
+		if err != nil {
+			return err
+		}
+
+		if input.FromLocationID != nil {
+			if err := takeFromLocation(tx, *input.FromLocationID, entry.ID, input.Quantity, perUnit); err != nil {
+				return err
+			}
+		} else {
+			var placed int
+			tx.Table(ns.TableName("LocationStock")).
+				Select("COALESCE(SUM(quantity), 0)").
+				Where("entry_id = ?", entry.ID).
+				Scan(&placed)
+			if unplaced := entry.StockQuantity - placed; input.Quantity > unplaced {
+				return fmt.Errorf("%w: only %d units of entry %d are not in a bin", ErrInvalidLocation, unplaced, entry.ID)
+			}
+		}
+
+		bin, err := loadLocation(tx, warehouseId, input.ToLocationID)
+		if err != nil {
+			return err
+		}
+		if err := placeInBin(tx, *bin, entry, input.Quantity, perUnit); err != nil {
+			return err
+		}
+		return syncWarehouseArea(tx, warehouseId)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("📍 Moved %d units of entry %d into location %d", input.Quantity, input.EntryID, input.ToLocationID)
+	return nil
+}
+
+// Occupancy maps every location of the warehouse with its free space and, for bins,
+// the stock in it
+func (r *LocationRepo) Occupancy(ctx context.Context, warehouseId uint) (*models.LocationOccupancyMap, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	var warehouse models.Warehouse
+	if err := db.Table(ns.TableName("Warehouse")).First(&warehouse, warehouseId).Error; err != nil {
+		return nil, fmt.Errorf("warehouse not found (ID=%d): %w", warehouseId, err)
+	}
+	locations, err := r.GetAll(ctx, warehouseId, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var stock []struct {
+		LocationID uint
+		models.LocationStockView
+	}
+	if err := db.Table(ns.TableName("LocationStock")+" AS ls").
+		Select("ls.location_id, ls.entry_id, ls.batch_id, ls.product_id, p.name AS product_name, p.category, ls.quantity").
+		Joins("JOIN "+ns.TableName("Product")+" AS p ON p.id = ls.product_id").
+		Where("ls.warehouse_id = ? AND ls.quantity > 0", warehouseId).
+		Order("ls.batch_id ASC, ls.entry_id ASC").
+		Scan(&stock).Error; err != nil {
+		return nil, fmt.Errorf("failed to load location stock: %w", err)
+	}
+	stockByLocation := map[uint][]models.LocationStockView{}
+	placed := 0
+	for _, s := range stock {
+		stockByLocation[s.LocationID] = append(stockByLocation[s.LocationID], s.LocationStockView)
+		placed += s.Quantity
+	}
+
+	var inStock int
+	db.Table(ns.TableName("BatchProductEntry")+" AS be").
+		Joins("JOIN "+ns.TableName("Batch")+" AS b ON b.id = be.batch_id").
+		Select("COALESCE(SUM(be.stock_quantity), 0)").
+		Where("b.warehouse_id = ?", warehouseId).
+		Scan(&inStock)
+
+	children := map[uint][]models.Location{}
+	var zones []models.Location
+	for _, l := range locations {
+		if l.ParentID == nil {
+			zones = append(zones, l)
+			continue
+		}
+		children[*l.ParentID] = append(children[*l.ParentID], l)
+	}
+
+	var build func(l models.Location) models.LocationOccupancy
+	build = func(l models.Location) models.LocationOccupancy {
+		node := models.LocationOccupancy{
+			ID:               l.ID,
+			Level:            l.Level,
+			Code:             l.Code,
+			Name:             l.Name,
+			Capacity:         l.Capacity,
+			UsedCapacity:     l.UsedCapacity,
+			Stock:            stockByLocation[l.ID],
+			DispatchDistance: l.DispatchDistance,
+		}
+		var childCapacity float64
+		for _, c := range children[l.ID] {
+			child := build(c)
+			childCapacity += child.Capacity
+			node.Children = append(node.Children, child)
+		}
+		// A level without a limit of its own holds what its children hold
+		if node.Capacity == 0 {
+			node.Capacity = childCapacity
+		}
+		node.FreeCapacity = math.Max(node.Capacity-node.UsedCapacity, 0)
+		if node.Capacity > 0 {
+			node.Utilisation = math.Round(node.UsedCapacity/node.Capacity*10000) / 100
+		}
+		return node
+	}
+
+	result := &models.LocationOccupancyMap{
+		WarehouseID:   warehouseId,
+		CapacityUnit:  warehouse.CapacityUnit,
+		TotalArea:     warehouse.TotalArea,
+		AvailableArea: warehouse.AvailableArea,
+		UnplacedUnits: max(inStock-placed, 0),
+		Zones:         []models.LocationOccupancy{},
+	}
+	for _, z := range zones {
+		result.Zones = append(result.Zones, build(z))
+	}
+	return result, nil
+}
+
+func levelIndex(level string) int {
+	for i, l := range models.LocationLevels {
+		if l == level {
+			return i
+		}
+	}
+	return -1
+}
+
+func loadLocation(tx *gorm.DB, warehouseId, id uint) (*models.Location, error) {
+	ns := tx.NamingStrategy
+
+	var location models.Location
+	if err := tx.Table(ns.TableName("Location")).
+		Where("id = ? AND warehouse_id = ?", id, warehouseId).
+		First(&location).Error; err != nil {
+		return nil, fmt.Errorf("location %d not found in warehouse %d: %w", id, warehouseId, err)
+	}
+	return &location, nil
+}
+
+// loadWarehouseEntry loads a batch entry held by the warehouse
+func loadWarehouseEntry(tx *gorm.DB, warehouseId, entryID uint) (models.BatchProductEntry, error) {
+	ns := tx.NamingStrategy
+
+	var entry models.BatchProductEntry
+	if err := tx.Table(ns.TableName("BatchProductEntry")+" AS be").
+		Select("be.*").
+		Joins("JOIN "+ns.TableName("Batch")+" AS b ON b.id = be.batch_id").
+		Where("be.id = ? AND b.warehouse_id = ?", entryID, warehouseId).
+		First(&entry).Error; err != nil {
+		return entry, fmt.Errorf("batch entry %d not found in warehouse %d: %w", entryID, warehouseId, err)
+	}
+	return entry, nil
+}
+
+// locationChain is a location followed by its parents up to the zone
+func locationChain(tx *gorm.DB, location models.Location) ([]models.Location, error) {
+	ns := tx.NamingStrategy
+
+	chain := []models.Location{location}
+	for parentID := location.ParentID; parentID != nil; {
+		var parent models.Location
+		if err := tx.Table(ns.TableName("Location")).First(&parent, *parentID).Error; err != nil {
+			return nil, fmt.Errorf("parent location %d not found: %w", *parentID, err)
+		}
+		chain = append(chain, parent)
+		parentID = parent.ParentID
+	}
+	return chain, nil
+}
+
+// occupyLocation takes space in a bin and in every level above it. Each update is
+// guarded, so concurrent putaways can never fill a location past its capacity.
+func occupyLocation(tx *gorm.DB, bin models.Location, space float64) error {
+	ns := tx.NamingStrategy
+
+	chain, err := locationChain(tx, bin)
+	if err != nil {
+		return err
+	}
+	for _, l := range chain {
+		res := tx.Table(ns.TableName("Location")).
+			Where("id = ? AND (capacity = 0 OR used_capacity + ? <= capacity + ?)", l.ID, space, capacityTolerance).
+			Update("used_capacity", gorm.Expr("used_capacity + ?", space))
+		if res.Error != nil {
+			return fmt.Errorf("failed to update location %s: %w", l.Code, res.Error)
+		}
+		if res.RowsAffected == 0 {
+			return fmt.Errorf("%w in location %s (free: %.2f, required: %.2f)", ErrLocationFull, l.Code, l.Capacity-l.UsedCapacity, space)
+		}
+	}
+	return nil
+}
+
+// freeLocation gives space back to a bin and every level above it
+func freeLocation(tx *gorm.DB, bin models.Location, space float64) error {
+	ns := tx.NamingStrategy
+
+	chain, err := locationChain(tx, bin)
+	if err != nil {
+		return err
+	}
+	for _, l := range chain {
+		if err := tx.Table(ns.TableName("Location")).
+			Where("id = ?", l.ID).
+			Update("used_capacity", gorm.Expr(
+				"CASE WHEN used_capacity - ? < 0 THEN 0 ELSE used_capacity - ? END", space, space)).Error; err != nil {
+			return fmt.Errorf("failed to update location %s: %w", l.Code, err)
+		}
+	}
+	return nil
+}
+
+// placeInBin stores qty units of an entry in a bin
+func placeInBin(tx *gorm.DB, bin models.Location, entry models.BatchProductEntry, qty int, perUnit float64) error {
+	ns := tx.NamingStrategy
+
+	if bin.Level != models.LevelBin {
+		return fmt.Errorf("%w: stock goes into bins, %s is a %s", ErrInvalidLocation, bin.Code, bin.Level)
+	}
+	if err := occupyLocation(tx, bin, perUnit*float64(qty)); err != nil {
+		return err
+	}
+
+	// One row per bin and entry; concurrent putaways of the same entry add up
+	table := ns.TableName("LocationStock")
+	if err := tx.Table(table).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "location_id"}, {Name: "entry_id"}},
+			DoUpdates: clause.Assignments(map[string]any{"quantity": gorm.Expr(table + ".quantity + EXCLUDED.quantity")}),
+		}).
+		Create(&models.LocationStock{
+			WarehouseID: bin.WarehouseID,
+			LocationID:  bin.ID,
+			EntryID:     entry.ID,
+			BatchID:     entry.BatchID,
+			ProductID:   entry.ProductID,
+			Quantity:    qty,
+		}).Error; err != nil {
+		return fmt.Errorf("failed to record location stock: %w", err)
+	}
+	return nil
+}
+
+// takeFromLocation removes qty units of an entry from one bin
+func takeFromLocation(tx *gorm.DB, locationID, entryID uint, qty int, perUnit float64) error {
+	ns := tx.NamingStrategy
+
+	res := tx.Table(ns.TableName("LocationStock")).
+		Where("location_id = ? AND entry_id = ? AND quantity >= ?", locationID, entryID, qty).
+		Update("quantity", gorm.Expr("quantity - ?", qty))
+	if res.Error != nil {
+		return fmt.Errorf("failed to update location stock: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("%w: location %d holds fewer than %d units of entry %d", ErrInvalidLocation, locationID, qty, entryID)
+	}
+	tx.Table(ns.TableName("LocationStock")).
+		Where("location_id = ? AND entry_id = ? AND quantity = 0", locationID, entryID).
+		Delete(&models.LocationStock{})
+
+	var bin models.Location
+	if err := tx.Table(ns.TableName("Location")).First(&bin, locationID).Error; err != nil {
+		return fmt.Errorf("location %d not found: %w", locationID, err)
+	}
+	return freeLocation(tx, bin, perUnit*float64(qty))
+}
+
+// warehouseHasBins reports whether a warehouse tracks its stock by location
+func warehouseHasBins(tx *gorm.DB, warehouseID uint) (bool, error) {
+	ns := tx.NamingStrategy
+
+	var bins int64
+	if err := tx.Table(ns.TableName("Location")).
+		Where("warehouse_id = ? AND level = ?", warehouseID, models.LevelBin).
+		Count(&bins).Error; err != nil {
+		return false, fmt.Errorf("failed to count locations: %w", err)
+	}
+	return bins > 0, nil
+}
+
+// syncWarehouseArea derives the warehouse's total and available area from its bins.
+// Warehouses without bins keep their hand-maintained area.
+func syncWarehouseArea(tx *gorm.DB, warehouseID uint) error {
+	ns := tx.NamingStrategy
+
+	binned, err := warehouseHasBins(tx, warehouseID)
+	if err != nil || !binned {
+		return err
+	}
+	bins := fmt.Sprintf("FROM %s WHERE warehouse_id = ? AND level = '%s'", ns.TableName("Location"), models.LevelBin)
+	if err := tx.Model(&models.Warehouse{}).
+		Where("id = ?", warehouseID).
+		Updates(map[string]any{
+			"total_area":     gorm.Expr("(SELECT COALESCE(SUM(capacity), 0) "+bins+")", warehouseID),
+			"available_area": gorm.Expr("(SELECT COALESCE(SUM(CASE WHEN capacity > used_capacity THEN capacity - used_capacity ELSE 0 END), 0) "+bins+")", warehouseID),
+		}).Error; err != nil {
+		return fmt.Errorf("failed to derive warehouse area: %w", err)
+	}
+	return nil
+}
+
+// pickPlan suggests the bins to pick qty units of an entry from: the emptiest first, so
+// picks free whole bins. held is what earlier lines already plan to take from each
+// location-stock row. Units the bins cannot cover are left out. With lock the rows stay
+// locked until the transaction ends.
+func pickPlan(tx *gorm.DB, entryID uint, qty int, held map[uint]int, lock bool) ([]models.Putaway, error) {
+	ns := tx.NamingStrategy
+
+	var rows []struct {
+		ID         uint
+		LocationID uint
+		Code       string
+		Quantity   int
+	}
+	if err := lockRows(tx.Table(ns.TableName("LocationStock")+" AS ls"), lock, "ls").
+		Select("ls.id, ls.location_id, l.code, ls.quantity").
+		Joins("JOIN "+ns.TableName("Location")+" AS l ON l.id = ls.location_id").
+		Where("ls.entry_id = ? AND ls.quantity > 0", entryID).
+		Order("ls.quantity ASC, l.code ASC").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to load stock locations: %w", err)
+	}
+
+	var plan []models.Putaway
+	for _, row := range rows {
+		if qty == 0 {
+			break
+		}
+		take := min(qty, row.Quantity-held[row.ID])
+		if take <= 0 {
+			continue
+		}
+		held[row.ID] += take
+		plan = append(plan, models.Putaway{LocationID: row.LocationID, LocationCode: row.Code, Quantity: take})
+		qty -= take
+	}
+	return plan, nil
+}
+
+// pickFromBins takes units of an entry that leave the warehouse out of its bins, in
+// pickPlan order. Units beyond what the bins hold were stored before the warehouse had
+// bins and come out of none.
+func pickFromBins(tx *gorm.DB, warehouseID uint, entry models.BatchProductEntry, product models.Product, qty int) ([]models.Putaway, error) {
+	binned, err := warehouseHasBins(tx, warehouseID)
+	if err != nil || !binned {
+		return nil, err
+	}
+	unit, err := warehouseCapacityUnit(tx, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	perUnit, err := spaceUsed(product, 1, unit)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := pickPlan(tx, entry.ID, qty, map[uint]int{}, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range plan {
+		if err := takeFromLocation(tx, p.LocationID, entry.ID, p.Quantity, perUnit); err != nil {
+			return nil, err
+		}
+	}
+	if err := syncWarehouseArea(tx, warehouseID); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
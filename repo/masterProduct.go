@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"warehouse/allocation"
+	"warehouse/capacity"
 	dbconn "warehouse/config/dbConn"
 	"warehouse/models"
 )
@@ -40,6 +42,11 @@ func (r *ProductRepo) Create(ctx context.Context, product *models.Product) (uint
 		return 0, fmt.Errorf("supplier with id %d not found", product.SupplierID)
 	}
 
+	// ✅ Footprint per unit
+	if err := checkProductFootprint(product); err != nil {
+		return 0, err
+	}
+
 	// ✅ Insert product
 	if err := db.Table(productTable).Create(product).Error; err != nil {
 		return 0, fmt.Errorf("failed to create product: %w", err)
@@ -88,6 +95,23 @@ func (r *ProductRepo) Update(ctx context.Context, id uint, update map[string]int
 	ns := db.NamingStrategy
 	table := ns.TableName("Product")
 
+	if v, ok := update["allocation_strategy"]; ok {
+		if name, isString := v.(string); !isString || !allocation.Valid(name) {
+			return fmt.Errorf("%w: %v", allocation.ErrUnknownStrategy, v)
+		}
+	}
+	if v, ok := update["area_unit"]; ok {
+		name, _ := v.(string)
+		unit, err := capacity.Normalize(name)
+		if err != nil {
+			return err
+		}
+		if !capacity.IsArea(unit) {
+			return fmt.Errorf("%w: area unit must be sqft or sqm, got %v", capacity.ErrUnknownUnit, v)
+		}
+		update["area_unit"] = unit
+	}
+
 	if err := db.Table(table).
 		Where("id = ?", id).
 		Updates(update).Error; err != nil {
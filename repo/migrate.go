@@ -1 +1,269 @@
 package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+	dbconn "warehouse/config/dbConn"
+	"warehouse/models"
+
+	"gorm.io/gorm"
+)
+
+type ImportRepo struct{}
+
+// NewImportRepo initializes the spreadsheet import repository
+func NewImportRepo() *ImportRepo {
+	return &ImportRepo{}
+}
+
+// errImportRollback aborts the import transaction without surfacing an error
+var errImportRollback = errors.New("import rolled back")
+
+type importGroupKey struct {
+	WarehouseID  uint
+	PurchaseDate string
+}
+
+type importGroup struct {
+	WarehouseID  uint
+	PurchaseDate time.Time
+	Rows         []int // indexes into report.Rows
+	Entries      []models.BatchProductEntry
+}
+
+// ImportBatches resolves suppliers/products by name, groups rows per warehouse and
+// purchase date and creates one batch per group through the AddBatch space check.
+// Rows can only name the caller's own warehouse, and batches are stored as of the
+// import, so rent never accrues from before the goods arrived. Nothing is committed
+// when any row is invalid or when dryRun is set.
+func (r *ImportRepo) ImportBatches(ctx context.Context, userID, defaultWarehouseID uint, rows []models.ExcelProductRow, dryRun bool) (*models.ImportReport, error) {
+	db := dbconn.DB.WithContext(ctx)
+
+	report := &models.ImportReport{
+		DryRun:    dryRun,
+		TotalRows: len(rows),
+		Rows:      make([]models.ImportRowResult, len(rows)),
+	}
+	for i, row := range rows {
+		report.Rows[i] = models.ImportRowResult{
+			Row:           row.RowNumber,
+			ProductName:   row.ProductName,
+			SupplierName:  row.SupplierName,
+			WarehouseName: row.WarehouseName,
+			Quantity:      row.Quantity,
+			Errors:        append([]string(nil), row.Errors...),
+		}
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		ns := tx.NamingStrategy
+
+		warehouses := map[string]uint{}
+		suppliers := map[string]uint{}
+		products := map[string]uint{}
+		footprints := map[uint]models.Product{}
+		groups := map[importGroupKey]*importGroup{}
+		var order []importGroupKey
+
+		for i, row := range rows {
+			res := &report.Rows[i]
+			if len(res.Errors) > 0 {
+				continue
+			}
+
+			// Step 1️⃣: Resolve warehouse (blank → caller's warehouse, no other allowed)
+			warehouseID := defaultWarehouseID
+			if name := strings.TrimSpace(row.WarehouseName); name != "" {
+				key := strings.ToLower(name)
+				id, ok := warehouses[key]
+				if !ok {
+					var wh models.Warehouse
+					if err := tx.Table(ns.TableName("Warehouse")).
+						Where("LOWER(name) = ?", key).
+						First(&wh).Error; err != nil {
+						if errors.Is(err, gorm.ErrRecordNotFound) {
+							res.Errors = append(res.Errors, fmt.Sprintf("warehouse %q not found", name))
+							continue
+						}
+						return fmt.Errorf("failed to resolve warehouse %q: %w", name, err)
+					}
+					id = wh.ID
+					warehouses[key] = id
+				}
+				if id != defaultWarehouseID {
+					res.Errors = append(res.Errors, fmt.Sprintf("warehouse %q is not your warehouse", name))
+					continue
+				}
+			}
+
+			// Step 2️⃣: Resolve or create supplier
+			supplierName := strings.TrimSpace(row.SupplierName)
+			supplierKey := strings.ToLower(supplierName)
+			supplierID, ok := suppliers[supplierKey]
+			if !ok {
+				var supplier models.Supplier
+				err := tx.Table(ns.TableName("Supplier")).
+					Where("LOWER(name) = ?", supplierKey).
+					First(&supplier).Error
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					supplier = models.Supplier{Name: supplierName}
+					if err := tx.Table(ns.TableName("Supplier")).Create(&supplier).Error; err != nil {
+						return fmt.Errorf("failed to create supplier %q: %w", supplierName, err)
+					}
+					log.Printf("🛠 Import created supplier: ID=%d, Name=%s", supplier.ID, supplier.Name)
+				} else if err != nil {
+					return fmt.Errorf("failed to resolve supplier %q: %w", supplierName, err)
+				}
+				supplierID = supplier.ID
+				suppliers[supplierKey] = supplierID
+			}
+
+			// Step 3️⃣: Resolve or create product (per supplier)
+			productName := strings.TrimSpace(row.ProductName)
+			productKey := fmt.Sprintf("%d|%s", supplierID, strings.ToLower(productName))
+			productID, ok := products[productKey]
+			if !ok {
+				var product models.Product
+				err := tx.Table(ns.TableName("Product")).
+					Where("LOWER(name) = ? AND supplier_id = ?", strings.ToLower(productName), supplierID).
+					First(&product).Error
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					if row.StorageArea <= 0 {
+						res.Errors = append(res.Errors, "storage area is required to create a new product")
+						continue
+					}
+					product = models.Product{
+						Name:        productName,
+						SupplierID:  supplierID,
+						Category:    strings.TrimSpace(row.ProductCategory),
+						StorageArea: row.StorageArea,
+					}
+					if err := tx.Table(ns.TableName("Product")).Create(&product).Error; err != nil {
+						return fmt.Errorf("failed to create product %q: %w", productName, err)
+					}
+					log.Printf("🧩 Import created product: ID=%d, Name=%s, SupplierID=%d", product.ID, product.Name, supplierID)
+				} else if err != nil {
+					return fmt.Errorf("failed to resolve product %q: %w", productName, err)
+				}
+				productID = product.ID
+				products[productKey] = productID
+				footprints[productID] = product
+			}
+
+			// Step 4️⃣: Group into batches per warehouse + purchase date
+			purchaseDate := row.PurchaseDate
+			if purchaseDate.IsZero() {
+				purchaseDate = time.Now()
+			}
+			key := importGroupKey{WarehouseID: warehouseID, PurchaseDate: purchaseDate.Format("2006-01-02")}
+			group, ok := groups[key]
+			if !ok {
+				group = &importGroup{WarehouseID: warehouseID, PurchaseDate: purchaseDate}
+				groups[key] = group
+				order = append(order, key)
+			}
+			group.Rows = append(group.Rows, i)
+			group.Entries = append(group.Entries, models.BatchProductEntry{
+				ProductID:    productID,
+				BillingPrice: row.PurchasePrice,
+				Quantity:     row.Quantity,
+			})
+		}
+
+		sort.SliceStable(order, func(i, j int) bool {
+			if order[i].WarehouseID != order[j].WarehouseID {
+				return order[i].WarehouseID < order[j].WarehouseID
+			}
+			return order[i].PurchaseDate < order[j].PurchaseDate
+		})
+
+		// Step 5️⃣: Create batches through the same space check as AddBatch
+		for n, key := range order {
+			group := groups[key]
+			batch := models.Batch{
+				WarehouseID: group.WarehouseID,
+				StoredAt:    time.Now(),
+				Products:    group.Entries,
+			}
+
+			savepoint := fmt.Sprintf("import_group_%d", n)
+			tx.SavePoint(savepoint)
+			if err := addBatchTx(tx, userID, &batch); err != nil {
+				tx.RollbackTo(savepoint)
+				for _, idx := range group.Rows {
+					report.Rows[idx].Errors = append(report.Rows[idx].Errors, err.Error())
+				}
+				continue
+			}
+
+			summary := models.ImportBatchSummary{
+				BatchID:      batch.ID,
+				WarehouseID:  batch.WarehouseID,
+				PurchaseDate: group.PurchaseDate,
+			}
+			for _, idx := range group.Rows {
+				summary.Rows = append(summary.Rows, report.Rows[idx].Row)
+				report.Rows[idx].BatchID = batch.ID
+			}
+			// AddBatch has already sized every entry in the warehouse's unit
+			unit, err := warehouseCapacityUnit(tx, batch.WarehouseID)
+			if err != nil {
+				return err
+			}
+			for _, entry := range batch.Products {
+				space, err := spaceUsed(footprints[entry.ProductID], entry.Quantity, unit)
+				if err != nil {
+					return err
+				}
+				summary.RequiredArea += space
+			}
+			report.Batches = append(report.Batches, summary)
+		}
+
+		// Step 6️⃣: Build the per-row report
+		for i := range report.Rows {
+			if len(report.Rows[i].Errors) > 0 {
+				report.Rows[i].Status = "invalid"
+				report.Rows[i].BatchID = 0
+				report.Invalid++
+			} else {
+				report.Rows[i].Status = "valid"
+				report.ValidRows++
+			}
+		}
+
+		if report.Invalid > 0 || dryRun {
+			return errImportRollback
+		}
+		return nil
+	})
+
+	if err != nil && !errors.Is(err, errImportRollback) {
+		log.Printf("🔥 Import failed: %v", err)
+		return nil, err
+	}
+
+	if err == nil {
+		report.Committed = true
+		for i := range report.Rows {
+			report.Rows[i].Status = "imported"
+		}
+	} else {
+		// Nothing was persisted, so batch IDs from the rolled back transaction are meaningless
+		for i := range report.Batches {
+			report.Batches[i].BatchID = 0
+		}
+		for i := range report.Rows {
+			report.Rows[i].BatchID = 0
+		}
+	}
+
+	log.Printf("📥 Import finished: rows=%d valid=%d invalid=%d batches=%d dry_run=%v committed=%v",
+		report.TotalRows, report.ValidRows, report.Invalid, len(report.Batches), dryRun, report.Committed)
+	return report, nil
+}
@@ -0,0 +1,156 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+	"warehouse/allocation"
+	dbconn "warehouse/config/dbConn"
+	"warehouse/models"
+
+	"gorm.io/gorm"
+)
+
+type StockMovementRepo struct{}
+
+// NewStockMovementRepo initializes the stock ledger repository
+func NewStockMovementRepo() *StockMovementRepo {
+	return &StockMovementRepo{}
+}
+
+// changeStock adds delta (negative = out) to an entry's stock in a single guarded UPDATE,
+// so concurrent bills, transfers or adjustments can never take it below zero, and
+// returns the entry with its new balance. columns are set in the same statement.
+func changeStock(tx *gorm.DB, entryID uint, delta int, columns map[string]any) (models.BatchProductEntry, error) {
+	updates := map[string]any{"stock_quantity": gorm.Expr("stock_quantity + ?", delta)}
+	for k, v := range columns {
+		updates[k] = v
+	}
+
+	var entry models.BatchProductEntry
+	res := tx.Model(&models.BatchProductEntry{}).
+		Where("id = ? AND stock_quantity + ? >= 0", entryID, delta).
+		Updates(updates)
+	if res.Error != nil {
+		return entry, fmt.Errorf("failed to update stock: %w", res.Error)
+	}
+	if err := tx.Model(&models.BatchProductEntry{}).First(&entry, entryID).Error; err != nil {
+		return entry, fmt.Errorf("batch entry not found (ID=%d): %w", entryID, err)
+	}
+	if res.RowsAffected == 0 {
+		return entry, fmt.Errorf("%w for product %d in batch %d (available: %d, requested: %d)",
+			allocation.ErrInsufficientStock, entry.ProductID, entry.BatchID, entry.StockQuantity, -delta)
+	}
+	return entry, nil
+}
+
+// newStockMovement builds a ledger row for a change already applied to entry.
+// qty is signed (positive = inbound) and entry.StockQuantity must hold the new balance.
+func newStockMovement(entry models.BatchProductEntry, warehouseID uint, qty int, reason, sourceType string, userID uint) models.StockMovement {
+	return models.StockMovement{
+		WarehouseID:  warehouseID,
+		ProductID:    entry.ProductID,
+		BatchID:      entry.BatchID,
+		EntryID:      entry.ID,
+		Quantity:     qty,
+		BalanceAfter: entry.StockQuantity,
+		Reason:       reason,
+		SourceType:   sourceType,
+		UserID:       userID,
+	}
+}
+
+// recordStockMovements links movements to their source document and appends them to the ledger
+func recordStockMovements(tx *gorm.DB, sourceID uint, movements []models.StockMovement) error {
+	ns := tx.NamingStrategy
+	for i := range movements {
+		movements[i].SourceID = sourceID
+		if err := tx.Table(ns.TableName("StockMovement")).Create(&movements[i]).Error; err != nil {
+			return fmt.Errorf("failed to record stock movement: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetProductMovements lists the ledger rows of a product in a warehouse, oldest first.
+// Zero from/to values leave that side of the date range open.
+func (r *StockMovementRepo) GetProductMovements(ctx context.Context, warehouseId, productId uint, from, to time.Time) ([]models.StockMovement, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	query := db.Table(ns.TableName("StockMovement")).
+		Where("warehouse_id = ? AND product_id = ?", warehouseId, productId)
+	if !from.IsZero() {
+		query = query.Where("created_at >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("created_at < ?", to)
+	}
+
+	var movements []models.StockMovement
+	if err := query.Order("created_at ASC, id ASC").Find(&movements).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch stock movements: %w", err)
+	}
+	return movements, nil
+}
+
+// CheckConsistency replays the ledger of every batch entry in the warehouse and reports
+// entries whose summed movements differ from the stored StockQuantity.
+func (r *StockMovementRepo) CheckConsistency(ctx context.Context, warehouseId uint) (*models.StockConsistencyReport, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	var rows []struct {
+		EntryID       uint
+		BatchID       uint
+		ProductID     uint
+		StockQuantity int
+		LedgerBalance int
+		Movements     int
+	}
+	err := db.Table(ns.TableName("BatchProductEntry")+" AS be").
+		Select(`
+			be.id AS entry_id,
+			be.batch_id,
+			be.product_id,
+			be.stock_quantity,
+			COALESCE(SUM(sm.quantity), 0) AS ledger_balance,
+			COUNT(sm.id) AS movements
+		`).
+		Joins("JOIN "+ns.TableName("Batch")+" AS b ON b.id = be.batch_id").
+		Joins("LEFT JOIN "+ns.TableName("StockMovement")+" AS sm ON sm.entry_id = be.id").
+		Where("b.warehouse_id = ?", warehouseId).
+		Group("be.id, be.batch_id, be.product_id, be.stock_quantity").
+		Order("be.id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to replay stock ledger: %w", err)
+	}
+
+	report := &models.StockConsistencyReport{
+		WarehouseID:    warehouseId,
+		CheckedEntries: len(rows),
+		Issues:         []models.StockConsistencyIssue{},
+	}
+	for _, row := range rows {
+		if row.LedgerBalance == row.StockQuantity {
+			continue
+		}
+		report.Issues = append(report.Issues, models.StockConsistencyIssue{
+			EntryID:       row.EntryID,
+			BatchID:       row.BatchID,
+			ProductID:     row.ProductID,
+			StockQuantity: row.StockQuantity,
+			LedgerBalance: row.LedgerBalance,
+			Difference:    row.StockQuantity - row.LedgerBalance,
+			Movements:     row.Movements,
+		})
+	}
+	report.Consistent = len(report.Issues) == 0
+
+	if !report.Consistent {
+		log.Printf("⚠️ Stock ledger mismatch in warehouse %d: %d of %d entries", warehouseId, len(report.Issues), report.CheckedEntries)
+	}
+	return report, nil
+}
@@ -0,0 +1,223 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	dbconn "warehouse/config/dbConn"
+	"warehouse/fx"
+	"warehouse/models"
+	"warehouse/money"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+type PaymentRepo struct{}
+
+// NewPaymentRepo initializes the payments repository
+func NewPaymentRepo() *PaymentRepo {
+	return &PaymentRepo{}
+}
+
+// ErrOverAllocation is returned when a payment is applied beyond its amount or a bill's balance
+var ErrOverAllocation = errors.New("payment allocation exceeds the open amount")
+
+// billOutstanding is what is still owed on a bill (invoice value incl. GST) after credit
+// notes and payments
+func billOutstanding(invoiceTotal, credited, paid decimal.Decimal) decimal.Decimal {
+	return decimal.Max(invoiceTotal.Sub(credited).Sub(paid), decimal.Zero)
+}
+
+// paymentStatus derives a bill's payment state. A bill past its due date with money
+// still owed is overdue, whether or not part of it was paid.
+func paymentStatus(invoiceTotal, credited, paid decimal.Decimal, dueDate *time.Time, now time.Time) string {
+	if !billOutstanding(invoiceTotal, credited, paid).IsPositive() {
+		return models.PaymentPaid
+	}
+	if dueDate != nil && now.After(*dueDate) {
+		return models.PaymentOverdue
+	}
+	if paid.IsPositive() {
+		return models.PaymentPartial
+	}
+	return models.PaymentUnpaid
+}
+
+// billDueDate is the requested due date, or the bill date plus the customer's credit days
+func billDueDate(billDate time.Time, customer *models.Customer, requested *time.Time) time.Time {
+	if requested != nil {
+		return *requested
+	}
+	days := customer.CreditDays
+	if days <= 0 {
+		days = models.DefaultCreditDays
+	}
+	return billDate.AddDate(0, 0, days)
+}
+
+// RecordPayment stores a payment from a customer and applies it to their bills in the
+// caller's warehouse. Explicit allocations are validated against each bill's balance;
+// without them the payment settles open bills oldest due date first. A payment only
+// settles bills in its own currency; the difference between the bill's booked rate and
+// the rate on the payment date is recorded as realised FX gain or loss.
+func (r *PaymentRepo) RecordPayment(ctx context.Context, warehouseId, userID uint, input models.PaymentInput, paidAt time.Time) (*models.Payment, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	payment := models.Payment{
+		WarehouseID: warehouseId,
+		CustomerID:  input.CustomerID,
+		Amount:      money.Round(input.Amount),
+		Method:      input.Method,
+		Reference:   input.Reference,
+		PaidAt:      paidAt,
+		Notes:       input.Notes,
+		CreatedBy:   userID,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		customer, err := loadCustomer(tx, input.CustomerID)
+		if err != nil {
+			return err
+		}
+		conv, err := newBillFX(tx, warehouseId, customer, input.Currency, paidAt)
+		if err != nil {
+			return err
+		}
+		payment.Currency, payment.ExchangeRate = conv.currency, conv.rate
+
+		remaining := payment.Amount
+		allocate := func(bill models.Billing, amount decimal.Decimal) error {
+			amount = money.Round(amount)
+			open := billOutstanding(bill.InvoiceTotal, bill.CreditedAmount, bill.PaidAmount)
+			if amount.GreaterThan(open) {
+				return fmt.Errorf("%w: bill %d has %s open, %s requested", ErrOverAllocation, bill.ID, open.StringFixed(2), amount.StringFixed(2))
+			}
+			if amount.GreaterThan(remaining) {
+				return fmt.Errorf("%w: only %s of the payment is left to allocate", ErrOverAllocation, remaining.StringFixed(2))
+			}
+			remaining = remaining.Sub(amount)
+			gainLoss := money.Round(fx.GainLoss(amount, bill.ExchangeRate, payment.ExchangeRate))
+			payment.FXGainLoss = payment.FXGainLoss.Add(gainLoss)
+			payment.Allocations = append(payment.Allocations, models.PaymentAllocation{BillingID: bill.ID, Amount: amount, FXGainLoss: gainLoss})
+			return tx.Table(ns.TableName("Billing")).
+				Where("id = ?", bill.ID).
+				Update("paid_amount", gorm.Expr("paid_amount + ?", amount)).Error
+		}
+
+		// Bills are locked before their open amount is checked, so concurrent payments or a
+		// reversal cannot both pass the check and overpay a bill
+		if len(input.Allocations) > 0 {
+			// Lock every named bill up front in id order, so two payments naming the same
+			// bills in a different order do not deadlock
+			billIDs := make([]uint, 0, len(input.Allocations))
+			for _, in := range input.Allocations {
+				billIDs = append(billIDs, in.BillingID)
+			}
+			var locked []uint
+			if err := forUpdate(tx).Table(ns.TableName("Billing")).
+				Where("id IN ?", billIDs).
+				Order("id ASC").
+				Pluck("id", &locked).Error; err != nil {
+				return fmt.Errorf("failed to lock bills: %w", err)
+			}
+
+			for _, in := range input.Allocations {
+				var bill models.Billing
+				if err := forUpdate(tx).Table(ns.TableName("Billing")).First(&bill, in.BillingID).Error; err != nil {
+					return fmt.Errorf("bill not found (ID=%d): %w", in.BillingID, err)
+				}
+				if bill.WarehouseID != warehouseId {
+					return fmt.Errorf("%w: bill %d belongs to warehouse %d", ErrWarehouseMismatch, bill.ID, bill.WarehouseID)
+				}
+				if bill.CustomerID != input.CustomerID {
+					return fmt.Errorf("bill %d belongs to customer %d, not %d", bill.ID, bill.CustomerID, input.CustomerID)
+				}
+				if bill.Currency != payment.Currency {
+					return fmt.Errorf("%w: bill %d is in %s, the payment in %s", ErrCurrencyMismatch, bill.ID, bill.Currency, payment.Currency)
+				}
+				if err := allocate(bill, in.Amount); err != nil {
+					return err
+				}
+			}
+		} else {
+			var bills []models.Billing
+			if err := forUpdate(tx).Table(ns.TableName("Billing")).
+				Where("warehouse_id = ? AND customer_id = ? AND currency = ?", warehouseId, input.CustomerID, payment.Currency).
+				Where("invoice_total - credited_amount - paid_amount > 0").
+				Order("due_date ASC NULLS LAST, created_at ASC, id ASC").
+				Find(&bills).Error; err != nil {
+				return fmt.Errorf("failed to load open bills: %w", err)
+			}
+			for _, bill := range bills {
+				if !remaining.IsPositive() {
+					break
+				}
+				amount := decimal.Min(remaining, billOutstanding(bill.InvoiceTotal, bill.CreditedAmount, bill.PaidAmount))
+				if err := allocate(bill, amount); err != nil {
+					return err
+				}
+			}
+		}
+
+		payment.UnallocatedAmount = decimal.Max(remaining, decimal.Zero)
+		if err := tx.Table(ns.TableName("Payment")).Create(&payment).Error; err != nil {
+			return fmt.Errorf("failed to record payment: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("❌ Payment from customer %d failed: %v", input.CustomerID, err)
+		return nil, err
+	}
+
+	log.Printf("💵 Payment %d of %s %s from customer %d applied to %d bills (%s on account, FX %s)",
+		payment.ID, payment.Amount.StringFixed(2), payment.Currency, payment.CustomerID, len(payment.Allocations),
+		payment.UnallocatedAmount.StringFixed(2), payment.FXGainLoss.StringFixed(2))
+	return &payment, nil
+}
+
+// GetByID fetches a payment of the warehouse with its allocations
+func (r *PaymentRepo) GetByID(ctx context.Context, warehouseId, paymentID uint) (*models.Payment, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	var payment models.Payment
+	if err := db.Table(ns.TableName("Payment")).
+		Preload("Allocations").
+		First(&payment, paymentID).Error; err != nil {
+		return nil, fmt.Errorf("payment not found (ID=%d): %w", paymentID, err)
+	}
+	if payment.WarehouseID != warehouseId {
+		return nil, fmt.Errorf("%w: payment %d belongs to warehouse %d", ErrWarehouseMismatch, paymentID, payment.WarehouseID)
+	}
+	return &payment, nil
+}
+
+// GetAll lists the warehouse's payments, optionally for one customer and paid in [from, to)
+func (r *PaymentRepo) GetAll(ctx context.Context, warehouseId, customerID uint, from, to time.Time) ([]models.Payment, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	query := db.Table(ns.TableName("Payment")).
+		Preload("Allocations").
+		Where("warehouse_id = ?", warehouseId)
+	if customerID != 0 {
+		query = query.Where("customer_id = ?", customerID)
+	}
+	if !from.IsZero() {
+		query = query.Where("paid_at >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("paid_at < ?", to)
+	}
+
+	var payments []models.Payment
+	if err := query.Order("paid_at DESC, id DESC").Find(&payments).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch payments: %w", err)
+	}
+	return payments, nil
+}
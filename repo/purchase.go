@@ -0,0 +1,406 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	dbconn "warehouse/config/dbConn"
+	"warehouse/models"
+	"warehouse/money"
+
+	"gorm.io/gorm"
+)
+
+type PurchaseRepo struct{}
+
+// NewPurchaseRepo initializes the purchase order and goods receipt repository
+func NewPurchaseRepo() *PurchaseRepo {
+	return &PurchaseRepo{}
+}
+
+// ErrInvalidPurchaseOrderState is returned when a purchase order step is not allowed in
+// the current status
+var ErrInvalidPurchaseOrderState = errors.New("invalid purchase order state")
+
+// CreateOrder raises a purchase order on a supplier for the caller's warehouse. Every
+// product must be one the supplier supplies.
+func (r *PurchaseRepo) CreateOrder(ctx context.Context, warehouseId, userID uint, input models.PurchaseOrderInput) (*models.PurchaseOrder, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	order := models.PurchaseOrder{
+		WarehouseID:  warehouseId,
+		SupplierID:   input.SupplierID,
+		Status:       models.PurchaseOrderOpen,
+		ExpectedDate: input.ExpectedDate,
+		Notes:        input.Notes,
+		CreatedBy:    userID,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		reporting, err := reportingCurrency(tx, warehouseId)
+		if err != nil {
+			return err
+		}
+		if order.Currency, err = documentCurrency(input.Currency, reporting); err != nil {
+			return err
+		}
+
+		var supplier models.Supplier
+		if err := tx.Table(ns.TableName("Supplier")).First(&supplier, input.SupplierID).Error; err != nil {
+			return fmt.Errorf("supplier not found (ID=%d): %w", input.SupplierID, err)
+		}
+
+		seen := map[uint]bool{}
+		for _, in := range input.Items {
+			if seen[in.ProductID] {
+				return fmt.Errorf("product %d listed more than once", in.ProductID)
+			}
+			seen[in.ProductID] = true
+
+			var product models.Product
+			if err := tx.Table(ns.TableName("Product")).First(&product, in.ProductID).Error; err != nil {
+				return fmt.Errorf("product not found (ID=%d): %w", in.ProductID, err)
+			}
+			if product.SupplierID != input.SupplierID {
+				return fmt.Errorf("product %d is supplied by supplier %d, not %d", product.ID, product.SupplierID, input.SupplierID)
+			}
+
+			order.TotalValue = order.TotalValue.Add(money.Round(in.UnitPrice.Mul(money.Qty(in.Quantity))))
+			order.Items = append(order.Items, models.PurchaseOrderItem{
+				ProductID:  in.ProductID,
+				OrderedQty: in.Quantity,
+				UnitPrice:  in.UnitPrice,
+			})
+		}
+
+		if err := tx.Table(ns.TableName("PurchaseOrder")).Create(&order).Error; err != nil {
+			return fmt.Errorf("failed to create purchase order: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("❌ Purchase order creation failed: %v", err)
+		return nil, err
+	}
+
+	log.Printf("📝 Purchase order %d raised on supplier %d (%d items, %s %s)", order.ID, order.SupplierID, len(order.Items), order.TotalValue.StringFixed(2), order.Currency)
+	return &order, nil
+}
+
+// Receive books a delivery against an open purchase order. Accepted units (received
+// less rejected) become one new batch through the same intake path as AddBatch, at the
+// agreed prices. Each line records what was short of or in excess of the quantity still
+// due; the order closes once every line has been fully received.
+func (r *PurchaseRepo) Receive(ctx context.Context, warehouseId, orderID, userID uint, input models.GoodsReceiptInput) (*models.GoodsReceipt, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	receipt := models.GoodsReceipt{
+		PurchaseOrderID: orderID,
+		WarehouseID:     warehouseId,
+		Notes:           input.Notes,
+		ReceivedBy:      userID,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		order, err := loadPurchaseOrder(forUpdate(tx), orderID)
+		if err != nil {
+			return err
+		}
+		if order.WarehouseID != warehouseId {
+			return fmt.Errorf("%w: purchase order %d belongs to warehouse %d", ErrWarehouseMismatch, orderID, order.WarehouseID)
+		}
+		if order.Status != models.PurchaseOrderOpen && order.Status != models.PurchaseOrderPartial {
+			return fmt.Errorf("%w: goods can only be received against open orders (status=%s)", ErrInvalidPurchaseOrderState, order.Status)
+		}
+
+		itemsByID := make(map[uint]*models.PurchaseOrderItem, len(order.Items))
+		for i := range order.Items {
+			itemsByID[order.Items[i].ID] = &order.Items[i]
+		}
+
+		// Step 1️⃣: Compare each delivered line with what is still due
+		batch := models.Batch{WarehouseID: warehouseId, Currency: order.Currency, Expenses: input.Expenses}
+		seen := map[uint]bool{}
+		for _, in := range input.Items {
+			item, ok := itemsByID[in.PurchaseOrderItemID]
+			if !ok {
+				return fmt.Errorf("item %d does not belong to purchase order %d", in.PurchaseOrderItemID, orderID)
+			}
+			if seen[item.ID] {
+				return fmt.Errorf("purchase order item %d listed more than once", item.ID)
+			}
+			seen[item.ID] = true
+			if in.RejectedQty > in.ReceivedQty {
+				return fmt.Errorf("rejected quantity %d exceeds received quantity %d for item %d", in.RejectedQty, in.ReceivedQty, item.ID)
+			}
+
+			due := item.OrderedQty - item.ReceivedQty
+			if due < 0 {
+				due = 0
+			}
+			line := models.GoodsReceiptItem{
+				PurchaseOrderItemID: item.ID,
+				ProductID:           item.ProductID,
+				DueQty:              due,
+				ReceivedQty:         in.ReceivedQty,
+				RejectedQty:         in.RejectedQty,
+				AcceptedQty:         in.ReceivedQty - in.RejectedQty,
+				RejectionReason:     in.RejectionReason,
+				LotNumber:           in.LotNumber,
+				ManufacturedAt:      in.ManufacturedAt,
+				ExpiresAt:           in.ExpiresAt,
+			}
+			if line.AcceptedQty < due {
+				line.ShortQty = due - line.AcceptedQty
+			} else {
+				line.ExcessQty = line.AcceptedQty - due
+			}
+			receipt.Items = append(receipt.Items, line)
+
+			item.ReceivedQty += line.AcceptedQty
+			item.RejectedQty += line.RejectedQty
+			item.ExcessQty += line.ExcessQty
+
+			if line.AcceptedQty > 0 {
+				batch.Products = append(batch.Products, models.BatchProductEntry{
+					ProductID:      item.ProductID,
+					BillingPrice:   item.UnitPrice,
+					Quantity:       line.AcceptedQty,
+					LotNumber:      in.LotNumber,
+					ManufacturedAt: in.ManufacturedAt,
+					ExpiresAt:      in.ExpiresAt,
+				})
+			}
+		}
+
+		received := 0
+		for _, line := range receipt.Items {
+			received += line.ReceivedQty
+		}
+		if received == 0 {
+			return fmt.Errorf("goods receipt has no received quantity")
+		}
+
+		// Step 2️⃣: Accepted stock becomes a batch (space is checked there)
+		if len(batch.Products) > 0 {
+			if err := addBatchTx(tx, userID, &batch); err != nil {
+				return err
+			}
+			receipt.BatchID = &batch.ID
+		} else if len(input.Expenses) > 0 {
+			return fmt.Errorf("onboarding expenses need at least one accepted item")
+		}
+
+		if err := tx.Table(ns.TableName("GoodsReceipt")).Create(&receipt).Error; err != nil {
+			return fmt.Errorf("failed to create goods receipt: %w", err)
+		}
+
+		// Step 3️⃣: Update the order lines and close the order once nothing is due
+		fullyReceived := true
+		for _, item := range order.Items {
+			if seen[item.ID] {
+				if err := tx.Table(ns.TableName("PurchaseOrderItem")).
+					Where("id = ?", item.ID).
+					Updates(map[string]any{
+						"received_qty": item.ReceivedQty,
+						"rejected_qty": item.RejectedQty,
+						"excess_qty":   item.ExcessQty,
+					}).Error; err != nil {
+					return fmt.Errorf("failed to update purchase order item %d: %w", item.ID, err)
+				}
+			}
+			if item.ReceivedQty < item.OrderedQty {
+				fullyReceived = false
+			}
+		}
+
+		update := map[string]any{"status": models.PurchaseOrderPartial}
+		if fullyReceived {
+			update = map[string]any{
+				"status":       models.PurchaseOrderClosed,
+				"closed_by":    userID,
+				"closed_at":    time.Now(),
+				"close_reason": "fully received",
+			}
+		}
+		return tx.Table(ns.TableName("PurchaseOrder")).
+			Where("id = ?", order.ID).
+			Updates(update).Error
+	})
+	if err != nil {
+		log.Printf("❌ Goods receipt for purchase order %d failed: %v", orderID, err)
+		return nil, err
+	}
+
+	log.Printf("📦 Goods receipt %d booked against purchase order %d", receipt.ID, orderID)
+	return &receipt, nil
+}
+
+// Close ends an open order that will not be delivered in full (short close). Orders with
+// nothing received yet are cancelled instead.
+func (r *PurchaseRepo) Close(ctx context.Context, warehouseId, orderID, userID uint, reason string) (*models.PurchaseOrder, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		order, err := loadPurchaseOrder(forUpdate(tx), orderID)
+		if err != nil {
+			return err
+		}
+		if order.WarehouseID != warehouseId {
+			return fmt.Errorf("%w: purchase order %d belongs to warehouse %d", ErrWarehouseMismatch, orderID, order.WarehouseID)
+		}
+		if order.Status != models.PurchaseOrderOpen && order.Status != models.PurchaseOrderPartial {
+			return fmt.Errorf("%w: only open orders can be closed (status=%s)", ErrInvalidPurchaseOrderState, order.Status)
+		}
+
+		status := models.PurchaseOrderClosed
+		if order.Status == models.PurchaseOrderOpen {
+			status = models.PurchaseOrderCancelled
+		}
+		return tx.Table(ns.TableName("PurchaseOrder")).
+			Where("id = ?", order.ID).
+			Updates(map[string]any{
+				"status":       status,
+				"closed_by":    userID,
+				"closed_at":    time.Now(),
+				"close_reason": reason,
+			}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("🔒 Purchase order %d closed by user %d", orderID, userID)
+	return r.GetByID(ctx, warehouseId, orderID)
+}
+
+// GetByID returns a purchase order of the caller's warehouse
+func (r *PurchaseRepo) GetByID(ctx context.Context, warehouseId, orderID uint) (*models.PurchaseOrder, error) {
+	db := dbconn.DB.WithContext(ctx)
+
+	order, err := loadPurchaseOrder(db, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if order.WarehouseID != warehouseId {
+		return nil, fmt.Errorf("%w: purchase order %d belongs to warehouse %d", ErrWarehouseMismatch, orderID, order.WarehouseID)
+	}
+	return order, nil
+}
+
+// GetAll lists the warehouse's purchase orders, optionally by supplier and status
+func (r *PurchaseRepo) GetAll(ctx context.Context, warehouseId, supplierID uint, status string) ([]models.PurchaseOrder, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	query := db.Table(ns.TableName("PurchaseOrder")).
+		Preload("Items").
+		Where("warehouse_id = ?", warehouseId)
+	if supplierID != 0 {
+		query = query.Where("supplier_id = ?", supplierID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var orders []models.PurchaseOrder
+	if err := query.Order("created_at DESC").Find(&orders).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch purchase orders: %w", err)
+	}
+	return orders, nil
+}
+
+// GetReceipts lists the goods receipts booked against a purchase order
+func (r *PurchaseRepo) GetReceipts(ctx context.Context, warehouseId, orderID uint) ([]models.GoodsReceipt, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	if _, err := r.GetByID(ctx, warehouseId, orderID); err != nil {
+		return nil, err
+	}
+
+	var receipts []models.GoodsReceipt
+	if err := db.Table(ns.TableName("GoodsReceipt")).
+		Preload("Items").
+		Where("purchase_order_id = ?", orderID).
+		Order("created_at ASC").
+		Find(&receipts).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch goods receipts for purchase order %d: %w", orderID, err)
+	}
+	return receipts, nil
+}
+
+// GetOpenOrdersBySupplier reports, per supplier, the orders still awaiting delivery and
+// the quantity and value outstanding on them. A non-zero supplierID limits the report.
+func (r *PurchaseRepo) GetOpenOrdersBySupplier(ctx context.Context, warehouseId, supplierID uint) ([]models.OpenPurchaseOrders, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	query := db.Table(ns.TableName("PurchaseOrder")).
+		Preload("Items").
+		Where("warehouse_id = ? AND status IN ?", warehouseId, []string{models.PurchaseOrderOpen, models.PurchaseOrderPartial})
+	if supplierID != 0 {
+		query = query.Where("supplier_id = ?", supplierID)
+	}
+	var orders []models.PurchaseOrder
+	if err := query.Order("supplier_id, expected_date ASC NULLS LAST, id").Find(&orders).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch open purchase orders: %w", err)
+	}
+
+	var suppliers []models.Supplier
+	if err := db.Table(ns.TableName("Supplier")).Unscoped().Find(&suppliers).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch suppliers: %w", err)
+	}
+	names := make(map[uint]string, len(suppliers))
+	for _, s := range suppliers {
+		names[s.ID] = s.Name
+	}
+
+	now := time.Now()
+	report := []models.OpenPurchaseOrders{}
+	index := map[uint]int{}
+	for _, order := range orders {
+		i, ok := index[order.SupplierID]
+		if !ok {
+			i = len(report)
+			index[order.SupplierID] = i
+			report = append(report, models.OpenPurchaseOrders{SupplierID: order.SupplierID, SupplierName: names[order.SupplierID]})
+		}
+		row := &report[i]
+		row.OpenOrders++
+		if order.ExpectedDate != nil && now.After(*order.ExpectedDate) {
+			row.OverdueOrders++
+		}
+		for _, item := range order.Items {
+			pending := item.OrderedQty - item.ReceivedQty
+			if pending < 0 {
+				pending = 0
+			}
+			row.OrderedQty += item.OrderedQty
+			row.ReceivedQty += item.ReceivedQty
+			row.PendingQty += pending
+			row.PendingValue = row.PendingValue.Add(money.Round(item.UnitPrice.Mul(money.Qty(pending))))
+		}
+		row.Orders = append(row.Orders, order)
+	}
+
+	log.Printf("📝 Open purchase orders for Warehouse %d: %d orders across %d suppliers", warehouseId, len(orders), len(report))
+	return report, nil
+}
+
+func loadPurchaseOrder(db *gorm.DB, orderID uint) (*models.PurchaseOrder, error) {
+	ns := db.NamingStrategy
+
+	var order models.PurchaseOrder
+	if err := db.Table(ns.TableName("PurchaseOrder")).
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		First(&order, orderID).Error; err != nil {
+		return nil, fmt.Errorf("purchase order not found (ID=%d): %w", orderID, err)
+	}
+	return &order, nil
+}
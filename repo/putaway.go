@@ -0,0 +1,289 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+	"warehouse/models"
+	"warehouse/putaway"
+
+	"gorm.io/gorm"
+)
+
+// putawayPlanner suggests bins for goods arriving in a warehouse and puts them there. It
+// plans against the bins as they were when it was made, plus what it placed since, so
+// one planner serves all the lines of a batch or transfer.
+type putawayPlanner struct {
+	tx          *gorm.DB
+	warehouseID uint
+	binned      bool
+	unit        string
+	layout      *putaway.Layout
+	movers      map[uint]string // movement class per product, loaded on first use
+}
+
+func newPutawayPlanner(tx *gorm.DB, warehouseID uint) (*putawayPlanner, error) {
+	p := &putawayPlanner{tx: tx, warehouseID: warehouseID}
+
+	binned, err := warehouseHasBins(tx, warehouseID)
+	if err != nil || !binned {
+		return p, err
+	}
+	p.binned = true
+	if p.unit, err = warehouseCapacityUnit(tx, warehouseID); err != nil {
+		return nil, err
+	}
+	bins, limits, err := putawayLayout(tx, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	p.layout = putaway.NewLayout(bins, limits)
+	return p, nil
+}
+
+// movement is the product's movement class from the warehouse's product analytics
+func (p *putawayPlanner) movement(productID uint) string {
+	if p.movers == nil {
+		p.movers = movementClasses(p.warehouseID)
+	}
+	return p.movers[productID]
+}
+
+// suggest plans qty units of a product: the requested bins first, the rest where the
+// putaway engine proposes. Nothing is written.
+func (p *putawayPlanner) suggest(product models.Product, qty int, requested []models.Putaway) ([]models.Putaway, error) {
+	if !p.binned {
+		if len(requested) > 0 {
+			return nil, fmt.Errorf("%w: warehouse %d has no bins", ErrInvalidLocation, p.warehouseID)
+		}
+		return nil, nil
+	}
+	perUnit, err := spaceUsed(product, 1, p.unit)
+	if err != nil {
+		return nil, err
+	}
+	item := putaway.Item{ProductID: product.ID, Category: product.Category, SpacePerUnit: perUnit}
+
+	var plan []models.Putaway
+	for _, r := range requested {
+		if r.Quantity <= 0 || r.Quantity > qty {
+			return nil, fmt.Errorf("%w: cannot put away %d of the %d units left of product %d", ErrInvalidLocation, r.Quantity, qty, product.ID)
+		}
+		bin, err := loadLocation(p.tx, p.warehouseID, r.LocationID)
+		if err != nil {
+			return nil, err
+		}
+		if bin.Level != models.LevelBin {
+			return nil, fmt.Errorf("%w: stock goes into bins, %s is a %s", ErrInvalidLocation, bin.Code, bin.Level)
+		}
+		placed, err := p.layout.Take(bin.ID, item, r.Quantity)
+		if err != nil {
+			return nil, noRoom(err)
+		}
+		plan = append(plan, putawayOf(placed))
+		qty -= r.Quantity
+	}
+
+	if qty > 0 {
+		item.Quantity = qty
+		item.Movement = p.movement(product.ID)
+		placements, err := p.layout.Place(item)
+		if err != nil {
+			return nil, noRoom(err)
+		}
+		for _, placed := range placements {
+			plan = append(plan, putawayOf(placed))
+		}
+	}
+	return plan, nil
+}
+
+// putAway places units of an entry that just arrived: the requested bins first, the rest
+// as suggested. It returns where the units went; warehouses without bins return nothing.
+func (p *putawayPlanner) putAway(entry models.BatchProductEntry, product models.Product, qty int, requested []models.Putaway) ([]models.Putaway, error) {
+	plan, err := p.suggest(product, qty, requested)
+	if err != nil || len(plan) == 0 {
+		return nil, err
+	}
+	perUnit, err := spaceUsed(product, 1, p.unit)
+	if err != nil {
+		return nil, err
+	}
+	for _, placed := range plan {
+		bin, err := loadLocation(p.tx, p.warehouseID, placed.LocationID)
+		if err != nil {
+			return nil, err
+		}
+		if err := placeInBin(p.tx, *bin, entry, placed.Quantity, perUnit); err != nil {
+			return nil, err
+		}
+	}
+	if err := syncWarehouseArea(p.tx, p.warehouseID); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// putAway places units of a single entry that arrived in a warehouse; see
+// putawayPlanner.putAway
+func putAway(tx *gorm.DB, warehouseID uint, entry models.BatchProductEntry, product models.Product, qty int, requested []models.Putaway) ([]models.Putaway, error) {
+	planner, err := newPutawayPlanner(tx, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	return planner.putAway(entry, product, qty, requested)
+}
+
+// putawayLayout loads the warehouse's bins for the putaway engine: their free space,
+// distance from dispatch and what they hold, and the free space of the zones, aisles and
+// racks around them that have a capacity of their own
+func putawayLayout(tx *gorm.DB, warehouseID uint) ([]putaway.Bin, map[uint]float64, error) {
+	ns := tx.NamingStrategy
+
+	var locations []models.Location
+	if err := tx.Table(ns.TableName("Location")).
+		Where("warehouse_id = ?", warehouseID).
+		Find(&locations).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to load locations: %w", err)
+	}
+
+	var stock []struct {
+		LocationID uint
+		ProductID  uint
+		Category   string
+	}
+	if err := tx.Table(ns.TableName("LocationStock")+" AS ls").
+		Select("DISTINCT ls.location_id, ls.product_id, p.category").
+		Joins("JOIN "+ns.TableName("Product")+" AS p ON p.id = ls.product_id").
+		Where("ls.warehouse_id = ? AND ls.quantity > 0", warehouseID).
+		Scan(&stock).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to load location stock: %w", err)
+	}
+	products := map[uint]map[uint]bool{}
+	categories := map[uint]map[string]bool{}
+	for _, s := range stock {
+		if products[s.LocationID] == nil {
+			products[s.LocationID] = map[uint]bool{}
+			categories[s.LocationID] = map[string]bool{}
+		}
+		products[s.LocationID][s.ProductID] = true
+		if s.Category != "" {
+			categories[s.LocationID][s.Category] = true
+		}
+	}
+
+	byID := make(map[uint]models.Location, len(locations))
+	for _, l := range locations {
+		byID[l.ID] = l
+	}
+
+	var bins []putaway.Bin
+	limits := map[uint]float64{}
+	for _, l := range locations {
+		if l.Level != models.LevelBin {
+			continue
+		}
+		bin := putaway.Bin{
+			ID:         l.ID,
+			Code:       l.Code,
+			Free:       max(l.Capacity-l.UsedCapacity, 0),
+			Distance:   l.DispatchDistance,
+			Products:   products[l.ID],
+			Categories: categories[l.ID],
+		}
+		// A bin without a distance of its own is as far as its nearest parent with one
+		for parentID := l.ParentID; parentID != nil; {
+			parent, ok := byID[*parentID]
+			if !ok {
+				break
+			}
+			if bin.Distance == 0 {
+				bin.Distance = parent.DispatchDistance
+			}
+			if parent.Capacity > 0 {
+				bin.Limits = append(bin.Limits, parent.ID)
+				limits[parent.ID] = max(parent.Capacity-parent.UsedCapacity, 0)
+			}
+			parentID = parent.ParentID
+		}
+		bins = append(bins, bin)
+	}
+	return bins, limits, nil
+}
+
+// movementTTL is how long a warehouse's movement classes are reused before they are
+// refreshed
+const movementTTL = time.Hour
+
+// movementCache keeps each warehouse's fast and slow movers. The product analytics
+// behind them is heavy, so it is refreshed in the background, outside any putaway's
+// transaction; until the first refresh completes every product moves normally.
+var movementCache = struct {
+	sync.Mutex
+	warehouses map[uint]*movementClassSet
+}{warehouses: map[uint]*movementClassSet{}}
+
+type movementClassSet struct {
+	classes    map[uint]string // replaced, never changed, so readers need no lock
+	loadedAt   time.Time
+	refreshing bool
+}
+
+// movementClasses returns the warehouse's cached movement classes, starting a refresh
+// when they are missing or older than movementTTL
+func movementClasses(warehouseID uint) map[uint]string {
+	movementCache.Lock()
+	defer movementCache.Unlock()
+
+	set := movementCache.warehouses[warehouseID]
+	if set == nil {
+		set = &movementClassSet{classes: map[uint]string{}}
+		movementCache.warehouses[warehouseID] = set
+	}
+	if time.Since(set.loadedAt) > movementTTL && !set.refreshing {
+		set.refreshing = true
+		go refreshMovementClasses(warehouseID)
+	}
+	return set.classes
+}
+
+// refreshMovementClasses marks the warehouse's fast and slow movers from the product
+// analytics. On failure the previous classes are kept until the next refresh.
+func refreshMovementClasses(warehouseID uint) {
+	classes := map[uint]string{}
+	analytics, err := NewAnalyticsRepo().GetFastAndSlowMovingProductAnalytics(context.Background(), warehouseID)
+	if err != nil {
+		log.Printf("⚠️ Movement classes of warehouse %d not refreshed: %v", warehouseID, err)
+	} else {
+		for _, p := range analytics["slow_products"] {
+			classes[p.ProductInfo.ID] = putaway.Slow
+		}
+		for _, p := range analytics["fast_products"] {
+			classes[p.ProductInfo.ID] = putaway.Fast
+		}
+	}
+
+	movementCache.Lock()
+	defer movementCache.Unlock()
+	set := movementCache.warehouses[warehouseID]
+	set.refreshing = false
+	set.loadedAt = time.Now()
+	if err == nil {
+		set.classes = classes
+	}
+}
+
+func putawayOf(p putaway.Placement) models.Putaway {
+	return models.Putaway{LocationID: p.BinID, LocationCode: p.Code, Quantity: p.Quantity, Reason: p.Reason}
+}
+
+// noRoom reports the engine running out of bins as ErrLocationFull
+func noRoom(err error) error {
+	if errors.Is(err, putaway.ErrNoRoom) {
+		return fmt.Errorf("%w: %w", ErrLocationFull, err)
+	}
+	return err
+}
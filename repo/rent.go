@@ -0,0 +1,169 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+	dbconn "warehouse/config/dbConn"
+	"warehouse/models"
+	"warehouse/rent"
+
+	"gorm.io/gorm"
+)
+
+type RentRateRepo struct{}
+
+// NewRentRateRepo initializes the rent rate history repository
+func NewRentRateRepo() *RentRateRepo {
+	return &RentRateRepo{}
+}
+
+// rentRateCache loads each warehouse's rent history and capacity unit once per request
+type rentRateCache struct {
+	db    *gorm.DB
+	rates map[uint][]rent.Rate
+	units map[uint]string
+}
+
+func newRentRateCache(db *gorm.DB) *rentRateCache {
+	return &rentRateCache{db: db, rates: map[uint][]rent.Rate{}, units: map[uint]string{}}
+}
+
+// capacityUnit is the unit the warehouse's rent rates are per
+func (c *rentRateCache) capacityUnit(warehouseID uint) (string, error) {
+	if unit, ok := c.units[warehouseID]; ok {
+		return unit, nil
+	}
+	unit, err := warehouseCapacityUnit(c.db, warehouseID)
+	if err != nil {
+		return "", err
+	}
+	c.units[warehouseID] = unit
+	return unit, nil
+}
+
+func (c *rentRateCache) forWarehouse(warehouseID uint) ([]rent.Rate, error) {
+	if rates, ok := c.rates[warehouseID]; ok {
+		return rates, nil
+	}
+	rates, err := warehouseRentRates(c.db, warehouseID)
+	if err != nil {
+		return nil, err
+	}
+	c.rates[warehouseID] = rates
+	return rates, nil
+}
+
+// warehouseRentRates returns the effective-dated rent versions of a warehouse, oldest first
+func warehouseRentRates(db *gorm.DB, warehouseID uint) ([]rent.Rate, error) {
+	ns := db.NamingStrategy
+
+	var versions []models.RentRateVersion
+	if err := db.Table(ns.TableName("RentRateVersion")).
+		Where("warehouse_id = ?", warehouseID).
+		Order("effective_from ASC, id ASC").
+		Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to load rent rates for warehouse %d: %w", warehouseID, err)
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("no rent rate configured for warehouse %d", warehouseID)
+	}
+
+	rates := make([]rent.Rate, 0, len(versions))
+	for _, v := range versions {
+		rates = append(rates, rent.Rate{
+			RatePerUnit:   v.RatePerSqft,
+			Currency:      v.Currency,
+			Cycle:         v.BillingCycle,
+			MinimumCharge: v.MinimumCharge,
+			EffectiveFrom: v.EffectiveFrom,
+		})
+	}
+	return rates, nil
+}
+
+// addRentRateVersion records a new rent version and mirrors it onto the warehouse's
+// current RentConfig so existing readers keep seeing the latest configuration.
+func addRentRateVersion(tx *gorm.DB, warehouseID, userID uint, version *models.RentRateVersion) error {
+	ns := tx.NamingStrategy
+
+	var warehouse models.Warehouse
+	if err := tx.Table(ns.TableName("Warehouse")).First(&warehouse, warehouseID).Error; err != nil {
+		return fmt.Errorf("warehouse not found (ID=%d): %w", warehouseID, err)
+	}
+
+	version.WarehouseID = warehouseID
+	version.BillingCycle = rent.NormalizeCycle(version.BillingCycle)
+	version.CreatedBy = userID
+	currency, err := documentCurrency(version.Currency, warehouse.ReportingCurrency)
+	if err != nil {
+		return err
+	}
+	version.Currency = currency
+	if version.EffectiveFrom.IsZero() {
+		version.EffectiveFrom = time.Now()
+	}
+	if err := tx.Table(ns.TableName("RentRateVersion")).Create(version).Error; err != nil {
+		return fmt.Errorf("failed to record rent rate version: %w", err)
+	}
+
+	// Only the latest version by effective date is mirrored onto RentConfig
+	var newer int64
+	tx.Table(ns.TableName("RentRateVersion")).
+		Where("warehouse_id = ? AND effective_from > ?", warehouseID, version.EffectiveFrom).
+		Count(&newer)
+	if newer == 0 && warehouse.RentConfigID != 0 {
+		if err := tx.Table(ns.TableName("RentRate")).
+			Where("id = ?", warehouse.RentConfigID).
+			Updates(map[string]any{
+				"rate_per_sqft":  version.RatePerSqft,
+				"currency":       version.Currency,
+				"billing_cycle":  version.BillingCycle,
+				"minimum_charge": version.MinimumCharge,
+			}).Error; err != nil {
+			return fmt.Errorf("failed to update rent config: %w", err)
+		}
+	}
+	return nil
+}
+
+// AddVersion schedules a rent change for a warehouse from EffectiveFrom (default now)
+func (r *RentRateRepo) AddVersion(ctx context.Context, warehouseID, userID uint, input models.RentRateVersionInput) (*models.RentRateVersion, error) {
+	db := dbconn.DB.WithContext(ctx)
+
+	version := models.RentRateVersion{
+		RatePerSqft:   input.RatePerSqft,
+		Currency:      input.Currency,
+		BillingCycle:  input.BillingCycle,
+		MinimumCharge: input.MinimumCharge,
+	}
+	if input.EffectiveFrom != nil {
+		version.EffectiveFrom = *input.EffectiveFrom
+	}
+
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return addRentRateVersion(tx, warehouseID, userID, &version)
+	}); err != nil {
+		return nil, err
+	}
+
+	log.Printf("💲 Rent rate %s/%s effective %s added for warehouse %d",
+		version.RatePerSqft.StringFixed(2), version.BillingCycle, version.EffectiveFrom.Format("2006-01-02"), warehouseID)
+	return &version, nil
+}
+
+// GetVersions lists a warehouse's rent history, oldest first
+func (r *RentRateRepo) GetVersions(ctx context.Context, warehouseID uint) ([]models.RentRateVersion, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	var versions []models.RentRateVersion
+	if err := db.Table(ns.TableName("RentRateVersion")).
+		Where("warehouse_id = ?", warehouseID).
+		Order("effective_from ASC, id ASC").
+		Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch rent rates: %w", err)
+	}
+	return versions, nil
+}
@@ -0,0 +1,203 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	"warehouse/capacity"
+	dbconn "warehouse/config/dbConn"
+	"warehouse/models"
+	"warehouse/money"
+	"warehouse/rent"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+type RentInvoiceRepo struct{}
+
+// NewRentInvoiceRepo initializes the periodic rent invoicing repository
+func NewRentInvoiceRepo() *RentInvoiceRepo {
+	return &RentInvoiceRepo{}
+}
+
+// ErrNothingToInvoice is returned when a rent run finds no unbilled days in the period
+var ErrNothingToInvoice = errors.New("no unbilled rent for the period")
+
+// RunInvoicing bills the rent of everything in stock up to periodEnd (inclusive). Each
+// entry is charged from the day after it was last invoiced (or its intake day), so arrears
+// from missed runs are picked up and no day is billed twice. The invoiced-to date is stored
+// on the entry, and offboarding only charges the days after it. Lines are not raised to
+// the minimum charge; offboarding tops the stay up to it.
+func (r *RentInvoiceRepo) RunInvoicing(ctx context.Context, warehouseId, userID uint, periodStart, periodEnd time.Time) (*models.RentInvoice, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	periodStart, periodEnd = rent.Day(periodStart), rent.Day(periodEnd)
+	if periodEnd.Before(periodStart) {
+		return nil, errors.New("period end must not be before period start")
+	}
+	if periodEnd.After(rent.Day(time.Now())) {
+		return nil, errors.New("period end must not be in the future")
+	}
+	until := periodEnd.AddDate(0, 0, 1)
+
+	invoice := models.RentInvoice{
+		WarehouseID: warehouseId,
+		PeriodStart: periodStart,
+		PeriodEnd:   periodEnd,
+		CreatedBy:   userID,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		rates, err := warehouseRentRates(tx, warehouseId)
+		if err != nil {
+			return err
+		}
+		unit, err := warehouseCapacityUnit(tx, warehouseId)
+		if err != nil {
+			return err
+		}
+
+		// ✅ Lock the entries first, in the id order bills lock them in, so no bill can
+		// offboard units between this read and the invoiced-to update, and a concurrent run
+		// waits and then sees the days already billed
+		var locked []uint
+		if err := lockRows(tx.Table(ns.TableName("BatchProductEntry")+" AS be"), true, "be").
+			Joins("JOIN "+ns.TableName("Batch")+" b ON b.id = be.batch_id").
+			Where("b.warehouse_id = ? AND be.stock_quantity > 0 AND b.stored_at < ?", warehouseId, until).
+			Order("be.id ASC").
+			Pluck("be.id", &locked).Error; err != nil {
+			return fmt.Errorf("failed to lock stock for rent invoicing: %w", err)
+		}
+
+		var rows []struct {
+			EntryID        uint
+			BatchID        uint
+			ProductID      uint
+			Quantity       int
+			StorageArea    float64
+			AreaUnit       string
+			StorageVolume  float64
+			UnitsPerPallet int
+			StoredAt       time.Time
+			RentBilledTo   *time.Time
+		}
+		if err := tx.Table(ns.TableName("BatchProductEntry")+" AS be").
+			Select(`be.id AS entry_id, be.batch_id, be.product_id, be.stock_quantity AS quantity,
+				p.storage_area, p.area_unit, p.storage_volume, p.units_per_pallet, b.stored_at, be.rent_billed_to`).
+			Joins("JOIN "+ns.TableName("Batch")+" b ON b.id = be.batch_id").
+			Joins("JOIN "+ns.TableName("Product")+" p ON p.id = be.product_id").
+			Where("b.warehouse_id = ? AND be.stock_quantity > 0 AND b.stored_at < ?", warehouseId, until).
+			Order("b.stored_at ASC, be.id ASC").
+			Scan(&rows).Error; err != nil {
+			return fmt.Errorf("failed to load stock for rent invoicing: %w", err)
+		}
+
+		for _, row := range rows {
+			from := rent.Day(row.StoredAt)
+			if row.RentBilledTo != nil && row.RentBilledTo.After(from) {
+				from = rent.Day(*row.RentBilledTo)
+			}
+			if !from.Before(until) {
+				continue
+			}
+
+			footprint := capacity.Footprint{Area: row.StorageArea, AreaUnit: row.AreaUnit, Volume: row.StorageVolume, UnitsPerPallet: row.UnitsPerPallet}
+			area, err := footprint.For(row.Quantity, unit)
+			if err != nil {
+				return fmt.Errorf("product %d: %w", row.ProductID, err)
+			}
+			charge := rent.Calculate(rates, area, from, until)
+			invoice.Currency = charge.Currency
+			invoice.TotalArea += area
+			invoice.Lines = append(invoice.Lines, models.RentInvoiceLine{
+				BatchID:   row.BatchID,
+				EntryID:   row.EntryID,
+				ProductID: row.ProductID,
+				Quantity:  row.Quantity,
+				Area:      area,
+				From:      charge.From,
+				To:        charge.To,
+				Days:      charge.Days,
+				Amount:    charge.Amount,
+			})
+		}
+		if len(invoice.Lines) == 0 {
+			return fmt.Errorf("%w: warehouse %d up to %s", ErrNothingToInvoice, warehouseId, periodEnd.Format("2006-01-02"))
+		}
+
+		// Lines are rounded like bill lines, so the total is exactly their sum
+		amounts := make([]decimal.Decimal, len(invoice.Lines))
+		for i, line := range invoice.Lines {
+			amounts[i] = line.Amount
+		}
+		for i, amount := range money.RoundLines(amounts, roundingRule("")) {
+			invoice.Lines[i].Amount = amount
+			invoice.TotalAmount = invoice.TotalAmount.Add(amount)
+		}
+
+		if err := tx.Table(ns.TableName("RentInvoice")).Create(&invoice).Error; err != nil {
+			return fmt.Errorf("failed to create rent invoice: %w", err)
+		}
+
+		// ✅ Mark the invoiced days so neither offboarding nor the next run bills them again
+		entryIDs := make([]uint, 0, len(invoice.Lines))
+		for _, line := range invoice.Lines {
+			entryIDs = append(entryIDs, line.EntryID)
+		}
+		if err := tx.Table(ns.TableName("BatchProductEntry")).
+			Where("id IN ?", entryIDs).
+			Update("rent_billed_to", until).Error; err != nil {
+			return fmt.Errorf("failed to update rent billed date: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("❌ Rent invoicing failed for warehouse %d: %v", warehouseId, err)
+		return nil, err
+	}
+
+	log.Printf("🧾 Rent invoice %d: warehouse %d, %s → %s, %d lines, amount %s",
+		invoice.ID, warehouseId, periodStart.Format("2006-01-02"), periodEnd.Format("2006-01-02"), len(invoice.Lines), invoice.TotalAmount.StringFixed(2))
+	return &invoice, nil
+}
+
+// GetByID fetches a rent invoice of the warehouse with its lines
+func (r *RentInvoiceRepo) GetByID(ctx context.Context, warehouseId, invoiceID uint) (*models.RentInvoice, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	var invoice models.RentInvoice
+	if err := db.Table(ns.TableName("RentInvoice")).
+		Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		First(&invoice, invoiceID).Error; err != nil {
+		return nil, fmt.Errorf("rent invoice not found (ID=%d): %w", invoiceID, err)
+	}
+	if invoice.WarehouseID != warehouseId {
+		return nil, fmt.Errorf("%w: rent invoice %d belongs to warehouse %d", ErrWarehouseMismatch, invoiceID, invoice.WarehouseID)
+	}
+	return &invoice, nil
+}
+
+// GetAll lists the warehouse's rent invoices, optionally limited to periods ending in [from, to)
+func (r *RentInvoiceRepo) GetAll(ctx context.Context, warehouseId uint, from, to time.Time) ([]models.RentInvoice, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	query := db.Table(ns.TableName("RentInvoice")).Where("warehouse_id = ?", warehouseId)
+	if !from.IsZero() {
+		query = query.Where("period_end >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("period_end < ?", to)
+	}
+
+	var invoices []models.RentInvoice
+	if err := query.Order("period_end DESC, id DESC").Find(&invoices).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch rent invoices: %w", err)
+	}
+	return invoices, nil
+}
@@ -4,10 +4,15 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"strings"
+	"math"
 	"time"
 	dbconn "warehouse/config/dbConn"
 	"warehouse/models"
+	"warehouse/money"
+	"warehouse/rent"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
 )
 
 type ProductStockRepo struct {
@@ -42,10 +47,13 @@ func (r *ProductStockRepo) GetProductStockWithRent(ctx context.Context, warehous
 			p.category,
 			s.name AS supplier_name,
 			p.storage_area,
+			`+spacePerUnit("p", "w")+` AS space_per_unit,
+			w.capacity_unit,
 			be.quantity,
 			be.stock_quantity,
 			be.billing_price,
 			b.created_at,
+			b.stored_at,
 			be.last_updated,
 			rr.rate_per_sqft,
 			rr.currency,
@@ -66,24 +74,14 @@ func (r *ProductStockRepo) GetProductStockWithRent(ctx context.Context, warehous
 
 	now := time.Now()
 	results := make([]map[string]any, 0)
+	rentRates, err := warehouseRentRates(db, warehouseId)
+	if err != nil {
+		return nil, err
+	}
 
 	for _, e := range entries {
-		// ---- Calculate storage duration ----
-		daysStored := now.Sub(e.CreatedAt).Hours() / 24
-		if daysStored < 1 {
-			daysStored = 1 // prevent 0-day rent
-		}
-
-		// ---- Rent Calculation ----
-		var rent float64
-		switch strings.ToLower(e.BillingCycle) {
-		case "daily":
-			rent = daysStored * e.StorageArea * e.RatePerSqft * float64(e.StockQuantity)
-		case "monthly":
-			rent = (daysStored / 30) * e.StorageArea * e.RatePerSqft * float64(e.StockQuantity)
-		default:
-			rent = (daysStored / 30) * e.StorageArea * e.RatePerSqft * float64(e.StockQuantity)
-		}
+		// ---- Rent Calculation (rent engine) ----
+		charge := rent.ForStay(rentRates, e.SpacePerUnit*float64(e.StockQuantity), e.StoredAt, now)
 
 		// ---- Status ----
 		status := "out_of_stock"
@@ -103,10 +101,12 @@ func (r *ProductStockRepo) GetProductStockWithRent(ctx context.Context, warehous
 			"billing_price":  e.BillingPrice,
 			"batch_quantity": e.Quantity,
 			"stock_quantity": e.StockQuantity,
-			"stored_at":      e.CreatedAt,
+			"stored_at":      e.StoredAt,
 			"last_updated":   e.LastUpdated,
-			"total_space":    e.StorageArea * float64(e.StockQuantity),
-			"total_rent":     rent,
+			"total_space":    e.SpacePerUnit * float64(e.StockQuantity),
+			"capacity_unit":  e.CapacityUnit,
+			"total_rent":     money.Round(charge.Amount),
+			"days_stored":    charge.Days,
 			"currency":       e.Currency,
 			"status":         status,
 		})
@@ -190,12 +190,12 @@ func (r *ProductStockRepo) GetAllStockProductData(ctx context.Context, warehouse
 		OnBoardCount   int
 		OffBoardCount  int
 		InStockCount   int
-		OnBoardingAmt  float64
-		OffBoardingAmt float64
-		InStockAmt     float64
-		ProfitAmt      float64
-		NetProfitAmt   float64
-		ExpenseAmt     float64
+		OnBoardingAmt  decimal.Decimal
+		OffBoardingAmt decimal.Decimal
+		InStockAmt     decimal.Decimal
+		ProfitAmt      decimal.Decimal
+		NetProfitAmt   decimal.Decimal
+		ExpenseAmt     decimal.Decimal
 	}
 
 	var rows []rawRow
@@ -253,6 +253,17 @@ func (r *ProductStockRepo) GetAllStockProductData(ctx context.Context, warehouse
 		return nil, nil
 	}
 
+	// Intake (onboarding) expenses per product + batch
+	intake, err := intakeExpenses(db, warehouseId, 0)
+	if err != nil {
+		return nil, err
+	}
+	intakeByEntry := make(map[[2]uint]intakeExpenseRow, len(intake))
+	for _, ie := range intake {
+		key := [2]uint{ie.ProductID, ie.BatchID}
+		intakeByEntry[key] = intakeByEntry[key].add(ie)
+	}
+
 	// GROUP BY PRODUCT
 	productMap := make(map[uint]*models.StockSearchData)
 
@@ -284,7 +295,8 @@ func (r *ProductStockRepo) GetAllStockProductData(ctx context.Context, warehouse
 					InStockAmount:     r.InStockAmt,
 					ProfitAmount:      r.ProfitAmt,
 					NetProfitAmount:   r.NetProfitAmt,
-					ExpenseAmount:     r.ExpenseAmt,
+					ExpenseAmount:     r.ExpenseAmt.Add(intakeByEntry[[2]uint{r.ProductID, r.BatchID}].Amount),
+					SoldIntakeAmount:  intakeByEntry[[2]uint{r.ProductID, r.BatchID}].SoldAmount,
 				},
 			},
 		)
@@ -310,9 +322,10 @@ func (r *ProductStockRepo) GetStockProductData(ctx context.Context, productId, w
 		SupplierName   string
 		Category       string
 		StorageArea    float64
+		SpacePerUnit   float64
 		WarehouseID    uint
 		WarehouseName  string
-		RentPerSqft    float64
+		RentPerSqft    decimal.Decimal
 		BatchID        uint
 		BatchCreatedAt time.Time
 
@@ -320,12 +333,12 @@ func (r *ProductStockRepo) GetStockProductData(ctx context.Context, productId, w
 		OffBoardCount int
 		InStockCount  int
 
-		OnBoardingAmt  float64
-		OffBoardingAmt float64
-		InStockAmt     float64
-		ProfitAmt      float64
-		NetProfitAmt   float64
-		ExpenseAmt     float64
+		OnBoardingAmt  decimal.Decimal
+		OffBoardingAmt decimal.Decimal
+		InStockAmt     decimal.Decimal
+		ProfitAmt      decimal.Decimal
+		NetProfitAmt   decimal.Decimal
+		ExpenseAmt     decimal.Decimal
 	}
 
 	var rows []rawRow
@@ -340,13 +353,14 @@ func (r *ProductStockRepo) GetStockProductData(ctx context.Context, productId, w
 		    s.name AS supplier_name,
 		    p.category,
 		    p.storage_area,
+		    `+spacePerUnit("p", "w")+` AS space_per_unit,
 
 		    b.warehouse_id,
 		    w.name AS warehouse_name,
 		    rr.rate_per_sqft AS rent_per_sqft,
 
 		    be.batch_id,
-		    b.created_at AS batch_created_at,
+		    b.stored_at AS batch_created_at,
 
 		    COALESCE(SUM(be.quantity), 0) AS on_board_count,
 		    COALESCE(SUM(be.stock_quantity), 0) AS in_stock_count,
@@ -370,8 +384,9 @@ func (r *ProductStockRepo) GetStockProductData(ctx context.Context, productId, w
 		Where("p.id = ? AND b.warehouse_id = ?", productId, warehouseId).
 		Group(`
 			p.id, p.name, s.name, p.category, p.storage_area,
-			b.warehouse_id, w.name, rr.rate_per_sqft,
-			be.batch_id, b.created_at
+			p.area_unit, p.storage_volume, p.units_per_pallet,
+			b.warehouse_id, w.name, w.capacity_unit, rr.rate_per_sqft,
+			be.batch_id, b.stored_at
 		`).
 		Order("be.batch_id ASC").
 		Scan(&rows).Error
@@ -383,6 +398,22 @@ func (r *ProductStockRepo) GetStockProductData(ctx context.Context, productId, w
 		return models.StockSearchData{}, fmt.Errorf("no stock data found for product %d", productId)
 	}
 
+	// Intake (onboarding) expenses per batch
+	intake, err := intakeExpenses(db, warehouseId, productId)
+	if err != nil {
+		return models.StockSearchData{}, err
+	}
+	intakeByBatch := make(map[uint]intakeExpenseRow, len(intake))
+	for _, ie := range intake {
+		intakeByBatch[ie.BatchID] = intakeByBatch[ie.BatchID].add(ie)
+	}
+
+	rentRates, err := warehouseRentRates(db, warehouseId)
+	if err != nil {
+		return models.StockSearchData{}, err
+	}
+	now := time.Now()
+
 	// -------------------------------------------------------------
 	// 🧱 Build response base (product details)
 	// -------------------------------------------------------------
@@ -401,8 +432,8 @@ func (r *ProductStockRepo) GetStockProductData(ctx context.Context, productId, w
 	// AGGREGATORS for Final Output
 	// -------------------------------------------------------------
 	var totalOnboard, totalOffboard, totalInStock int
-	var totOnboardAmt, totOffboardAmt, totInStockAmt float64
-	var totProfit, totNetProfit, totExpense float64
+	var totOnboardAmt, totOffboardAmt, totInStockAmt decimal.Decimal
+	var totProfit, totNetProfit, totExpense, totSoldIntake decimal.Decimal
 
 	// -------------------------------------------------------------
 	// 📦 Loop each batch and compute Rent + filter zero-stock batches
@@ -414,14 +445,12 @@ func (r *ProductStockRepo) GetStockProductData(ctx context.Context, productId, w
 			continue
 		}
 
-		// 📅 Duration in days
-		duration := int(time.Since(r.BatchCreatedAt).Hours() / 24)
-		if duration < 1 {
-			duration = 1
-		}
+		// 💰 Rent accrued on the stock still held, priced by the rent engine
+		rentAmount := money.Round(rent.ForStay(rentRates, r.SpacePerUnit*float64(r.InStockCount), r.BatchCreatedAt, now).Amount)
 
-		// 💰 RentAmount = storage_area × rate × duration × quantity
-		rentAmount := r.StorageArea * r.RentPerSqft * float64(duration) * float64(r.InStockCount)
+		// 🚚 Expenses include the intake cost allocated to this batch
+		expenseAmt := r.ExpenseAmt.Add(intakeByBatch[r.BatchID].Amount)
+		soldIntake := intakeByBatch[r.BatchID].SoldAmount
 
 		// Append batch-specific stock data
 		result.StockData = append(result.StockData, models.StockData{
@@ -437,7 +466,8 @@ func (r *ProductStockRepo) GetStockProductData(ctx context.Context, productId, w
 				InStockAmount:     r.InStockAmt,
 				ProfitAmount:      r.ProfitAmt,
 				NetProfitAmount:   r.NetProfitAmt,
-				ExpenseAmount:     r.ExpenseAmt,
+				ExpenseAmount:     expenseAmt,
+				SoldIntakeAmount:  soldIntake,
 			},
 			RentAmount: rentAmount,
 		})
@@ -447,13 +477,14 @@ func (r *ProductStockRepo) GetStockProductData(ctx context.Context, productId, w
 		totalOffboard += r.OffBoardCount
 		totalInStock += r.InStockCount
 
-		totOnboardAmt += r.OnBoardingAmt
-		totOffboardAmt += r.OffBoardingAmt
-		totInStockAmt += r.InStockAmt
+		totOnboardAmt = totOnboardAmt.Add(r.OnBoardingAmt)
+		totOffboardAmt = totOffboardAmt.Add(r.OffBoardingAmt)
+		totInStockAmt = totInStockAmt.Add(r.InStockAmt)
 
-		totProfit += r.ProfitAmt
-		totNetProfit += r.NetProfitAmt
-		totExpense += r.ExpenseAmt
+		totProfit = totProfit.Add(r.ProfitAmt)
+		totNetProfit = totNetProfit.Add(r.NetProfitAmt)
+		totExpense = totExpense.Add(expenseAmt)
+		totSoldIntake = totSoldIntake.Add(soldIntake)
 	}
 
 	// -------------------------------------------------------------
@@ -472,6 +503,7 @@ func (r *ProductStockRepo) GetStockProductData(ctx context.Context, productId, w
 		ProfitAmount:      totProfit,
 		NetProfitAmount:   totNetProfit,
 		ExpenseAmount:     totExpense,
+		SoldIntakeAmount:  totSoldIntake,
 	}
 
 	return result, nil
@@ -493,12 +525,12 @@ func (r *ProductStockRepo) GetAllProductStockDatas(ctx context.Context, warehous
 		OffBoardCount int
 		InStockCount  int
 
-		OnBoardingAmt  float64
-		OffBoardingAmt float64
-		InStockAmt     float64
-		ProfitAmt      float64
-		NetProfitAmt   float64
-		ExpenseAmt     float64
+		OnBoardingAmt  decimal.Decimal
+		OffBoardingAmt decimal.Decimal
+		InStockAmt     decimal.Decimal
+		ProfitAmt      decimal.Decimal
+		NetProfitAmt   decimal.Decimal
+		ExpenseAmt     decimal.Decimal
 	}
 
 	var rows []rawRow
@@ -547,6 +579,16 @@ func (r *ProductStockRepo) GetAllProductStockDatas(ctx context.Context, warehous
 		return []models.ProductStockDatas{}, nil
 	}
 
+	// Intake (onboarding) expenses per product
+	intake, err := intakeExpenses(db, warehouseId, 0)
+	if err != nil {
+		return nil, err
+	}
+	intakeByProduct := make(map[uint]intakeExpenseRow, len(intake))
+	for _, ie := range intake {
+		intakeByProduct[ie.ProductID] = intakeByProduct[ie.ProductID].add(ie)
+	}
+
 	// -------------------------
 	// 🧩 Map to final struct
 	// -------------------------
@@ -566,7 +608,8 @@ func (r *ProductStockRepo) GetAllProductStockDatas(ctx context.Context, warehous
 				InStockAmount:     r.InStockAmt,
 				ProfitAmount:      r.ProfitAmt,
 				NetProfitAmount:   r.NetProfitAmt,
-				ExpenseAmount:     r.ExpenseAmt,
+				ExpenseAmount:     r.ExpenseAmt.Add(intakeByProduct[r.ProductID].Amount),
+				SoldIntakeAmount:  intakeByProduct[r.ProductID].SoldAmount,
 			},
 
 			StokCount: models.Stock{
@@ -579,3 +622,97 @@ func (r *ProductStockRepo) GetAllProductStockDatas(ctx context.Context, warehous
 
 	return result, nil
 }
+
+type intakeExpenseRow struct {
+	ProductID  uint
+	BatchID    uint
+	Amount     decimal.Decimal // every unit received
+	SoldAmount decimal.Decimal // units billed, less returns; what the profit figures deduct
+}
+
+func (r intakeExpenseRow) add(o intakeExpenseRow) intakeExpenseRow {
+	r.Amount = r.Amount.Add(o.Amount)
+	r.SoldAmount = r.SoldAmount.Add(o.SoldAmount)
+	return r
+}
+
+// intakeExpenses returns the onboarding expense allocated to each (product, batch) in a
+// warehouse, for every unit received and for the units billed out less those returned
+// through credit notes. A zero productId returns every product. It is queried separately
+// from the billing/profit joins so the amounts are not multiplied by their fan-out.
+func intakeExpenses(db *gorm.DB, warehouseId, productId uint) ([]intakeExpenseRow, error) {
+	ns := db.NamingStrategy
+
+	sold := db.Table(ns.TableName("BillingItem")).
+		Select("product_id, batch_id, SUM(offboard_qty - reversed_qty) AS qty").
+		Group("product_id, batch_id")
+
+	query := db.Table(ns.TableName("BatchProductEntry")+" AS be").
+		Select(`
+			be.product_id,
+			be.batch_id,
+			COALESCE(SUM(be.on_board_cost * be.quantity), 0) AS amount,
+			COALESCE(SUM(be.on_board_cost * be.quantity) / NULLIF(SUM(be.quantity), 0) * MAX(sold.qty), 0) AS sold_amount
+		`).
+		Joins("JOIN "+ns.TableName("Batch")+" AS b ON b.id = be.batch_id").
+		Joins("LEFT JOIN (?) AS sold ON sold.product_id = be.product_id AND sold.batch_id = be.batch_id", sold).
+		Where("b.warehouse_id = ?", warehouseId)
+	if productId != 0 {
+		query = query.Where("be.product_id = ?", productId)
+	}
+
+	var rows []intakeExpenseRow
+	if err := query.Group("be.product_id, be.batch_id").Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch onboard expenses: %w", err)
+	}
+	return rows, nil
+}
+
+// GetExpiringStock reports in-stock entries of a warehouse that have expired or expire
+// within the next days days
+func (r *ProductStockRepo) GetExpiringStock(ctx context.Context, warehouseId uint, days int) (*models.ExpiringStockReport, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := dbconn.DB.NamingStrategy
+
+	now := time.Now()
+	items := make([]models.ExpiringStock, 0)
+	err := db.Table(fmt.Sprintf("%s AS be", ns.TableName("BatchProductEntry"))).
+		Select(`
+			be.batch_id,
+			be.id AS entry_id,
+			p.id AS product_id,
+			p.name AS product_name,
+			p.category,
+			COALESCE(be.lot_number, '') AS lot_number,
+			be.manufactured_at,
+			be.expires_at,
+			be.stock_quantity,
+			be.billing_price
+		`).
+		Joins(fmt.Sprintf("JOIN %s AS b ON b.id = be.batch_id", ns.TableName("Batch"))).
+		Joins(fmt.Sprintf("JOIN %s AS p ON p.id = be.product_id", ns.TableName("Product"))).
+		Where("b.warehouse_id = ? AND be.stock_quantity > 0", warehouseId).
+		Where("be.expires_at IS NOT NULL AND be.expires_at < ?", now.AddDate(0, 0, days)).
+		Order("be.expires_at ASC, be.id ASC").
+		Scan(&items).Error
+	if err != nil {
+		log.Printf("❌ Expiring stock query error: %v\n", err)
+		return nil, err
+	}
+
+	report := &models.ExpiringStockReport{WarehouseID: warehouseId, Days: days, AsOf: now, Items: items}
+	for i := range report.Items {
+		it := &report.Items[i]
+		it.StockValue = money.Round(it.BillingPrice.Mul(money.Qty(it.StockQuantity)))
+		it.DaysLeft = int(math.Floor(it.ExpiresAt.Sub(now).Hours() / 24))
+		it.Expired = !it.ExpiresAt.After(now)
+		if it.Expired {
+			report.ExpiredQty += it.StockQuantity
+			report.ExpiredValue = report.ExpiredValue.Add(it.StockValue)
+		} else {
+			report.ExpiringQty += it.StockQuantity
+			report.ExpiringValue = report.ExpiringValue.Add(it.StockValue)
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,149 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	dbconn "warehouse/config/dbConn"
+	"warehouse/models"
+	"warehouse/tax"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+type TaxRateRepo struct {
+}
+
+// ErrMissingTaxRate is returned when a product's category has no tax rate. Exempt
+// categories need an explicit 0% rate.
+var ErrMissingTaxRate = errors.New("no tax rate for category")
+
+// NewTaxRateRepo initializes the repository
+func NewTaxRateRepo() *TaxRateRepo {
+	return &TaxRateRepo{}
+}
+
+// Create inserts a new category tax rate
+func (r *TaxRateRepo) Create(ctx context.Context, rate *models.TaxRate) (uint, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+	table := ns.TableName("TaxRate")
+
+	if err := db.Table(table).Create(&rate).Error; err != nil {
+		return 0, fmt.Errorf("failed to create tax rate: %w", err)
+	}
+
+	log.Printf("🧾 New tax rate created: ID=%d, Category=%s, Rate=%.2f%%", rate.ID, rate.Category, rate.Rate)
+	return rate.ID, nil
+}
+
+// GetByID fetches a tax rate by ID
+func (r *TaxRateRepo) GetByID(ctx context.Context, id uint) (*models.TaxRate, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+	table := ns.TableName("TaxRate")
+
+	var rate models.TaxRate
+	if err := db.Table(table).First(&rate, id).Error; err != nil {
+		return nil, fmt.Errorf("tax rate not found (ID=%d): %w", id, err)
+	}
+	return &rate, nil
+}
+
+// GetAll fetches all tax rates
+func (r *TaxRateRepo) GetAll(ctx context.Context) ([]models.TaxRate, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+	table := ns.TableName("TaxRate")
+
+	var rates []models.TaxRate
+	if err := db.Table(table).Order("category ASC").Find(&rates).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch tax rates: %w", err)
+	}
+
+	log.Printf("🧾 Retrieved %d tax rates", len(rates))
+	return rates, nil
+}
+
+// Update modifies a tax rate. Bills already raised keep the rate they were taxed at.
+func (r *TaxRateRepo) Update(ctx context.Context, update models.TaxRate) error {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+	table := ns.TableName("TaxRate")
+
+	res := db.Table(table).
+		Where("id = ?", update.ID).
+		Select("category", "rate", "description").
+		Updates(update)
+	if res.Error != nil {
+		return fmt.Errorf("failed to update tax rate ID %d: %w", update.ID, res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("tax rate not found (ID=%d): %w", update.ID, gorm.ErrRecordNotFound)
+	}
+
+	log.Printf("🔄 Tax rate updated: ID=%d, Category=%s, Rate=%.2f%%", update.ID, update.Category, update.Rate)
+	return nil
+}
+
+// Delete removes a tax rate; products of its category cannot be billed until the category
+// has a rate again. Exempt goods keep an explicit 0% rate.
+func (r *TaxRateRepo) Delete(ctx context.Context, id uint) error {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+	table := ns.TableName("TaxRate")
+
+	if err := db.Table(table).Delete(&models.TaxRate{}, id).Error; err != nil {
+		return fmt.Errorf("failed to delete tax rate ID %d: %w", id, err)
+	}
+
+	log.Printf("🗑️ Tax rate deleted: ID=%d", id)
+	return nil
+}
+
+// billTax taxes the lines of one bill: category rates, the pricing mode and the supply
+// type are resolved once per bill.
+type billTax struct {
+	rates      map[string]float64 // lower-cased category -> percent
+	inclusive  bool
+	supplyType string
+}
+
+// newBillTax loads the tax rates and decides the supply type from the warehouse and
+// customer states
+func newBillTax(db *gorm.DB, warehouseID uint, customer *models.Customer, inclusive bool) (*billTax, error) {
+	ns := db.NamingStrategy
+
+	var warehouse models.Warehouse
+	if err := db.Table(ns.TableName("Warehouse")).Select("id", "state").First(&warehouse, warehouseID).Error; err != nil {
+		return nil, fmt.Errorf("warehouse not found (ID=%d): %w", warehouseID, err)
+	}
+
+	var rates []models.TaxRate
+	if err := db.Table(ns.TableName("TaxRate")).Find(&rates).Error; err != nil {
+		return nil, fmt.Errorf("failed to load tax rates: %w", err)
+	}
+
+	t := &billTax{
+		rates:      make(map[string]float64, len(rates)),
+		inclusive:  inclusive,
+		supplyType: tax.SupplyType(warehouse.State, customer.State),
+	}
+	for _, rate := range rates {
+		t.rates[strings.ToLower(strings.TrimSpace(rate.Category))] = rate.Rate
+	}
+	return t, nil
+}
+
+// line is amount, the quantity times the selling price of product, at the product's rate.
+// A category without a rate is a configuration error, not an exemption, so the bill fails.
+func (t *billTax) line(product models.Product, amount decimal.Decimal) (tax.Line, error) {
+	rate, ok := t.rates[strings.ToLower(strings.TrimSpace(product.Category))]
+	if !ok {
+		return tax.Line{}, fmt.Errorf("%w: category %q of product %d", ErrMissingTaxRate, product.Category, product.ID)
+	}
+	return tax.Line{Rate: rate, Amount: amount}, nil
+}
@@ -0,0 +1,428 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+	dbconn "warehouse/config/dbConn"
+	"warehouse/models"
+
+	"gorm.io/gorm"
+)
+
+type TransferRepo struct{}
+
+// NewTransferRepo initializes the inter-warehouse transfer repository
+func NewTransferRepo() *TransferRepo {
+	return &TransferRepo{}
+}
+
+// ErrInvalidTransferState is returned when a transfer step is not allowed in the current status
+var ErrInvalidTransferState = errors.New("invalid transfer state")
+
+// CreateTransfer drafts a transfer from the caller's warehouse. Stock is only checked
+// here; nothing moves until the order is dispatched.
+func (r *TransferRepo) CreateTransfer(ctx context.Context, warehouseId, userID uint, input models.TransferOrderInput) (*models.TransferOrder, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	if input.DestWarehouseID == warehouseId {
+		return nil, errors.New("destination warehouse must differ from the source warehouse")
+	}
+
+	order := models.TransferOrder{
+		SourceWarehouseID: warehouseId,
+		DestWarehouseID:   input.DestWarehouseID,
+		Status:            models.TransferDraft,
+		Notes:             input.Notes,
+		CreatedBy:         userID,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var dest models.Warehouse
+		if err := tx.Table(ns.TableName("Warehouse")).First(&dest, input.DestWarehouseID).Error; err != nil {
+			return fmt.Errorf("destination warehouse not found (ID=%d): %w", input.DestWarehouseID, err)
+		}
+		unit, err := warehouseCapacityUnit(tx, warehouseId)
+		if err != nil {
+			return err
+		}
+
+		requested := map[uint]int{}
+		for _, in := range input.Items {
+			var entry models.BatchProductEntry
+			if err := tx.Table(ns.TableName("BatchProductEntry")).
+				Where("batch_id = ? AND product_id = ?", in.BatchID, in.ProductID).
+				First(&entry).Error; err != nil {
+				return fmt.Errorf("invalid batch or product reference (batch_id=%d, product_id=%d): %w", in.BatchID, in.ProductID, err)
+			}
+
+			var batch models.Batch
+			if err := tx.Table(ns.TableName("Batch")).First(&batch, entry.BatchID).Error; err != nil {
+				return fmt.Errorf("batch not found (ID=%d): %w", entry.BatchID, err)
+			}
+			if batch.WarehouseID != warehouseId {
+				return fmt.Errorf("%w: batch %d belongs to warehouse %d", ErrWarehouseMismatch, batch.ID, batch.WarehouseID)
+			}
+
+			requested[entry.ID] += in.Quantity
+			if requested[entry.ID] > entry.StockQuantity {
+				return fmt.Errorf("insufficient stock for product %d in batch %d (available: %d, requested: %d)",
+					entry.ProductID, entry.BatchID, entry.StockQuantity, requested[entry.ID])
+			}
+
+			var product models.Product
+			if err := tx.Table(ns.TableName("Product")).First(&product, entry.ProductID).Error; err != nil {
+				return fmt.Errorf("product not found (ID=%d): %w", entry.ProductID, err)
+			}
+
+			space, err := spaceUsed(product, in.Quantity, unit)
+			if err != nil {
+				return err
+			}
+			order.TotalArea += space
+			order.Items = append(order.Items, models.TransferOrderItem{
+				SourceBatchID:  entry.BatchID,
+				SourceEntryID:  entry.ID,
+				ProductID:      entry.ProductID,
+				Quantity:       in.Quantity,
+				BillingPrice:   entry.BillingPrice,
+				OnBoardCost:    entry.OnBoardCost,
+				StoredAt:       batch.StoredAt,
+				LotNumber:      entry.LotNumber,
+				ManufacturedAt: entry.ManufacturedAt,
+				ExpiresAt:      entry.ExpiresAt,
+			})
+		}
+
+		if err := tx.Table(ns.TableName("TransferOrder")).Create(&order).Error; err != nil {
+			return fmt.Errorf("failed to create transfer order: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("❌ Transfer creation failed: %v", err)
+		return nil, err
+	}
+
+	log.Printf("🚚 Transfer %d drafted: warehouse %d → %d (%d items)", order.ID, order.SourceWarehouseID, order.DestWarehouseID, len(order.Items))
+	return &order, nil
+}
+
+// Dispatch takes the stock out of the source warehouse and frees its area
+func (r *TransferRepo) Dispatch(ctx context.Context, warehouseId, transferID, userID uint) (*models.TransferOrder, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		order, err := loadTransfer(forUpdate(tx), transferID)
+		if err != nil {
+			return err
+		}
+		if order.SourceWarehouseID != warehouseId {
+			return fmt.Errorf("%w: only the source warehouse can dispatch transfer %d", ErrWarehouseMismatch, transferID)
+		}
+		if order.Status != models.TransferDraft {
+			return fmt.Errorf("%w: only draft transfers can be dispatched (status=%s)", ErrInvalidTransferState, order.Status)
+		}
+
+		unit, err := warehouseCapacityUnit(tx, order.SourceWarehouseID)
+		if err != nil {
+			return err
+		}
+
+		var (
+			movements     []models.StockMovement
+			releasedArea  float64
+			sourceBatches = map[uint]bool{}
+		)
+		for _, item := range order.Items {
+			var entry models.BatchProductEntry
+			if err := forUpdate(tx.Table(ns.TableName("BatchProductEntry"))).First(&entry, item.SourceEntryID).Error; err != nil {
+				return fmt.Errorf("source batch entry not found (ID=%d): %w", item.SourceEntryID, err)
+			}
+			if entry.StockQuantity < item.Quantity {
+				return fmt.Errorf("insufficient stock for product %d in batch %d (available: %d, requested: %d)",
+					entry.ProductID, entry.BatchID, entry.StockQuantity, item.Quantity)
+			}
+
+			var product models.Product
+			if err := tx.Table(ns.TableName("Product")).First(&product, entry.ProductID).Error; err != nil {
+				return fmt.Errorf("product not found (ID=%d): %w", entry.ProductID, err)
+			}
+
+			entry, err = changeStock(tx, entry.ID, -item.Quantity, nil)
+			if err != nil {
+				return err
+			}
+			movements = append(movements, newStockMovement(entry, order.SourceWarehouseID, -item.Quantity, models.MovementTransferOut, models.SourceTransfer, userID))
+
+			// ✅ The stay's rent state travels with the units as of dispatch; an invoicing run
+			// or bill since the draft has moved it on, and the destination must not bill again
+			if err := tx.Table(ns.TableName("TransferOrderItem")).
+				Where("id = ?", item.ID).
+				Updates(map[string]any{"rent_billed_to": entry.RentBilledTo, "rent_minimum_met": entry.RentMinimumMet}).Error; err != nil {
+				return fmt.Errorf("failed to update transfer item %d: %w", item.ID, err)
+			}
+			if _, err := pickFromBins(tx, order.SourceWarehouseID, entry, product, item.Quantity); err != nil {
+				return err
+			}
+
+			space, err := spaceUsed(product, item.Quantity, unit)
+			if err != nil {
+				return err
+			}
+			releasedArea += space
+			sourceBatches[entry.BatchID] = true
+		}
+
+		if err := releaseWarehouseArea(tx, order.SourceWarehouseID, releasedArea); err != nil {
+			return err
+		}
+		if err := recordStockMovements(tx, order.ID, movements); err != nil {
+			return err
+		}
+
+		// ✅ Mark emptied source batches inactive
+		for batchID := range sourceBatches {
+			var remaining int64
+			if err := tx.Table(ns.TableName("BatchProductEntry")).
+				Where("batch_id = ? AND stock_quantity > 0", batchID).
+				Count(&remaining).Error; err != nil {
+				return fmt.Errorf("failed to count stock left in batch %d: %w", batchID, err)
+			}
+			if remaining == 0 {
+				if err := tx.Table(ns.TableName("Batch")).
+					Where("id = ?", batchID).
+					Update("status", "inactive").Error; err != nil {
+					return fmt.Errorf("failed to update batch %d status: %w", batchID, err)
+				}
+			}
+		}
+
+		now := time.Now()
+		return tx.Table(ns.TableName("TransferOrder")).
+			Where("id = ?", order.ID).
+			Updates(map[string]any{
+				"status":        models.TransferDispatched,
+				"dispatched_by": userID,
+				"dispatched_at": now,
+			}).Error
+	})
+	if err != nil {
+		log.Printf("❌ Transfer %d dispatch failed: %v", transferID, err)
+		return nil, err
+	}
+
+	log.Printf("🚚 Transfer %d dispatched", transferID)
+	return r.GetByID(ctx, warehouseId, transferID)
+}
+
+// Receive books a dispatched transfer into the destination warehouse. Each source batch
+// becomes a destination batch with the same StoredAt (and rent invoiced-to date), so rent
+// keeps accruing from the original intake date; space is checked the same way AddBatch does.
+func (r *TransferRepo) Receive(ctx context.Context, warehouseId, transferID, userID uint) (*models.TransferOrder, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		order, err := loadTransfer(forUpdate(tx), transferID)
+		if err != nil {
+			return err
+		}
+		if order.DestWarehouseID != warehouseId {
+			return fmt.Errorf("%w: only the destination warehouse can receive transfer %d", ErrWarehouseMismatch, transferID)
+		}
+		if order.Status != models.TransferDispatched {
+			return fmt.Errorf("%w: only dispatched transfers can be received (status=%s)", ErrInvalidTransferState, order.Status)
+		}
+
+		// Step 1️⃣: Check and reserve destination space, in the destination's capacity unit
+		unit, err := warehouseCapacityUnit(tx, order.DestWarehouseID)
+		if err != nil {
+			return err
+		}
+		var requiredArea float64
+		products := map[uint]models.Product{}
+		for _, item := range order.Items {
+			var product models.Product
+			if err := tx.Table(ns.TableName("Product")).First(&product, item.ProductID).Error; err != nil {
+				return fmt.Errorf("product not found (ID=%d): %w", item.ProductID, err)
+			}
+			products[product.ID] = product
+			space, err := spaceUsed(product, item.Quantity, unit)
+			if err != nil {
+				return err
+			}
+			requiredArea += space
+		}
+		if err := reserveWarehouseArea(tx, order.DestWarehouseID, requiredArea); err != nil {
+			return err
+		}
+
+		// Step 2️⃣: One destination batch per source batch, keeping its age
+		now := time.Now()
+		batches := map[uint]*models.Batch{}
+		var sourceOrder []uint
+		for _, item := range order.Items {
+			batch, ok := batches[item.SourceBatchID]
+			if !ok {
+				var source models.Batch
+				if err := tx.Table(ns.TableName("Batch")).Select("id", "currency").First(&source, item.SourceBatchID).Error; err != nil {
+					return fmt.Errorf("source batch not found (ID=%d): %w", item.SourceBatchID, err)
+				}
+				// Costs stay in the purchase currency, booked into the destination's reporting currency
+				batch = &models.Batch{
+					WarehouseID: order.DestWarehouseID,
+					StoredAt:    item.StoredAt,
+					Status:      "active",
+					Currency:    source.Currency,
+				}
+				if err := bookBatchCurrency(tx, batch); err != nil {
+					return err
+				}
+				batches[item.SourceBatchID] = batch
+				sourceOrder = append(sourceOrder, item.SourceBatchID)
+			}
+			batch.Products = append(batch.Products, models.BatchProductEntry{
+				ProductID:      item.ProductID,
+				BillingPrice:   item.BillingPrice,
+				OnBoardCost:    item.OnBoardCost,
+				Quantity:       item.Quantity,
+				StockQuantity:  item.Quantity,
+				RentBilledTo:   item.RentBilledTo,
+				RentMinimumMet: item.RentMinimumMet,
+				LotNumber:      item.LotNumber,
+				ManufacturedAt: item.ManufacturedAt,
+				ExpiresAt:      item.ExpiresAt,
+				LastUpdated:    &now,
+			})
+		}
+
+		planner, err := newPutawayPlanner(tx, order.DestWarehouseID)
+		if err != nil {
+			return err
+		}
+		var movements []models.StockMovement
+		for _, sourceBatchID := range sourceOrder {
+			batch := batches[sourceBatchID]
+			if err := tx.Table(ns.TableName("Batch")).Create(batch).Error; err != nil {
+				return fmt.Errorf("failed to create destination batch: %w", err)
+			}
+			for _, entry := range batch.Products {
+				movements = append(movements, newStockMovement(entry, order.DestWarehouseID, entry.Quantity, models.MovementTransferIn, models.SourceTransfer, userID))
+				if _, err := planner.putAway(entry, products[entry.ProductID], entry.Quantity, nil); err != nil {
+					return err
+				}
+			}
+		}
+		if err := recordStockMovements(tx, order.ID, movements); err != nil {
+			return err
+		}
+
+		// Step 3️⃣: Link items to the entries they became
+		used := map[uint]int{}
+		for _, item := range order.Items {
+			batch := batches[item.SourceBatchID]
+			entry := batch.Products[used[item.SourceBatchID]]
+			used[item.SourceBatchID]++
+			if err := tx.Table(ns.TableName("TransferOrderItem")).
+				Where("id = ?", item.ID).
+				Updates(map[string]any{
+					"dest_batch_id": batch.ID,
+					"dest_entry_id": entry.ID,
+				}).Error; err != nil {
+				return fmt.Errorf("failed to update transfer item %d: %w", item.ID, err)
+			}
+		}
+
+		return tx.Table(ns.TableName("TransferOrder")).
+			Where("id = ?", order.ID).
+			Updates(map[string]any{
+				"status":      models.TransferReceived,
+				"received_by": userID,
+				"received_at": now,
+			}).Error
+	})
+	if err != nil {
+		log.Printf("❌ Transfer %d receipt failed: %v", transferID, err)
+		return nil, err
+	}
+
+	log.Printf("📦 Transfer %d received", transferID)
+	return r.GetByID(ctx, warehouseId, transferID)
+}
+
+// Cancel drops a draft transfer; dispatched stock has to be received first
+func (r *TransferRepo) Cancel(ctx context.Context, warehouseId, transferID uint) (*models.TransferOrder, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		order, err := loadTransfer(forUpdate(tx), transferID)
+		if err != nil {
+			return err
+		}
+		if order.SourceWarehouseID != warehouseId {
+			return fmt.Errorf("%w: only the source warehouse can cancel transfer %d", ErrWarehouseMismatch, transferID)
+		}
+		if order.Status != models.TransferDraft {
+			return fmt.Errorf("%w: only draft transfers can be cancelled (status=%s)", ErrInvalidTransferState, order.Status)
+		}
+		return tx.Table(ns.TableName("TransferOrder")).
+			Where("id = ?", order.ID).
+			Update("status", models.TransferCancelled).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetByID(ctx, warehouseId, transferID)
+}
+
+// GetByID returns a transfer visible to the caller's warehouse (source or destination)
+func (r *TransferRepo) GetByID(ctx context.Context, warehouseId, transferID uint) (*models.TransferOrder, error) {
+	db := dbconn.DB.WithContext(ctx)
+
+	order, err := loadTransfer(db, transferID)
+	if err != nil {
+		return nil, err
+	}
+	if order.SourceWarehouseID != warehouseId && order.DestWarehouseID != warehouseId {
+		return nil, fmt.Errorf("%w: transfer %d does not involve warehouse %d", ErrWarehouseMismatch, transferID, warehouseId)
+	}
+	return order, nil
+}
+
+// GetAll lists outgoing and incoming transfers of the warehouse, optionally by ?status=
+func (r *TransferRepo) GetAll(ctx context.Context, warehouseId uint, status string) ([]models.TransferOrder, error) {
+	db := dbconn.DB.WithContext(ctx)
+	ns := db.NamingStrategy
+
+	query := db.Table(ns.TableName("TransferOrder")).
+		Preload("Items").
+		Where("source_warehouse_id = ? OR dest_warehouse_id = ?", warehouseId, warehouseId)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var orders []models.TransferOrder
+	if err := query.Order("created_at DESC").Find(&orders).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch transfers: %w", err)
+	}
+	return orders, nil
+}
+
+func loadTransfer(db *gorm.DB, transferID uint) (*models.TransferOrder, error) {
+	ns := db.NamingStrategy
+
+	var order models.TransferOrder
+	if err := db.Table(ns.TableName("TransferOrder")).
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		First(&order, transferID).Error; err != nil {
+		return nil, fmt.Errorf("transfer not found (ID=%d): %w", transferID, err)
+	}
+	return &order, nil
+}
@@ -0,0 +1,54 @@
+package repo
+
+import (
+	"context"
+	"testing"
+	"time"
+	"warehouse/models"
+)
+
+func TestTransferCarriesRentInvoicedBeforeDispatch(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	f := seedStock(t, db, 10, 1)
+	dest := seedStock(t, db, 1, 1).warehouse
+
+	transfers := NewTransferRepo()
+	order, err := transfers.CreateTransfer(ctx, f.warehouse.ID, 1, models.TransferOrderInput{
+		DestWarehouseID: dest.ID,
+		Items:           []models.TransferItemInput{{BatchID: f.entry.BatchID, ProductID: f.product.ID, Quantity: 4}},
+	})
+	if err != nil {
+		t.Fatalf("draft: %v", err)
+	}
+
+	// Invoicing runs while the transfer is still a draft
+	today := time.Now()
+	if _, err := NewRentInvoiceRepo().RunInvoicing(ctx, f.warehouse.ID, 1, today, today); err != nil {
+		t.Fatalf("invoicing: %v", err)
+	}
+	var source models.BatchProductEntry
+	if err := db.First(&source, f.entry.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if source.RentBilledTo == nil {
+		t.Fatal("invoicing did not mark the source entry")
+	}
+
+	if _, err := transfers.Dispatch(ctx, f.warehouse.ID, order.ID, 1); err != nil {
+		t.Fatalf("dispatch: %v", err)
+	}
+	received, err := transfers.Receive(ctx, dest.ID, order.ID, 1)
+	if err != nil {
+		t.Fatalf("receive: %v", err)
+	}
+
+	// The destination entry starts billing where the invoice stopped, not at the draft
+	var moved models.BatchProductEntry
+	if err := db.First(&moved, *received.Items[0].DestEntryID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if moved.RentBilledTo == nil || !moved.RentBilledTo.Equal(*source.RentBilledTo) {
+		t.Errorf("destination rent billed to %v, want %v", moved.RentBilledTo, *source.RentBilledTo)
+	}
+}
@@ -5,8 +5,13 @@ import (
 	"fmt"
 	"log"
 	"time"
+	"warehouse/capacity"
 	dbconn "warehouse/config/dbConn"
+	"warehouse/fx"
 	"warehouse/models"
+	"warehouse/rent"
+
+	"gorm.io/gorm"
 )
 
 type WarehouseRepo struct {
@@ -26,11 +31,37 @@ func (r *WarehouseRepo) Create(ctx context.Context, warehouse *models.Warehouse)
 	ns := db.NamingStrategy
 	table := ns.TableName("Warehouse")
 
-	if err := db.Table(table).Create(&warehouse).Error; err != nil {
-		return 0, fmt.Errorf("failed to create warehouse: %w", err)
+	currency, err := documentCurrency(warehouse.ReportingCurrency)
+	if err != nil {
+		return 0, err
+	}
+	warehouse.ReportingCurrency = currency
+	if warehouse.RentConfig.Currency == "" {
+		warehouse.RentConfig.Currency = currency
+	}
+	if warehouse.CapacityUnit, err = capacity.Normalize(warehouse.CapacityUnit); err != nil {
+		return 0, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table(table).Create(&warehouse).Error; err != nil {
+			return fmt.Errorf("failed to create warehouse: %w", err)
+		}
+
+		// ✅ Start the rent history with the initial configuration
+		return addRentRateVersion(tx, warehouse.ID, 0, &models.RentRateVersion{
+			RatePerSqft:   warehouse.RentConfig.RatePerSqft,
+			Currency:      warehouse.RentConfig.Currency,
+			BillingCycle:  warehouse.RentConfig.BillingCycle,
+			MinimumCharge: warehouse.RentConfig.MinimumCharge,
+			EffectiveFrom: warehouse.CreatedAt,
+		})
+	})
+	if err != nil {
+		return 0, err
 	}
 
-	log.Printf("🏠 New warehouse created: ID=%d, Name=%s", warehouse.ID, warehouse.Name)
+	log.Printf("🏠 New warehouse created: ID=%d, Name=%s, %.2f %s", warehouse.ID, warehouse.Name, warehouse.TotalArea, warehouse.CapacityUnit)
 	return warehouse.ID, nil
 }
 
@@ -69,7 +100,6 @@ func (r *WarehouseRepo) Update(ctx context.Context, warehouse *models.Warehouse)
 	db := dbconn.DB.WithContext(ctx)
 	ns := db.NamingStrategy
 	warehouseTable := ns.TableName("Warehouse")
-	rentRateTable := ns.TableName("RentRate")
 
 	var existing models.Warehouse
 
@@ -80,22 +110,78 @@ func (r *WarehouseRepo) Update(ctx context.Context, warehouse *models.Warehouse)
 		return fmt.Errorf("warehouse not found: %w", err)
 	}
 
-	// Update warehouse (excluding RentConfig relationship)
-	if err := db.Table(warehouseTable).
-		Where("id = ?", warehouse.ID).
-		Omit("RentConfig").
-		Updates(warehouse).Error; err != nil {
-		return fmt.Errorf("failed to update warehouse: %w", err)
+	// Documents are booked at rates into the reporting currency, so it cannot move later
+	if code := fx.Code(warehouse.ReportingCurrency); code != "" && code != existing.ReportingCurrency {
+		return fmt.Errorf("%w: warehouse %d reports in %s and cannot switch to %s", fx.ErrInvalidCurrency, warehouse.ID, existing.ReportingCurrency, code)
+	}
+	warehouse.ReportingCurrency = ""
+
+	// Stored goods and rent rates are measured in the capacity unit, so it is fixed too
+	if warehouse.CapacityUnit != "" {
+		unit, err := capacity.Normalize(warehouse.CapacityUnit)
+		if err != nil {
+			return err
+		}
+		if current, _ := capacity.Normalize(existing.CapacityUnit); unit != current {
+			return fmt.Errorf("%w: warehouse %d counts space in %s and cannot switch to %s", capacity.ErrUnknownUnit, warehouse.ID, current, unit)
+		}
 	}
+	warehouse.CapacityUnit = ""
+
+	// With bins the area is derived from them, not edited by hand
+	binned, err := warehouseHasBins(db, warehouse.ID)
+	if err != nil {
+		return err
+	}
+	if binned {
+		warehouse.TotalArea, warehouse.AvailableArea = 0, 0
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Update warehouse (excluding RentConfig relationship)
+		if err := tx.Table(warehouseTable).
+			Where("id = ?", warehouse.ID).
+			Omit("RentConfig").
+			Updates(warehouse).Error; err != nil {
+			return fmt.Errorf("failed to update warehouse: %w", err)
+		}
+
+		// ✅ A rent change becomes a new version instead of repricing past days
+		current := existing.RentConfig
+		next := models.RentRateVersion{
+			RatePerSqft:   current.RatePerSqft,
+			Currency:      current.Currency,
+			BillingCycle:  current.BillingCycle,
+			MinimumCharge: current.MinimumCharge,
+		}
+		in := warehouse.RentConfig
+		if !in.RatePerSqft.IsZero() {
+			next.RatePerSqft = in.RatePerSqft
+		}
+		if in.Currency != "" {
+			next.Currency = in.Currency
+		}
+		if in.BillingCycle != "" {
+			next.BillingCycle = in.BillingCycle
+		}
+		if !in.MinimumCharge.IsZero() {
+			next.MinimumCharge = in.MinimumCharge
+		}
+		if in.EffectiveFrom != nil {
+			next.EffectiveFrom = *in.EffectiveFrom
+		}
 
-	// ✅ Update RentConfig if exists
-	if existing.RentConfig.ID != 0 {
-		warehouse.RentConfig.ID = existing.RentConfig.ID
-		if err := db.Table(rentRateTable).
-			Where("id = ?", existing.RentConfig.ID).
-			Updates(&warehouse.RentConfig).Error; err != nil {
-			return fmt.Errorf("failed to update rent config: %w", err)
+		changed := !next.RatePerSqft.Equal(current.RatePerSqft) ||
+			next.Currency != current.Currency ||
+			rent.NormalizeCycle(next.BillingCycle) != rent.NormalizeCycle(current.BillingCycle) ||
+			!next.MinimumCharge.Equal(current.MinimumCharge)
+		if !changed {
+			return nil
 		}
+		return addRentRateVersion(tx, warehouse.ID, 0, &next)
+	})
+	if err != nil {
+		return err
 	}
 
 	log.Printf("✅ Warehouse updated: ID=%d", warehouse.ID)
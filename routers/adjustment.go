@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"warehouse/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func StockAdjustmentRoutes(r *gin.RouterGroup) {
+	a := r.Group("/stock-adjustments")
+	{
+		a.POST("/", handlers.CreateStockAdjustmentHandler)
+		a.GET("/", handlers.GetAllStockAdjustmentsHandler)
+		a.GET("/:id", handlers.GetStockAdjustmentHandler)
+		a.PUT("/:id/counts", handlers.RecordStockCountsHandler)
+		a.POST("/:id/submit", handlers.SubmitStockAdjustmentHandler)
+		a.POST("/:id/post", handlers.PostStockAdjustmentHandler)
+	}
+}
+
+// StockAdjustmentApprovalRoutes holds the admin-only approval steps
+func StockAdjustmentApprovalRoutes(r *gin.RouterGroup) {
+	a := r.Group("/stock-adjustments")
+	{
+		a.POST("/:id/approve", handlers.ApproveStockAdjustmentHandler)
+		a.POST("/:id/reject", handlers.RejectStockAdjustmentHandler)
+	}
+}
@@ -11,6 +11,9 @@ func AnalyticsRoutes(r *gin.RouterGroup) {
 	{
 		a.GET("/:duration", handlers.GetAnalyticsHandler)
 		a.GET("/fast-moving", handlers.GetFastAndSlowMovingProductAnalytics)
+		a.GET("/ar-ageing", handlers.GetARAgeingHandler)
+		a.GET("/tax-summary", handlers.GetTaxSummaryHandler)
+		a.GET("/fx-gain-loss", handlers.GetRealisedFXHandler)
 		a.GET("/product/:product_id", handlers.GetProductAnalyticsByIdHandler)
 	}
 }
@@ -2,6 +2,7 @@ package routes
 
 import (
 	"warehouse/handlers"
+	"warehouse/middleware"
 
 	"github.com/gin-gonic/gin"
 )
@@ -10,10 +11,11 @@ import (
 func RegisterBatchRoutes(r *gin.RouterGroup) {
 	b := r.Group("/batches")
 	{
-		b.POST("/", handlers.CreateBatchHandler)
+		b.POST("/", middleware.Idempotency(), handlers.CreateBatchHandler)
+		b.POST("/putaway-suggestions", handlers.SuggestBatchPutawayHandler)
 		b.GET("/", handlers.GetAllBatchesHandler)
 		b.GET("/:id", handlers.GetBatchByIDHandler)
 		b.GET("/product/:id", handlers.GetBatchesByProductIDHandler)
-		
+		b.POST("/import", middleware.Idempotency(), handlers.ImportBatchesFromFile)
 	}
 }
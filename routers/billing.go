@@ -2,6 +2,7 @@ package routes
 
 import (
 	"warehouse/handlers"
+	"warehouse/middleware"
 
 	"github.com/gin-gonic/gin"
 )
@@ -9,10 +10,14 @@ import (
 func BillingRoutes(r *gin.RouterGroup) {
 	b := r.Group("/billing")
 	{
-		b.POST("/generate/batch", handlers.CreateBillingWithBatchId)
-		b.POST("/generate", handlers.CreateBillingWithOutBatchId)
+		b.POST("/generate/batch", middleware.Idempotency(), handlers.CreateBillingWithBatchId)
+		b.POST("/generate", middleware.Idempotency(), handlers.CreateBillingWithOutBatchId)
+		b.POST("/preview", handlers.PreviewBillingHandler)
 		b.GET("/", handlers.GetAllBillsHandler)
 		b.GET("/:id", handlers.GetBillByIDHandler)
+		b.GET("/:id/pdf", handlers.GetBillPDFHandler)
+		b.POST("/:id/reverse", handlers.ReverseBillingHandler)
+		b.GET("/:id/credit-notes", handlers.GetCreditNotesByBillIDHandler)
 		b.GET("/product", handlers.GetAllProductsForBilling)
 	}
 }
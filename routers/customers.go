@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"warehouse/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func CustomerRoutes(r *gin.RouterGroup) {
+	p := r.Group("/customers")
+	{
+		p.POST("/", handlers.CreateCustomer)
+		p.GET("/", handlers.GetAllCustomers)
+		p.GET("/:id", handlers.GetCustomer)
+		p.PUT("/:id", handlers.UpdateCustomer)
+		p.DELETE("/:id", handlers.DeleteCustomer)
+		p.GET("/:id/statement", handlers.GetCustomerStatement)
+	}
+}
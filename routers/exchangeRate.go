@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"warehouse/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ExchangeRateRoutes(r *gin.RouterGroup) {
+	x := r.Group("/exchange-rates")
+	{
+		x.POST("/", handlers.CreateExchangeRate)
+		x.POST("/import", handlers.ImportExchangeRates)
+		x.GET("/", handlers.GetAllExchangeRates)
+		x.DELETE("/:id", handlers.DeleteExchangeRate)
+	}
+}
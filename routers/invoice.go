@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"warehouse/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func InvoiceTemplateRoutes(r *gin.RouterGroup) {
+	w := r.Group("/warehouses")
+	{
+		w.GET("/:id/invoice-template", handlers.GetInvoiceTemplateHandler)
+		w.PUT("/:id/invoice-template", handlers.UpdateInvoiceTemplateHandler)
+		w.GET("/:id/invoice-series", handlers.GetInvoiceSeriesHandler)
+		w.PUT("/:id/invoice-series", handlers.UpdateInvoiceSeriesHandler)
+	}
+}
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"warehouse/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func LocationRoutes(r *gin.RouterGroup) {
+	l := r.Group("/locations")
+	{
+		l.GET("/", handlers.GetAllLocationsHandler)
+		l.GET("/occupancy", handlers.GetLocationOccupancyHandler)
+		l.POST("/move", handlers.MoveLocationStockHandler)
+	}
+}
+
+// LocationLayoutRoutes holds the admin-only changes to the location hierarchy
+func LocationLayoutRoutes(r *gin.RouterGroup) {
+	l := r.Group("/locations")
+	{
+		l.POST("/", handlers.CreateLocationHandler)
+		l.PUT("/:id", handlers.UpdateLocationHandler)
+		l.DELETE("/:id", handlers.DeleteLocationHandler)
+	}
+}
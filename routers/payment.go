@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"warehouse/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func PaymentRoutes(r *gin.RouterGroup) {
+	p := r.Group("/payments")
+	{
+		p.POST("/", handlers.RecordPaymentHandler)
+		p.GET("/", handlers.GetAllPaymentsHandler)
+		p.GET("/:id", handlers.GetPaymentHandler)
+	}
+}
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"warehouse/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func PurchaseOrderRoutes(r *gin.RouterGroup) {
+	p := r.Group("/purchase-orders")
+	{
+		p.POST("/", handlers.CreatePurchaseOrderHandler)
+		p.GET("/", handlers.GetAllPurchaseOrdersHandler)
+		p.GET("/open", handlers.GetOpenPurchaseOrdersHandler)
+		p.GET("/:id", handlers.GetPurchaseOrderHandler)
+		p.POST("/:id/receipts", handlers.ReceiveGoodsHandler)
+		p.GET("/:id/receipts", handlers.GetGoodsReceiptsHandler)
+		p.POST("/:id/close", handlers.ClosePurchaseOrderHandler)
+	}
+}
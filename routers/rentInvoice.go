@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"warehouse/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RentInvoiceRoutes(r *gin.RouterGroup) {
+	ri := r.Group("/rent-invoices")
+	{
+		ri.POST("/run", handlers.RunRentInvoicingHandler)
+		ri.GET("/", handlers.GetAllRentInvoicesHandler)
+		ri.GET("/:id", handlers.GetRentInvoiceHandler)
+	}
+}
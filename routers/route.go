@@ -7,7 +7,6 @@ import (
 )
 
 func SetupRoutes(r *gin.Engine) {
-
 	// gzip
 	r.Use(middleware.GzipMiddleware())
 
@@ -36,7 +35,13 @@ func registerEmployeeAndAdminRoutes(r *gin.RouterGroup) {
 	BillingRoutes(group)
 	RegisterBatchRoutes(group)
 	SupplierRoutes(group)
+	CustomerRoutes(group)
+	PaymentRoutes(group)
 	RegisterStockRoutes(group)
+	StockAdjustmentRoutes(group)
+	TransferRoutes(group)
+	PurchaseOrderRoutes(group)
+	LocationRoutes(group)
 }
 
 // admin related routes
@@ -46,4 +51,10 @@ func registerAdminRoutes(r *gin.RouterGroup) {
 
 	AnalyticsRoutes(admin)
 	AdminRoutes(admin)
+	StockAdjustmentApprovalRoutes(admin)
+	RentInvoiceRoutes(admin)
+	InvoiceTemplateRoutes(admin)
+	TaxRateRoutes(admin)
+	ExchangeRateRoutes(admin)
+	LocationLayoutRoutes(admin)
 }
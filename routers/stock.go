@@ -10,5 +10,8 @@ func RegisterStockRoutes(r *gin.RouterGroup) {
 	s := r.Group("/stock")
 	s.GET("/", handlers.GetAllProductStockDatasHandler)
 	s.GET("/products", handlers.GetAllProductStockHandler)
+	s.GET("/consistency", handlers.CheckStockConsistencyHandler)
+	s.GET("/expiring", handlers.GetExpiringStockHandler)
 	s.GET("/:product_id", handlers.SearchStockProductData)
+	s.GET("/:product_id/movements", handlers.GetStockMovementsHandler)
 }
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"warehouse/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TaxRateRoutes(r *gin.RouterGroup) {
+	t := r.Group("/tax-rates")
+	{
+		t.POST("/", handlers.CreateTaxRate)
+		t.GET("/", handlers.GetAllTaxRates)
+		t.GET("/:id", handlers.GetTaxRate)
+		t.PUT("/:id", handlers.UpdateTaxRate)
+		t.DELETE("/:id", handlers.DeleteTaxRate)
+	}
+}
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"warehouse/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TransferRoutes(r *gin.RouterGroup) {
+	t := r.Group("/transfers")
+	{
+		t.POST("/", handlers.CreateTransferHandler)
+		t.GET("/", handlers.GetAllTransfersHandler)
+		t.GET("/:id", handlers.GetTransferHandler)
+		t.POST("/:id/dispatch", handlers.DispatchTransferHandler)
+		t.POST("/:id/receive", handlers.ReceiveTransferHandler)
+		t.POST("/:id/cancel", handlers.CancelTransferHandler)
+	}
+}
@@ -14,5 +14,7 @@ func WarehouseRoutes(r *gin.RouterGroup) {
 		w.GET("/:id", handlers.GetWarehouse)
 		w.PUT("/:id", handlers.UpdateWarehouse)
 		w.DELETE("/:id", handlers.DeleteWarehouse)
+		w.GET("/:id/rent-rates", handlers.GetRentRatesHandler)
+		w.POST("/:id/rent-rates", handlers.AddRentRateHandler)
 	}
 }
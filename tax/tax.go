@@ -0,0 +1,144 @@
+// Package tax computes Indian GST on a sale line. Intra-state supplies split the rate
+// equally into CGST and SGST; inter-state supplies carry the whole rate as IGST.
+package tax
+
+import (
+	"strings"
+	"warehouse/money"
+
+	"github.com/shopspring/decimal"
+)
+
+// Supply types
+const (
+	IntraState = "intra_state"
+	InterState = "inter_state"
+)
+
+// Breakdown is the tax on one amount. Taxable + Tax = Total, Tax = CGST + SGST + IGST.
+type Breakdown struct {
+	Rate    float64         `json:"rate"` // percent
+	Taxable decimal.Decimal `json:"taxable"`
+	CGST    decimal.Decimal `json:"cgst"`
+	SGST    decimal.Decimal `json:"sgst"`
+	IGST    decimal.Decimal `json:"igst"`
+	Tax     decimal.Decimal `json:"tax"`
+	Total   decimal.Decimal `json:"total"`
+}
+
+// SupplyType decides intra- or inter-state supply from the warehouse state (place of
+// dispatch) and the customer state (place of supply). An unknown customer state is
+// treated as a local sale.
+func SupplyType(warehouseState, customerState string) string {
+	w := strings.ToLower(strings.TrimSpace(warehouseState))
+	c := strings.ToLower(strings.TrimSpace(customerState))
+	if w == "" || c == "" || w == c {
+		return IntraState
+	}
+	return InterState
+}
+
+// Compute taxes amount at rate percent. With inclusive pricing amount already contains
+// the tax and is split back into taxable value and tax; otherwise tax is added on top.
+// Every component is rounded to paise.
+func Compute(rate float64, amount decimal.Decimal, inclusive bool, supplyType string) Breakdown {
+	b := Breakdown{Rate: rate}
+	if inclusive {
+		b.Taxable = money.Round(amount.Div(percent(rate).Add(decimal.NewFromInt(1))))
+		b.Tax = money.Round(amount.Sub(b.Taxable))
+	} else {
+		b.Taxable = money.Round(amount)
+		b.Tax = money.Round(b.Taxable.Mul(percent(rate)))
+	}
+	b.split(supplyType, money.Round(b.Tax.Div(two)))
+	return b
+}
+
+// Line is one amount of a document to be taxed at Rate percent
+type Line struct {
+	Rate   float64
+	Amount decimal.Decimal
+}
+
+// Document taxes the lines of one document under a money rounding rule. Per line every
+// line is computed on its own, as Compute does. Per document the exact taxable values
+// and taxes are rounded as totals and spread back over the lines (see
+// money.RoundLines). Either way the document totals are the sums of the returned lines.
+func Document(lines []Line, inclusive bool, supplyType, rounding string) []Breakdown {
+	out := make([]Breakdown, len(lines))
+	if rounding != money.PerDocument {
+		for i, l := range lines {
+			out[i] = Compute(l.Rate, l.Amount, inclusive, supplyType)
+		}
+		return out
+	}
+
+	amounts := make([]decimal.Decimal, len(lines))
+	taxes := make([]decimal.Decimal, len(lines))
+	for i, l := range lines {
+		amounts[i] = l.Amount
+		if inclusive {
+			taxes[i] = l.Amount.Sub(l.Amount.Div(percent(l.Rate).Add(decimal.NewFromInt(1))))
+		} else {
+			taxes[i] = l.Amount.Mul(percent(l.Rate))
+		}
+	}
+	halves := make([]decimal.Decimal, len(lines))
+	for i, t := range taxes {
+		halves[i] = t.Div(two)
+	}
+
+	amounts = money.RoundLines(amounts, money.PerDocument)
+	taxes = money.RoundLines(taxes, money.PerDocument)
+	halves = money.RoundLines(halves, money.PerDocument)
+	for i, l := range lines {
+		b := Breakdown{Rate: l.Rate, Tax: taxes[i]}
+		if inclusive {
+			b.Taxable = amounts[i].Sub(b.Tax)
+		} else {
+			b.Taxable = amounts[i]
+		}
+		b.split(supplyType, halves[i])
+		out[i] = b
+	}
+	return out
+}
+
+// split divides Tax into IGST, or into CGST and SGST with SGST absorbing the rounding,
+// and sets Total
+func (b *Breakdown) split(supplyType string, cgst decimal.Decimal) {
+	if supplyType == InterState {
+		b.IGST = b.Tax
+	} else {
+		b.CGST = cgst
+		b.SGST = b.Tax.Sub(b.CGST)
+	}
+	b.Total = b.Taxable.Add(b.Tax)
+}
+
+// Scale returns the share qty/of of b, e.g. for a partially returned line. SGST and
+// IGST absorb the rounding so the parts still add up.
+func (b Breakdown) Scale(qty, of int) Breakdown {
+	if of <= 0 {
+		return Breakdown{Rate: b.Rate}
+	}
+	f := func(d decimal.Decimal) decimal.Decimal {
+		return money.Round(d.Mul(money.Qty(qty)).Div(money.Qty(of)))
+	}
+	s := Breakdown{Rate: b.Rate, Taxable: f(b.Taxable), Tax: f(b.Tax)}
+	if !b.IGST.IsZero() {
+		s.IGST = s.Tax
+	} else {
+		s.CGST = f(b.CGST)
+		s.SGST = s.Tax.Sub(s.CGST)
+	}
+	s.Total = s.Taxable.Add(s.Tax)
+	return s
+}
+
+var two = decimal.NewFromInt(2)
+
+// percent is rate percent as a fraction
+func percent(rate float64) decimal.Decimal {
+	return decimal.NewFromFloat(rate).Div(decimal.NewFromInt(100))
+}